@@ -0,0 +1,259 @@
+package main
+
+import (
+	"time"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// availabilityPreset is a choice of how long a menu marked sold out stays
+// so; Label is its catalogue key
+type availabilityPreset struct {
+	Key   string
+	Label string
+}
+
+var availabilityPresets = []availabilityPreset{
+	{"manual", "availability.preset_manual"},
+	{"1h", "availability.preset_1h"},
+	{"3h", "availability.preset_3h"},
+	{"tomorrow", "availability.preset_tomorrow"},
+}
+
+// Opening hour the "tomorrow" preset brings sold out menus back at
+const availabilityMorningHour = 7
+
+// The "sold out until" preset each admin picked on the availability board
+var availabilityUntil = make(map[int64]string)
+
+// availableAgainAt resolves a preset to the time a sold out menu comes back,
+// or nil when it stays sold out until switched back by hand
+func availableAgainAt(preset string, now time.Time) *time.Time {
+	var t time.Time
+	switch preset {
+	case "1h":
+		t = now.Add(time.Hour)
+	case "3h":
+		t = now.Add(3 * time.Hour)
+	case "tomorrow":
+		t = time.Date(now.Year(), now.Month(), now.Day()+1, availabilityMorningHour, 0, 0, 0, now.Location())
+	default:
+		return nil
+	}
+	return &t
+}
+
+func availabilityPresetLabel(preset string) string {
+	for _, p := range availabilityPresets {
+		if p.Key == preset {
+			return t(p.Label)
+		}
+	}
+	return t(availabilityPresets[0].Label)
+}
+
+// formatAgainAt shows a restore time as a clock time, with the date when it
+// is not today
+func formatAgainAt(raw string) string {
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return raw
+	}
+	t = t.Local()
+	now := time.Now()
+	if t.YearDay() == now.YearDay() && t.Year() == now.Year() {
+		return t.Format("15:04")
+	}
+	return t.Format("02/01 15:04")
+}
+
+// branchSoldOut is what is sold out in a branch: the menus switched off
+// by hand, and the number of menus out of stock
+type branchSoldOut struct {
+	manual     []int
+	outOfStock int
+}
+
+// fetchSoldOut reads what is sold out in a branch, or reports the failure
+// and returns false
+func fetchSoldOut(chatID int64, branchID int) (branchSoldOut, bool) {
+	var soldOut branchSoldOut
+	resp, err := cachedPost(menuServiceURL, shared.Request{
+		Action: "list",
+		Payload: map[string]interface{}{
+			"branch_id": branchID,
+		},
+	})
+	if err != nil || !resp.Success {
+		sendMessage(chatID, tm("menu.load_failed"), nil)
+		return soldOut, false
+	}
+
+	menusData, _ := resp.Data.(map[string]interface{})["menus"].([]interface{})
+	for _, item := range menusData {
+		menu := item.(map[string]interface{})
+		if outOfStock, _ := menu["out_of_stock"].(bool); outOfStock {
+			soldOut.outOfStock++
+		} else if !menu["is_available"].(bool) {
+			soldOut.manual = append(soldOut.manual, int(menu["id"].(float64)))
+		}
+	}
+	return soldOut, true
+}
+
+// showAvailabilityBoard lists a page of the menus of a branch as toggles.
+// When messageID is set the existing board is edited in place, so baristas
+// can tap through items without the chat filling up.
+func showAvailabilityBoard(chatID int64, messageID int, userID int64, branchID int, page int) {
+	resp, err := cachedPost(menuServiceURL, shared.Request{
+		Action: "list",
+		Payload: pagePayload(map[string]interface{}{
+			"branch_id": branchID,
+		}, page),
+	})
+
+	if err != nil || !resp.Success {
+		sendMessage(chatID, tm("menu.load_failed"), nil)
+		return
+	}
+	soldOut, ok := fetchSoldOut(chatID, branchID)
+	if !ok {
+		return
+	}
+
+	preset := availabilityUntil[userID]
+	data, _ := resp.Data.(map[string]interface{})
+	menusData, _ := data["menus"].([]interface{})
+	total := listTotal(data, menusData)
+
+	text := tm("availability.title", branchName(branchID)) + "\n\n"
+	text += tm("availability.hint") + "\n"
+	text += tm("availability.until", availabilityPresetLabel(preset)) + "\n"
+
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+
+	for _, item := range menusData {
+		menu := item.(map[string]interface{})
+		id := int(menu["id"].(float64))
+		label := "✅ " + menu["name"].(string)
+		target := 0
+
+		// Menus out of stock come back by restocking, not by a toggle
+		if outOfStock, _ := menu["out_of_stock"].(bool); outOfStock {
+			keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+				button(t("availability.out_of_stock", menu["name"].(string)), "admin_inventory"),
+			))
+			continue
+		}
+
+		if available := menu["is_available"].(bool); !available {
+			label = "❌ " + menu["name"].(string)
+			if againAt, ok := menu["available_again_at"].(string); ok {
+				label = t("availability.sold_out_until", label, formatAgainAt(againAt))
+			}
+			target = 1
+		}
+
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			button(label, "avail_toggle", id, target, page),
+		))
+	}
+
+	if total == 0 {
+		text += "\n" + tm("admin.menu_list_empty") + "\n"
+	} else {
+		text += "\n" + tm("availability.summary", len(soldOut.manual)+soldOut.outOfStock, total)
+	}
+	footer, pageRow := pageFooter(page, total, "avail_refresh")
+	text += footer
+	if pageRow != nil {
+		keyboard = append(keyboard, pageRow)
+	}
+
+	var presetRow []tgbotapi.InlineKeyboardButton
+	for _, p := range availabilityPresets {
+		label := t(p.Label)
+		if p.Key == preset || (preset == "" && p.Key == "manual") {
+			label = "• " + label
+		}
+		presetRow = append(presetRow, button(label, "avail_until", p.Key, page))
+	}
+	keyboard = append(keyboard, presetRow)
+
+	// Only menus switched off by hand can be switched back on at once
+	if len(soldOut.manual) > 0 {
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			button(t("availability.btn_all_available"), "avail_all", page),
+		))
+	}
+	keyboard = append(keyboard,
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("availability.btn_refresh"), "avail_refresh", page),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("admin.btn_back_panel"), "back", "admin"),
+		),
+	)
+
+	markup := tgbotapi.NewInlineKeyboardMarkup(keyboard...)
+	if messageID != 0 {
+		editMessage(chatID, messageID, text, markup)
+		return
+	}
+	sendMessage(chatID, text, markup)
+}
+
+// toggleAvailability marks a single menu sold out or available in a branch,
+// using the admin's "habis sampai" preset for sold out menus
+func toggleAvailability(chatID int64, messageID int, userID int64, branchID int, menuID int, available bool, page int) {
+	payload := map[string]interface{}{
+		"menu_id":      menuID,
+		"branch_id":    branchID,
+		"is_available": available,
+	}
+	if !available {
+		if againAt := availableAgainAt(availabilityUntil[userID], time.Now()); againAt != nil {
+			payload["available_again_at"] = againAt.Format(time.RFC3339)
+		}
+	}
+
+	if setAvailability(chatID, payload) {
+		showAvailabilityBoard(chatID, messageID, userID, branchID, page)
+	}
+}
+
+// markAllAvailable switches every menu of a branch sold out by hand back on
+// at once. Menus out of stock are left to restocking.
+func markAllAvailable(chatID int64, messageID int, userID int64, branchID int, page int) {
+	soldOut, ok := fetchSoldOut(chatID, branchID)
+	if !ok {
+		return
+	}
+
+	if len(soldOut.manual) > 0 && !setAvailability(chatID, map[string]interface{}{
+		"menu_ids":     soldOut.manual,
+		"branch_id":    branchID,
+		"is_available": true,
+	}) {
+		return
+	}
+	showAvailabilityBoard(chatID, messageID, userID, branchID, page)
+}
+
+func setAvailability(chatID int64, payload map[string]interface{}) bool {
+	resp, err := cachedPost(menuServiceURL, shared.Request{
+		Action:  "set_availability",
+		Payload: payload,
+	})
+
+	if err != nil || !resp.Success {
+		errMsg := tm("availability.update_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		sendMessage(chatID, errMsg, nil)
+		return false
+	}
+	return true
+}
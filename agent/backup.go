@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/base64"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// sendBackup creates a fresh backup of every service database and sends the
+// archive to the chat. The archive holds all café data, so callers must
+// check isOwner first.
+func sendBackup(chatID int64) {
+	sendMessage(chatID, tm("backup.creating"), nil)
+
+	resp, err := httpClient.Post(backupServiceURL, shared.Request{Action: "create"})
+	if err != nil || !resp.Success {
+		errMsg := tm("backup.create_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		sendMessage(chatID, errMsg, nil)
+		return
+	}
+	backup := resp.Data.(map[string]interface{})["backup"].(map[string]interface{})
+	name := backup["name"].(string)
+
+	resp, err = httpClient.Post(backupServiceURL, shared.Request{
+		Action:  "download",
+		Payload: map[string]interface{}{"name": name},
+	})
+	if err != nil || !resp.Success {
+		errMsg := tm("backup.send_failed", name)
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		sendMessage(chatID, errMsg, nil)
+		return
+	}
+
+	content, err := base64.StdEncoding.DecodeString(resp.Data.(map[string]interface{})["content"].(string))
+	if err != nil {
+		sendMessage(chatID, tm("backup.file_invalid"), nil)
+		return
+	}
+
+	files, _ := backup["files"].([]interface{})
+	sendDocument(chatID, tgbotapi.FileBytes{Name: name, Bytes: content},
+		tm("backup.caption", len(files), backup["checksum"].(string)))
+}
@@ -0,0 +1,524 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// defaultBranchID is used until a user picks a branch
+const defaultBranchID = 1
+
+// deletedBranches passes the branches deleted in info-service from /events
+// to the update loop, which owns userBranches
+var deletedBranches = make(chan int, 16)
+
+// BRANCH FUNCTIONS
+
+func fetchBranches() ([]interface{}, error) {
+	resp, err := cachedPost(infoServiceURL, shared.Request{
+		Action: "list",
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("failed to list branches")
+	}
+
+	branches, _ := resp.Data.(map[string]interface{})["branches"].([]interface{})
+	return branches, nil
+}
+
+// getUserBranch returns the branch picked by a user, loading it from
+// info-service the first time
+func getUserBranch(userID int64) int {
+	if branchID, ok := userBranches[userID]; ok {
+		return branchID
+	}
+
+	loadUserPreference(userID)
+	return userBranches[userID]
+}
+
+// forgetBranch forgets a deleted branch for the users who picked it, so
+// their branch is loaded again from info-service, which moved them to the
+// default branch
+func forgetBranch(branchID int) {
+	for userID, picked := range userBranches {
+		if picked == branchID {
+			delete(userBranches, userID)
+		}
+	}
+}
+
+func branchName(branchID int) string {
+	branches, err := fetchBranches()
+	if err != nil {
+		return t("branch.name_fallback", branchID)
+	}
+	for _, item := range branches {
+		branch := item.(map[string]interface{})
+		if int(branch["id"].(float64)) == branchID {
+			return branch["name"].(string)
+		}
+	}
+	return t("branch.name_fallback", branchID)
+}
+
+func showBranchPicker(chatID int64, userID int64) {
+	branches, err := fetchBranches()
+	if err != nil {
+		sendMessage(chatID, tm("branch.load_failed"), nil)
+		return
+	}
+
+	current := getUserBranch(userID)
+	text := tm("branch.pick_title") + "\n\n"
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+
+	for _, item := range branches {
+		branch := item.(map[string]interface{})
+		id := int(branch["id"].(float64))
+		name := branch["name"].(string)
+		address := branch["address"].(string)
+
+		marker := "▫️"
+		if id == current {
+			marker = "✅"
+		}
+
+		text += md("%s *%s*\n   📍 %s\n\n", marker, name, address)
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			button(marker+" "+name, "set_branch", id),
+		))
+	}
+
+	keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+		button(t("common.btn_home"), "back", "start"),
+	))
+
+	sendMessage(chatID, text, tgbotapi.NewInlineKeyboardMarkup(keyboard...))
+}
+
+func setUserBranch(chatID int64, userID int64, branchID int) {
+	resp, err := cachedPost(infoServiceURL, shared.Request{
+		Action: "set_preference",
+		Payload: map[string]interface{}{
+			"telegram_id": strconv.FormatInt(userID, 10),
+			"branch_id":   branchID,
+		},
+	})
+
+	if err != nil || !resp.Success {
+		errMsg := tm("branch.set_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		sendMessage(chatID, errMsg, nil)
+		return
+	}
+
+	userBranches[userID] = branchID
+	sendMessage(chatID, tm("branch.active", branchName(branchID)), nil)
+	showUserMenu(chatID)
+}
+
+// requestUserLocation asks the user to share their location through a
+// one-time reply keyboard
+func requestUserLocation(chatID int64) {
+	keyboard := tgbotapi.NewOneTimeReplyKeyboard(
+		tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButtonLocation(t("branch.btn_send_location")),
+		),
+	)
+	keyboard.ResizeKeyboard = true
+
+	sendMessage(chatID, tm("branch.location_prompt"), keyboard)
+}
+
+func showNearestBranches(chatID int64, userID int64, latitude, longitude float64) {
+	resp, err := cachedPost(infoServiceURL, shared.Request{
+		Action: "nearest",
+		Payload: map[string]interface{}{
+			"latitude":  latitude,
+			"longitude": longitude,
+			"limit":     5,
+		},
+	})
+
+	if err != nil || !resp.Success {
+		sendMessage(chatID, tm("branch.nearest_failed"), tgbotapi.NewRemoveKeyboard(true))
+		return
+	}
+
+	branches, _ := resp.Data.(map[string]interface{})["branches"].([]interface{})
+	if len(branches) == 0 {
+		sendMessage(chatID, tm("branch.nearest_none"), tgbotapi.NewRemoveKeyboard(true))
+		return
+	}
+
+	// Drop the location reply keyboard before showing the inline one
+	sendMessage(chatID, tm("branch.location_received"), tgbotapi.NewRemoveKeyboard(true))
+
+	text := tm("branch.nearest_title") + "\n\n"
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+
+	for _, item := range branches {
+		branch := item.(map[string]interface{})
+		id := int(branch["id"].(float64))
+		name := branch["name"].(string)
+		distance := branch["distance_km"].(float64)
+		isOpen, _ := branch["is_open"].(bool)
+
+		status := t("info.closed")
+		if isOpen {
+			status = t("info.open")
+		}
+
+		text += md("*%s* — %s\n", name, formatDistance(distance))
+		text += md("   📍 %s\n", branch["address"].(string))
+		text += md("   🕐 %s - %s (%s)\n\n", branch["opening_hour"].(string), branch["closing_hour"].(string), status)
+
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			button(t("branch.btn_pick", name), "set_branch", id),
+		))
+	}
+
+	keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+		button(t("common.btn_home"), "back", "start"),
+	))
+
+	sendMessage(chatID, text, tgbotapi.NewInlineKeyboardMarkup(keyboard...))
+}
+
+func formatDistance(km float64) string {
+	if km < 1 {
+		return fmt.Sprintf("%d m", int(km*1000))
+	}
+	return fmt.Sprintf("%.1f km", km)
+}
+
+// ADMIN BRANCH FUNCTIONS
+
+func showAdminBranchManagement(chatID int64, userID int64, admin adminRecord) {
+	scope := admin.scope
+	branchID := admin.branch(userID)
+
+	text := tm("branch.admin_title") + "\n\n"
+	text += tm("branch.admin_active", branchName(branchID)) + "\n\n"
+	text += tm("admin.choose_operation") + "\n\n"
+
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	if scope == 0 {
+		keyboard = append(keyboard,
+			tgbotapi.NewInlineKeyboardRow(
+				button(t("branch.btn_switch"), "branch_pick"),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				button(t("branch.btn_create"), "branch_create"),
+			),
+		)
+	}
+	keyboard = append(keyboard,
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("branch.btn_list"), "branch_read_all"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("branch.btn_menu"), "branch_menu_list"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("admin.btn_back_panel"), "back", "admin"),
+		),
+	)
+
+	sendMessage(chatID, text, tgbotapi.NewInlineKeyboardMarkup(keyboard...))
+}
+
+func showBranchList(chatID int64) {
+	branches, err := fetchBranches()
+	if err != nil {
+		sendMessage(chatID, tm("branch.load_failed"), nil)
+		return
+	}
+
+	text := tm("branch.list_title") + "\n\n"
+	for _, item := range branches {
+		branch := item.(map[string]interface{})
+		text += md("*%s* (#%d)\n", branch["name"].(string), int(branch["id"].(float64)))
+		text += md("   📍 %s\n", branch["address"].(string))
+		text += md("   📞 %s\n", branch["phone"].(string))
+		text += md("   🕐 %s - %s\n\n", branch["opening_hour"].(string), branch["closing_hour"].(string))
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			backButton("admin_branch"),
+		),
+	)
+
+	sendMessage(chatID, text, keyboard)
+}
+
+// startAddBranchDialog asks for a new branch; admin is the record of the
+// central admin adding it
+func startAddBranchDialog(chatID int64, userID int64, admin adminRecord) {
+	startWizard(chatID, userID, "add_branch", map[string]interface{}{"admin": admin})
+}
+
+// branchHours reads opening hours typed as "08:00-22:00"
+func branchHours(msg *tgbotapi.Message, _ map[string]interface{}) (interface{}, markup) {
+	hours := strings.Split(strings.TrimSpace(msg.Text), "-")
+	if len(hours) != 2 {
+		return nil, tm("branch.hours_invalid")
+	}
+	return []string{strings.TrimSpace(hours[0]), strings.TrimSpace(hours[1])}, ""
+}
+
+func finishAddBranch(chatID int64, userID int64, data map[string]interface{}) {
+	hours := data["hours"].([]string)
+	resp, err := cachedPost(infoServiceURL, shared.Request{
+		Action: "create",
+		Payload: map[string]interface{}{
+			"name":         data["name"],
+			"address":      data["address"],
+			"phone":        data["phone"],
+			"opening_hour": hours[0],
+			"closing_hour": hours[1],
+		},
+	})
+
+	if err != nil || !resp.Success {
+		errMsg := tm("branch.create_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		sendMessage(chatID, errMsg, nil)
+		return
+	}
+
+	infoData := resp.Data.(map[string]interface{})["info"].(map[string]interface{})
+	sendMessage(chatID, tm("branch.created", infoData["name"].(string)), nil)
+	showAdminBranchManagement(chatID, userID, data["admin"].(adminRecord))
+}
+
+// showBranchMenuList lists menus with the price and availability that apply
+// in the admin's active branch
+func showBranchMenuList(chatID int64, branchID int) {
+	resp, err := cachedPost(menuServiceURL, shared.Request{
+		Action: "list",
+		Payload: map[string]interface{}{
+			"branch_id": branchID,
+		},
+	})
+
+	if err != nil || !resp.Success {
+		sendMessage(chatID, tm("menu.load_failed"), nil)
+		return
+	}
+
+	menusData, ok := resp.Data.(map[string]interface{})["menus"].([]interface{})
+	text := tm("branch.menu_title", branchName(branchID)) + "\n\n"
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+
+	if ok && len(menusData) > 0 {
+		for _, item := range menusData {
+			menu := item.(map[string]interface{})
+			name := menu["name"].(string)
+			menuPrice := money(menu["price"])
+			id := int(menu["id"].(float64))
+			available := menu["is_available"].(bool)
+			overridden, _ := menu["has_branch_override"].(bool)
+
+			status := "✅"
+			if !available {
+				status = "❌"
+			}
+			marker := ""
+			if overridden {
+				marker = " ✳️"
+			}
+
+			text += md("%s *%s*%s\n   💰 %s\n\n", status, name, marker, price(menuPrice))
+			keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+				button(status+" "+name, "branch_menu", id),
+			))
+		}
+		text += tm("branch.override_legend")
+	} else {
+		text += tm("admin.menu_list_empty") + "\n"
+	}
+
+	keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+		backButton("admin_branch"),
+	))
+
+	sendMessage(chatID, text, tgbotapi.NewInlineKeyboardMarkup(keyboard...))
+}
+
+func showBranchMenuDetail(chatID int64, branchID int, menuID int) {
+	resp, err := cachedPost(menuServiceURL, shared.Request{
+		Action: "read",
+		Payload: map[string]interface{}{
+			"id":        menuID,
+			"branch_id": branchID,
+		},
+	})
+
+	if err != nil || !resp.Success {
+		sendMessage(chatID, tm("menu.not_found"), nil)
+		return
+	}
+
+	menuData := resp.Data.(map[string]interface{})["menu"].(map[string]interface{})
+	name := menuData["name"].(string)
+	menuPrice := money(menuData["price"])
+	available := menuData["is_available"].(bool)
+	overridden, _ := menuData["has_branch_override"].(bool)
+
+	status := t("branch.status_available")
+	toggleLabel := t("branch.btn_mark_sold_out")
+	toggleValue := 0
+	if !available {
+		status = t("branch.status_sold_out")
+		toggleLabel = t("branch.btn_mark_available")
+		toggleValue = 1
+	}
+
+	text := md("🍽️ *%s*\n🏪 %s\n\n", name, branchName(branchID))
+	text += tm("branch.detail_price", price(menuPrice)) + "\n"
+	text += tm("branch.detail_status", status) + "\n"
+	if againAt, ok := menuData["available_again_at"].(string); ok {
+		text += tm("branch.detail_again_at", formatAgainAt(againAt)) + "\n"
+	}
+	if overridden {
+		text += "\n" + tm("branch.detail_overridden")
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(
+			button(toggleLabel, "branch_avail", menuID, toggleValue),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("branch.btn_price"), "branch_price", menuID),
+		),
+	}
+	if overridden {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			button(t("branch.btn_reset"), "branch_reset", menuID),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		backButton("branch_menu_list"),
+	))
+
+	sendMessage(chatID, text, tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+func setBranchOverride(chatID int64, branchID int, menuID int, fields map[string]interface{}) bool {
+	payload := map[string]interface{}{
+		"menu_id":   menuID,
+		"branch_id": branchID,
+	}
+	for k, v := range fields {
+		payload[k] = v
+	}
+
+	resp, err := cachedPost(menuServiceURL, shared.Request{
+		Action:  "set_branch_override",
+		Payload: payload,
+	})
+
+	if err != nil || !resp.Success {
+		errMsg := tm("branch.override_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		sendMessage(chatID, errMsg, nil)
+		return false
+	}
+	return true
+}
+
+func resetBranchOverride(chatID int64, branchID int, menuID int) {
+	resp, err := cachedPost(menuServiceURL, shared.Request{
+		Action: "clear_branch_override",
+		Payload: map[string]interface{}{
+			"menu_id":   menuID,
+			"branch_id": branchID,
+		},
+	})
+
+	if err != nil || !resp.Success {
+		sendMessage(chatID, tm("branch.reset_failed"), nil)
+		return
+	}
+
+	showBranchMenuDetail(chatID, branchID, menuID)
+}
+
+func startBranchPriceDialog(chatID int64, userID int64, branchID int, menuID int) {
+	userStates[userID] = "set_branch_price"
+	userTempData[userID] = map[string]interface{}{
+		"branch_id": branchID,
+		"menu_id":   menuID,
+	}
+	sendMessage(chatID, tm("branch.prompt_price")+"\n\n"+tm("common.cancel_hint"), nil)
+}
+
+func handleSetBranchPrice(msg *tgbotapi.Message, userID int64) {
+	input := strings.TrimSpace(msg.Text)
+	data := userTempData[userID]
+	branchID := data["branch_id"].(int)
+	menuID := data["menu_id"].(int)
+
+	var price interface{}
+	if input != "-" {
+		p, err := shared.ParseMoney(input)
+		if err != nil || p < 0 {
+			sendMessage(msg.Chat.ID, tm("branch.price_invalid"), nil)
+			return
+		}
+		price = p
+	}
+
+	delete(userStates, userID)
+	delete(userTempData, userID)
+
+	if setBranchOverride(msg.Chat.ID, branchID, menuID, map[string]interface{}{"price": price}) {
+		showBranchMenuDetail(msg.Chat.ID, branchID, menuID)
+	}
+}
+
+func init() {
+	registerWizard(&wizard{
+		name:   "add_branch",
+		title:  "branch.create_title",
+		finish: finishAddBranch,
+		steps: []wizardStep{
+			{
+				key:    "name",
+				prompt: textPrompt("branch.prompt_name"),
+				parse:  requiredText("branch.name_empty"),
+			},
+			{
+				key:    "address",
+				prompt: textPrompt("branch.prompt_address"),
+				parse:  requiredText("branch.address_empty"),
+			},
+			{
+				key:    "phone",
+				prompt: textPrompt("branch.prompt_phone"),
+				parse:  requiredText("branch.phone_empty"),
+			},
+			{
+				key:    "hours",
+				prompt: textPrompt("branch.prompt_hours"),
+				parse:  branchHours,
+			},
+		},
+	})
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+)
+
+// Actions whose answers are cached per service; everything else goes
+// straight to the service
+var cacheableActions = map[string]bool{"list": true, "read": true, "list_categories": true}
+
+// Actions of the cached services that write, but nothing any cached answer
+// shows. set_preference stores a user's branch and language in
+// info-service, which its list and read answers leave out.
+var nonInvalidatingActions = map[string]bool{"set_preference": true}
+
+// invalidates reports whether a successful action drops the cached answers
+// of its service: every action does unless it only reads or is one of
+// nonInvalidatingActions
+func invalidates(action string) bool {
+	return !shared.IsReadOnlyAction(action) && !nonInvalidatingActions[action]
+}
+
+var (
+	cacheRequests = shared.NewCounter("cafe_agent_cache_requests_total",
+		"Cacheable service calls, by service and result (hit or miss).", "service", "result")
+	cacheInvalidations = shared.NewCounter("cafe_agent_cache_invalidations_total",
+		"Events that dropped cached answers, by service.", "service")
+)
+
+// viewCache holds the answers of menu-service and info-service for the views
+// customers open most. It is filled on reads and emptied per service by the
+// events the services post to /events.
+var viewCache *responseCache
+
+// cachedServices maps the URL of each cached service to its name
+var cachedServices map[string]string
+
+// initCache sets up the cache from CACHE_TTL (default 1m, 0 disables it) and
+// CACHE_MAX_ENTRIES (default 1000, 0 for no limit)
+func initCache() {
+	ttl, err := time.ParseDuration(getEnv("CACHE_TTL", "1m"))
+	if err != nil || ttl < 0 {
+		shared.Logger(requestCtx).Warn("invalid CACHE_TTL, using 1m")
+		ttl = time.Minute
+	}
+	maxEntries, err := strconv.Atoi(getEnv("CACHE_MAX_ENTRIES", "1000"))
+	if err != nil || maxEntries < 0 {
+		shared.Logger(requestCtx).Warn("invalid CACHE_MAX_ENTRIES, using 1000")
+		maxEntries = 1000
+	}
+
+	viewCache = newResponseCache(ttl, maxEntries)
+	cachedServices = map[string]string{
+		menuServiceURL: "menu-service",
+		infoServiceURL: "info-service",
+	}
+}
+
+// cachedPost is httpClient.Post for the cached services. Reads may be
+// answered from the cache; only successful answers are cached. A change the
+// agent makes drops the answers of its service right away, since the event
+// announcing it may arrive after the admin opens the next view.
+func cachedPost(url string, req shared.Request) (*shared.Response, error) {
+	service, ok := cachedServices[url]
+	if !ok || viewCache.ttl == 0 {
+		return httpClient.Post(url, req)
+	}
+	if !cacheableActions[req.Action] {
+		response, err := httpClient.Post(url, req)
+		if err == nil && response.Success && invalidates(req.Action) {
+			viewCache.invalidate(service)
+		}
+		return response, err
+	}
+
+	keyData, err := json.Marshal(req)
+	if err != nil {
+		return httpClient.Post(url, req)
+	}
+	key := service + " " + string(keyData)
+
+	if response, ok := viewCache.get(key); ok {
+		cacheRequests.Inc(service, "hit")
+		return response, nil
+	}
+	cacheRequests.Inc(service, "miss")
+
+	// An answer fetched while the service changed may predate the change
+	generation := viewCache.generation(service)
+	response, err := httpClient.Post(url, req)
+	if err == nil && response.Success {
+		viewCache.put(key, service, generation, response)
+	}
+	return response, err
+}
+
+// handleEvents drops the cached answers of the service an event comes from,
+// and has the update loop forget deleted branches. Doing either twice does
+// no harm, so handled events are only remembered in memory.
+var handleEvents = shared.HandleEvents(shared.NewMemoryDeduper(10000), func(ctx context.Context, event shared.Event) error {
+	if dropped := viewCache.invalidate(event.Service); dropped > 0 {
+		cacheInvalidations.Inc(event.Service)
+		shared.Logger(ctx).Debug("cache invalidated", "service", event.Service, "dropped", dropped)
+	}
+	if event.Type == "branch.deleted" {
+		if branchID, ok := event.Data["branch_id"].(float64); ok {
+			select {
+			case deletedBranches <- int(branchID):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	return nil
+})
+
+// responseCache is an LRU cache of service answers with a TTL. Answers are
+// kept as JSON, so callers get their own copy to read and modify.
+type responseCache struct {
+	mu          sync.Mutex
+	ttl         time.Duration
+	maxEntries  int
+	entries     map[string]*list.Element
+	order       *list.List        // most recently used first
+	generations map[string]uint64 // per service, bumped by invalidate
+}
+
+type cacheEntry struct {
+	key     string
+	service string
+	data    []byte
+	expires time.Time
+}
+
+func newResponseCache(ttl time.Duration, maxEntries int) *responseCache {
+	return &responseCache{
+		ttl:         ttl,
+		maxEntries:  maxEntries,
+		entries:     map[string]*list.Element{},
+		order:       list.New(),
+		generations: map[string]uint64{},
+	}
+}
+
+func (c *responseCache) get(key string) (*shared.Response, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(elem)
+		return nil, false
+	}
+
+	var response shared.Response
+	if json.Unmarshal(entry.data, &response) != nil {
+		c.remove(elem)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return &response, true
+}
+
+// generation returns the generation of a service's answers; take it before
+// fetching an answer to put
+func (c *responseCache) generation(service string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generations[service]
+}
+
+// put caches an answer fetched at generation, unless the service's answers
+// were invalidated since
+func (c *responseCache) put(key, service string, generation uint64, response *shared.Response) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.generations[service] != generation {
+		return
+	}
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	entry := &cacheEntry{key: key, service: service, data: data, expires: time.Now().Add(c.ttl)}
+	c.entries[key] = c.order.PushFront(entry)
+
+	for c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+	}
+}
+
+// invalidate drops every answer of a service and returns how many it
+// dropped. Answers being fetched meanwhile are not cached.
+func (c *responseCache) invalidate(service string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generations[service]++
+	dropped := 0
+	for elem := c.order.Front(); elem != nil; {
+		next := elem.Next()
+		if elem.Value.(*cacheEntry).service == service {
+			c.remove(elem)
+			dropped++
+		}
+		elem = next
+	}
+	return dropped
+}
+
+func (c *responseCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry).key)
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+)
+
+func cached(data string) *shared.Response {
+	return &shared.Response{Success: true, Data: data}
+}
+
+func TestCacheRejectsStalePut(t *testing.T) {
+	cache := newResponseCache(time.Minute, 0)
+
+	// An answer fetched while the service changed may predate the change
+	generation := cache.generation("menu-service")
+	cache.invalidate("menu-service")
+	cache.put("menu list", "menu-service", generation, cached("old"))
+	if _, ok := cache.get("menu list"); ok {
+		t.Error("answer fetched before an invalidation was cached")
+	}
+
+	cache.put("menu list", "menu-service", cache.generation("menu-service"), cached("new"))
+	if response, ok := cache.get("menu list"); !ok || response.Data != "new" {
+		t.Errorf("get() = %v, %v; want the new answer", response, ok)
+	}
+
+	// Other services keep their answers and generation
+	cache.put("info list", "info-service", cache.generation("info-service"), cached("branches"))
+	if dropped := cache.invalidate("menu-service"); dropped != 1 {
+		t.Errorf("invalidate() dropped %d answers, want 1", dropped)
+	}
+	if _, ok := cache.get("info list"); !ok {
+		t.Error("invalidating menu-service dropped an info-service answer")
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	cache := newResponseCache(20*time.Millisecond, 0)
+	cache.put("menu list", "menu-service", 0, cached("menus"))
+	if _, ok := cache.get("menu list"); !ok {
+		t.Fatal("fresh answer missing")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := cache.get("menu list"); ok {
+		t.Error("expired answer returned")
+	}
+	if cache.order.Len() != 0 || len(cache.entries) != 0 {
+		t.Error("expired answer kept in the cache")
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	cache := newResponseCache(time.Minute, 2)
+	cache.put("a", "menu-service", 0, cached("a"))
+	cache.put("b", "menu-service", 0, cached("b"))
+
+	// Reading a makes b the least recently used
+	cache.get("a")
+	cache.put("c", "menu-service", 0, cached("c"))
+
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok := cache.get(key); ok != want {
+			t.Errorf("get(%q) found = %v, want %v", key, ok, want)
+		}
+	}
+}
+
+func TestCacheCopies(t *testing.T) {
+	cache := newResponseCache(time.Minute, 0)
+	cache.put("menu", "menu-service", 0, &shared.Response{Success: true, Data: map[string]interface{}{"name": "Latte"}})
+
+	first, _ := cache.get("menu")
+	first.Data.(map[string]interface{})["name"] = "changed"
+	second, _ := cache.get("menu")
+	if name := second.Data.(map[string]interface{})["name"]; name != "Latte" {
+		t.Errorf("cached answer changed through a copy: %v", name)
+	}
+}
+
+func TestInvalidates(t *testing.T) {
+	for action, want := range map[string]bool{
+		"create":           true,
+		"update":           true,
+		"set_availability": true,
+		"adjust_stock":     true,
+		"list":             false,
+		"quote":            false,
+		"get_recipe":       false,
+		"get_preference":   false,
+		"set_preference":   false,
+	} {
+		if got := invalidates(action); got != want {
+			t.Errorf("invalidates(%q) = %v, want %v", action, got, want)
+		}
+	}
+}
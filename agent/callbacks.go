@@ -0,0 +1,358 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Button callback data is written as
+//
+//	<version><code>:<arg>:...:<signature>
+//
+// where code is the short name of a registered action, integers are written
+// in base 36 and the signature is a truncated HMAC of everything before it.
+// Telegram sends callback data back as it was set, but a modified client can
+// send anything; the signature makes sure only buttons the bot made are
+// handled. Buttons from before a change of the format or the key, e.g. in old
+// messages, are answered with a note to open the menu again.
+
+// callbackVersion is the format of the data written by button; bump it
+// when codes or arguments of existing actions change meaning
+const callbackVersion = '1'
+
+// Telegram's limit for callback data, in bytes
+const maxCallbackData = 64
+
+// Bytes of the HMAC kept in callback data
+const callbackSignatureSize = 8
+
+// Buttons whose data does not fit are kept here and sent as a token; the
+// oldest are dropped past this many
+const maxLongCallbacks = 1024
+
+// Random bytes in the token of a long button
+const longCallbackTokenSize = 9
+
+const longCallbackCode = "~"
+
+var (
+	// callbackKey signs button data, from CALLBACK_SECRET
+	callbackKey []byte
+
+	callbackRoutes = make(map[string]*callbackRoute)
+	callbackCodes  = make(map[string]*callbackRoute)
+
+	longCallbacks     = make(map[string]string)
+	longCallbackOrder []string
+
+	errStaleCallback   = errors.New("stale callback data")
+	errInvalidCallback = errors.New("invalid callback data")
+)
+
+var argEscaper = strings.NewReplacer("%", "%25", ":", "%3A")
+var argUnescaper = strings.NewReplacer("%3A", ":", "%25", "%")
+
+// access is who may press a button
+type access int
+
+const (
+	anyone access = iota
+	adminOnly
+	// centralAdmin is an admin not bound to a branch
+	centralAdmin
+)
+
+// callbackRoute is a button action: its code in callback data, who may press
+// it, its arguments and its handler
+type callbackRoute struct {
+	action string
+	code   string
+	access access
+
+	// args lists the kind of each argument: i for an integer, b for a flag
+	// and s for a string. Arguments after a "?" may be left out; "*" takes
+	// the rest as strings.
+	args string
+
+	// screen marks actions that only show a screen; they replace the message
+	// they were pressed on and are kept on the navigation stack
+	screen bool
+	// paged screens take the page of their list as last argument
+	paged bool
+	// inPlace actions replace the message they were pressed on like screens,
+	// but are not kept on the navigation stack
+	inPlace bool
+
+	handle func(c *callbackContext)
+}
+
+// callbackCall is a decoded button press
+type callbackCall struct {
+	action string
+	args   []string
+}
+
+// callbackContext is what a handler gets: who pressed the button, where and
+// its checked arguments
+type callbackContext struct {
+	query    *tgbotapi.CallbackQuery
+	route    *callbackRoute
+	chatID   int64
+	userID   int64
+	username string
+	args     []string
+
+	// adminRecord is looked up on first use, once per update
+	adminRecord *adminRecord
+}
+
+// register adds a button action
+func register(action string, route callbackRoute) {
+	route.action = action
+	if _, ok := callbackCodes[route.code]; ok || route.code == "" || route.code == longCallbackCode {
+		panic("callback code of " + action + " is not unique: " + route.code)
+	}
+	callbackRoutes[action] = &route
+	callbackCodes[route.code] = &route
+}
+
+// initCallbackKey reads CALLBACK_SECRET. Without it a random key is used, so
+// buttons sent before a restart stop working.
+func initCallbackKey() {
+	if secret := getEnv("CALLBACK_SECRET", ""); secret != "" {
+		callbackKey = []byte(secret)
+		return
+	}
+	shared.Logger(requestCtx).Warn("CALLBACK_SECRET is not set; buttons sent before a restart will expire")
+	callbackKey = make([]byte, 32)
+	if _, err := rand.Read(callbackKey); err != nil {
+		panic(err)
+	}
+}
+
+// button returns an inline button pressing action with args
+func button(label, action string, args ...interface{}) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(label, encodeCallback(action, args...))
+}
+
+// encodeCallback writes the signed callback data of action with args
+func encodeCallback(action string, args ...interface{}) string {
+	route, ok := callbackRoutes[action]
+	if !ok {
+		shared.Logger(requestCtx).Error("button for unknown action", "action", action)
+		return string(callbackVersion)
+	}
+
+	body := route.code
+	for _, arg := range args {
+		body += ":" + encodeArg(arg)
+	}
+	data := signCallback(body)
+	if len(data) > maxCallbackData {
+		data = signCallback(longCallbackCode + ":" + storeLongCallback(body))
+	}
+	return data
+}
+
+func encodeArg(arg interface{}) string {
+	switch v := arg.(type) {
+	case int:
+		return strconv.FormatInt(int64(v), 36)
+	case int64:
+		return strconv.FormatInt(v, 36)
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case string:
+		return argEscaper.Replace(v)
+	default:
+		return argEscaper.Replace(fmt.Sprint(v))
+	}
+}
+
+func signCallback(body string) string {
+	payload := string(callbackVersion) + body
+	return payload + ":" + callbackSignature(payload)
+}
+
+func callbackSignature(payload string) string {
+	mac := hmac.New(sha256.New, callbackKey)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:callbackSignatureSize])
+}
+
+// storeLongCallback keeps data too long for a button and returns its token.
+// Tokens are random rather than counted, so after a restart the token of an
+// old button is unknown and the button expires instead of pressing whatever
+// was stored under the same number since.
+func storeLongCallback(body string) string {
+	raw := make([]byte, longCallbackTokenSize)
+	if _, err := rand.Read(raw); err != nil {
+		panic(err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+	longCallbacks[token] = body
+	longCallbackOrder = append(longCallbackOrder, token)
+	if len(longCallbackOrder) > maxLongCallbacks {
+		delete(longCallbacks, longCallbackOrder[0])
+		longCallbackOrder = longCallbackOrder[1:]
+	}
+	return token
+}
+
+// decodeCallback checks the signature of callback data and returns the call
+// it encodes
+func decodeCallback(data string) (callbackCall, error) {
+	if data == "" || data[0] != callbackVersion {
+		return callbackCall{}, errStaleCallback
+	}
+	i := strings.LastIndexByte(data, ':')
+	if i < 0 {
+		return callbackCall{}, errStaleCallback
+	}
+	payload, signature := data[:i], data[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(callbackSignature(payload))) {
+		// Also what buttons signed with an earlier key look like
+		return callbackCall{}, errInvalidCallback
+	}
+
+	body := payload[1:]
+	if token, ok := strings.CutPrefix(body, longCallbackCode+":"); ok {
+		if body, ok = longCallbacks[token]; !ok {
+			return callbackCall{}, errStaleCallback
+		}
+	}
+
+	parts := strings.Split(body, ":")
+	route, ok := callbackCodes[parts[0]]
+	if !ok {
+		return callbackCall{}, errStaleCallback
+	}
+	call := callbackCall{action: route.action}
+	for _, part := range parts[1:] {
+		call.args = append(call.args, argUnescaper.Replace(part))
+	}
+	return call, nil
+}
+
+// checkArgs reports whether args match the declared arguments of route
+func (route *callbackRoute) checkArgs(args []string) bool {
+	kinds := strings.Replace(route.args, "?", "", 1)
+	rest := strings.HasSuffix(kinds, "*")
+	kinds = strings.TrimSuffix(kinds, "*")
+	required := strings.IndexByte(route.args, '?')
+	if required < 0 {
+		required = len(kinds)
+	}
+
+	if len(args) < required || (!rest && len(args) > len(kinds)) {
+		return false
+	}
+	for i, arg := range args {
+		if i >= len(kinds) {
+			break
+		}
+		switch kinds[i] {
+		case 'i':
+			if _, err := strconv.ParseInt(arg, 36, 64); err != nil {
+				return false
+			}
+		case 'b':
+			if arg != "0" && arg != "1" {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// allowed reports whether the user of c may press a button of route
+func (route *callbackRoute) allowed(c *callbackContext) bool {
+	switch route.access {
+	case adminOnly:
+		return c.admin().canManage()
+	case centralAdmin:
+		admin := c.admin()
+		return admin.admin && admin.scope == 0
+	default:
+		return true
+	}
+}
+
+// callbackAction names the action of callback data for logs and metrics
+func callbackAction(data string) string {
+	call, err := decodeCallback(data)
+	if err != nil {
+		return "invalid"
+	}
+	return call.action
+}
+
+// int returns integer argument i, or 0 when it was left out
+func (c *callbackContext) int(i int) int {
+	if i >= len(c.args) {
+		return 0
+	}
+	n, _ := strconv.ParseInt(c.args[i], 36, 64)
+	return int(n)
+}
+
+// str returns string argument i, or "" when it was left out
+func (c *callbackContext) str(i int) string {
+	if i >= len(c.args) {
+		return ""
+	}
+	return c.args[i]
+}
+
+// flag returns flag argument i
+func (c *callbackContext) flag(i int) bool {
+	return i < len(c.args) && c.args[i] == "1"
+}
+
+// page returns the page of a paged screen, its last argument; the first
+// page is 0
+func (c *callbackContext) page() int {
+	if !c.route.paged {
+		return 0
+	}
+	return c.int(len(strings.Trim(strings.Replace(c.route.args, "?", "", 1), "*")) - 1)
+}
+
+// messageID is the message the button was pressed on
+func (c *callbackContext) messageID() int {
+	if c.query.Message == nil {
+		return 0
+	}
+	return c.query.Message.MessageID
+}
+
+// admin is the admin record of the user who pressed the button
+func (c *callbackContext) admin() adminRecord {
+	if c.adminRecord == nil {
+		record := lookupAdmin(c.userID, c.username)
+		c.adminRecord = &record
+	}
+	return *c.adminRecord
+}
+
+// scope is the branch an admin is bound to, 0 for every branch
+func (c *callbackContext) scope() int {
+	return c.admin().scope
+}
+
+// branch is the branch an admin works on
+func (c *callbackContext) branch() int {
+	return c.admin().branch(c.userID)
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+)
+
+func withCallbackKey(t *testing.T, key string) {
+	t.Helper()
+	previous := callbackKey
+	callbackKey = []byte(key)
+	t.Cleanup(func() { callbackKey = previous })
+}
+
+// tamper changes the last character of data
+func tamper(data string) string {
+	last := "A"
+	if strings.HasSuffix(data, last) {
+		last = "B"
+	}
+	return data[:len(data)-1] + last
+}
+
+func TestCallbackRoundTrip(t *testing.T) {
+	withCallbackKey(t, "test-secret")
+
+	tests := []struct {
+		name     string
+		action   string
+		args     []interface{}
+		wantArgs []string
+	}{
+		{"no args", "show_user_menu", nil, nil},
+		{"string", "back", []interface{}{"admin"}, []string{"admin"}},
+		{"separator in string", "back", []interface{}{"a:b%c"}, []string{"a:b%c"}},
+		{"integer in base 36", "back", []interface{}{71}, []string{"1z"}},
+		{"flag", "back", []interface{}{true}, []string{"1"}},
+		{"long data", "back", []interface{}{strings.Repeat("x", 80)}, []string{strings.Repeat("x", 80)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := encodeCallback(tt.action, tt.args...)
+			if len(data) > maxCallbackData {
+				t.Fatalf("encodeCallback() is %d bytes, over %d", len(data), maxCallbackData)
+			}
+			call, err := decodeCallback(data)
+			if err != nil {
+				t.Fatalf("decodeCallback(%q) error = %v", data, err)
+			}
+			if call.action != tt.action || strings.Join(call.args, "|") != strings.Join(tt.wantArgs, "|") {
+				t.Errorf("decodeCallback() = %s %q, want %s %q", call.action, call.args, tt.action, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestDecodeCallbackRejects(t *testing.T) {
+	withCallbackKey(t, "test-secret")
+
+	valid := encodeCallback("back", "admin")
+	long := encodeCallback("back", strings.Repeat("x", 80))
+	otherKey := func() string {
+		callbackKey = []byte("old-secret")
+		defer func() { callbackKey = []byte("test-secret") }()
+		return encodeCallback("back", "admin")
+	}()
+	expired := func() string {
+		data := encodeCallback("back", strings.Repeat("y", 80))
+		token := strings.Split(data, ":")[1]
+		delete(longCallbacks, token)
+		return data
+	}()
+
+	tests := []struct {
+		name string
+		data string
+		want error
+	}{
+		{"empty", "", errStaleCallback},
+		{"wrong version", "0" + valid[1:], errStaleCallback},
+		{"unsigned", valid[:strings.LastIndexByte(valid, ':')], errInvalidCallback},
+		{"tampered argument", strings.Replace(valid, "admin", "start", 1), errInvalidCallback},
+		{"tampered signature", tamper(valid), errInvalidCallback},
+		{"signed with another key", otherKey, errInvalidCallback},
+		{"long data after restart", expired, errStaleCallback},
+		{"forged long token", strings.Replace(long, strings.Split(long, ":")[1], "AAAAAAAAAAAA", 1), errInvalidCallback},
+		{"unknown code", signCallback("zz:1"), errStaleCallback},
+		{"unknown long token", signCallback(longCallbackCode + ":AAAAAAAAAAAA"), errStaleCallback},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCallback(tt.data); !errors.Is(err, tt.want) {
+				t.Errorf("decodeCallback(%q) error = %v, want %v", tt.data, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCallbackCheckArgs(t *testing.T) {
+	tests := []struct {
+		args string
+		got  []string
+		want bool
+	}{
+		{"", nil, true},
+		{"", []string{"x"}, false},
+		{"i", []string{"1z"}, true},
+		{"i", []string{"-"}, false},
+		{"i", nil, false},
+		{"b", []string{"1"}, true},
+		{"b", []string{"2"}, false},
+		{"is?i", []string{"a", "x"}, true},
+		{"is?i", []string{"a", "x", "b"}, true},
+		{"is?i", []string{"a"}, false},
+		{"s?*", nil, false},
+		{"s?*", []string{"a", "b", "c"}, true},
+		{"?s", nil, true},
+	}
+	for _, tt := range tests {
+		route := &callbackRoute{args: tt.args}
+		if got := route.checkArgs(tt.got); got != tt.want {
+			t.Errorf("checkArgs(%q, %q) = %v, want %v", tt.args, tt.got, got, tt.want)
+		}
+	}
+}
+
+// withAuthService points the agent at an auth-service answering verify with
+// admin, or with an error when admin is nil, and counts the calls
+func withAuthService(t *testing.T, admin map[string]interface{}) *atomic.Int32 {
+	t.Helper()
+	calls := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if admin == nil {
+			w.Write([]byte(`{"success":false,"error":{"code":"ERR_UNAUTHORIZED","message":"bukan admin"}}`))
+			return
+		}
+		json.NewEncoder(w).Encode(shared.Response{Success: true, Data: map[string]interface{}{"admin": admin}})
+	}))
+	t.Cleanup(server.Close)
+
+	previousURL, previousClient := authServiceURL, httpClient
+	authServiceURL, httpClient = server.URL, shared.NewHTTPClient()
+	t.Cleanup(func() { authServiceURL, httpClient = previousURL, previousClient })
+	return calls
+}
+
+func TestCallbackAdminLookup(t *testing.T) {
+	tests := []struct {
+		name        string
+		admin       map[string]interface{}
+		access      access
+		wantAllowed bool
+		wantScope   int
+	}{
+		{"manager", map[string]interface{}{"role": "manager", "branch_id": 2}, adminOnly, true, 2},
+		{"manager on central screen", map[string]interface{}{"role": "manager", "branch_id": 2}, centralAdmin, false, 2},
+		{"owner", map[string]interface{}{"role": "owner"}, centralAdmin, true, 0},
+		{"manager without branch", map[string]interface{}{"role": "manager"}, adminOnly, false, noBranchAccess},
+		{"not an admin", nil, adminOnly, false, noBranchAccess},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := withAuthService(t, tt.admin)
+			route := &callbackRoute{access: tt.access}
+			c := &callbackContext{route: route, userID: 42}
+
+			if allowed := route.allowed(c); allowed != tt.wantAllowed {
+				t.Errorf("allowed() = %v, want %v", allowed, tt.wantAllowed)
+			}
+			if scope := c.scope(); scope != tt.wantScope {
+				t.Errorf("scope() = %d, want %d", scope, tt.wantScope)
+			}
+			if n := calls.Load(); n != 1 {
+				t.Errorf("auth-service asked %d times for one button press, want 1", n)
+			}
+		})
+	}
+}
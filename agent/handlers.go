@@ -1,9 +1,7 @@
 package main
 
 import (
-	"fmt"
-	"strconv"
-	"strings"
+	"errors"
 
 	"github.com/alrescha79-cmd/bot-cafe/shared"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -25,8 +23,14 @@ func handleMessage(msg *tgbotapi.Message) {
 		return
 	}
 
+	// Shared location: look up the nearest branches
+	if msg.Location != nil {
+		showNearestBranches(msg.Chat.ID, userID, msg.Location.Latitude, msg.Location.Longitude)
+		return
+	}
+
 	// Default response
-	text := "Gunakan menu atau ketik /start untuk memulai."
+	text := tm("common.use_start")
 	sendMessage(msg.Chat.ID, text, nil)
 }
 
@@ -35,27 +39,63 @@ func handleCommand(msg *tgbotapi.Message) {
 	username := msg.From.UserName
 	command := msg.Command()
 
+	// A command starts a new screen below, so older screens are not walked
+	// back to
+	resetNavigation(userID)
+
 	switch command {
 	case "start":
 		handleStartCommand(msg)
 	case "menu":
 		showUserMenu(msg.Chat.ID)
 	case "promo":
-		showPromos(msg.Chat.ID, true)
+		showPromos(msg.Chat.ID, true, getUserBranch(userID))
 	case "info":
-		showCafeInfo(msg.Chat.ID)
+		showCafeInfo(msg.Chat.ID, getUserBranch(userID))
+	case "cabang":
+		showBranchPicker(msg.Chat.ID, userID)
+	case "pesanan":
+		showMyOrders(msg.Chat.ID, userID)
+	case "reservasi":
+		startReservation(msg.Chat.ID, msg.From)
+	case "laporan":
+		if admin := lookupAdmin(userID, username); admin.canManage() {
+			showReportMenu(msg.Chat.ID, admin.scope)
+		} else {
+			sendMessage(msg.Chat.ID, tm("common.no_admin_access"), nil)
+		}
+	case "backup":
+		if isOwner(userID, username) {
+			sendBackup(msg.Chat.ID)
+		} else {
+			sendMessage(msg.Chat.ID, tm("backup.owner_only"), nil)
+		}
+	case "habis":
+		if admin := lookupAdmin(userID, username); admin.canManage() {
+			showAvailabilityBoard(msg.Chat.ID, 0, userID, admin.branch(userID), 0)
+		} else {
+			sendMessage(msg.Chat.ID, tm("common.no_admin_access"), nil)
+		}
+	case "status":
+		if lookupAdmin(userID, username).canManage() {
+			showServiceStatus(msg.Chat.ID)
+		} else {
+			sendMessage(msg.Chat.ID, tm("common.no_admin_access"), nil)
+		}
 	case "admin":
-		if isAdmin(userID, username) {
+		if lookupAdmin(userID, username).canManage() {
 			showAdminMenu(msg.Chat.ID)
 		} else {
-			sendMessage(msg.Chat.ID, "⚠️ Anda tidak memiliki akses admin.", nil)
+			sendMessage(msg.Chat.ID, tm("common.no_admin_access"), nil)
 		}
+	case "bahasa":
+		showLanguagePicker(msg.Chat.ID)
 	case "cancel":
 		delete(userStates, userID)
 		delete(userTempData, userID)
-		sendMessage(msg.Chat.ID, "❌ Operasi dibatalkan.", nil)
+		sendMessage(msg.Chat.ID, tm("common.cancelled"), nil)
 	default:
-		sendMessage(msg.Chat.ID, "Perintah tidak dikenal. Gunakan /start untuk melihat menu.", nil)
+		sendMessage(msg.Chat.ID, tm("common.unknown_command"), nil)
 	}
 }
 
@@ -63,334 +103,484 @@ func handleStartCommand(msg *tgbotapi.Message) {
 	userID := msg.From.ID
 	username := msg.From.UserName
 
-	shared.LogInfo("[START] User %d (@%s) executed /start", userID, username)
-
 	// Admins go directly to admin menu
 	isAdminUser := isAdmin(userID, username)
-	shared.LogInfo("[START] isAdmin check result: %v for user %d (@%s)", isAdminUser, userID, username)
+	shared.Logger(requestCtx).Debug("start", "admin", isAdminUser)
 
 	if isAdminUser {
-		welcomeText := "👋 Selamat datang di Bot Café!\n\n"
-		welcomeText += "Anda login sebagai *Admin*."
+		welcomeText := tm("start.welcome") + "\n\n"
+		welcomeText += tm("start.admin")
 
-		msg2 := tgbotapi.NewMessage(msg.Chat.ID, welcomeText)
-		msg2.ParseMode = "Markdown"
-		bot.Send(msg2)
+		sendMessage(msg.Chat.ID, welcomeText, nil)
 
 		// Show admin menu directly
-		shared.LogInfo("[START] Showing ADMIN menu for user %d (@%s)", userID, username)
 		showAdminMenu(msg.Chat.ID)
 		return
 	}
 
 	// Regular users see the standard welcome menu
-	shared.LogInfo("[START] Showing USER menu for user %d (@%s)", userID, username)
-	welcomeText := "👋 Selamat datang di Bot Café!\n\n"
-	welcomeText += "Pilih menu di bawah ini:"
+	welcomeText := tm("start.welcome") + "\n\n"
+	welcomeText += tm("start.choose")
 
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+	rows := [][]tgbotapi.InlineKeyboardButton{
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📋 Lihat Menu", "show_user_menu"),
-			tgbotapi.NewInlineKeyboardButtonData("🎉 Lihat Promo", "show_promo"),
+			button(t("start.btn_menu"), "show_user_menu"),
+			button(t("start.btn_promo"), "show_promo"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("ℹ️ Info Café", "show_info"),
+			button(t("start.btn_info"), "show_info"),
+			button(t("start.btn_orders"), "my_orders"),
 		),
-	)
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("start.btn_reservation"), "my_reservations"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("start.btn_language"), "lang_pick"),
+		),
+	}
+
+	// Only offer the branch picker when there is something to pick
+	if branches, err := fetchBranches(); err == nil && len(branches) > 1 {
+		rows = append(rows,
+			tgbotapi.NewInlineKeyboardRow(
+				button(t("start.btn_branch", branchName(getUserBranch(userID))), "branch_pick"),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				button(t("start.btn_nearest"), "branch_nearest"),
+			),
+		)
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
 
-	msg2 := tgbotapi.NewMessage(msg.Chat.ID, welcomeText)
-	msg2.ParseMode = "Markdown"
-	msg2.ReplyMarkup = keyboard
-	bot.Send(msg2)
+	sendMessage(msg.Chat.ID, welcomeText, keyboard)
 }
 
+// handleCallback routes a button press to the handler of its action, after
+// checking the button was made by the bot and the user may press it
 func handleCallback(callback *tgbotapi.CallbackQuery) {
-	userID := callback.From.ID
-	username := callback.From.UserName
-	data := callback.Data
-
-	// Answer callback to remove loading state
-	bot.Request(tgbotapi.NewCallback(callback.ID, ""))
-
-	parts := strings.Split(data, ":")
-	action := parts[0]
-
-	switch action {
-	case "menu_category":
-		if len(parts) > 1 {
-			category := parts[1]
-			showMenuByCategory(callback.Message.Chat.ID, category)
-		}
-	case "menu_detail":
-		if len(parts) > 1 {
-			menuID, _ := strconv.Atoi(parts[1])
-			showMenuDetail(callback.Message.Chat.ID, menuID)
-		}
-	case "admin_menu":
-		if !isAdmin(userID, username) {
-			sendMessage(callback.Message.Chat.ID, "⚠️ Akses ditolak.", nil)
-			return
-		}
-		showAdminMenuManagement(callback.Message.Chat.ID)
-	case "admin_promo":
-		if !isAdmin(userID, username) {
-			sendMessage(callback.Message.Chat.ID, "⚠️ Akses ditolak.", nil)
-			return
-		}
-		showAdminPromoManagement(callback.Message.Chat.ID)
-	case "admin_info":
-		if !isAdmin(userID, username) {
-			sendMessage(callback.Message.Chat.ID, "⚠️ Akses ditolak.", nil)
-			return
+	call, err := decodeCallback(callback.Data)
+	if err != nil {
+		if errors.Is(err, errInvalidCallback) {
+			shared.Logger(requestCtx).Warn("callback data with a bad signature", "data", callback.Data)
 		}
-		showAdminInfoManagement(callback.Message.Chat.ID)
-	case "admin_category":
-		if !isAdmin(userID, username) {
-			sendMessage(callback.Message.Chat.ID, "⚠️ Akses ditolak.", nil)
-			return
-		}
-		showAdminCategoryManagement(callback.Message.Chat.ID)
+		bot.Request(tgbotapi.NewCallbackWithAlert(callback.ID, t("common.button_expired")))
+		return
+	}
 
-	// Menu CRUD Operations
-	case "menu_create":
-		if !isAdmin(userID, username) {
-			return
-		}
-		startAddMenuDialog(callback.Message.Chat.ID, userID)
-	case "menu_read_all":
-		if !isAdmin(userID, username) {
-			return
-		}
-		showMenuList(callback.Message.Chat.ID, "view")
-	case "menu_update_list":
-		if !isAdmin(userID, username) {
-			return
-		}
-		showMenuList(callback.Message.Chat.ID, "update")
-	case "menu_delete_list":
-		if !isAdmin(userID, username) {
-			return
-		}
-		showMenuList(callback.Message.Chat.ID, "delete")
+	c := &callbackContext{
+		query:    callback,
+		chatID:   callback.From.ID,
+		userID:   callback.From.ID,
+		username: callback.From.UserName,
+	}
+	if callback.Message != nil {
+		c.chatID = callback.Message.Chat.ID
+	}
 
-	// Promo CRUD Operations
-	case "promo_create":
-		if !isAdmin(userID, username) {
+	// Back buttons go to the screen the user came from
+	if call.action == "nav_back" {
+		if !callbackRoutes["nav_back"].checkArgs(call.args) {
+			bot.Request(tgbotapi.NewCallbackWithAlert(callback.ID, t("common.button_expired")))
 			return
 		}
-		startAddPromoDialog(callback.Message.Chat.ID, userID)
-	case "promo_read_all":
-		if !isAdmin(userID, username) {
-			return
-		}
-		showPromoList(callback.Message.Chat.ID, "view")
-	case "promo_update_list":
-		if !isAdmin(userID, username) {
-			return
-		}
-		showPromoList(callback.Message.Chat.ID, "update")
-	case "promo_delete_list":
-		if !isAdmin(userID, username) {
-			return
-		}
-		showPromoList(callback.Message.Chat.ID, "delete")
+		call = popScreen(c.userID, callbackCall{action: call.args[0], args: call.args[1:]})
+	}
 
-	// Category CRUD Operations
-	case "category_create":
-		if !isAdmin(userID, username) {
-			return
-		}
-		startAddCategoryDialog(callback.Message.Chat.ID, userID)
-	case "category_read_all":
-		if !isAdmin(userID, username) {
-			return
-		}
-		showCategoryList(callback.Message.Chat.ID, "view")
-	case "category_delete_list":
-		if !isAdmin(userID, username) {
-			return
-		}
-		showCategoryList(callback.Message.Chat.ID, "delete")
+	route, ok := callbackRoutes[call.action]
+	if !ok || route.handle == nil || !route.checkArgs(call.args) {
+		shared.Logger(requestCtx).Warn("callback with unexpected arguments", "action", call.action, "args", call.args)
+		bot.Request(tgbotapi.NewCallbackWithAlert(callback.ID, t("common.button_expired")))
+		return
+	}
+	c.route = route
+	c.args = call.args
+	if !route.allowed(c) {
+		bot.Request(tgbotapi.NewCallbackWithAlert(callback.ID, t("common.access_denied")))
+		return
+	}
 
-	// Info CRUD Operations
-	case "info_read":
-		if !isAdmin(userID, username) {
-			return
-		}
-		showCafeInfoDetail(callback.Message.Chat.ID)
-	case "info_update":
-		if !isAdmin(userID, username) {
-			return
-		}
-		startEditCafeInfoDialog(callback.Message.Chat.ID, userID)
+	// Answer callback to remove loading state
+	bot.Request(tgbotapi.NewCallback(callback.ID, ""))
 
-	// Legacy handlers (keep for compatibility)
-	case "add_menu":
-		if !isAdmin(userID, username) {
-			return
-		}
-		startAddMenuDialog(callback.Message.Chat.ID, userID)
-	case "edit_menu":
-		if !isAdmin(userID, username) {
-			return
-		}
-		if len(parts) > 1 {
-			menuID, _ := strconv.Atoi(parts[1])
-			startEditMenuDialog(callback.Message.Chat.ID, userID, menuID)
-		}
-	case "confirm_delete_menu":
-		if !isAdmin(userID, username) {
-			return
-		}
-		if len(parts) > 1 {
-			menuID, _ := strconv.Atoi(parts[1])
-			keyboard := tgbotapi.NewInlineKeyboardMarkup(
-				tgbotapi.NewInlineKeyboardRow(
-					tgbotapi.NewInlineKeyboardButtonData("✅ Ya, Hapus", fmt.Sprintf("delete_menu:%d", menuID)),
-					tgbotapi.NewInlineKeyboardButtonData("❌ Batal", "menu_delete_list"),
-				),
-			)
-			sendMessage(callback.Message.Chat.ID, "⚠️ Apakah Anda yakin ingin menghapus menu ini?", keyboard)
-		}
-	case "delete_menu":
-		if !isAdmin(userID, username) {
-			return
+	if (route.screen || route.inPlace) && callback.Message != nil {
+		navMessage = callback.Message
+		defer func() { navMessage = nil }()
+		if route.screen {
+			pushScreen(c.userID, call)
 		}
-		if len(parts) > 1 {
-			menuID, _ := strconv.Atoi(parts[1])
-			deleteMenu(callback.Message.Chat.ID, menuID)
-		}
-	case "edit_promo":
-		if !isAdmin(userID, username) {
-			return
-		}
-		if len(parts) > 1 {
-			promoID, _ := strconv.Atoi(parts[1])
-			sendMessage(callback.Message.Chat.ID, fmt.Sprintf("⚠️ Fitur edit promo akan segera ditambahkan. (Promo ID: %d)", promoID), nil)
-		}
-	case "confirm_delete_promo":
-		if !isAdmin(userID, username) {
-			return
-		}
-		if len(parts) > 1 {
-			promoID, _ := strconv.Atoi(parts[1])
-			keyboard := tgbotapi.NewInlineKeyboardMarkup(
-				tgbotapi.NewInlineKeyboardRow(
-					tgbotapi.NewInlineKeyboardButtonData("✅ Ya, Hapus", fmt.Sprintf("delete_promo:%d", promoID)),
-					tgbotapi.NewInlineKeyboardButtonData("❌ Batal", "promo_delete_list"),
-				),
-			)
-			sendMessage(callback.Message.Chat.ID, "⚠️ Apakah Anda yakin ingin menghapus promo ini?", keyboard)
-		}
-	case "delete_promo":
-		if !isAdmin(userID, username) {
-			return
-		}
-		if len(parts) > 1 {
-			promoID, _ := strconv.Atoi(parts[1])
-			deletePromo(callback.Message.Chat.ID, promoID)
-		}
-	case "confirm_delete_category":
-		if !isAdmin(userID, username) {
-			return
-		}
-		if len(parts) > 1 {
-			categoryID, _ := strconv.Atoi(parts[1])
-			keyboard := tgbotapi.NewInlineKeyboardMarkup(
-				tgbotapi.NewInlineKeyboardRow(
-					tgbotapi.NewInlineKeyboardButtonData("✅ Ya, Hapus", fmt.Sprintf("delete_category:%d", categoryID)),
-					tgbotapi.NewInlineKeyboardButtonData("❌ Batal", "category_delete_list"),
-				),
-			)
-			sendMessage(callback.Message.Chat.ID, "⚠️ Apakah Anda yakin ingin menghapus kategori ini?\n\n*Perhatian:* Menu dengan kategori ini mungkin terpengaruh.", keyboard)
-		}
-	case "delete_category":
-		if !isAdmin(userID, username) {
-			return
-		}
-		if len(parts) > 1 {
-			categoryID, _ := strconv.Atoi(parts[1])
-			deleteCategory(callback.Message.Chat.ID, categoryID)
-		}
-	case "show_user_menu":
-		showUserMenu(callback.Message.Chat.ID)
-	case "show_promo":
-		showPromos(callback.Message.Chat.ID, true)
-	case "show_info":
-		showCafeInfo(callback.Message.Chat.ID)
-	case "edit_info":
-		if !isAdmin(userID, username) {
-			return
-		}
-		if len(parts) > 1 {
-			field := parts[1]
-			var prompt string
-			var state string
-
-			switch field {
-			case "name":
-				prompt = "Masukkan nama café baru:\n\n(Ketik /cancel untuk membatalkan)"
-				state = "edit_cafe_info_name"
-			case "address":
-				prompt = "Masukkan alamat café baru:\n\n(Ketik /cancel untuk membatalkan)"
-				state = "edit_cafe_info_address"
-			case "phone":
-				prompt = "Masukkan nomor telepon baru:\n\n(Ketik /cancel untuk membatalkan)"
-				state = "edit_cafe_info_phone"
-			case "email":
-				prompt = "Masukkan email baru (atau ketik - untuk menghapus):\n\n(Ketik /cancel untuk membatalkan)"
-				state = "edit_cafe_info_email"
-			case "opening_hour":
-				prompt = "Masukkan jam buka baru (contoh: 08:00):\n\n(Ketik /cancel untuk membatalkan)"
-				state = "edit_cafe_info_opening_hour"
-			case "closing_hour":
-				prompt = "Masukkan jam tutup baru (contoh: 22:00):\n\n(Ketik /cancel untuk membatalkan)"
-				state = "edit_cafe_info_closing_hour"
-			case "description":
-				prompt = "Masukkan deskripsi baru (atau ketik - untuk menghapus):\n\n(Ketik /cancel untuk membatalkan)"
-				state = "edit_cafe_info_description"
-			default:
+	}
+	route.handle(c)
+}
+
+func init() {
+	// Navigation
+	register("nav_back", callbackRoute{code: "nb", args: "s?*"})
+	register("back", callbackRoute{code: "bk", args: "s", screen: true, handle: func(c *callbackContext) {
+		switch c.str(0) {
+		case "admin":
+			if !c.admin().admin {
 				return
 			}
+			showAdminMenu(c.chatID)
+		case "user":
+			showUserMenu(c.chatID)
+		case "start":
+			handleStartCommand(&tgbotapi.Message{Chat: &tgbotapi.Chat{ID: c.chatID}, From: c.query.From})
+		}
+	}})
+	register("show_user_menu", callbackRoute{code: "um", screen: true, handle: func(c *callbackContext) {
+		showUserMenu(c.chatID)
+	}})
+	register("show_promo", callbackRoute{code: "up", screen: true, handle: func(c *callbackContext) {
+		showPromos(c.chatID, true, getUserBranch(c.userID))
+	}})
+	register("show_info", callbackRoute{code: "ui", screen: true, handle: func(c *callbackContext) {
+		showCafeInfo(c.chatID, getUserBranch(c.userID))
+	}})
+	register("show_admin_panel", callbackRoute{code: "ap", access: adminOnly, screen: true, handle: func(c *callbackContext) {
+		showAdminMenu(c.chatID)
+	}})
+	register("lang_pick", callbackRoute{code: "lp", screen: true, handle: func(c *callbackContext) {
+		showLanguagePicker(c.chatID)
+	}})
+	register("set_lang", callbackRoute{code: "ls", args: "s", handle: func(c *callbackContext) {
+		if shared.IsSupportedLocale(c.str(0)) {
+			setUserLanguage(c.chatID, c.userID, c.str(0))
+		}
+	}})
 
-			userStates[userID] = state
-			userTempData[userID] = make(map[string]interface{})
-			sendMessage(callback.Message.Chat.ID, prompt, nil)
-		}
-	case "show_admin_panel":
-		if !isAdmin(userID, username) {
-			sendMessage(callback.Message.Chat.ID, "⚠️ Akses ditolak.", nil)
+	// Customer menu
+	register("menu_category", callbackRoute{code: "mc", args: "s?i", screen: true, paged: true, handle: func(c *callbackContext) {
+		showMenuByCategory(c.chatID, c.str(0), getUserBranch(c.userID), c.page())
+	}})
+	register("menu_detail", callbackRoute{code: "md", args: "i", screen: true, handle: func(c *callbackContext) {
+		showMenuDetail(c.chatID, c.int(0), getUserBranch(c.userID))
+	}})
+
+	// Variant Selection
+	register("opt_start", callbackRoute{code: "os", args: "i", handle: func(c *callbackContext) {
+		startOptionSelection(c.chatID, c.userID, c.int(0))
+	}})
+	register("opt_pick", callbackRoute{code: "op", args: "i", handle: func(c *callbackContext) {
+		pickOption(c.chatID, c.userID, c.int(0))
+	}})
+	register("opt_next", callbackRoute{code: "on", handle: func(c *callbackContext) {
+		nextOptionStep(c.chatID, c.userID)
+	}})
+	register("opt_prev", callbackRoute{code: "ob", handle: func(c *callbackContext) {
+		prevOptionStep(c.chatID, c.userID)
+	}})
+
+	// Orders
+	register("order_place", callbackRoute{code: "pl", args: "i", handle: func(c *callbackContext) {
+		quantity := c.int(0)
+		if quantity < 1 {
+			quantity = 1
+		}
+		placeOrder(c.chatID, c.query.From, quantity)
+	}})
+	register("my_orders", callbackRoute{code: "mo", screen: true, handle: func(c *callbackContext) {
+		showMyOrders(c.chatID, c.userID)
+	}})
+	register("admin_orders", callbackRoute{code: "ao", access: adminOnly, screen: true, handle: func(c *callbackContext) {
+		showAdminOrders(c.chatID, c.scope())
+	}})
+	register("order_status", callbackRoute{code: "st", access: adminOnly, args: "is", handle: func(c *callbackContext) {
+		updateOrderStatus(c.chatID, c.int(0), c.str(1), c.scope())
+	}})
+
+	// Reservations
+	register("reservation_new", callbackRoute{code: "rn", handle: func(c *callbackContext) {
+		startReservation(c.chatID, c.query.From)
+	}})
+	register("my_reservations", callbackRoute{code: "rm", screen: true, handle: func(c *callbackContext) {
+		showMyReservations(c.chatID, c.userID)
+	}})
+	register("confirm_cancel_reservation", callbackRoute{code: "rq", args: "i", screen: true, handle: func(c *callbackContext) {
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				button(t("reservation.btn_confirm_cancel"), "cancel_reservation", c.int(0)),
+				backButton("my_reservations"),
+			),
+		)
+		sendMessage(c.chatID, tm("reservation.confirm_cancel", c.int(0)), keyboard)
+	}})
+	register("cancel_reservation", callbackRoute{code: "rx", args: "i", handle: func(c *callbackContext) {
+		cancelMyReservation(c.chatID, c.userID, c.int(0))
+	}})
+	register("admin_reservations", callbackRoute{code: "ra", access: adminOnly, args: "?i", screen: true, paged: true, handle: func(c *callbackContext) {
+		showAdminReservations(c.chatID, c.scope(), c.page())
+	}})
+	register("reservation_status", callbackRoute{code: "rv", access: adminOnly, args: "is", handle: func(c *callbackContext) {
+		setReservationStatus(c.chatID, c.userID, c.int(0), c.str(1), c.scope())
+	}})
+	register("reservation_tables", callbackRoute{code: "rb", access: adminOnly, screen: true, handle: func(c *callbackContext) {
+		showReservationTables(c.chatID, c.branch())
+	}})
+	register("table_create", callbackRoute{code: "rf", access: adminOnly, handle: func(c *callbackContext) {
+		startWizard(c.chatID, c.userID, "add_table", map[string]interface{}{"branch_id": c.branch()})
+	}})
+	register("confirm_delete_table", callbackRoute{code: "rd", access: adminOnly, args: "i", screen: true, handle: func(c *callbackContext) {
+		confirmDelete(c.chatID, tm("reservation.table_confirm_delete"), "delete_table", c.int(0), "reservation_tables")
+	}})
+	register("delete_table", callbackRoute{code: "re", access: adminOnly, args: "i", handle: func(c *callbackContext) {
+		deleteTable(c.chatID, c.int(0), c.branch())
+	}})
+
+	// Reports
+	register("admin_report", callbackRoute{code: "ar", access: adminOnly, screen: true, handle: func(c *callbackContext) {
+		showReportMenu(c.chatID, c.scope())
+	}})
+	register("report", callbackRoute{code: "rp", access: adminOnly, args: "ss", screen: true, handle: func(c *callbackContext) {
+		showReport(c.chatID, c.str(0), c.str(1), c.scope())
+	}})
+	register("report_custom", callbackRoute{code: "rc", access: adminOnly, handle: func(c *callbackContext) {
+		startReportRangeDialog(c.chatID, c.userID, c.scope())
+	}})
+	register("report_chart", callbackRoute{code: "rg", access: adminOnly, args: "sss", handle: func(c *callbackContext) {
+		sendReportChart(c.chatID, c.str(0), c.str(1), c.str(2), c.scope())
+	}})
+
+	// Quick Availability
+	register("avail_board", callbackRoute{code: "vb", access: adminOnly, args: "?i", paged: true, handle: func(c *callbackContext) {
+		showAvailabilityBoard(c.chatID, 0, c.userID, c.branch(), c.page())
+	}})
+	register("avail_refresh", callbackRoute{code: "vr", access: adminOnly, args: "?i", paged: true, handle: func(c *callbackContext) {
+		showAvailabilityBoard(c.chatID, c.messageID(), c.userID, c.branch(), c.page())
+	}})
+	register("avail_toggle", callbackRoute{code: "vt", access: adminOnly, args: "ib?i", paged: true, handle: func(c *callbackContext) {
+		toggleAvailability(c.chatID, c.messageID(), c.userID, c.branch(), c.int(0), c.flag(1), c.page())
+	}})
+	register("avail_until", callbackRoute{code: "vu", access: adminOnly, args: "s?i", paged: true, handle: func(c *callbackContext) {
+		availabilityUntil[c.userID] = c.str(0)
+		showAvailabilityBoard(c.chatID, c.messageID(), c.userID, c.branch(), c.page())
+	}})
+	register("avail_all", callbackRoute{code: "va", access: adminOnly, args: "?i", paged: true, handle: func(c *callbackContext) {
+		markAllAvailable(c.chatID, c.messageID(), c.userID, c.branch(), c.page())
+	}})
+
+	// Admin panels
+	register("admin_menu", callbackRoute{code: "am", access: adminOnly, screen: true, handle: func(c *callbackContext) {
+		showAdminMenuManagement(c.chatID)
+	}})
+	register("admin_promo", callbackRoute{code: "aq", access: adminOnly, screen: true, handle: func(c *callbackContext) {
+		showAdminPromoManagement(c.chatID)
+	}})
+	register("admin_info", callbackRoute{code: "ai", access: adminOnly, screen: true, handle: func(c *callbackContext) {
+		showAdminInfoManagement(c.chatID)
+	}})
+	register("admin_category", callbackRoute{code: "ac", access: adminOnly, screen: true, handle: func(c *callbackContext) {
+		showAdminCategoryManagement(c.chatID)
+	}})
+	register("admin_branch", callbackRoute{code: "ab", access: adminOnly, screen: true, handle: func(c *callbackContext) {
+		showAdminBranchManagement(c.chatID, c.userID, c.admin())
+	}})
+
+	// Branch Operations
+	register("branch_pick", callbackRoute{code: "bp", screen: true, handle: func(c *callbackContext) {
+		showBranchPicker(c.chatID, c.userID)
+	}})
+	register("branch_nearest", callbackRoute{code: "bn", handle: func(c *callbackContext) {
+		requestUserLocation(c.chatID)
+	}})
+	register("set_branch", callbackRoute{code: "bs", args: "i", handle: func(c *callbackContext) {
+		if admin := c.admin(); admin.admin && admin.scope != 0 {
+			sendMessage(c.chatID, tm("branch.admin_bound"), nil)
 			return
 		}
-		showAdminMenu(callback.Message.Chat.ID)
-	case "back":
-		if len(parts) > 1 {
-			target := parts[1]
-			switch target {
-			case "admin":
-				showAdminMenu(callback.Message.Chat.ID)
-			case "user":
-				showUserMenu(callback.Message.Chat.ID)
-			case "start":
-				handleStartCommand(&tgbotapi.Message{Chat: &tgbotapi.Chat{ID: callback.Message.Chat.ID}, From: callback.From})
-			}
-		}
+		setUserBranch(c.chatID, c.userID, c.int(0))
+	}})
+	register("branch_read_all", callbackRoute{code: "bl", access: adminOnly, screen: true, handle: func(c *callbackContext) {
+		showBranchList(c.chatID)
+	}})
+	register("branch_create", callbackRoute{code: "bc", access: centralAdmin, handle: func(c *callbackContext) {
+		startAddBranchDialog(c.chatID, c.userID, c.admin())
+	}})
+	register("branch_menu_list", callbackRoute{code: "bm", access: adminOnly, screen: true, handle: func(c *callbackContext) {
+		showBranchMenuList(c.chatID, c.branch())
+	}})
+	register("branch_menu", callbackRoute{code: "bd", access: adminOnly, args: "i", screen: true, handle: func(c *callbackContext) {
+		showBranchMenuDetail(c.chatID, c.branch(), c.int(0))
+	}})
+	register("branch_avail", callbackRoute{code: "ba", access: adminOnly, args: "ib", handle: func(c *callbackContext) {
+		menuID, branchID := c.int(0), c.branch()
+		if setBranchOverride(c.chatID, branchID, menuID, map[string]interface{}{"is_available": c.flag(1)}) {
+			showBranchMenuDetail(c.chatID, branchID, menuID)
+		}
+	}})
+	register("branch_price", callbackRoute{code: "bq", access: adminOnly, args: "i", handle: func(c *callbackContext) {
+		startBranchPriceDialog(c.chatID, c.userID, c.branch(), c.int(0))
+	}})
+	register("branch_reset", callbackRoute{code: "br", access: adminOnly, args: "i", handle: func(c *callbackContext) {
+		resetBranchOverride(c.chatID, c.branch(), c.int(0))
+	}})
+
+	// Menu CRUD Operations
+	register("menu_create", callbackRoute{code: "mn", access: adminOnly, handle: func(c *callbackContext) {
+		startAddMenuDialog(c.chatID, c.userID)
+	}})
+	register("menu_import", callbackRoute{code: "mi", access: adminOnly, handle: func(c *callbackContext) {
+		startMenuImportDialog(c.chatID, c.userID)
+	}})
+	register("menu_import_apply", callbackRoute{code: "mj", access: adminOnly, handle: func(c *callbackContext) {
+		applyMenuImport(c.chatID, c.userID)
+	}})
+	register("menu_import_cancel", callbackRoute{code: "mk", handle: func(c *callbackContext) {
+		delete(userStates, c.userID)
+		delete(userTempData, c.userID)
+		sendMessage(c.chatID, tm("menu_import.cancelled"), nil)
+	}})
+	register("menu_export", callbackRoute{code: "mx", access: adminOnly, args: "?s", handle: func(c *callbackContext) {
+		if format := c.str(0); format != "" {
+			sendMenuExport(c.chatID, format)
+		} else {
+			showMenuExportFormats(c.chatID)
+		}
+	}})
+	for _, list := range []struct{ action, code, operation string }{
+		{"menu_read_all", "lv", "view"},
+		{"menu_update_list", "lu", "update"},
+		{"menu_delete_list", "ld", "delete"},
+		{"menu_recipe_list", "lr", "recipe"},
+		{"menu_options_list", "lo", "options"},
+		{"menu_translate_list", "lt", "translate"},
+	} {
+		list := list
+		register(list.action, callbackRoute{code: list.code, access: adminOnly, args: "?i", screen: true, paged: true,
+			handle: func(c *callbackContext) {
+				showMenuList(c.chatID, list.operation, c.page())
+			}})
 	}
-}
 
-func sendMessage(chatID int64, text string, keyboard interface{}) {
-	msg := tgbotapi.NewMessage(chatID, text)
-	msg.ParseMode = "Markdown"
-	if keyboard != nil {
-		msg.ReplyMarkup = keyboard
+	// Inventory
+	register("admin_inventory", callbackRoute{code: "iv", access: adminOnly, screen: true, handle: func(c *callbackContext) {
+		showInventory(c.chatID, c.branch())
+	}})
+	register("inv_item", callbackRoute{code: "ii", access: adminOnly, args: "i", screen: true, handle: func(c *callbackContext) {
+		showIngredientDetail(c.chatID, c.int(0), c.branch())
+	}})
+	register("inv_create", callbackRoute{code: "ic", access: adminOnly, handle: func(c *callbackContext) {
+		startAddIngredientDialog(c.chatID, c.userID, c.branch())
+	}})
+	register("inv_adjust", callbackRoute{code: "ia", access: adminOnly, args: "i", handle: func(c *callbackContext) {
+		startAdjustStockDialog(c.chatID, c.userID, c.int(0), c.branch())
+	}})
+	register("inv_delete", callbackRoute{code: "id", access: adminOnly, args: "i", handle: func(c *callbackContext) {
+		deleteIngredient(c.chatID, c.int(0), c.branch())
+	}})
+	register("recipe", callbackRoute{code: "rr", access: adminOnly, args: "i?i", screen: true, handle: func(c *callbackContext) {
+		showRecipe(c.chatID, c.int(0), c.int(1))
+	}})
+	register("recipe_set", callbackRoute{code: "rs", access: adminOnly, args: "i?i", handle: func(c *callbackContext) {
+		startSetRecipeDialog(c.chatID, c.userID, c.int(0), c.int(1))
+	}})
+
+	// Variant Management
+	register("menu_options", callbackRoute{code: "mp", access: adminOnly, args: "i", screen: true, handle: func(c *callbackContext) {
+		showAdminMenuOptions(c.chatID, c.int(0))
+	}})
+	register("optgroup_create", callbackRoute{code: "gc", access: adminOnly, args: "i", handle: func(c *callbackContext) {
+		startAddOptionGroupDialog(c.chatID, c.userID, c.int(0))
+	}})
+	register("optgroup_add_option", callbackRoute{code: "ga", access: adminOnly, args: "ii", handle: func(c *callbackContext) {
+		startAddOptionDialog(c.chatID, c.userID, c.int(0), c.int(1))
+	}})
+	register("optgroup_delete", callbackRoute{code: "gd", access: adminOnly, args: "ii", handle: func(c *callbackContext) {
+		deleteOptionGroup(c.chatID, c.int(0), c.int(1))
+	}})
+	register("option_delete", callbackRoute{code: "od", access: adminOnly, args: "ii", handle: func(c *callbackContext) {
+		deleteOption(c.chatID, c.int(0), c.int(1))
+	}})
+
+	// Translations
+	register("tr_show", callbackRoute{code: "ts", access: adminOnly, args: "si", screen: true, handle: func(c *callbackContext) {
+		showTranslations(c.chatID, c.str(0), c.int(1))
+	}})
+	register("tr_edit", callbackRoute{code: "te", access: adminOnly, args: "siss", handle: func(c *callbackContext) {
+		startTranslationDialog(c.chatID, c.userID, c.str(0), c.int(1), c.str(2), c.str(3))
+	}})
+
+	// Promo CRUD Operations
+	register("promo_create", callbackRoute{code: "pn", access: adminOnly, handle: func(c *callbackContext) {
+		startAddPromoDialog(c.chatID, c.userID, c.scope())
+	}})
+	for _, list := range []struct{ action, code, operation string }{
+		{"promo_read_all", "qv", "view"},
+		{"promo_update_list", "qu", "update"},
+		{"promo_delete_list", "qd", "delete"},
+		{"promo_translate_list", "qt", "translate"},
+	} {
+		list := list
+		register(list.action, callbackRoute{code: list.code, access: adminOnly, args: "?i", screen: true, paged: true,
+			handle: func(c *callbackContext) {
+				showPromoList(c.chatID, list.operation, c.scope(), c.page())
+			}})
 	}
-	bot.Send(msg)
-}
 
-func sendMessageWithoutMarkdown(chatID int64, text string, keyboard interface{}) {
-	msg := tgbotapi.NewMessage(chatID, text)
-	if keyboard != nil {
-		msg.ReplyMarkup = keyboard
+	// Category CRUD Operations
+	register("category_create", callbackRoute{code: "cn", access: adminOnly, handle: func(c *callbackContext) {
+		startAddCategoryDialog(c.chatID, c.userID)
+	}})
+	for _, list := range []struct{ action, code, operation string }{
+		{"category_read_all", "kv", "view"},
+		{"category_delete_list", "kd", "delete"},
+	} {
+		list := list
+		register(list.action, callbackRoute{code: list.code, access: adminOnly, args: "?i", screen: true, paged: true,
+			handle: func(c *callbackContext) {
+				showCategoryList(c.chatID, list.operation, c.page())
+			}})
 	}
-	bot.Send(msg)
+
+	// Info CRUD Operations
+	register("info_read", callbackRoute{code: "fr", access: adminOnly, screen: true, handle: func(c *callbackContext) {
+		showCafeInfoDetail(c.chatID, c.branch())
+	}})
+	register("info_update", callbackRoute{code: "fu", access: adminOnly, screen: true, handle: func(c *callbackContext) {
+		startEditCafeInfoDialog(c.chatID, c.userID, c.branch())
+	}})
+	register("edit_info", callbackRoute{code: "fe", access: adminOnly, args: "s", handle: func(c *callbackContext) {
+		startEditCafeInfoField(c.chatID, c.userID, c.branch(), c.str(0))
+	}})
+
+	// Edit and delete from the lists
+	register("add_menu", callbackRoute{code: "ma", access: adminOnly, handle: func(c *callbackContext) {
+		startAddMenuDialog(c.chatID, c.userID)
+	}})
+	register("edit_menu", callbackRoute{code: "me", access: adminOnly, args: "i", handle: func(c *callbackContext) {
+		startEditMenuDialog(c.chatID, c.userID, c.int(0))
+	}})
+	register("confirm_delete_menu", callbackRoute{code: "mr", access: adminOnly, args: "i", screen: true, handle: func(c *callbackContext) {
+		confirmDelete(c.chatID, tm("menu.confirm_delete"), "delete_menu", c.int(0), "menu_delete_list")
+	}})
+	register("delete_menu", callbackRoute{code: "ms", access: adminOnly, args: "i", handle: func(c *callbackContext) {
+		deleteMenu(c.chatID, c.int(0))
+	}})
+	register("edit_promo", callbackRoute{code: "pe", access: adminOnly, args: "i", handle: func(c *callbackContext) {
+		sendMessage(c.chatID, tm("promo.edit_soon", c.int(0)), nil)
+	}})
+	register("confirm_delete_promo", callbackRoute{code: "pr", access: adminOnly, args: "i", screen: true, handle: func(c *callbackContext) {
+		confirmDelete(c.chatID, tm("promo.confirm_delete"), "delete_promo", c.int(0), "promo_delete_list")
+	}})
+	register("delete_promo", callbackRoute{code: "ps", access: adminOnly, args: "i", handle: func(c *callbackContext) {
+		deletePromo(c.chatID, c.int(0))
+	}})
+	register("confirm_delete_category", callbackRoute{code: "cr", access: adminOnly, args: "i", screen: true, handle: func(c *callbackContext) {
+		confirmDelete(c.chatID, tm("category.confirm_delete"), "delete_category", c.int(0), "category_delete_list")
+	}})
+	register("delete_category", callbackRoute{code: "cs", access: adminOnly, args: "i", handle: func(c *callbackContext) {
+		deleteCategory(c.chatID, c.int(0))
+	}})
+}
+
+// confirmDelete asks to confirm deleting a record; cancelling goes back to
+// the list it was picked from
+func confirmDelete(chatID int64, question markup, deleteAction string, id int, listAction string) {
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("common.btn_confirm_delete"), deleteAction, id),
+			button(t("common.btn_cancel"), listAction),
+		),
+	)
+	sendMessage(chatID, question, keyboard)
 }
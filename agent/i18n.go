@@ -0,0 +1,149 @@
+package main
+
+import (
+	"strconv"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var (
+	// currentLocale is the language of the user whose update is being
+	// handled; jobs use the default one
+	currentLocale = shared.DefaultLocale
+
+	// Bahasa pilihan per user lewat /bahasa (cache dari info-service);
+	// kosong berarti mengikuti bahasa Telegram
+	userLanguages = make(map[int64]string)
+)
+
+// languageNames are shown on the /bahasa buttons, each in its own language
+var languageNames = map[string]string{
+	"id": "🇮🇩 Bahasa Indonesia",
+	"en": "🇬🇧 English",
+}
+
+// t returns the catalogue text of key in the current user's language
+func t(key string, args ...interface{}) string {
+	return shared.T(currentLocale, key, args...)
+}
+
+// tn returns the catalogue text of key for count n in the current user's
+// language
+func tn(key string, n int, args ...interface{}) string {
+	return shared.TN(currentLocale, key, n, args...)
+}
+
+// inLocale runs fn with the texts of locale, for messages to someone other
+// than the current user
+func inLocale(locale string, fn func()) {
+	previous := currentLocale
+	currentLocale = locale
+	defer func() { currentLocale = previous }()
+	fn()
+}
+
+// price formats a rupiah amount for the current user
+func price(amount shared.Money) string {
+	return amount.Format(currentLocale)
+}
+
+// money reads an amount from a decoded service response, where it is a
+// JSON number
+func money(value interface{}) shared.Money {
+	amount, _ := shared.MoneyFromPayload(value)
+	return amount
+}
+
+// errorText returns a service error in the current user's language, escaped
+// for message text. Errors without a catalogue key keep the message the
+// service sent.
+func errorText(info *shared.ErrorInfo) markup {
+	if info.Key == "" {
+		return esc(info.Message)
+	}
+	params := make([]interface{}, len(info.Params))
+	for i, param := range info.Params {
+		params[i] = param
+		if name, ok := shared.Lookup(currentLocale, "resource."+param); ok {
+			params[i] = name
+		}
+	}
+	return tm(info.Key, params...)
+}
+
+// userLocale returns the language to talk to user in: the one they picked
+// with /bahasa, otherwise their Telegram language
+func userLocale(user *tgbotapi.User) string {
+	if user == nil {
+		return shared.DefaultLocale
+	}
+	if _, ok := userLanguages[user.ID]; !ok {
+		loadUserPreference(user.ID)
+	}
+	if language := userLanguages[user.ID]; language != "" {
+		return language
+	}
+	return shared.NormalizeLocale(user.LanguageCode)
+}
+
+// loadUserPreference caches the branch and language a user picked, as stored
+// in info-service
+func loadUserPreference(userID int64) {
+	branchID := defaultBranchID
+	language := ""
+	resp, err := cachedPost(infoServiceURL, shared.Request{
+		Action: "get_preference",
+		Payload: map[string]interface{}{
+			"telegram_id": strconv.FormatInt(userID, 10),
+		},
+	})
+	if err == nil && resp.Success {
+		pref, _ := resp.Data.(map[string]interface{})["preference"].(map[string]interface{})
+		if id, ok := pref["branch_id"].(float64); ok && id > 0 {
+			branchID = int(id)
+		}
+		language, _ = pref["language"].(string)
+	}
+
+	userBranches[userID] = branchID
+	userLanguages[userID] = language
+}
+
+func showLanguagePicker(chatID int64) {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, locale := range shared.Locales {
+		label := languageNames[locale]
+		if locale == currentLocale {
+			label = "✅ " + label
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			button(label, "set_lang", locale),
+		))
+	}
+
+	sendMessage(chatID, tm("language.pick"), tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+func setUserLanguage(chatID int64, userID int64, language string) {
+	resp, err := cachedPost(infoServiceURL, shared.Request{
+		Action: "set_preference",
+		Payload: map[string]interface{}{
+			"telegram_id": strconv.FormatInt(userID, 10),
+			"language":    language,
+		},
+	})
+
+	if err != nil || !resp.Success {
+		errMsg := tm("language.failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		sendMessage(chatID, errMsg, nil)
+		return
+	}
+
+	userLanguages[userID] = language
+	currentLocale = language
+	sendMessage(chatID, tm("language.changed", languageNames[language]), nil)
+}
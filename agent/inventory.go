@@ -0,0 +1,495 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const lowStockCheckInterval = 10 * time.Minute
+
+// stockKey is an ingredient in the stock of one branch
+type stockKey struct {
+	branchID, ingredientID int
+}
+
+var (
+	// Bahan yang sudah dilaporkan menipis, agar admin tidak menerima
+	// peringatan yang sama berulang kali
+	lowStockAlerted   = make(map[stockKey]bool)
+	lowStockAlertedMu sync.Mutex
+)
+
+// checkLowStock looks for low ingredients in every branch so manual
+// adjustments and missed order reports still raise an alert. The agent runs
+// it periodically.
+func checkLowStock() {
+	branches, err := fetchBranches()
+	if err != nil {
+		return
+	}
+	for _, item := range branches {
+		branchID := int(item.(map[string]interface{})["id"].(float64))
+		resp, err := cachedPost(menuServiceURL, shared.Request{
+			Action:  "list_ingredients",
+			Payload: map[string]interface{}{"branch_id": branchID, "low_only": true},
+		})
+		if err == nil && resp.Success {
+			ingredients, _ := resp.Data.(map[string]interface{})["ingredients"].([]interface{})
+			alertLowStock(branchID, ingredients, true)
+		}
+	}
+}
+
+// alertLowStock notifies admins about ingredients that just went low in a
+// branch. When complete is set the list holds every low ingredient of the
+// branch, so ingredients missing from it have been restocked and may alert
+// again later.
+func alertLowStock(branchID int, ingredients []interface{}, complete bool) {
+	lowStockAlertedMu.Lock()
+	low := map[stockKey]bool{}
+	var fresh []map[string]interface{}
+	for _, item := range ingredients {
+		ingredient := item.(map[string]interface{})
+		key := stockKey{branchID, int(ingredient["id"].(float64))}
+		low[key] = true
+		if !lowStockAlerted[key] {
+			lowStockAlerted[key] = true
+			fresh = append(fresh, ingredient)
+		}
+	}
+	if complete {
+		for key := range lowStockAlerted {
+			if key.branchID == branchID && !low[key] {
+				delete(lowStockAlerted, key)
+			}
+		}
+	}
+	lowStockAlertedMu.Unlock()
+
+	if len(fresh) == 0 {
+		return
+	}
+
+	text := tm("inventory.low_stock_title", branchName(branchID)) + "\n\n"
+	for _, ingredient := range fresh {
+		text += tm("inventory.low_stock_line", ingredient["name"].(string),
+			formatQuantity(ingredient["stock"].(float64)), ingredient["unit"].(string),
+			formatQuantity(ingredient["low_stock_threshold"].(float64))) + "\n"
+	}
+	text += "\n" + tm("inventory.low_stock_hint")
+	notifyAdmins(text)
+}
+
+// reportStockChanges tells admins about low ingredients of a branch and
+// menus switched on or off by stock, from a menu-service stock report
+func reportStockChanges(branchID int, report map[string]interface{}) {
+	if lowStock, ok := report["low_stock"].([]interface{}); ok {
+		alertLowStock(branchID, lowStock, true)
+	}
+
+	changes, _ := report["availability_changes"].([]interface{})
+	if len(changes) == 0 {
+		return
+	}
+
+	text := tm("inventory.availability_title") + "\n\n"
+	for _, item := range changes {
+		change := item.(map[string]interface{})
+		branch := branchName(int(change["branch_id"].(float64)))
+		if change["is_available"].(bool) {
+			text += tm("inventory.menu_back", change["menu_name"].(string), branch) + "\n"
+		} else {
+			text += tm("inventory.menu_out", change["menu_name"].(string), branch) + "\n"
+		}
+	}
+	notifyAdmins(text)
+}
+
+// formatQuantity prints stock amounts without trailing zeros
+func formatQuantity(quantity float64) string {
+	return strconv.FormatFloat(quantity, 'f', -1, 64)
+}
+
+// ADMIN INVENTORY
+
+func showInventory(chatID int64, branchID int) {
+	resp, err := cachedPost(menuServiceURL, shared.Request{
+		Action:  "list_ingredients",
+		Payload: map[string]interface{}{"branch_id": branchID},
+	})
+
+	if err != nil || !resp.Success {
+		sendMessage(chatID, tm("inventory.load_failed"), nil)
+		return
+	}
+
+	ingredients, _ := resp.Data.(map[string]interface{})["ingredients"].([]interface{})
+	text := tm("inventory.title", branchName(branchID)) + "\n\n"
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+
+	if len(ingredients) == 0 {
+		text += tm("inventory.empty") + "\n"
+	}
+	for _, item := range ingredients {
+		ingredient := item.(map[string]interface{})
+		id := int(ingredient["id"].(float64))
+		name := ingredient["name"].(string)
+
+		status := "✅"
+		if ingredient["is_low"].(bool) {
+			status = "⚠️"
+		}
+		text += md("%s *%s*: %s %s\n", status, name,
+			formatQuantity(ingredient["stock"].(float64)), ingredient["unit"].(string))
+
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			button("📦 "+name, "inv_item", id),
+		))
+	}
+
+	keyboard = append(keyboard,
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("inventory.btn_create"), "inv_create"),
+			button(t("inventory.btn_recipes"), "menu_recipe_list"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("admin.btn_back_panel"), "back", "admin"),
+		),
+	)
+
+	sendMessage(chatID, text, tgbotapi.NewInlineKeyboardMarkup(keyboard...))
+}
+
+func showIngredientDetail(chatID int64, ingredientID, branchID int) {
+	resp, err := cachedPost(menuServiceURL, shared.Request{
+		Action:  "list_ingredients",
+		Payload: map[string]interface{}{"branch_id": branchID},
+	})
+	if err != nil || !resp.Success {
+		sendMessage(chatID, tm("inventory.ingredient_load_failed"), nil)
+		return
+	}
+
+	var ingredient map[string]interface{}
+	ingredients, _ := resp.Data.(map[string]interface{})["ingredients"].([]interface{})
+	for _, item := range ingredients {
+		if int(item.(map[string]interface{})["id"].(float64)) == ingredientID {
+			ingredient = item.(map[string]interface{})
+		}
+	}
+	if ingredient == nil {
+		sendMessage(chatID, tm("inventory.ingredient_not_found"), nil)
+		return
+	}
+
+	unit := ingredient["unit"].(string)
+	text := md("📦 *%s*\n\n", ingredient["name"].(string))
+	text += tm("inventory.detail_stock", formatQuantity(ingredient["stock"].(float64)), unit) + "\n"
+	text += tm("inventory.detail_threshold", formatQuantity(ingredient["low_stock_threshold"].(float64)), unit) + "\n"
+
+	movementsResp, err := cachedPost(menuServiceURL, shared.Request{
+		Action: "list_stock_movements",
+		Payload: map[string]interface{}{
+			"ingredient_id": ingredientID,
+			"branch_id":     branchID,
+			"limit":         5,
+		},
+	})
+	if err == nil && movementsResp.Success {
+		movements, _ := movementsResp.Data.(map[string]interface{})["movements"].([]interface{})
+		if len(movements) > 0 {
+			text += "\n" + tm("inventory.history_title") + "\n"
+		}
+		for _, item := range movements {
+			movement := item.(map[string]interface{})
+			change := movement["change"].(float64)
+			sign := ""
+			if change > 0 {
+				sign = "+"
+			}
+			reason := movement["reason"].(string)
+			switch reason {
+			case "order":
+				reason = t("inventory.reason_order_plain")
+				if ref, ok := movement["reference"].(string); ok {
+					reason = t("inventory.reason_order", strings.TrimPrefix(ref, "order:"))
+				}
+			case "initial":
+				reason = t("inventory.reason_initial")
+			}
+			text += md("• %s%s %s — %s\n", sign, formatQuantity(change), unit, reason)
+		}
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("inventory.btn_adjust"), "inv_adjust", ingredientID),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("inventory.btn_delete"), "inv_delete", ingredientID),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			backButton("admin_inventory"),
+		),
+	)
+
+	sendMessage(chatID, text, keyboard)
+}
+
+func startAddIngredientDialog(chatID int64, userID int64, branchID int) {
+	userStates[userID] = "add_ingredient"
+	userTempData[userID] = map[string]interface{}{
+		"branch_id": branchID,
+	}
+	sendMessage(chatID, tm("inventory.create_prompt")+"\n\n"+tm("common.cancel_hint"), nil)
+}
+
+func handleAddIngredient(msg *tgbotapi.Message, userID int64) {
+	parts := strings.Split(msg.Text, "|")
+	if len(parts) != 4 {
+		sendMessage(msg.Chat.ID, tm("inventory.create_format_invalid"), nil)
+		return
+	}
+
+	stock, errStock := strconv.ParseFloat(strings.TrimSpace(parts[2]), 64)
+	threshold, errThreshold := strconv.ParseFloat(strings.TrimSpace(parts[3]), 64)
+	if errStock != nil || errThreshold != nil {
+		sendMessage(msg.Chat.ID, tm("inventory.create_numbers_invalid"), nil)
+		return
+	}
+
+	branchID := userTempData[userID]["branch_id"].(int)
+	resp, err := cachedPost(menuServiceURL, shared.Request{
+		Action: "create_ingredient",
+		Payload: map[string]interface{}{
+			"branch_id":           branchID,
+			"name":                strings.TrimSpace(parts[0]),
+			"unit":                strings.TrimSpace(parts[1]),
+			"stock":               stock,
+			"low_stock_threshold": threshold,
+		},
+	})
+
+	if err != nil || !resp.Success {
+		errMsg := tm("inventory.create_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		sendMessage(msg.Chat.ID, errMsg, nil)
+		return
+	}
+
+	delete(userStates, userID)
+	delete(userTempData, userID)
+	sendMessage(msg.Chat.ID, tm("inventory.created"), nil)
+	showInventory(msg.Chat.ID, branchID)
+}
+
+func startAdjustStockDialog(chatID int64, userID int64, ingredientID, branchID int) {
+	userStates[userID] = "adjust_stock"
+	userTempData[userID] = map[string]interface{}{
+		"ingredient_id": ingredientID,
+		"branch_id":     branchID,
+	}
+	sendMessage(chatID, tm("inventory.adjust_prompt")+"\n\n"+tm("common.cancel_hint"), nil)
+}
+
+func handleAdjustStock(msg *tgbotapi.Message, userID int64) {
+	fields := strings.Fields(msg.Text)
+	if len(fields) < 2 {
+		sendMessage(msg.Chat.ID, tm("inventory.adjust_format_invalid"), nil)
+		return
+	}
+
+	change, err := strconv.ParseFloat(strings.TrimPrefix(fields[0], "+"), 64)
+	if err != nil || change == 0 {
+		sendMessage(msg.Chat.ID, tm("inventory.adjust_amount_invalid"), nil)
+		return
+	}
+
+	ingredientID := userTempData[userID]["ingredient_id"].(int)
+	branchID := userTempData[userID]["branch_id"].(int)
+	resp, err := cachedPost(menuServiceURL, shared.Request{
+		Action: "adjust_stock",
+		Payload: map[string]interface{}{
+			"ingredient_id": ingredientID,
+			"branch_id":     branchID,
+			"change":        change,
+			"reason":        strings.Join(fields[1:], " "),
+		},
+	})
+
+	if err != nil || !resp.Success {
+		errMsg := tm("inventory.adjust_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		sendMessage(msg.Chat.ID, errMsg, nil)
+		return
+	}
+
+	delete(userStates, userID)
+	delete(userTempData, userID)
+
+	report := resp.Data.(map[string]interface{})
+	if ingredient, ok := report["ingredient"].(map[string]interface{}); ok && ingredient["is_low"].(bool) {
+		alertLowStock(branchID, []interface{}{ingredient}, false)
+	}
+	reportStockChanges(branchID, report)
+
+	sendMessage(msg.Chat.ID, tm("inventory.adjusted"), nil)
+	showIngredientDetail(msg.Chat.ID, ingredientID, branchID)
+}
+
+func deleteIngredient(chatID int64, ingredientID, branchID int) {
+	resp, err := cachedPost(menuServiceURL, shared.Request{
+		Action: "delete_ingredient",
+		Payload: map[string]interface{}{
+			"id": ingredientID,
+		},
+	})
+
+	if err != nil || !resp.Success {
+		sendMessage(chatID, tm("inventory.delete_failed"), nil)
+		return
+	}
+
+	reportStockChanges(branchID, resp.Data.(map[string]interface{}))
+	sendMessage(chatID, tm("inventory.deleted"), nil)
+	showInventory(chatID, branchID)
+}
+
+// ADMIN RECIPES
+
+// recipePayload selects the recipe of a menu, or the ingredients one of its
+// options adds when optionID is set
+func recipePayload(menuID, optionID int) map[string]interface{} {
+	if optionID != 0 {
+		return map[string]interface{}{"option_id": optionID}
+	}
+	return map[string]interface{}{"menu_id": menuID}
+}
+
+// showRecipe shows the recipe of a menu, or of one of its options when
+// optionID is set
+func showRecipe(chatID int64, menuID, optionID int) {
+	resp, err := cachedPost(menuServiceURL, shared.Request{
+		Action:  "get_recipe",
+		Payload: recipePayload(menuID, optionID),
+	})
+
+	if err != nil || !resp.Success {
+		sendMessage(chatID, tm("inventory.recipe_load_failed"), nil)
+		return
+	}
+
+	recipe, _ := resp.Data.(map[string]interface{})["recipe"].([]interface{})
+	title, empty, back := tm("inventory.recipe_title"), tm("inventory.recipe_empty"), backButton("menu_recipe_list")
+	if optionID != 0 {
+		title, empty, back = tm("inventory.option_recipe_title"), tm("inventory.option_recipe_empty"), backButton("menu_options", menuID)
+	}
+	text := title + "\n\n"
+	if len(recipe) == 0 {
+		text += empty + "\n"
+	}
+	for _, item := range recipe {
+		line := item.(map[string]interface{})
+		text += md("• %s: %s %s\n", line["ingredient_name"].(string),
+			formatQuantity(line["quantity"].(float64)), line["unit"].(string))
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("inventory.btn_set_recipe"), "recipe_set", menuID, optionID),
+		),
+		tgbotapi.NewInlineKeyboardRow(back),
+	)
+
+	sendMessage(chatID, text, keyboard)
+}
+
+func startSetRecipeDialog(chatID int64, userID int64, menuID, optionID int) {
+	userStates[userID] = "set_recipe"
+	userTempData[userID] = map[string]interface{}{
+		"menu_id":   menuID,
+		"option_id": optionID,
+	}
+	sendMessage(chatID, tm("inventory.recipe_prompt")+"\n"+tm("common.cancel_hint"), nil)
+}
+
+func handleSetRecipe(msg *tgbotapi.Message, userID int64) {
+	menuID := userTempData[userID]["menu_id"].(int)
+	optionID := userTempData[userID]["option_id"].(int)
+
+	resp, err := cachedPost(menuServiceURL, shared.Request{
+		Action:  "list_ingredients",
+		Payload: map[string]interface{}{},
+	})
+	if err != nil || !resp.Success {
+		sendMessage(msg.Chat.ID, tm("inventory.ingredients_load_failed"), nil)
+		return
+	}
+
+	byName := map[string]int{}
+	ingredients, _ := resp.Data.(map[string]interface{})["ingredients"].([]interface{})
+	for _, item := range ingredients {
+		ingredient := item.(map[string]interface{})
+		byName[strings.ToLower(ingredient["name"].(string))] = int(ingredient["id"].(float64))
+	}
+
+	items := []map[string]interface{}{}
+	if strings.TrimSpace(msg.Text) != "-" {
+		for _, line := range strings.Split(msg.Text, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			i := strings.LastIndex(line, " ")
+			if i <= 0 {
+				sendMessage(msg.Chat.ID, tm("inventory.recipe_line_invalid", line), nil)
+				return
+			}
+			quantity, err := strconv.ParseFloat(line[i+1:], 64)
+			if err != nil || quantity <= 0 {
+				sendMessage(msg.Chat.ID, tm("inventory.recipe_amount_invalid", line), nil)
+				return
+			}
+			ingredientID, ok := byName[strings.ToLower(strings.TrimSpace(line[:i]))]
+			if !ok {
+				sendMessage(msg.Chat.ID, tm("inventory.recipe_ingredient_unknown", strings.TrimSpace(line[:i])), nil)
+				return
+			}
+			items = append(items, map[string]interface{}{
+				"ingredient_id": ingredientID,
+				"quantity":      quantity,
+			})
+		}
+	}
+
+	payload := recipePayload(menuID, optionID)
+	payload["items"] = items
+	setResp, err := cachedPost(menuServiceURL, shared.Request{
+		Action:  "set_recipe",
+		Payload: payload,
+	})
+
+	if err != nil || !setResp.Success {
+		errMsg := tm("inventory.recipe_save_failed")
+		if setResp != nil && setResp.Error != nil {
+			errMsg += "\n" + errorText(setResp.Error)
+		}
+		sendMessage(msg.Chat.ID, errMsg, nil)
+		return
+	}
+
+	delete(userStates, userID)
+	delete(userTempData, userID)
+	reportStockChanges(0, setResp.Data.(map[string]interface{}))
+	sendMessage(msg.Chat.ID, tm("inventory.recipe_saved"), nil)
+	showRecipe(msg.Chat.ID, menuID, optionID)
+}
@@ -1,10 +1,13 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"log"
+	"net/http"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/alrescha79-cmd/bot-cafe/shared"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -12,21 +15,41 @@ import (
 )
 
 var (
-	bot            *tgbotapi.BotAPI
+	bot *tgbotapi.BotAPI
+
+	// httpClient makes the service calls of the update or job being handled
+	// and carries its request ID; baseHTTPClient is the client it wraps
 	httpClient     *shared.HTTPClient
+	baseHTTPClient *shared.HTTPClient
+
+	// requestCtx carries the request ID and log fields of the update or job
+	// being handled
+	requestCtx = context.Background()
+
 	adminIDs       []string
 	adminUsernames []string
 
 	// Service URLs
-	authServiceURL  string
-	menuServiceURL  string
-	promoServiceURL string
-	infoServiceURL  string
-	mediaServiceURL string
+	authServiceURL   string
+	menuServiceURL   string
+	promoServiceURL  string
+	infoServiceURL   string
+	mediaServiceURL  string
+	orderServiceURL  string
+	reportServiceURL string
+	backupServiceURL string
+
+	reservationServiceURL string
 
 	// User states untuk dialog CRUD
 	userStates   = make(map[int64]string)
 	userTempData = make(map[int64]map[string]interface{})
+
+	// Cabang terpilih per user (cache dari info-service)
+	userBranches = make(map[int64]int)
+
+	// Pilihan varian/topping yang sedang berlangsung per user
+	userSelections = make(map[int64]*menuSelection)
 )
 
 type VarsConfig struct {
@@ -37,10 +60,16 @@ type VarsConfig struct {
 func main() {
 	// Load environment variables
 	godotenv.Load()
+	shared.ConfigureLogger("agent")
+	shared.ConfigureTracing("agent")
+
+	// The bot library logs API errors with request URLs, which hold the
+	// token; route them through the redacting logger
+	tgbotapi.SetLogger(log.Default())
 
 	// Load admin vars
 	if err := loadAdminVars(); err != nil {
-		log.Printf("Warning: Failed to load admin vars: %v", err)
+		shared.Logger(requestCtx).Warn("failed to load admin vars", "error", err)
 	}
 
 	// Get bot token
@@ -50,24 +79,38 @@ func main() {
 	}
 
 	// Get service URLs
-	authServiceURL = getEnv("AUTH_SERVICE_URL", "http://localhost:8081")
-	menuServiceURL = getEnv("MENU_SERVICE_URL", "http://localhost:8082")
-	promoServiceURL = getEnv("PROMO_SERVICE_URL", "http://localhost:8083")
-	infoServiceURL = getEnv("INFO_SERVICE_URL", "http://localhost:8084")
-	mediaServiceURL = getEnv("MEDIA_SERVICE_URL", "http://localhost:8085")
+	authServiceURL = shared.ServiceURL("auth-service")
+	menuServiceURL = shared.ServiceURL("menu-service")
+	promoServiceURL = shared.ServiceURL("promo-service")
+	infoServiceURL = shared.ServiceURL("info-service")
+	mediaServiceURL = shared.ServiceURL("media-service")
+	orderServiceURL = shared.ServiceURL("order-service")
+	reportServiceURL = shared.ServiceURL("report-service")
+	backupServiceURL = shared.ServiceURL("backup-service")
+	reservationServiceURL = shared.ServiceURL("reservation-service")
+
+	// Key signing button callback data
+	initCallbackKey()
+
+	// Cache menus and café info; services post their events to /events
+	initCache()
+
+	// Expose metrics for Prometheus
+	go serveMetrics(getEnv("AGENT_PORT", "8080"))
 
 	// Initialize bot
 	var err error
-	bot, err = tgbotapi.NewBotAPI(token)
+	bot, err = tgbotapi.NewBotAPIWithClient(token, tgbotapi.APIEndpoint, &telegramClient{client: &http.Client{}})
 	if err != nil {
 		log.Fatalf("Failed to create bot: %v", err)
 	}
 
 	bot.Debug = false
-	shared.LogInfo("Authorized on account %s", bot.Self.UserName)
+	shared.Logger(requestCtx).Info("authorized", "account", bot.Self.UserName)
 
 	// Initialize HTTP client
-	httpClient = shared.NewHTTPClient()
+	baseHTTPClient = shared.NewHTTPClient()
+	httpClient = baseHTTPClient
 
 	// Configure updates
 	u := tgbotapi.NewUpdate(0)
@@ -75,16 +118,107 @@ func main() {
 
 	updates := bot.GetUpdatesChan(u)
 
-	// Handle updates
-	for update := range updates {
-		if update.Message != nil {
+	// Alert admins when ingredients run low
+	lowStockTicker := time.NewTicker(lowStockCheckInterval)
+	defer lowStockTicker.Stop()
+	runJob("low_stock", checkLowStock)
+
+	// Remind customers of their bookings
+	reminderTicker := time.NewTicker(reservationReminderInterval)
+	defer reminderTicker.Stop()
+
+	// Updates and jobs are handled one at a time, each with its own request
+	// context
+	for {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				return
+			}
+			handleUpdate(update)
+		case <-lowStockTicker.C:
+			runJob("low_stock", checkLowStock)
+		case <-reminderTicker.C:
+			runJob("reservation_reminders", sendReservationReminders)
+		case branchID := <-deletedBranches:
+			runJob("forget_branch", func() { forgetBranch(branchID) })
+		}
+	}
+}
+
+// handleUpdate handles a message or button press. Its logs and the service
+// calls it makes share one request ID and one trace, so it can be followed
+// through every service.
+func handleUpdate(update tgbotapi.Update) {
+	switch {
+	case update.Message != nil:
+		action := "message"
+		if update.Message.IsCommand() {
+			action = "/" + update.Message.Command()
+		} else if state := userStates[update.Message.Chat.ID]; state != "" {
+			action = "dialog:" + state
+		}
+		fields := []interface{}{"update_id", update.UpdateID, "chat_id", update.Message.Chat.ID}
+		runWithContext(action, fields, func() {
+			currentLocale = userLocale(update.Message.From)
 			handleMessage(update.Message)
-		} else if update.CallbackQuery != nil {
-			handleCallback(update.CallbackQuery)
+			notifyOutage(update.Message.Chat.ID)
+		})
+	case update.CallbackQuery != nil:
+		chatID := update.CallbackQuery.From.ID
+		if update.CallbackQuery.Message != nil {
+			chatID = update.CallbackQuery.Message.Chat.ID
 		}
+		action := callbackAction(update.CallbackQuery.Data)
+		fields := []interface{}{"update_id", update.UpdateID, "chat_id", chatID}
+		runWithContext("callback:"+action, fields, func() {
+			currentLocale = userLocale(update.CallbackQuery.From)
+			handleCallback(update.CallbackQuery)
+			notifyOutage(chatID)
+		})
+	}
+}
+
+// notifyOutage tells the user when a service call of the current update was
+// refused because the service keeps failing, so they know to try again later
+// instead of only seeing a generic error
+func notifyOutage(chatID int64) {
+	if httpClient.RefusedByBreaker() {
+		sendMessage(chatID, tm("common.outage"), nil)
 	}
 }
 
+// runJob runs a periodic job with its own request context
+func runJob(name string, job func()) {
+	runWithContext("job:"+name, nil, job)
+}
+
+// runWithContext runs fn with a new request context: a request ID, a trace
+// and the action and fields for logs and spans. It then logs and records how
+// long fn took.
+func runWithContext(action string, fields []interface{}, fn func()) {
+	start := time.Now()
+	ctx := shared.WithLogFields(context.Background(), append([]interface{}{"action", action}, fields...)...)
+	ctx = shared.WithRequestID(ctx, shared.NewRequestID())
+	ctx, span := shared.StartSpan(ctx, action, shared.SpanKindServer)
+	span.SetAttributes(fields...)
+	span.SetAttributes("request_id", shared.RequestIDFromContext(ctx))
+
+	requestCtx = ctx
+	httpClient = baseHTTPClient.WithContext(ctx)
+	defer func() {
+		duration := time.Since(start)
+		recordUpdate(action, duration)
+		span.Finish()
+		shared.Logger(requestCtx).Info("handled", "duration_ms", duration.Milliseconds())
+		requestCtx = context.Background()
+		httpClient = baseHTTPClient
+		currentLocale = shared.DefaultLocale
+	}()
+
+	fn()
+}
+
 func loadAdminVars() error {
 	varsFile := getEnv("ADMIN_VARS_FILE", ".vars.json")
 
@@ -111,49 +245,110 @@ func loadAdminVars() error {
 
 	adminIDs = config.AdminTelegramIDs
 	adminUsernames = config.AdminUsernames
-	shared.LogInfo("Loaded %d admin IDs and %d admin usernames from %s", len(adminIDs), len(adminUsernames), varsFile)
+	shared.Logger(requestCtx).Info("loaded admins", "ids", len(adminIDs), "usernames", len(adminUsernames), "file", varsFile)
 	return nil
 }
 
-func isAdmin(userID int64, username string) bool {
-	userIDStr := strconv.FormatInt(userID, 10)
+// noBranchAccess is the scope of an admin whose branches auth-service could
+// not tell; callers deny such admins any access
+const noBranchAccess = -1
 
-	shared.LogInfo("[AUTH] Checking admin status for user %d (@%s)", userID, username)
-	shared.LogInfo("[AUTH] Loaded admin IDs: %v", adminIDs)
-	shared.LogInfo("[AUTH] Loaded admin usernames: %v", adminUsernames)
+// adminRecord is what a user may do as an admin. Looking it up asks
+// auth-service, so an update looks it up once and passes it down.
+type adminRecord struct {
+	admin bool
+	owner bool
+	// scope is the branch the admin is limited to, 0 when the admin may
+	// manage every branch, or noBranchAccess when auth-service does not tell
+	scope int
+}
 
-	// Check in vars
-	for _, id := range adminIDs {
-		shared.LogInfo("[AUTH] Comparing userID '%s' with admin ID '%s'", userIDStr, id)
-		if id == userIDStr {
-			shared.LogInfo("[AUTH] ✅ MATCH! User %d is admin (by ID)", userID)
-			return true
-		}
-	}
-	for _, un := range adminUsernames {
-		shared.LogInfo("[AUTH] Comparing username '%s' with admin username '%s'", username, un)
-		if un == username {
-			shared.LogInfo("[AUTH] ✅ MATCH! User @%s is admin (by username)", username)
-			return true
-		}
+// lookupAdmin reads the admin record of a user. Admins from the vars file
+// are always owners.
+func lookupAdmin(userID int64, username string) adminRecord {
+	userIDStr := strconv.FormatInt(userID, 10)
+	if shared.Contains(adminIDs, userIDStr) || (username != "" && shared.Contains(adminUsernames, username)) {
+		return adminRecord{admin: true, owner: true}
 	}
 
-	// Verify with auth service
-	shared.LogInfo("[AUTH] No match in vars, checking auth service...")
 	resp, err := httpClient.Post(authServiceURL, shared.Request{
 		Action: "verify",
 		Payload: map[string]interface{}{
 			"telegram_id": userIDStr,
 		},
 	})
+	if err != nil {
+		shared.Logger(requestCtx).Warn("admin check failed", "error", err)
+		return adminRecord{scope: noBranchAccess}
+	}
+	if !resp.Success {
+		return adminRecord{scope: noBranchAccess}
+	}
+
+	record := adminRecord{admin: true, scope: noBranchAccess}
+	data, _ := resp.Data.(map[string]interface{})
+	admin, _ := data["admin"].(map[string]interface{})
+	if role, _ := admin["role"].(string); role == "owner" {
+		record.owner = true
+		record.scope = 0
+	} else if branchID, ok := admin["branch_id"].(float64); ok && branchID > 0 {
+		record.scope = int(branchID)
+	}
+	return record
+}
+
+// canManage reports whether the admin may manage a branch at all
+func (r adminRecord) canManage() bool {
+	return r.admin && r.scope != noBranchAccess
+}
 
+// branch returns the branch the admin is working on: their own branch for
+// managers, otherwise the branch they picked
+func (r adminRecord) branch(userID int64) int {
+	if r.scope != 0 {
+		return r.scope
+	}
+	return getUserBranch(userID)
+}
+
+func isAdmin(userID int64, username string) bool {
+	return lookupAdmin(userID, username).admin
+}
+
+// isOwner reports whether an admin may manage the whole café: admins from the
+// vars file and auth-service admins with the owner role
+func isOwner(userID int64, username string) bool {
+	return lookupAdmin(userID, username).owner
+}
+
+// notifyAdmins sends a message to every admin from the vars file and every
+// active admin registered in auth-service
+func notifyAdmins(text markup) {
+	recipients := map[int64]bool{}
+	for _, id := range adminIDs {
+		if chatID, err := strconv.ParseInt(id, 10, 64); err == nil {
+			recipients[chatID] = true
+		}
+	}
+
+	resp, err := httpClient.Post(authServiceURL, shared.Request{Action: "list"})
 	if err == nil && resp.Success {
-		shared.LogInfo("[AUTH] ✅ User %d is admin (verified by auth service)", userID)
-		return true
+		admins, _ := resp.Data.(map[string]interface{})["admins"].([]interface{})
+		for _, item := range admins {
+			admin := item.(map[string]interface{})
+			if active, _ := admin["is_active"].(bool); !active {
+				continue
+			}
+			telegramID, _ := admin["telegram_id"].(string)
+			if chatID, err := strconv.ParseInt(telegramID, 10, 64); err == nil {
+				recipients[chatID] = true
+			}
+		}
 	}
 
-	shared.LogInfo("[AUTH] ❌ User %d (@%s) is NOT admin", userID, username)
-	return false
+	for chatID := range recipients {
+		sendMessage(chatID, text, nil)
+	}
 }
 
 func getEnv(key, defaultValue string) string {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/alrescha79-cmd/bot-cafe/shared"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -14,44 +15,72 @@ import (
 func showAdminMenu(chatID int64) {
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📋 Kelola Menu", "admin_menu"),
+			button(t("admin.btn_orders"), "admin_orders"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🎉 Kelola Promo", "admin_promo"),
+			button(t("admin.btn_reservations"), "admin_reservations"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("ℹ️ Kelola Info Café", "admin_info"),
+			button(t("admin.btn_availability"), "avail_board"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📁 Kelola Kategori", "admin_category"),
+			button(t("admin.btn_reports"), "admin_report"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🏠 Kembali ke Menu Utama", "back:start"),
+			button(t("admin.btn_menu"), "admin_menu"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("admin.btn_inventory"), "admin_inventory"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("admin.btn_promo"), "admin_promo"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("admin.btn_info"), "admin_info"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("admin.btn_category"), "admin_category"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("admin.btn_branch"), "admin_branch"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("common.btn_home"), "back", "start"),
 		),
 	)
 
-	sendMessage(chatID, "👨‍💼 *Panel Admin*\n\nPilih menu:", keyboard)
+	sendMessage(chatID, tm("admin.title"), keyboard)
 }
 
 func showAdminMenuManagement(chatID int64) {
-	text := "📋 *Manajemen Menu*\n\n"
-	text += "Pilih operasi yang ingin dilakukan:\n\n"
+	text := tm("admin.menu_title") + "\n\n"
+	text += tm("admin.choose_operation") + "\n\n"
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("➕ Tambah Menu Baru", "menu_create"),
+			button(t("admin.btn_menu_create"), "menu_create"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("admin.btn_menu_list"), "menu_read_all"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("admin.btn_menu_update"), "menu_update_list"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📖 Lihat Semua Menu", "menu_read_all"),
+			button(t("admin.btn_menu_delete"), "menu_delete_list"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("✏️ Edit Menu", "menu_update_list"),
+			button(t("admin.btn_menu_options"), "menu_options_list"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🗑️ Hapus Menu", "menu_delete_list"),
+			button(t("admin.btn_menu_translate"), "menu_translate_list"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("⬅️ Kembali ke Panel Admin", "back:admin"),
+			button(t("admin.btn_menu_import"), "menu_import"),
+			button(t("admin.btn_menu_export"), "menu_export"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("admin.btn_back_panel"), "back", "admin"),
 		),
 	)
 
@@ -59,24 +88,27 @@ func showAdminMenuManagement(chatID int64) {
 }
 
 func showAdminPromoManagement(chatID int64) {
-	text := "🎉 *Manajemen Promo*\n\n"
-	text += "Pilih operasi yang ingin dilakukan:\n\n"
+	text := tm("admin.promo_title") + "\n\n"
+	text += tm("admin.choose_operation") + "\n\n"
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("➕ Tambah Promo Baru", "promo_create"),
+			button(t("admin.btn_promo_create"), "promo_create"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("admin.btn_promo_list"), "promo_read_all"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📖 Lihat Semua Promo", "promo_read_all"),
+			button(t("admin.btn_promo_update"), "promo_update_list"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("✏️ Edit Promo", "promo_update_list"),
+			button(t("admin.btn_promo_delete"), "promo_delete_list"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🗑️ Hapus Promo", "promo_delete_list"),
+			button(t("admin.btn_promo_translate"), "promo_translate_list"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("⬅️ Kembali ke Panel Admin", "back:admin"),
+			button(t("admin.btn_back_panel"), "back", "admin"),
 		),
 	)
 
@@ -84,18 +116,18 @@ func showAdminPromoManagement(chatID int64) {
 }
 
 func showAdminInfoManagement(chatID int64) {
-	text := "ℹ️ *Manajemen Info Café*\n\n"
-	text += "Pilih operasi yang ingin dilakukan:\n\n"
+	text := tm("admin.info_title") + "\n\n"
+	text += tm("admin.choose_operation") + "\n\n"
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📖 Lihat Info Café", "info_read"),
+			button(t("admin.btn_info_read"), "info_read"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("✏️ Edit Info Café", "info_update"),
+			button(t("admin.btn_info_update"), "info_update"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("⬅️ Kembali ke Panel Admin", "back:admin"),
+			button(t("admin.btn_back_panel"), "back", "admin"),
 		),
 	)
 
@@ -103,21 +135,21 @@ func showAdminInfoManagement(chatID int64) {
 }
 
 func showAdminCategoryManagement(chatID int64) {
-	text := "📁 *Manajemen Kategori Menu*\n\n"
-	text += "Pilih operasi yang ingin dilakukan:\n\n"
+	text := tm("admin.category_title") + "\n\n"
+	text += tm("admin.choose_operation") + "\n\n"
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("➕ Tambah Kategori Baru", "category_create"),
+			button(t("admin.btn_category_create"), "category_create"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📖 Lihat Semua Kategori", "category_read_all"),
+			button(t("admin.btn_category_list"), "category_read_all"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🗑️ Hapus Kategori", "category_delete_list"),
+			button(t("admin.btn_category_delete"), "category_delete_list"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("⬅️ Kembali ke Panel Admin", "back:admin"),
+			button(t("admin.btn_back_panel"), "back", "admin"),
 		),
 	)
 
@@ -126,26 +158,54 @@ func showAdminCategoryManagement(chatID int64) {
 
 // READ Operations - List Views
 
-func showMenuList(chatID int64, forOperation string) {
-	resp, err := httpClient.Post(menuServiceURL, shared.Request{
+// The list screen of each operation, for its page buttons
+var (
+	menuListScreens = map[string]string{
+		"view":      "menu_read_all",
+		"update":    "menu_update_list",
+		"delete":    "menu_delete_list",
+		"recipe":    "menu_recipe_list",
+		"options":   "menu_options_list",
+		"translate": "menu_translate_list",
+	}
+	promoListScreens = map[string]string{
+		"view":      "promo_read_all",
+		"update":    "promo_update_list",
+		"delete":    "promo_delete_list",
+		"translate": "promo_translate_list",
+	}
+	categoryListScreens = map[string]string{
+		"view":   "category_read_all",
+		"delete": "category_delete_list",
+	}
+)
+
+func showMenuList(chatID int64, forOperation string, page int) {
+	resp, err := cachedPost(menuServiceURL, shared.Request{
 		Action:  "list",
-		Payload: map[string]interface{}{},
+		Payload: pagePayload(map[string]interface{}{}, page),
 	})
 
 	if err != nil || !resp.Success {
-		sendMessage(chatID, "⚠️ Gagal memuat menu.", nil)
+		sendMessage(chatID, tm("menu.load_failed"), nil)
 		return
 	}
 
-	menusData, ok := resp.Data.(map[string]interface{})["menus"].([]interface{})
-	text := "📋 *Daftar Menu*\n\n"
+	data := resp.Data.(map[string]interface{})
+	menusData, ok := data["menus"].([]interface{})
+	if len(menusData) == 0 && page > 0 {
+		// The page emptied since it was shown, e.g. after a delete
+		showMenuList(chatID, forOperation, pageCount(listTotal(data, menusData))-1)
+		return
+	}
+	text := tm("admin.menu_list_title") + "\n\n"
 	var keyboard [][]tgbotapi.InlineKeyboardButton
 
 	if ok && len(menusData) > 0 {
 		for _, item := range menusData {
 			menu := item.(map[string]interface{})
 			name := menu["name"].(string)
-			price := int(menu["price"].(float64))
+			menuPrice := money(menu["price"])
 			id := int(menu["id"].(float64))
 			available := menu["is_available"].(bool)
 			category := menu["category"].(string)
@@ -155,43 +215,66 @@ func showMenuList(chatID int64, forOperation string) {
 				status = "❌"
 			}
 
-			text += fmt.Sprintf("%s *%s*\n", status, name)
-			text += fmt.Sprintf("   💰 %s | 📁 %s\n\n", shared.FormatPrice(price), category)
+			text += md("%s *%s*\n", status, name)
+			text += md("   💰 %s | 📁 %s\n\n", price(menuPrice), category)
 
 			if forOperation == "update" {
 				keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
-					tgbotapi.NewInlineKeyboardButtonData("✏️ Edit "+name, fmt.Sprintf("edit_menu:%d", id)),
+					button(t("common.btn_edit", name), "edit_menu", id),
 				))
 			} else if forOperation == "delete" {
 				keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
-					tgbotapi.NewInlineKeyboardButtonData("🗑️ Hapus "+name, fmt.Sprintf("confirm_delete_menu:%d", id)),
+					button(t("common.btn_delete", name), "confirm_delete_menu", id),
+				))
+			} else if forOperation == "options" {
+				keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+					button("🧩 "+name, "menu_options", id),
+				))
+			} else if forOperation == "recipe" {
+				keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+					button("🧾 "+name, "recipe", id),
+				))
+			} else if forOperation == "translate" {
+				keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+					button("🌐 "+name, "tr_show", "menu", id),
 				))
 			}
 		}
 	} else {
-		text += "Belum ada menu.\n"
+		text += tm("admin.menu_list_empty") + "\n"
 	}
 
+	pageLine, pageRow := pageFooter(page, listTotal(data, menusData), menuListScreens[forOperation])
+	text += pageLine
+	if pageRow != nil {
+		keyboard = append(keyboard, pageRow)
+	}
 	keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("⬅️ Kembali", "admin_menu"),
+		backButton("admin_menu"),
 	))
 
 	sendMessage(chatID, text, tgbotapi.NewInlineKeyboardMarkup(keyboard...))
 }
 
-func showPromoList(chatID int64, forOperation string) {
+func showPromoList(chatID int64, forOperation string, branchID int, page int) {
 	resp, err := httpClient.Post(promoServiceURL, shared.Request{
 		Action:  "list",
-		Payload: map[string]interface{}{"active_only": false},
+		Payload: pagePayload(map[string]interface{}{"active_only": false, "branch_id": branchID}, page),
 	})
 
 	if err != nil || !resp.Success {
-		sendMessage(chatID, "⚠️ Gagal memuat promo.", nil)
+		sendMessage(chatID, tm("promo.load_failed"), nil)
 		return
 	}
 
-	promosData, ok := resp.Data.(map[string]interface{})["promos"].([]interface{})
-	text := "🎉 *Daftar Promo*\n\n"
+	data := resp.Data.(map[string]interface{})
+	promosData, ok := data["promos"].([]interface{})
+	if len(promosData) == 0 && page > 0 {
+		// The page emptied since it was shown, e.g. after a delete
+		showPromoList(chatID, forOperation, branchID, pageCount(listTotal(data, promosData))-1)
+		return
+	}
+	text := tm("admin.promo_list_title") + "\n\n"
 	var keyboard [][]tgbotapi.InlineKeyboardButton
 
 	if ok && len(promosData) > 0 {
@@ -202,52 +285,72 @@ func showPromoList(chatID int64, forOperation string) {
 			discount := int(promo["discount"].(float64))
 			discountType := promo["discount_type"].(string)
 			isActive := promo["is_active"].(bool)
+			promoBranch, _ := promo["branch_id"].(float64)
 
 			status := "✅"
 			if !isActive {
 				status = "❌"
 			}
 
-			text += fmt.Sprintf("%s *%s*\n", status, title)
+			text += md("%s *%s*\n", status, title)
+			if promoBranch > 0 {
+				text += md("   🏪 %s\n", branchName(int(promoBranch)))
+			}
 			if discountType == "percentage" {
-				text += fmt.Sprintf("   🎁 Diskon %d%%\n\n", discount)
+				text += "   🎁 " + tm("promo.discount_percent", discount) + "\n\n"
 			} else {
-				text += fmt.Sprintf("   🎁 Diskon %s\n\n", shared.FormatPrice(discount))
+				text += "   🎁 " + tm("promo.discount_amount", price(shared.Money(discount))) + "\n\n"
 			}
 
 			if forOperation == "update" {
 				keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
-					tgbotapi.NewInlineKeyboardButtonData("✏️ Edit "+title, fmt.Sprintf("edit_promo:%d", id)),
+					button(t("common.btn_edit", title), "edit_promo", id),
 				))
 			} else if forOperation == "delete" {
 				keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
-					tgbotapi.NewInlineKeyboardButtonData("🗑️ Hapus "+title, fmt.Sprintf("confirm_delete_promo:%d", id)),
+					button(t("common.btn_delete", title), "confirm_delete_promo", id),
+				))
+			} else if forOperation == "translate" {
+				keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+					button("🌐 "+title, "tr_show", "promo", id),
 				))
 			}
 		}
 	} else {
-		text += "Belum ada promo.\n"
+		text += tm("admin.promo_list_empty") + "\n"
 	}
 
+	pageLine, pageRow := pageFooter(page, listTotal(data, promosData), promoListScreens[forOperation])
+	text += pageLine
+	if pageRow != nil {
+		keyboard = append(keyboard, pageRow)
+	}
 	keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("⬅️ Kembali", "admin_promo"),
+		backButton("admin_promo"),
 	))
 
 	sendMessage(chatID, text, tgbotapi.NewInlineKeyboardMarkup(keyboard...))
 }
 
-func showCategoryList(chatID int64, forOperation string) {
-	resp, err := httpClient.Post(menuServiceURL, shared.Request{
-		Action: "list_categories",
+func showCategoryList(chatID int64, forOperation string, page int) {
+	resp, err := cachedPost(menuServiceURL, shared.Request{
+		Action:  "list_categories",
+		Payload: pagePayload(map[string]interface{}{}, page),
 	})
 
 	if err != nil || !resp.Success {
-		sendMessage(chatID, "⚠️ Gagal memuat kategori.", nil)
+		sendMessage(chatID, tm("category.load_failed"), nil)
 		return
 	}
 
-	categoriesData, ok := resp.Data.(map[string]interface{})["categories"].([]interface{})
-	text := "📁 *Daftar Kategori*\n\n"
+	data := resp.Data.(map[string]interface{})
+	categoriesData, ok := data["categories"].([]interface{})
+	if len(categoriesData) == 0 && page > 0 {
+		// The page emptied since it was shown, e.g. after a delete
+		showCategoryList(chatID, forOperation, pageCount(listTotal(data, categoriesData))-1)
+		return
+	}
+	text := tm("admin.category_list_title") + "\n\n"
 	var keyboard [][]tgbotapi.InlineKeyboardButton
 
 	if ok && len(categoriesData) > 0 {
@@ -256,32 +359,38 @@ func showCategoryList(chatID int64, forOperation string) {
 			name := category["name"].(string)
 			id := int(category["id"].(float64))
 
-			text += fmt.Sprintf("• *%s*\n", name)
+			text += md("• *%s*\n", name)
 
 			if forOperation == "delete" {
 				keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
-					tgbotapi.NewInlineKeyboardButtonData("🗑️ Hapus "+name, fmt.Sprintf("confirm_delete_category:%d", id)),
+					button(t("common.btn_delete", name), "confirm_delete_category", id),
 				))
 			}
 		}
 	} else {
-		text += "Belum ada kategori.\n"
+		text += tm("admin.category_list_empty") + "\n"
 	}
 
+	pageLine, pageRow := pageFooter(page, listTotal(data, categoriesData), categoryListScreens[forOperation])
+	text += pageLine
+	if pageRow != nil {
+		keyboard = append(keyboard, pageRow)
+	}
 	keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("⬅️ Kembali", "admin_category"),
+		backButton("admin_category"),
 	))
 
 	sendMessage(chatID, text, tgbotapi.NewInlineKeyboardMarkup(keyboard...))
 }
 
-func showCafeInfoDetail(chatID int64) {
-	resp, err := httpClient.Post(infoServiceURL, shared.Request{
-		Action: "read",
+func showCafeInfoDetail(chatID int64, branchID int) {
+	resp, err := cachedPost(infoServiceURL, shared.Request{
+		Action:  "read",
+		Payload: map[string]interface{}{"branch_id": branchID},
 	})
 
 	if err != nil || !resp.Success {
-		sendMessage(chatID, "⚠️ Gagal memuat informasi café.", nil)
+		sendMessage(chatID, tm("info.load_failed"), nil)
 		return
 	}
 
@@ -293,18 +402,18 @@ func showCafeInfoDetail(chatID int64) {
 	closingHour := infoData["closing_hour"].(string)
 	description := infoData["description"].(string)
 
-	text := "ℹ️ *Informasi Café*\n\n"
-	text += fmt.Sprintf("*Nama:* %s\n", name)
-	text += fmt.Sprintf("*Alamat:* %s\n", address)
-	text += fmt.Sprintf("*Telepon:* %s\n", phone)
-	text += fmt.Sprintf("*Jam Operasional:* %s - %s\n", openingHour, closingHour)
+	text := tm("admin.info_detail_title") + "\n\n"
+	text += tm("admin.info_name", name) + "\n"
+	text += tm("admin.info_address", address) + "\n"
+	text += tm("admin.info_phone", phone) + "\n"
+	text += tm("admin.info_hours", openingHour, closingHour) + "\n"
 	if description != "" {
-		text += fmt.Sprintf("*Deskripsi:* %s\n", description)
+		text += tm("admin.info_description", description) + "\n"
 	}
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("⬅️ Kembali", "admin_info"),
+			backButton("admin_info"),
 		),
 	)
 
@@ -316,139 +425,87 @@ func showCafeInfoDetail(chatID int64) {
 func handleDialogState(msg *tgbotapi.Message, state string) {
 	userID := msg.From.ID
 
+	if pos, ok := wizardStates[state]; ok {
+		handleWizardInput(msg, userID, pos)
+		return
+	}
+
 	switch state {
-	case "add_menu_name":
-		handleAddMenuName(msg, userID)
-	case "add_menu_price":
-		handleAddMenuPrice(msg, userID)
-	case "add_menu_category":
-		handleAddMenuCategory(msg, userID)
-	case "add_menu_description":
-		handleAddMenuDescription(msg, userID)
-	case "add_promo_title":
-		handleAddPromoTitle(msg, userID)
-	case "add_promo_description":
-		handleAddPromoDescription(msg, userID)
-	case "add_promo_discount":
-		handleAddPromoDiscount(msg, userID)
-	case "add_promo_discount_type":
-		handleAddPromoDiscountType(msg, userID)
-	case "add_promo_start_date":
-		handleAddPromoStartDate(msg, userID)
-	case "add_promo_end_date":
-		handleAddPromoEndDate(msg, userID)
-	case "add_category_name":
-		handleAddCategoryName(msg, userID)
+	case "set_branch_price":
+		handleSetBranchPrice(msg, userID)
+	case "add_option":
+		handleAddOption(msg, userID)
+	case "set_translation":
+		handleSetTranslation(msg, userID)
+	case "add_ingredient":
+		handleAddIngredient(msg, userID)
+	case "adjust_stock":
+		handleAdjustStock(msg, userID)
+	case "set_recipe":
+		handleSetRecipe(msg, userID)
+	case "report_range":
+		handleReportRange(msg, userID)
+	case "menu_import":
+		handleMenuImportFile(msg, userID)
 	default:
 		delete(userStates, userID)
-		sendMessage(msg.Chat.ID, "State tidak dikenal. Gunakan /cancel untuk membatalkan.", nil)
+		sendMessage(msg.Chat.ID, tm("common.unknown_state"), nil)
 	}
 }
 
 func startAddMenuDialog(chatID int64, userID int64) {
-	userStates[userID] = "add_menu_name"
-	userTempData[userID] = make(map[string]interface{})
-	sendMessage(chatID, "➕ *Tambah Menu Baru*\n\nMasukkan nama menu:\n\n(Ketik /cancel untuk membatalkan)", nil)
+	startWizard(chatID, userID, "add_menu", nil)
 }
 
-func handleAddMenuName(msg *tgbotapi.Message, userID int64) {
-	name := strings.TrimSpace(msg.Text)
-	if name == "" {
-		sendMessage(msg.Chat.ID, "⚠️ Nama menu tidak boleh kosong. Coba lagi:", nil)
-		return
-	}
-
-	userTempData[userID]["name"] = name
-	userStates[userID] = "add_menu_price"
-	sendMessage(msg.Chat.ID, "Masukkan harga menu (angka saja, tanpa Rp):", nil)
-}
-
-func handleAddMenuPrice(msg *tgbotapi.Message, userID int64) {
-	price, err := strconv.Atoi(strings.TrimSpace(msg.Text))
-	if err != nil || price < 0 {
-		sendMessage(msg.Chat.ID, "⚠️ Harga tidak valid. Masukkan angka positif:", nil)
-		return
-	}
-
-	userTempData[userID]["price"] = price
-	userStates[userID] = "add_menu_category"
-
-	// Get categories
-	resp, err := httpClient.Post(menuServiceURL, shared.Request{
+// menuCategoryChoices offers the existing categories for a new menu
+func menuCategoryChoices(map[string]interface{}) []wizardChoice {
+	resp, err := cachedPost(menuServiceURL, shared.Request{
 		Action: "list_categories",
 	})
-
-	text := "Pilih kategori:\n\n"
-	if err == nil && resp.Success {
-		categoriesData, ok := resp.Data.(map[string]interface{})["categories"].([]interface{})
-		if ok {
-			for _, item := range categoriesData {
-				category := item.(map[string]interface{})
-				name := category["name"].(string)
-				text += fmt.Sprintf("• %s\n", name)
-			}
-		}
+	if err != nil || !resp.Success {
+		return nil
 	}
-	text += "\nKetik nama kategori:"
-
-	sendMessage(msg.Chat.ID, text, nil)
-}
 
-func handleAddMenuCategory(msg *tgbotapi.Message, userID int64) {
-	category := strings.TrimSpace(msg.Text)
-	if category == "" {
-		sendMessage(msg.Chat.ID, "⚠️ Kategori tidak boleh kosong. Coba lagi:", nil)
-		return
+	var choices []wizardChoice
+	categoriesData, _ := resp.Data.(map[string]interface{})["categories"].([]interface{})
+	for _, item := range categoriesData {
+		name := item.(map[string]interface{})["name"].(string)
+		choices = append(choices, wizardChoice{value: name, label: name})
 	}
-
-	userTempData[userID]["category"] = category
-	userStates[userID] = "add_menu_description"
-	sendMessage(msg.Chat.ID, "Masukkan deskripsi menu (atau ketik - untuk skip):", nil)
+	return choices
 }
 
-func handleAddMenuDescription(msg *tgbotapi.Message, userID int64) {
-	description := strings.TrimSpace(msg.Text)
-	if description == "-" {
-		description = ""
-	}
-
-	userTempData[userID]["description"] = description
-
-	// Create menu
-	data := userTempData[userID]
-	resp, err := httpClient.Post(menuServiceURL, shared.Request{
+func finishAddMenu(chatID int64, userID int64, data map[string]interface{}) {
+	resp, err := cachedPost(menuServiceURL, shared.Request{
 		Action: "create",
 		Payload: map[string]interface{}{
 			"name":         data["name"],
 			"price":        data["price"],
 			"category":     data["category"],
-			"description":  description,
+			"description":  data["description"],
 			"is_available": true,
 		},
 	})
 
-	delete(userStates, userID)
-	delete(userTempData, userID)
-
 	if err != nil || !resp.Success {
-		sendMessage(msg.Chat.ID, "⚠️ Gagal menambahkan menu. Silakan coba lagi.", nil)
+		sendMessage(chatID, tm("menu.create_failed"), nil)
 		return
 	}
 
 	menuData := resp.Data.(map[string]interface{})["menu"].(map[string]interface{})
 	name := menuData["name"].(string)
-	price := int(menuData["price"].(float64))
+	menuPrice := money(menuData["price"])
 
-	text := fmt.Sprintf("✅ *Menu berhasil ditambahkan!*\n\n🍽️ %s\n💰 %s", name, shared.FormatPrice(price))
-	sendMessage(msg.Chat.ID, text, nil)
+	text := tm("menu.created", name, price(menuPrice))
+	sendMessage(chatID, text, nil)
 }
 
 func startEditMenuDialog(chatID int64, userID int64, menuID int) {
-	sendMessage(chatID, "✏️ Fitur edit menu akan segera tersedia.", nil)
+	sendMessage(chatID, tm("menu.edit_soon"), nil)
 }
 
 func deleteMenu(chatID int64, menuID int) {
-	resp, err := httpClient.Post(menuServiceURL, shared.Request{
+	resp, err := cachedPost(menuServiceURL, shared.Request{
 		Action: "delete",
 		Payload: map[string]interface{}{
 			"id": menuID,
@@ -456,85 +513,67 @@ func deleteMenu(chatID int64, menuID int) {
 	})
 
 	if err != nil || !resp.Success {
-		sendMessage(chatID, "⚠️ Gagal menghapus menu.", nil)
+		sendMessage(chatID, tm("menu.delete_failed"), nil)
 		return
 	}
 
-	sendMessage(chatID, "✅ Menu berhasil dihapus!", nil)
+	sendMessage(chatID, tm("menu.deleted"), nil)
 	showAdminMenuManagement(chatID)
 }
 
-func startAddPromoDialog(chatID int64, userID int64) {
-	userStates[userID] = "add_promo_title"
-	userTempData[userID] = make(map[string]interface{})
-	sendMessage(chatID, "➕ *Tambah Promo Baru*\n\nMasukkan judul promo:\n\n(Ketik /cancel untuk membatalkan)", nil)
+func startAddPromoDialog(chatID int64, userID int64, branchID int) {
+	startWizard(chatID, userID, "add_promo", map[string]interface{}{
+		"branch_id": branchID,
+	})
 }
 
-func handleAddPromoTitle(msg *tgbotapi.Message, userID int64) {
-	title := strings.TrimSpace(msg.Text)
-	if title == "" {
-		sendMessage(msg.Chat.ID, "⚠️ Judul promo tidak boleh kosong. Coba lagi:", nil)
-		return
-	}
-
-	userTempData[userID]["title"] = title
-	userStates[userID] = "add_promo_description"
-	sendMessage(msg.Chat.ID, "Masukkan deskripsi promo (atau ketik - untuk skip):", nil)
+// parsePromoDate reads a promo date, written as YYYY-MM-DD
+func parsePromoDate(msg *tgbotapi.Message) (time.Time, bool) {
+	date, err := time.Parse("2006-01-02", strings.TrimSpace(msg.Text))
+	return date, err == nil
 }
 
-func handleAddPromoDescription(msg *tgbotapi.Message, userID int64) {
-	description := strings.TrimSpace(msg.Text)
-	if description == "-" {
-		description = ""
+func promoStartDate(msg *tgbotapi.Message, _ map[string]interface{}) (interface{}, markup) {
+	date, ok := parsePromoDate(msg)
+	if !ok {
+		return nil, tm("promo.date_invalid")
 	}
-
-	userTempData[userID]["description"] = description
-	userStates[userID] = "add_promo_discount_type"
-	sendMessage(msg.Chat.ID, "Pilih tipe diskon:\n\nKetik *percentage* atau *amount*:", nil)
+	return date.Format("2006-01-02"), ""
 }
 
-func handleAddPromoDiscountType(msg *tgbotapi.Message, userID int64) {
-	discountType := strings.ToLower(strings.TrimSpace(msg.Text))
-	if discountType != "percentage" && discountType != "amount" {
-		sendMessage(msg.Chat.ID, "⚠️ Tipe harus 'percentage' atau 'amount'. Coba lagi:", nil)
-		return
+// promoEndDate reads the end date of a promo, which may not be before its
+// start date
+func promoEndDate(msg *tgbotapi.Message, data map[string]interface{}) (interface{}, markup) {
+	date, ok := parsePromoDate(msg)
+	if !ok {
+		return nil, tm("promo.date_invalid")
 	}
-
-	userTempData[userID]["discount_type"] = discountType
-	userStates[userID] = "add_promo_discount"
-
-	if discountType == "percentage" {
-		sendMessage(msg.Chat.ID, "Masukkan jumlah diskon (dalam %, angka saja):", nil)
-	} else {
-		sendMessage(msg.Chat.ID, "Masukkan jumlah diskon (dalam Rp, angka saja):", nil)
+	if start, _ := time.Parse("2006-01-02", data["start_date"].(string)); date.Before(start) {
+		return nil, tm("promo.end_before_start")
 	}
+	return date.Format("2006-01-02"), ""
 }
 
-func handleAddPromoDiscount(msg *tgbotapi.Message, userID int64) {
-	discount, err := strconv.Atoi(strings.TrimSpace(msg.Text))
-	if err != nil || discount < 0 {
-		sendMessage(msg.Chat.ID, "⚠️ Diskon tidak valid. Masukkan angka positif:", nil)
-		return
+// promoDiscount reads a discount, a percentage or an amount by its type
+func promoDiscount(msg *tgbotapi.Message, data map[string]interface{}) (interface{}, markup) {
+	// Percentages use the same parser, so "10%" and "10" both work
+	discount, err := shared.ParseMoney(strings.TrimSuffix(strings.TrimSpace(msg.Text), "%"))
+	isPercentage := data["discount_type"] == "percentage"
+	if err != nil || discount < 0 || (isPercentage && discount > 100) {
+		return nil, tm("promo.discount_invalid")
 	}
-
-	userTempData[userID]["discount"] = discount
-	userStates[userID] = "add_promo_start_date"
-	sendMessage(msg.Chat.ID, "Masukkan tanggal mulai (format: YYYY-MM-DD, contoh: 2025-01-01):", nil)
+	return discount, ""
 }
 
-func handleAddPromoStartDate(msg *tgbotapi.Message, userID int64) {
-	startDate := strings.TrimSpace(msg.Text)
-
-	userTempData[userID]["start_date"] = startDate
-	userStates[userID] = "add_promo_end_date"
-	sendMessage(msg.Chat.ID, "Masukkan tanggal akhir (format: YYYY-MM-DD):", nil)
+func showPromoDiscount(data map[string]interface{}) string {
+	discount := data["discount"].(shared.Money)
+	if data["discount_type"] == "percentage" {
+		return fmt.Sprintf("%d%%", discount.Int())
+	}
+	return price(discount)
 }
 
-func handleAddPromoEndDate(msg *tgbotapi.Message, userID int64) {
-	endDate := strings.TrimSpace(msg.Text)
-
-	// Create promo
-	data := userTempData[userID]
+func finishAddPromo(chatID int64, userID int64, data map[string]interface{}) {
 	resp, err := httpClient.Post(promoServiceURL, shared.Request{
 		Action: "create",
 		Payload: map[string]interface{}{
@@ -543,28 +582,26 @@ func handleAddPromoEndDate(msg *tgbotapi.Message, userID int64) {
 			"discount":      data["discount"],
 			"discount_type": data["discount_type"],
 			"start_date":    data["start_date"],
-			"end_date":      endDate,
+			"end_date":      data["end_date"],
 			"is_active":     true,
+			"branch_id":     data["branch_id"],
 		},
 	})
 
-	delete(userStates, userID)
-	delete(userTempData, userID)
-
 	if err != nil || !resp.Success {
-		errMsg := "⚠️ Gagal menambahkan promo. Periksa format tanggal (YYYY-MM-DD)."
+		errMsg := tm("promo.create_failed")
 		if resp != nil && resp.Error != nil {
-			errMsg = "⚠️ " + resp.Error.Message
+			errMsg = "⚠️ " + errorText(resp.Error)
 		}
-		sendMessage(msg.Chat.ID, errMsg, nil)
+		sendMessage(chatID, errMsg, nil)
 		return
 	}
 
 	promoData := resp.Data.(map[string]interface{})["promo"].(map[string]interface{})
 	title := promoData["title"].(string)
 
-	text := fmt.Sprintf("✅ *Promo berhasil ditambahkan!*\n\n🎁 %s", title)
-	sendMessage(msg.Chat.ID, text, nil)
+	text := tm("promo.created", title)
+	sendMessage(chatID, text, nil)
 }
 
 func deletePromo(chatID int64, promoID int) {
@@ -576,16 +613,16 @@ func deletePromo(chatID int64, promoID int) {
 	})
 
 	if err != nil || !resp.Success {
-		sendMessage(chatID, "⚠️ Gagal menghapus promo.", nil)
+		sendMessage(chatID, tm("promo.delete_failed"), nil)
 		return
 	}
 
-	sendMessage(chatID, "✅ Promo berhasil dihapus.", nil)
+	sendMessage(chatID, tm("promo.deleted"), nil)
 	showAdminPromoManagement(chatID)
 }
 
 func deleteCategory(chatID int64, categoryID int) {
-	resp, err := httpClient.Post(menuServiceURL, shared.Request{
+	resp, err := cachedPost(menuServiceURL, shared.Request{
 		Action: "delete_category",
 		Payload: map[string]interface{}{
 			"id": categoryID,
@@ -593,65 +630,55 @@ func deleteCategory(chatID int64, categoryID int) {
 	})
 
 	if err != nil || !resp.Success {
-		errMsg := "⚠️ Gagal menghapus kategori."
-		if resp.Error != nil {
-			errMsg += "\n" + resp.Error.Message
+		errMsg := tm("category.delete_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
 		}
 		sendMessage(chatID, errMsg, nil)
 		return
 	}
 
-	sendMessage(chatID, "✅ Kategori berhasil dihapus.", nil)
+	sendMessage(chatID, tm("category.deleted"), nil)
 	showAdminCategoryManagement(chatID)
 }
 
 func startAddCategoryDialog(chatID int64, userID int64) {
-	userStates[userID] = "add_category_name"
-	userTempData[userID] = make(map[string]interface{})
-	sendMessage(chatID, "📁 *Tambah Kategori Baru*\n\nMasukkan nama kategori:\n\n_Ketik /cancel untuk membatalkan_", nil)
+	startWizard(chatID, userID, "add_category", nil)
 }
 
-func handleAddCategoryName(msg *tgbotapi.Message, userID int64) {
-	categoryName := strings.TrimSpace(msg.Text)
-	if categoryName == "" {
-		sendMessage(msg.Chat.ID, "⚠️ Nama kategori tidak boleh kosong. Coba lagi:", nil)
-		return
-	}
-
-	// Create category
-	resp, err := httpClient.Post(menuServiceURL, shared.Request{
+func finishAddCategory(chatID int64, userID int64, data map[string]interface{}) {
+	categoryName := data["name"].(string)
+	resp, err := cachedPost(menuServiceURL, shared.Request{
 		Action: "create_category",
 		Payload: map[string]interface{}{
 			"name": categoryName,
 		},
 	})
 
-	delete(userStates, userID)
-	delete(userTempData, userID)
-
 	if err != nil || !resp.Success {
-		errMsg := "⚠️ Gagal menambahkan kategori."
-		if resp.Error != nil {
-			errMsg += "\n" + resp.Error.Message
+		errMsg := tm("category.create_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
 		}
-		sendMessage(msg.Chat.ID, errMsg, nil)
+		sendMessage(chatID, errMsg, nil)
 		return
 	}
 
-	sendMessage(msg.Chat.ID, fmt.Sprintf("✅ Kategori *%s* berhasil ditambahkan!", categoryName), nil)
-	showAdminCategoryManagement(msg.Chat.ID)
+	sendMessage(chatID, tm("category.created", categoryName), nil)
+	showAdminCategoryManagement(chatID)
 }
 
 // CAFE INF EDIT DIALOG FUNCTIONS
 
-func startEditCafeInfoDialog(chatID int64, userID int64) {
+func startEditCafeInfoDialog(chatID int64, userID int64, branchID int) {
 	// First, get current cafe info
-	resp, err := httpClient.Post(infoServiceURL, shared.Request{
-		Action: "read",
+	resp, err := cachedPost(infoServiceURL, shared.Request{
+		Action:  "read",
+		Payload: map[string]interface{}{"branch_id": branchID},
 	})
 
 	if err != nil || !resp.Success {
-		sendMessage(chatID, "⚠️ Gagal memuat informasi café.", nil)
+		sendMessage(chatID, tm("info.load_failed"), nil)
 		return
 	}
 
@@ -664,126 +691,118 @@ func startEditCafeInfoDialog(chatID int64, userID int64) {
 	closingHour := infoData["closing_hour"].(string)
 	description := infoData["description"].(string)
 
-	text := "✏️ *Edit Info Café*\n\n"
-	text += "*Info Saat Ini:*\n\n"
-	text += fmt.Sprintf("📍 *Nama:* %s\n", name)
-	text += fmt.Sprintf("🏠 *Alamat:* %s\n", address)
-	text += fmt.Sprintf("📞 *Telepon:* %s\n", phone)
+	text := tm("info.edit_title") + "\n\n"
+	text += tm("info.current") + "\n\n"
+	text += "📍 " + tm("admin.info_name", name) + "\n"
+	text += "🏠 " + tm("admin.info_address", address) + "\n"
+	text += "📞 " + tm("admin.info_phone", phone) + "\n"
 	if email != "" {
-		text += fmt.Sprintf("📧 *Email:* %s\n", email)
+		text += "📧 " + tm("admin.info_email", email) + "\n"
 	}
-	text += fmt.Sprintf("🕐 *Jam Buka:* %s\n", openingHour)
-	text += fmt.Sprintf("🕔 *Jam Tutup:* %s\n", closingHour)
+	text += "🕐 " + tm("admin.info_opening_hour", openingHour) + "\n"
+	text += "🕔 " + tm("admin.info_closing_hour", closingHour) + "\n"
 	if description != "" {
-		text += fmt.Sprintf("📝 *Deskripsi:* %s\n", description)
+		text += "📝 " + tm("admin.info_description", description) + "\n"
 	}
-	text += "\n_Pilih field yang ingin diubah:_"
+	text += "\n_" + tm("info.pick_field") + "_"
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📍 Edit Nama", "edit_info:name"),
+			button(t("info.btn_edit_name"), "edit_info", "name"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🏠 Edit Alamat", "edit_info:address"),
+			button(t("info.btn_edit_address"), "edit_info", "address"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📞 Edit Telepon", "edit_info:phone"),
+			button(t("info.btn_edit_phone"), "edit_info", "phone"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📧 Edit Email", "edit_info:email"),
+			button(t("info.btn_edit_email"), "edit_info", "email"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🕐 Edit Jam Buka", "edit_info:opening_hour"),
+			button(t("info.btn_edit_opening_hour"), "edit_info", "opening_hour"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🕔 Edit Jam Tutup", "edit_info:closing_hour"),
+			button(t("info.btn_edit_closing_hour"), "edit_info", "closing_hour"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📝 Edit Deskripsi", "edit_info:description"),
+			button(t("info.btn_edit_description"), "edit_info", "description"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("⬅️ Kembali", "admin_info"),
+			button(t("info.btn_edit_location"), "edit_info", "location"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			backButton("admin_info"),
 		),
 	)
 
 	sendMessage(chatID, text, keyboard)
 }
 
-func handleEditCafeInfoName(msg *tgbotapi.Message, userID int64) {
-	name := strings.TrimSpace(msg.Text)
-	if name == "" {
-		sendMessage(msg.Chat.ID, "⚠️ Nama café tidak boleh kosong. Coba lagi:", nil)
+// startEditCafeInfoField asks for the new value of one café info field
+func startEditCafeInfoField(chatID int64, userID int64, branchID int, field string) {
+	if _, ok := wizardStates["edit_cafe_info_"+field]; !ok {
 		return
 	}
-
-	updateCafeInfo(msg.Chat.ID, userID, map[string]interface{}{"name": name})
+	startWizard(chatID, userID, "edit_cafe_info", map[string]interface{}{
+		"branch_id": branchID,
+		"field":     field,
+	})
 }
 
-func handleEditCafeInfoAddress(msg *tgbotapi.Message, userID int64) {
-	address := strings.TrimSpace(msg.Text)
-	if address == "" {
-		sendMessage(msg.Chat.ID, "⚠️ Alamat tidak boleh kosong. Coba lagi:", nil)
-		return
+// cafeInfoStep is the step of the edit_cafe_info wizard for field, asked
+// when that field is edited
+func cafeInfoStep(field string, step wizardStep) wizardStep {
+	step.key = field
+	step.prompt = textPrompt("info.prompt_" + field)
+	step.when = func(data map[string]interface{}) bool { return data["field"] == field }
+	if step.parse == nil {
+		step.parse = requiredText("info." + field + "_empty")
 	}
-
-	updateCafeInfo(msg.Chat.ID, userID, map[string]interface{}{"address": address})
+	return step
 }
 
-func handleEditCafeInfoPhone(msg *tgbotapi.Message, userID int64) {
-	phone := strings.TrimSpace(msg.Text)
-	if phone == "" {
-		sendMessage(msg.Chat.ID, "⚠️ Telepon tidak boleh kosong. Coba lagi:", nil)
-		return
+// cafeLocation reads a shared location or typed coordinates
+func cafeLocation(msg *tgbotapi.Message, _ map[string]interface{}) (interface{}, markup) {
+	if msg.Location != nil {
+		return []float64{msg.Location.Latitude, msg.Location.Longitude}, ""
 	}
 
-	updateCafeInfo(msg.Chat.ID, userID, map[string]interface{}{"phone": phone})
-}
-
-func handleEditCafeInfoEmail(msg *tgbotapi.Message, userID int64) {
-	email := strings.TrimSpace(msg.Text)
-	updateCafeInfo(msg.Chat.ID, userID, map[string]interface{}{"email": email})
-}
-
-func handleEditCafeInfoOpeningHour(msg *tgbotapi.Message, userID int64) {
-	openingHour := strings.TrimSpace(msg.Text)
-	if openingHour == "" {
-		sendMessage(msg.Chat.ID, "⚠️ Jam buka tidak boleh kosong. Coba lagi:", nil)
-		return
+	parts := strings.Split(strings.TrimSpace(msg.Text), ",")
+	if len(parts) != 2 {
+		return nil, tm("info.location_invalid")
 	}
-
-	updateCafeInfo(msg.Chat.ID, userID, map[string]interface{}{"opening_hour": openingHour})
-}
-
-func handleEditCafeInfoClosingHour(msg *tgbotapi.Message, userID int64) {
-	closingHour := strings.TrimSpace(msg.Text)
-	if closingHour == "" {
-		sendMessage(msg.Chat.ID, "⚠️ Jam tutup tidak boleh kosong. Coba lagi:", nil)
-		return
+	lat, errLat := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	lon, errLon := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if errLat != nil || errLon != nil {
+		return nil, tm("info.coordinates_invalid")
 	}
-
-	updateCafeInfo(msg.Chat.ID, userID, map[string]interface{}{"closing_hour": closingHour})
+	return []float64{lat, lon}, ""
 }
 
-func handleEditCafeInfoDescription(msg *tgbotapi.Message, userID int64) {
-	description := strings.TrimSpace(msg.Text)
-	if description == "-" {
-		description = ""
+func finishEditCafeInfo(chatID int64, userID int64, data map[string]interface{}) {
+	field := data["field"].(string)
+	payload := map[string]interface{}{"branch_id": data["branch_id"]}
+	if field == "location" {
+		// A skipped location clears it
+		payload["latitude"], payload["longitude"] = nil, nil
+		if location, ok := data[field].([]float64); ok {
+			payload["latitude"], payload["longitude"] = location[0], location[1]
+		}
+	} else {
+		payload[field] = data[field]
 	}
-
-	updateCafeInfo(msg.Chat.ID, userID, map[string]interface{}{"description": description})
+	updateCafeInfo(chatID, payload)
 }
 
-func updateCafeInfo(chatID int64, userID int64, data map[string]interface{}) {
-	resp, err := httpClient.Post(infoServiceURL, shared.Request{
+func updateCafeInfo(chatID int64, data map[string]interface{}) {
+	resp, err := cachedPost(infoServiceURL, shared.Request{
 		Action:  "update",
 		Payload: data,
 	})
 
-	delete(userStates, userID)
-	delete(userTempData, userID)
-
 	if err != nil || !resp.Success {
-		sendMessage(chatID, "⚠️ Gagal mengupdate informasi café. Silakan coba lagi.", nil)
+		sendMessage(chatID, tm("info.update_failed"), nil)
 		return
 	}
 
@@ -796,24 +815,157 @@ func updateCafeInfo(chatID int64, userID int64, data map[string]interface{}) {
 	closingHour := infoData["closing_hour"].(string)
 	description := infoData["description"].(string)
 
-	text := "✅ *Info Café berhasil diperbarui!*\n\n"
-	text += fmt.Sprintf("📍 *Nama:* %s\n", name)
-	text += fmt.Sprintf("🏠 *Alamat:* %s\n", address)
-	text += fmt.Sprintf("📞 *Telepon:* %s\n", phone)
+	text := tm("info.updated") + "\n\n"
+	text += "📍 " + tm("admin.info_name", name) + "\n"
+	text += "🏠 " + tm("admin.info_address", address) + "\n"
+	text += "📞 " + tm("admin.info_phone", phone) + "\n"
 	if email != "" {
-		text += fmt.Sprintf("📧 *Email:* %s\n", email)
+		text += "📧 " + tm("admin.info_email", email) + "\n"
 	}
-	text += fmt.Sprintf("🕐 *Jam Buka:* %s\n", openingHour)
-	text += fmt.Sprintf("🕔 *Jam Tutup:* %s\n", closingHour)
+	text += "🕐 " + tm("admin.info_opening_hour", openingHour) + "\n"
+	text += "🕔 " + tm("admin.info_closing_hour", closingHour) + "\n"
 	if description != "" {
-		text += fmt.Sprintf("📝 *Deskripsi:* %s\n", description)
+		text += "📝 " + tm("admin.info_description", description) + "\n"
+	}
+	if lat, ok := infoData["latitude"].(float64); ok {
+		text += "📌 " + tm("admin.info_location", lat, infoData["longitude"].(float64)) + "\n"
 	}
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("⬅️ Kembali", "admin_info"),
+			backButton("admin_info"),
 		),
 	)
 
 	sendMessage(chatID, text, keyboard)
 }
+
+func init() {
+	registerWizard(&wizard{
+		name:    "add_menu",
+		title:   "menu.add_title",
+		confirm: true,
+		finish:  finishAddMenu,
+		steps: []wizardStep{
+			{
+				key:    "name",
+				prompt: textPrompt("menu.prompt_name"),
+				parse:  requiredText("menu.name_empty"),
+				label:  "menu.label_name",
+			},
+			{
+				key:    "price",
+				prompt: textPrompt("menu.prompt_price"),
+				parse: func(msg *tgbotapi.Message, _ map[string]interface{}) (interface{}, markup) {
+					menuPrice, err := shared.ParseMoney(msg.Text)
+					if err != nil || menuPrice < 0 {
+						return nil, tm("menu.price_invalid")
+					}
+					return menuPrice, ""
+				},
+				label: "menu.label_price",
+				show: func(data map[string]interface{}) string {
+					return price(data["price"].(shared.Money))
+				},
+			},
+			{
+				key:     "category",
+				prompt:  textPrompt("menu.prompt_category"),
+				choices: menuCategoryChoices,
+				parse:   requiredText("menu.category_empty_input"),
+				label:   "menu.label_category",
+			},
+			{
+				key:      "description",
+				prompt:   textPrompt("menu.prompt_description"),
+				parse:    anyText,
+				optional: true,
+				label:    "menu.label_description",
+			},
+		},
+	})
+
+	registerWizard(&wizard{
+		name:    "add_promo",
+		title:   "promo.add_title",
+		confirm: true,
+		finish:  finishAddPromo,
+		steps: []wizardStep{
+			{
+				key:    "title",
+				prompt: textPrompt("promo.prompt_title"),
+				parse:  requiredText("promo.title_empty"),
+				label:  "promo.label_title",
+			},
+			{
+				key:      "description",
+				prompt:   textPrompt("promo.prompt_description"),
+				parse:    anyText,
+				optional: true,
+				label:    "promo.label_description",
+			},
+			{
+				key:    "discount_type",
+				prompt: textPrompt("promo.prompt_discount_type"),
+				choices: func(map[string]interface{}) []wizardChoice {
+					return []wizardChoice{
+						{value: "percentage", label: t("promo.type_percentage")},
+						{value: "amount", label: t("promo.type_amount")},
+					}
+				},
+			},
+			{
+				key: "discount",
+				prompt: func(data map[string]interface{}) markup {
+					if data["discount_type"] == "percentage" {
+						return tm("promo.prompt_discount_percent")
+					}
+					return tm("promo.prompt_discount_amount")
+				},
+				parse: promoDiscount,
+				label: "promo.label_discount",
+				show:  showPromoDiscount,
+			},
+			{
+				key:    "start_date",
+				prompt: textPrompt("promo.prompt_start_date"),
+				parse:  promoStartDate,
+				label:  "promo.label_start_date",
+			},
+			{
+				key:    "end_date",
+				prompt: textPrompt("promo.prompt_end_date"),
+				parse:  promoEndDate,
+				label:  "promo.label_end_date",
+			},
+		},
+	})
+
+	registerWizard(&wizard{
+		name:   "add_category",
+		title:  "category.add_title",
+		finish: finishAddCategory,
+		steps: []wizardStep{
+			{
+				key:    "name",
+				prompt: textPrompt("category.prompt_name"),
+				parse:  requiredText("category.name_empty"),
+			},
+		},
+	})
+
+	registerWizard(&wizard{
+		name:   "edit_cafe_info",
+		finish: finishEditCafeInfo,
+		steps: []wizardStep{
+			cafeInfoStep("name", wizardStep{}),
+			cafeInfoStep("address", wizardStep{}),
+			cafeInfoStep("phone", wizardStep{}),
+			cafeInfoStep("email", wizardStep{parse: anyText, optional: true}),
+			cafeInfoStep("opening_hour", wizardStep{}),
+			cafeInfoStep("closing_hour", wizardStep{}),
+			cafeInfoStep("description", wizardStep{parse: anyText, optional: true}),
+			cafeInfoStep("location", wizardStep{parse: cafeLocation, optional: true}),
+		},
+	})
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/alrescha79-cmd/bot-cafe/agent/render"
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Largest import file accepted, matching menu-service
+const maxImportFileSize = 2 << 20
+
+// Row errors listed in an import preview; the rest are only counted
+const importErrorLines = 15
+
+func showMenuExportFormats(chatID int64) {
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("menu_import.btn_csv"), "menu_export", "csv"),
+			button(t("menu_import.btn_xlsx"), "menu_export", "xlsx"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("common.btn_back"), "admin_menu"),
+		),
+	)
+	sendMessage(chatID, tm("menu_import.export_title"), keyboard)
+}
+
+func sendMenuExport(chatID int64, format string) {
+	resp, err := cachedPost(menuServiceURL, shared.Request{
+		Action:  "export",
+		Payload: map[string]interface{}{"format": format},
+	})
+
+	if err != nil || !resp.Success {
+		errMsg := tm("menu_import.export_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		sendMessage(chatID, errMsg, nil)
+		return
+	}
+
+	data := resp.Data.(map[string]interface{})
+	content, err := base64.StdEncoding.DecodeString(data["content"].(string))
+	if err != nil {
+		sendMessage(chatID, tm("menu_import.export_invalid"), nil)
+		return
+	}
+
+	sendDocument(chatID, tgbotapi.FileBytes{Name: data["filename"].(string), Bytes: content},
+		tnm("menu_import.export_caption", int(data["count"].(float64))))
+}
+
+func startMenuImportDialog(chatID int64, userID int64) {
+	userStates[userID] = "menu_import"
+	userTempData[userID] = map[string]interface{}{}
+
+	sendMessage(chatID, tm("menu_import.prompt")+"\n\n"+tm("common.cancel_hint"), nil)
+}
+
+// handleMenuImportFile checks an uploaded file with a dry run and, when every
+// row is valid, asks for confirmation before applying it
+func handleMenuImportFile(msg *tgbotapi.Message, userID int64) {
+	if msg.Document == nil {
+		sendMessage(msg.Chat.ID, tm("menu_import.not_document"), nil)
+		return
+	}
+
+	format := strings.TrimPrefix(strings.ToLower(path.Ext(msg.Document.FileName)), ".")
+	if format != "csv" && format != "xlsx" {
+		sendMessage(msg.Chat.ID, tm("menu_import.format_invalid"), nil)
+		return
+	}
+	if msg.Document.FileSize > maxImportFileSize {
+		sendMessage(msg.Chat.ID, tm("menu_import.too_large"), nil)
+		return
+	}
+
+	content, err := downloadTelegramFile(msg.Document.FileID)
+	if err != nil {
+		shared.Logger(requestCtx).Error("failed to download import file", "error", err)
+		sendMessage(msg.Chat.ID, tm("menu_import.download_failed"), nil)
+		return
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(content)
+	result, errMsg := runMenuImport(format, encoded, true)
+	if result == nil {
+		sendMessage(msg.Chat.ID, errMsg, nil)
+		return
+	}
+
+	text := tm("menu_import.preview_title", msg.Document.FileName) + "\n\n"
+	text += tm("menu_import.preview_counts",
+		int(result["rows"].(float64)), int(result["created"].(float64)), int(result["updated"].(float64))) + "\n"
+	if categories, _ := result["new_categories"].([]interface{}); len(categories) > 0 {
+		names := make([]markup, len(categories))
+		for i, c := range categories {
+			names[i] = esc(c.(string))
+		}
+		text += tm("menu_import.new_categories", render.Join(names, ", ")) + "\n"
+	}
+
+	if errors, _ := result["errors"].([]interface{}); len(errors) > 0 {
+		text += "\n" + tnm("menu_import.errors_title", len(errors)) + "\n"
+		for i, item := range errors {
+			if i == importErrorLines {
+				text += tm("menu_import.errors_more", len(errors)-importErrorLines) + "\n"
+				break
+			}
+			rowErr := item.(map[string]interface{})
+			text += tm("menu_import.error_line", int(rowErr["row"].(float64)), importRowErrorText(rowErr)) + "\n"
+		}
+		text += "\n" + tm("menu_import.errors_hint")
+		sendMessage(msg.Chat.ID, text, nil)
+		return
+	}
+
+	userTempData[userID]["format"] = format
+	userTempData[userID]["content"] = encoded
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("wizard.btn_confirm"), "menu_import_apply"),
+			button(t("common.btn_cancel"), "menu_import_cancel"),
+		),
+	)
+	sendMessage(msg.Chat.ID, text, keyboard)
+}
+
+// applyMenuImport writes the file previewed by handleMenuImportFile
+func applyMenuImport(chatID int64, userID int64) {
+	data := userTempData[userID]
+	encoded, _ := data["content"].(string)
+	format, _ := data["format"].(string)
+	if userStates[userID] != "menu_import" || encoded == "" {
+		sendMessage(chatID, tm("menu_import.nothing_pending"), nil)
+		return
+	}
+
+	delete(userStates, userID)
+	delete(userTempData, userID)
+
+	result, errMsg := runMenuImport(format, encoded, false)
+	if result == nil {
+		sendMessage(chatID, errMsg, nil)
+		return
+	}
+	if applied, _ := result["applied"].(bool); !applied {
+		sendMessage(chatID, tm("menu_import.changed"), nil)
+		return
+	}
+
+	text := tm("menu_import.done",
+		int(result["created"].(float64)), int(result["updated"].(float64)))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("menu_import.btn_back"), "admin_menu"),
+		),
+	)
+	sendMessage(chatID, text, keyboard)
+}
+
+// runMenuImport sends a file to menu-service. New categories in the file are
+// created; the admin sees them in the preview before confirming.
+func runMenuImport(format, encoded string, dryRun bool) (map[string]interface{}, markup) {
+	resp, err := cachedPost(menuServiceURL, shared.Request{
+		Action: "import",
+		Payload: map[string]interface{}{
+			"format":            format,
+			"content":           encoded,
+			"dry_run":           dryRun,
+			"create_categories": true,
+		},
+	})
+
+	if err != nil || !resp.Success {
+		errMsg := tm("menu_import.process_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		return nil, errMsg
+	}
+
+	return resp.Data.(map[string]interface{})["result"].(map[string]interface{}), ""
+}
+
+// importRowErrorText returns the text of a row error in the current user's
+// language
+func importRowErrorText(rowErr map[string]interface{}) markup {
+	info := &shared.ErrorInfo{}
+	info.Message, _ = rowErr["message"].(string)
+	info.Key, _ = rowErr["key"].(string)
+	if params, ok := rowErr["params"].([]interface{}); ok {
+		for _, param := range params {
+			value, _ := param.(string)
+			info.Params = append(info.Params, value)
+		}
+	}
+	return errorText(info)
+}
+
+// downloadTelegramFile fetches a file sent to the bot
+func downloadTelegramFile(fileID string) ([]byte, error) {
+	url, err := bot.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxImportFileSize+1))
+}
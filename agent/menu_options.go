@@ -0,0 +1,535 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/alrescha79-cmd/bot-cafe/agent/render"
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// menuSelection tracks a customer stepping through the option groups of a menu
+type menuSelection struct {
+	MenuID   int
+	MenuName string
+	BranchID int
+	Groups   []interface{}
+	Step     int
+	Selected map[int]bool
+}
+
+// currentGroup returns the option group of the current step
+func (s *menuSelection) currentGroup() map[string]interface{} {
+	return s.Groups[s.Step].(map[string]interface{})
+}
+
+// countSelected counts the selected options of a group
+func (s *menuSelection) countSelected(group map[string]interface{}) int {
+	count := 0
+	for _, item := range group["options"].([]interface{}) {
+		option := item.(map[string]interface{})
+		if s.Selected[int(option["id"].(float64))] {
+			count++
+		}
+	}
+	return count
+}
+
+// optionIDs returns the selected option IDs in group order
+func (s *menuSelection) optionIDs() []int {
+	ids := []int{}
+	for _, g := range s.Groups {
+		for _, item := range g.(map[string]interface{})["options"].([]interface{}) {
+			id := int(item.(map[string]interface{})["id"].(float64))
+			if s.Selected[id] {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// CUSTOMER OPTION SELECTION
+
+func startOptionSelection(chatID int64, userID int64, menuID int) {
+	branchID := getUserBranch(userID)
+	resp, err := cachedPost(menuServiceURL, shared.Request{
+		Action: "read",
+		Payload: map[string]interface{}{
+			"id":        menuID,
+			"branch_id": branchID,
+			"locale":    currentLocale,
+		},
+	})
+
+	if err != nil || !resp.Success {
+		sendMessage(chatID, tm("menu.not_found"), nil)
+		return
+	}
+
+	data := resp.Data.(map[string]interface{})
+	menuData := data["menu"].(map[string]interface{})
+	groups, _ := data["option_groups"].([]interface{})
+
+	userSelections[userID] = &menuSelection{
+		MenuID:   menuID,
+		MenuName: menuData["name"].(string),
+		BranchID: branchID,
+		Groups:   groups,
+		Selected: make(map[int]bool),
+	}
+
+	if len(groups) == 0 {
+		showSelectionSummary(chatID, userID)
+		return
+	}
+	showOptionStep(chatID, userID)
+}
+
+func showOptionStep(chatID int64, userID int64) {
+	sel, ok := userSelections[userID]
+	if !ok {
+		sendMessage(chatID, tm("options.session_expired"), nil)
+		return
+	}
+	if sel.Step >= len(sel.Groups) {
+		// A button from an earlier step was tapped after the last group
+		showSelectionSummary(chatID, userID)
+		return
+	}
+
+	group := sel.currentGroup()
+	name := group["name"].(string)
+	minSelect := int(group["min_select"].(float64))
+	maxSelect := int(group["max_select"].(float64))
+
+	text := tm("options.step_title", sel.MenuName, sel.Step+1, len(sel.Groups)) + "\n\n"
+	text += md("*%s*\n", name)
+	switch {
+	case minSelect == 1 && maxSelect == 1:
+		text += tm("options.rule_one")
+	case minSelect == 0 && maxSelect == 1:
+		text += tm("options.rule_optional_one")
+	case minSelect == 0:
+		text += tm("options.rule_optional_max", maxSelect)
+	default:
+		text += tm("options.rule_range", minSelect, maxSelect)
+	}
+
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+	for _, item := range group["options"].([]interface{}) {
+		option := item.(map[string]interface{})
+		id := int(option["id"].(float64))
+		label := option["name"].(string)
+		if delta := money(option["price_delta"]); delta > 0 {
+			label += " (+" + price(delta) + ")"
+		} else if delta < 0 {
+			label += " (-" + price(-delta) + ")"
+		}
+		if available, _ := option["is_available"].(bool); !available {
+			label = t("options.sold_out_label", label)
+		}
+
+		marker := "▫️"
+		if sel.Selected[id] {
+			marker = "✅"
+		}
+
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			button(marker+" "+label, "opt_pick", id),
+		))
+	}
+
+	// Single-choice groups advance on tap; the rest need an explicit "next"
+	var nav []tgbotapi.InlineKeyboardButton
+	if sel.Step > 0 {
+		nav = append(nav, button(t("options.btn_prev"), "opt_prev"))
+	}
+	if maxSelect > 1 || minSelect == 0 {
+		label := t("options.btn_next")
+		if minSelect == 0 && sel.countSelected(group) == 0 {
+			label = t("options.btn_skip")
+		}
+		nav = append(nav, button(label, "opt_next"))
+	}
+	if len(nav) > 0 {
+		keyboard = append(keyboard, nav)
+	}
+	keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+		button(t("common.btn_cancel"), "menu_detail", sel.MenuID),
+	))
+
+	sendMessage(chatID, text, tgbotapi.NewInlineKeyboardMarkup(keyboard...))
+}
+
+func pickOption(chatID int64, userID int64, optionID int) {
+	sel, ok := userSelections[userID]
+	if !ok {
+		sendMessage(chatID, tm("options.session_expired"), nil)
+		return
+	}
+	if sel.Step >= len(sel.Groups) {
+		showSelectionSummary(chatID, userID)
+		return
+	}
+
+	group := sel.currentGroup()
+	maxSelect := int(group["max_select"].(float64))
+	minSelect := int(group["min_select"].(float64))
+
+	var picked map[string]interface{}
+	for _, item := range group["options"].([]interface{}) {
+		option := item.(map[string]interface{})
+		if int(option["id"].(float64)) == optionID {
+			picked = option
+		}
+	}
+	if picked == nil {
+		return
+	}
+	if available, _ := picked["is_available"].(bool); !available {
+		sendMessage(chatID, tm("options.sold_out", picked["name"].(string)), nil)
+		return
+	}
+
+	if maxSelect == 1 {
+		// Radio behaviour: replace any previous choice in this group
+		for _, item := range group["options"].([]interface{}) {
+			delete(sel.Selected, int(item.(map[string]interface{})["id"].(float64)))
+		}
+		sel.Selected[optionID] = true
+		if minSelect == 1 {
+			nextOptionStep(chatID, userID)
+			return
+		}
+		showOptionStep(chatID, userID)
+		return
+	}
+
+	if sel.Selected[optionID] {
+		delete(sel.Selected, optionID)
+	} else {
+		if sel.countSelected(group) >= maxSelect {
+			sendMessage(chatID, tm("options.too_many", maxSelect, group["name"].(string)), nil)
+			return
+		}
+		sel.Selected[optionID] = true
+	}
+	showOptionStep(chatID, userID)
+}
+
+func nextOptionStep(chatID int64, userID int64) {
+	sel, ok := userSelections[userID]
+	if !ok {
+		sendMessage(chatID, tm("options.session_expired"), nil)
+		return
+	}
+	if sel.Step >= len(sel.Groups) {
+		showSelectionSummary(chatID, userID)
+		return
+	}
+
+	group := sel.currentGroup()
+	minSelect := int(group["min_select"].(float64))
+	if sel.countSelected(group) < minSelect {
+		sendMessage(chatID, tm("options.too_few", minSelect, group["name"].(string)), nil)
+		return
+	}
+
+	sel.Step++
+	if sel.Step >= len(sel.Groups) {
+		showSelectionSummary(chatID, userID)
+		return
+	}
+	showOptionStep(chatID, userID)
+}
+
+func prevOptionStep(chatID int64, userID int64) {
+	sel, ok := userSelections[userID]
+	if !ok {
+		sendMessage(chatID, tm("options.session_expired"), nil)
+		return
+	}
+
+	if sel.Step > 0 {
+		sel.Step--
+	}
+	showOptionStep(chatID, userID)
+}
+
+// showSelectionSummary prices the selection through menu-service so the
+// total shown always matches what downstream services compute
+func showSelectionSummary(chatID int64, userID int64) {
+	sel, ok := userSelections[userID]
+	if !ok {
+		sendMessage(chatID, tm("options.session_expired"), nil)
+		return
+	}
+
+	resp, err := cachedPost(menuServiceURL, shared.Request{
+		Action: "quote",
+		Payload: map[string]interface{}{
+			"menu_id":    sel.MenuID,
+			"branch_id":  sel.BranchID,
+			"option_ids": sel.optionIDs(),
+			"quantity":   1,
+		},
+	})
+
+	if err != nil || !resp.Success {
+		errMsg := tm("options.quote_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		sendMessage(chatID, errMsg, nil)
+		return
+	}
+
+	quote := resp.Data.(map[string]interface{})["quote"].(map[string]interface{})
+	text := md("🧾 *%s*\n\n", quote["menu_name"].(string))
+	text += tm("options.base_price", price(money(quote["base_price"]))) + "\n"
+	for _, item := range quote["options"].([]interface{}) {
+		option := item.(map[string]interface{})
+		delta := money(option["price_delta"])
+		line := md("• %s: %s", option["group_name"].(string), option["name"].(string))
+		if delta > 0 {
+			line += md(" (+%s)", price(delta))
+		} else if delta < 0 {
+			line += md(" (-%s)", price(-delta))
+		}
+		text += line + "\n"
+	}
+	text += "\n" + tm("options.total", price(money(quote["unit_price"])))
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("options.btn_order"), "order_place", 1),
+			button("2x", "order_place", 2),
+			button("3x", "order_place", 3),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("options.btn_restart"), "opt_start", sel.MenuID),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("common.btn_back"), "menu_detail", sel.MenuID),
+		),
+	)
+
+	sendMessage(chatID, text, keyboard)
+}
+
+// ADMIN OPTION MANAGEMENT
+
+func showAdminMenuOptions(chatID int64, menuID int) {
+	resp, err := cachedPost(menuServiceURL, shared.Request{
+		Action: "read",
+		Payload: map[string]interface{}{
+			"id": menuID,
+		},
+	})
+
+	if err != nil || !resp.Success {
+		sendMessage(chatID, tm("menu.not_found"), nil)
+		return
+	}
+
+	data := resp.Data.(map[string]interface{})
+	menuData := data["menu"].(map[string]interface{})
+	groups, _ := data["option_groups"].([]interface{})
+
+	text := tm("options.admin_title", menuData["name"].(string)) + "\n\n"
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+
+	if len(groups) == 0 {
+		text += tm("options.admin_empty") + "\n"
+	}
+	for _, item := range groups {
+		group := item.(map[string]interface{})
+		groupID := int(group["id"].(float64))
+		groupName := group["name"].(string)
+
+		text += tm("options.admin_group", groupName,
+			int(group["min_select"].(float64)), int(group["max_select"].(float64))) + "\n"
+		for _, o := range group["options"].([]interface{}) {
+			option := o.(map[string]interface{})
+			text += md("   • %s (%+d)\n", option["name"].(string), money(option["price_delta"]))
+			optionID := int(option["id"].(float64))
+			keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+				button("🗑️ "+groupName+": "+option["name"].(string), "option_delete", optionID, menuID),
+				button(t("options.btn_option_recipe"), "recipe", menuID, optionID),
+			))
+		}
+		text += "\n"
+
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			button(t("options.btn_add_option", groupName), "optgroup_add_option", groupID, menuID),
+			button(t("options.btn_delete_group", groupName), "optgroup_delete", groupID, menuID),
+		))
+	}
+
+	keyboard = append(keyboard,
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("options.btn_create_group"), "optgroup_create", menuID),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			backButton("menu_options_list"),
+		),
+	)
+
+	sendMessage(chatID, text, tgbotapi.NewInlineKeyboardMarkup(keyboard...))
+}
+
+func startAddOptionGroupDialog(chatID int64, userID int64, menuID int) {
+	startWizard(chatID, userID, "add_option_group", map[string]interface{}{"menu_id": menuID})
+}
+
+// optionGroupRule reads how many options of a group may be picked, typed
+// as "min-max"
+func optionGroupRule(msg *tgbotapi.Message, _ map[string]interface{}) (interface{}, markup) {
+	parts := strings.Split(strings.TrimSpace(msg.Text), "-")
+	if len(parts) != 2 {
+		return nil, tm("options.group_rule_invalid")
+	}
+	minSelect, errMin := strconv.Atoi(strings.TrimSpace(parts[0]))
+	maxSelect, errMax := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if errMin != nil || errMax != nil {
+		return nil, tm("options.group_rule_not_numbers")
+	}
+	return []int{minSelect, maxSelect}, ""
+}
+
+func finishAddOptionGroup(chatID int64, userID int64, data map[string]interface{}) {
+	menuID := data["menu_id"].(int)
+	rule := data["rule"].([]int)
+	resp, err := cachedPost(menuServiceURL, shared.Request{
+		Action: "create_option_group",
+		Payload: map[string]interface{}{
+			"menu_id":    menuID,
+			"name":       data["name"],
+			"min_select": rule[0],
+			"max_select": rule[1],
+		},
+	})
+
+	if err != nil || !resp.Success {
+		errMsg := tm("options.group_create_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		sendMessage(chatID, errMsg, nil)
+		return
+	}
+
+	group := resp.Data.(map[string]interface{})["option_group"].(map[string]interface{})
+	startAddOptionDialog(chatID, userID, int(group["id"].(float64)), menuID)
+}
+
+func startAddOptionDialog(chatID int64, userID int64, groupID int, menuID int) {
+	userStates[userID] = "add_option"
+	userTempData[userID] = map[string]interface{}{
+		"group_id": groupID,
+		"menu_id":  menuID,
+	}
+	sendMessage(chatID, tm("options.prompt_options")+"\n\n"+tm("common.cancel_hint"), nil)
+}
+
+func handleAddOption(msg *tgbotapi.Message, userID int64) {
+	data := userTempData[userID]
+	groupID := data["group_id"].(int)
+	menuID := data["menu_id"].(int)
+
+	added := 0
+	var failed []markup
+	for _, line := range strings.Split(msg.Text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		// The last word is the price delta if it parses as a number
+		name := line
+		var delta shared.Money
+		if i := strings.LastIndex(line, " "); i > 0 {
+			if d, err := shared.ParseMoney(line[i+1:]); err == nil {
+				name = strings.TrimSpace(line[:i])
+				delta = d
+			}
+		}
+
+		resp, err := cachedPost(menuServiceURL, shared.Request{
+			Action: "create_option",
+			Payload: map[string]interface{}{
+				"group_id":    groupID,
+				"name":        name,
+				"price_delta": delta,
+			},
+		})
+		if err != nil || !resp.Success {
+			failed = append(failed, esc(name))
+			continue
+		}
+		added++
+	}
+
+	delete(userStates, userID)
+	delete(userTempData, userID)
+
+	text := tnm("options.added", added)
+	if len(failed) > 0 {
+		text += "\n" + tm("options.add_failed", render.Join(failed, ", "))
+	}
+	sendMessage(msg.Chat.ID, text, nil)
+	showAdminMenuOptions(msg.Chat.ID, menuID)
+}
+
+func deleteOptionGroup(chatID int64, groupID int, menuID int) {
+	resp, err := cachedPost(menuServiceURL, shared.Request{
+		Action: "delete_option_group",
+		Payload: map[string]interface{}{
+			"id": groupID,
+		},
+	})
+
+	if err != nil || !resp.Success {
+		sendMessage(chatID, tm("options.group_delete_failed"), nil)
+		return
+	}
+
+	showAdminMenuOptions(chatID, menuID)
+}
+
+func deleteOption(chatID int64, optionID int, menuID int) {
+	resp, err := cachedPost(menuServiceURL, shared.Request{
+		Action: "delete_option",
+		Payload: map[string]interface{}{
+			"id": optionID,
+		},
+	})
+
+	if err != nil || !resp.Success {
+		sendMessage(chatID, tm("options.delete_failed"), nil)
+		return
+	}
+
+	showAdminMenuOptions(chatID, menuID)
+}
+
+func init() {
+	registerWizard(&wizard{
+		name:   "add_option_group",
+		finish: finishAddOptionGroup,
+		steps: []wizardStep{
+			{
+				key:    "name",
+				prompt: textPrompt("options.group_prompt_name"),
+				parse:  requiredText("options.group_name_empty"),
+			},
+			{
+				key:    "rule",
+				prompt: textPrompt("options.group_prompt_rule"),
+				parse:  optionGroupRule,
+			},
+		},
+	})
+}
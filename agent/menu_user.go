@@ -1,7 +1,7 @@
 package main
 
 import (
-	"fmt"
+	"strings"
 
 	"github.com/alrescha79-cmd/bot-cafe/shared"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
@@ -12,119 +12,156 @@ import (
 func showUserMenu(chatID int64) {
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("☕ Coffee", "menu_category:Coffee"),
-			tgbotapi.NewInlineKeyboardButtonData("🍽️ Makanan", "menu_category:Makanan"),
+			button(t("menu.category_coffee"), "menu_category", "Coffee"),
+			button(t("menu.category_food"), "menu_category", "Makanan"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🥤 Minuman", "menu_category:Minuman"),
-			tgbotapi.NewInlineKeyboardButtonData("🍪 Snack", "menu_category:Snack"),
+			button(t("menu.category_drinks"), "menu_category", "Minuman"),
+			button(t("menu.category_snack"), "menu_category", "Snack"),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🏠 Kembali ke Menu Utama", "back:start"),
+			button(t("common.btn_home"), "back", "start"),
 		),
 	)
 
-	sendMessage(chatID, "📋 *Menu Café*\n\nPilih kategori:", keyboard)
+	sendMessage(chatID, tm("menu.title"), keyboard)
 }
 
-func showMenuByCategory(chatID int64, category string) {
-	resp, err := httpClient.Post(menuServiceURL, shared.Request{
+func showMenuByCategory(chatID int64, category string, branchID int, page int) {
+	resp, err := cachedPost(menuServiceURL, shared.Request{
 		Action: "list",
-		Payload: map[string]interface{}{
+		Payload: pagePayload(map[string]interface{}{
 			"category":       category,
 			"available_only": true,
-		},
+			"branch_id":      branchID,
+			"locale":         currentLocale,
+		}, page),
 	})
 
 	if err != nil || !resp.Success {
-		sendMessage(chatID, "⚠️ Gagal memuat menu.", nil)
+		sendMessage(chatID, tm("menu.load_failed"), nil)
 		return
 	}
 
-	menusData, ok := resp.Data.(map[string]interface{})["menus"].([]interface{})
+	data := resp.Data.(map[string]interface{})
+	menusData, ok := data["menus"].([]interface{})
+	if len(menusData) == 0 && page > 0 {
+		// The page emptied since it was shown, e.g. after a delete
+		showMenuByCategory(chatID, category, branchID, pageCount(listTotal(data, menusData))-1)
+		return
+	}
 	if !ok || len(menusData) == 0 {
-		sendMessage(chatID, fmt.Sprintf("Tidak ada menu dalam kategori *%s*", category), nil)
+		sendMessage(chatID, tm("menu.category_empty", category), nil)
 		return
 	}
 
-	text := fmt.Sprintf("📋 *Menu %s*\n\n", category)
+	total := listTotal(data, menusData)
+	text := tnm("menu.category_title", total, category) + "\n\n"
 	var keyboard [][]tgbotapi.InlineKeyboardButton
 
 	for _, item := range menusData {
 		menu := item.(map[string]interface{})
 		name := menu["name"].(string)
-		price := int(menu["price"].(float64))
+		menuPrice := money(menu["price"])
 		id := int(menu["id"].(float64))
 
-		text += fmt.Sprintf("🍽️ *%s*\nHarga: %s\n\n", name, shared.FormatPrice(price))
+		text += tm("menu.item", name, price(menuPrice)) + "\n\n"
 
 		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📖 "+name, fmt.Sprintf("menu_detail:%d", id)),
+			button("📖 "+name, "menu_detail", id),
 		))
 	}
 
+	pageLine, pageRow := pageFooter(page, total, "menu_category", category)
+	text += pageLine
+	if pageRow != nil {
+		keyboard = append(keyboard, pageRow)
+	}
 	keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("⬅️ Kembali", "back:user"),
+		backButton("show_user_menu"),
 	))
 
 	sendMessage(chatID, text, tgbotapi.NewInlineKeyboardMarkup(keyboard...))
 }
 
-func showMenuDetail(chatID int64, menuID int) {
-	resp, err := httpClient.Post(menuServiceURL, shared.Request{
+func showMenuDetail(chatID int64, menuID int, branchID int) {
+	resp, err := cachedPost(menuServiceURL, shared.Request{
 		Action: "read",
 		Payload: map[string]interface{}{
-			"id": menuID,
+			"id":        menuID,
+			"branch_id": branchID,
+			"locale":    currentLocale,
 		},
 	})
 
 	if err != nil || !resp.Success {
-		sendMessage(chatID, "⚠️ Menu tidak ditemukan.", nil)
+		sendMessage(chatID, tm("menu.not_found"), nil)
 		return
 	}
 
 	menuData := resp.Data.(map[string]interface{})["menu"].(map[string]interface{})
+	groups, _ := resp.Data.(map[string]interface{})["option_groups"].([]interface{})
 	name := menuData["name"].(string)
 	description := menuData["description"].(string)
-	price := int(menuData["price"].(float64))
+	menuPrice := money(menuData["price"])
 	category := menuData["category"].(string)
 
-	text := fmt.Sprintf("🍽️ *%s*\n\n", name)
+	text := md("🍽️ *%s*\n\n", name)
 	if description != "" {
-		text += fmt.Sprintf("%s\n\n", description)
+		text += esc(description) + "\n\n"
 	}
-	text += fmt.Sprintf("💰 Harga: %s\n", shared.FormatPrice(price))
-	text += fmt.Sprintf("📁 Kategori: %s\n", category)
-
-	keyboard := tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("⬅️ Kembali", fmt.Sprintf("menu_category:%s", category)),
-		),
-	)
+	text += tm("menu.detail_price", price(menuPrice)) + "\n"
+	text += tm("menu.detail_category", category) + "\n"
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if len(groups) > 0 {
+		text += "\n" + tm("menu.detail_options") + "\n"
+		for _, item := range groups {
+			group := item.(map[string]interface{})
+			var names []string
+			for _, o := range group["options"].([]interface{}) {
+				names = append(names, o.(map[string]interface{})["name"].(string))
+			}
+			text += md("• %s: %s\n", group["name"].(string), strings.Join(names, ", "))
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			button(t("menu.btn_pick_options"), "opt_start", menuID),
+		))
+	} else if available, _ := menuData["is_available"].(bool); available {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			button(t("menu.btn_order"), "opt_start", menuID),
+		))
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		backButton("menu_category", category),
+	))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
 
 	sendMessage(chatID, text, keyboard)
 }
 
-func showPromos(chatID int64, activeOnly bool) {
+func showPromos(chatID int64, activeOnly bool, branchID int) {
 	resp, err := httpClient.Post(promoServiceURL, shared.Request{
 		Action: "list",
 		Payload: map[string]interface{}{
 			"active_only": activeOnly,
+			"branch_id":   branchID,
+			"locale":      currentLocale,
 		},
 	})
 
 	if err != nil || !resp.Success {
-		sendMessage(chatID, "⚠️ Gagal memuat promo.", nil)
+		sendMessage(chatID, tm("promo.load_failed"), nil)
 		return
 	}
 
 	promosData, ok := resp.Data.(map[string]interface{})["promos"].([]interface{})
 	if !ok || len(promosData) == 0 {
-		sendMessage(chatID, "Belum ada promo tersedia saat ini.", nil)
+		sendMessage(chatID, tm("promo.none_available"), nil)
 		return
 	}
 
-	text := "🎉 *Promo Tersedia*\n\n"
+	text := tnm("promo.available_title", len(promosData)) + "\n\n"
 
 	for _, item := range promosData {
 		promo := item.(map[string]interface{})
@@ -133,39 +170,43 @@ func showPromos(chatID int64, activeOnly bool) {
 		discount := int(promo["discount"].(float64))
 		discountType := promo["discount_type"].(string)
 
-		text += fmt.Sprintf("🎁 *%s*\n", title)
+		text += md("🎁 *%s*\n", title)
 		if description != "" {
-			text += fmt.Sprintf("%s\n", description)
+			text += esc(description) + "\n"
 		}
 
 		if discountType == "percentage" {
-			text += fmt.Sprintf("Diskon: %d%%\n", discount)
+			text += tm("promo.discount_percent", discount) + "\n"
 		} else {
-			text += fmt.Sprintf("Diskon: %s\n", shared.FormatPrice(discount))
+			text += tm("promo.discount_amount", price(shared.Money(discount))) + "\n"
 		}
 		text += "\n"
 	}
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🏠 Kembali ke Menu Utama", "back:start"),
+			button(t("common.btn_home"), "back", "start"),
 		),
 	)
 
 	sendMessage(chatID, text, keyboard)
 }
 
-func showCafeInfo(chatID int64) {
-	resp, err := httpClient.Post(infoServiceURL, shared.Request{
+func showCafeInfo(chatID int64, branchID int) {
+	resp, err := cachedPost(infoServiceURL, shared.Request{
 		Action: "read",
+		Payload: map[string]interface{}{
+			"branch_id": branchID,
+		},
 	})
 
 	if err != nil || !resp.Success {
-		sendMessage(chatID, "⚠️ Gagal memuat informasi café.", nil)
+		sendMessage(chatID, tm("info.load_failed"), nil)
 		return
 	}
 
 	infoData := resp.Data.(map[string]interface{})["info"].(map[string]interface{})
+	isOpen, _ := resp.Data.(map[string]interface{})["is_open"].(bool)
 	name := infoData["name"].(string)
 	address := infoData["address"].(string)
 	phone := infoData["phone"].(string)
@@ -173,17 +214,30 @@ func showCafeInfo(chatID int64) {
 	closingHour := infoData["closing_hour"].(string)
 	description := infoData["description"].(string)
 
-	text := fmt.Sprintf("ℹ️ *%s*\n\n", name)
+	status := tm("info.closed")
+	if isOpen {
+		status = tm("info.open")
+	}
+
+	text := md("ℹ️ *%s*\n\n", name)
 	if description != "" {
-		text += fmt.Sprintf("%s\n\n", description)
+		text += esc(description) + "\n\n"
+	}
+	text += tm("info.address", address) + "\n"
+	text += tm("info.phone", phone) + "\n"
+	text += tm("info.hours", openingHour, closingHour) + "\n"
+	text += status + "\n"
+
+	// Send the venue first so the map pin sits above the details
+	lat, hasLat := infoData["latitude"].(float64)
+	lon, hasLon := infoData["longitude"].(float64)
+	if hasLat && hasLon {
+		bot.Send(tgbotapi.NewVenue(chatID, name, address, lat, lon))
 	}
-	text += fmt.Sprintf("📍 Alamat: %s\n", address)
-	text += fmt.Sprintf("📞 Telepon: %s\n", phone)
-	text += fmt.Sprintf("🕐 Jam Buka: %s - %s\n", openingHour, closingHour)
 
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🏠 Kembali ke Menu Utama", "back:start"),
+			button(t("common.btn_home"), "back", "start"),
 		),
 	)
 
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+)
+
+// Commands handled by handleCommand; other commands share one metrics label
+var botCommands = []string{"start", "menu", "promo", "info", "cabang", "pesanan", "reservasi", "laporan", "backup", "habis", "status", "bahasa", "admin", "cancel"}
+
+var (
+	updatesTotal = shared.NewCounter("cafe_agent_updates_total",
+		"Telegram updates and jobs handled, by action.", "action")
+	updateDuration = shared.NewHistogram("cafe_agent_update_duration_seconds",
+		"Time to handle a Telegram update or job, by action.", shared.DefaultBuckets, "action")
+	activeDialogs = shared.NewGauge("cafe_agent_active_dialogs",
+		"Users in the middle of a dialog, by dialog state.", "state")
+
+	telegramRequestsTotal = shared.NewCounter("cafe_telegram_api_requests_total",
+		"Telegram Bot API calls, by method.", "method")
+	telegramRequestFailures = shared.NewCounter("cafe_telegram_api_failures_total",
+		"Telegram Bot API calls that failed or were rejected, by method.", "method")
+	telegramRequestDuration = shared.NewHistogram("cafe_telegram_api_duration_seconds",
+		"Time until the Telegram Bot API answered, by method.", shared.DefaultBuckets, "method")
+)
+
+// serveMetrics serves /metrics, /health, the aggregated /status and the
+// /events services post their changes to on port
+func serveMetrics(port string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", shared.MetricsHandler)
+	mux.HandleFunc("/health", shared.NewHealth("agent").HandleHealth)
+	mux.HandleFunc("/status", handleStatus)
+	mux.HandleFunc("/events", handleEvents)
+
+	addr := fmt.Sprintf(":%s", port)
+	shared.Logger(requestCtx).Info("agent metrics listening", "addr", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		shared.Logger(requestCtx).Error("failed to serve metrics", "error", err)
+	}
+}
+
+// recordUpdate counts a handled update or job and refreshes the dialog gauge
+func recordUpdate(action string, duration time.Duration) {
+	if command, ok := strings.CutPrefix(action, "/"); ok && !shared.Contains(botCommands, command) {
+		action = "/unknown"
+	}
+	updatesTotal.Inc(action)
+	updateDuration.Observe(duration.Seconds(), action)
+
+	counts := map[string]int{}
+	for _, state := range userStates {
+		counts[state]++
+	}
+	activeDialogs.Reset()
+	for state, count := range counts {
+		activeDialogs.Set(float64(count), state)
+	}
+}
+
+// telegramClient is the HTTP client of the bot. It records every Bot API
+// call; a call fails when it gets no answer or a status other than 200.
+type telegramClient struct {
+	client *http.Client
+}
+
+func (c *telegramClient) Do(req *http.Request) (*http.Response, error) {
+	method := path.Base(req.URL.Path)
+
+	// Calls made while handling an update are part of its trace. Only
+	// getUpdates runs outside the update loop, so it never reads requestCtx.
+	var span *shared.Span
+	if method != "getUpdates" {
+		_, span = shared.StartSpan(requestCtx, "telegram "+method, shared.SpanKindClient)
+	}
+
+	start := time.Now()
+	resp, err := c.client.Do(req)
+
+	telegramRequestsTotal.Inc(method)
+	telegramRequestDuration.Observe(time.Since(start).Seconds(), method)
+	failed := err != nil || resp.StatusCode != http.StatusOK
+	if failed {
+		telegramRequestFailures.Inc(method)
+	}
+	if span != nil {
+		if failed {
+			span.SetError("telegram API call failed")
+		}
+		span.Finish()
+	}
+	return resp, err
+}
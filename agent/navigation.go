@@ -0,0 +1,178 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/alrescha79-cmd/bot-cafe/agent/render"
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Buttons that only move between screens replace the message they sit on
+// instead of sending a new one below it, so a chat holds one live menu
+// rather than a trail of stale keyboards. The screens a user went through
+// are kept on a stack, which "⬅️ Kembali" walks back.
+
+// Deepest navigation stack kept per user
+const maxNavDepth = 20
+
+var (
+	// navMessage is the message whose button opened the screen being shown;
+	// the first message sent while handling it replaces that message
+	navMessage *tgbotapi.Message
+
+	// Screens each user went through, oldest first
+	navStacks = make(map[int64][]callbackCall)
+)
+
+// isRootScreen reports whether call shows a screen that starts over: the
+// stack is cleared when one is shown
+func isRootScreen(call callbackCall) bool {
+	switch call.action {
+	case "show_admin_panel":
+		return true
+	case "back":
+		return len(call.args) > 0 && (call.args[0] == "admin" || call.args[0] == "start")
+	}
+	return false
+}
+
+// backButton returns the "⬅️ Kembali" button of a screen. It goes to the
+// screen the user came from, or to fallback when that is unknown, e.g.
+// after a restart or on an old message.
+func backButton(fallback string, args ...interface{}) tgbotapi.InlineKeyboardButton {
+	return button(t("common.btn_back"), "nav_back", append([]interface{}{fallback}, args...)...)
+}
+
+// pushScreen records that a user opened screen. Opening a screen already on
+// the stack goes back to it, so moving in circles or between the pages of a
+// list does not grow the stack.
+func pushScreen(userID int64, screen callbackCall) {
+	if isRootScreen(screen) {
+		navStacks[userID] = []callbackCall{screen}
+		return
+	}
+
+	stack := navStacks[userID]
+	key := screenKey(screen)
+	for i, s := range stack {
+		if screenKey(s) == key {
+			stack[i] = screen
+			navStacks[userID] = stack[:i+1]
+			return
+		}
+	}
+	if len(stack) == maxNavDepth {
+		stack = stack[1:]
+	}
+	navStacks[userID] = append(stack, screen)
+}
+
+// popScreen leaves the current screen and returns the one before it, or
+// fallback when there is none
+func popScreen(userID int64, fallback callbackCall) callbackCall {
+	stack := navStacks[userID]
+	if len(stack) > 0 {
+		stack = stack[:len(stack)-1]
+	}
+	if len(stack) == 0 {
+		delete(navStacks, userID)
+		return fallback
+	}
+	// The previous screen is pushed again when it is shown
+	navStacks[userID] = stack[:len(stack)-1]
+	return stack[len(stack)-1]
+}
+
+// resetNavigation forgets the screens of a user, e.g. when they type a
+// command and start somewhere new
+func resetNavigation(userID int64) {
+	delete(navStacks, userID)
+}
+
+// takeNavMessage returns the message to replace for a message sent to
+// chatID, once per handled button
+func takeNavMessage(chatID int64) *tgbotapi.Message {
+	target := navMessage
+	if target == nil || target.Chat == nil || target.Chat.ID != chatID {
+		return nil
+	}
+	navMessage = nil
+	return target
+}
+
+// replaceText edits target to show text, reporting whether it did. Photos
+// and other media cannot become text, so those are left for a new message.
+func replaceText(target *tgbotapi.Message, text markup, keyboard interface{}) bool {
+	if target.Text == "" {
+		return false
+	}
+
+	var edit tgbotapi.EditMessageTextConfig
+	switch markup := keyboard.(type) {
+	case nil:
+		edit = tgbotapi.NewEditMessageText(target.Chat.ID, target.MessageID, render.HTML(text))
+	case tgbotapi.InlineKeyboardMarkup:
+		edit = tgbotapi.NewEditMessageTextAndMarkup(target.Chat.ID, target.MessageID, render.HTML(text), markup)
+	default:
+		// Reply keyboards are only shown with new messages
+		return false
+	}
+	edit.ParseMode = tgbotapi.ModeHTML
+	return sendEdit(edit)
+}
+
+// replacePhoto edits target, a photo, to show another photo
+func replacePhoto(target *tgbotapi.Message, file tgbotapi.RequestFileData, caption markup, keyboard interface{}) bool {
+	if len(target.Photo) == 0 {
+		return false
+	}
+
+	media := tgbotapi.NewInputMediaPhoto(file)
+	media.Caption = render.HTML(caption)
+	media.ParseMode = tgbotapi.ModeHTML
+	edit := tgbotapi.EditMessageMediaConfig{
+		BaseEdit: tgbotapi.BaseEdit{ChatID: target.Chat.ID, MessageID: target.MessageID},
+		Media:    media,
+	}
+	switch markup := keyboard.(type) {
+	case nil:
+	case tgbotapi.InlineKeyboardMarkup:
+		edit.ReplyMarkup = &markup
+	default:
+		return false
+	}
+	return sendEdit(edit)
+}
+
+// sendEdit sends an edit, reporting whether the message now shows it
+func sendEdit(edit tgbotapi.Chattable) bool {
+	_, err := bot.Send(edit)
+	if err == nil || strings.Contains(err.Error(), "message is not modified") {
+		return true
+	}
+	shared.Logger(requestCtx).Debug("message not edited, sending a new one", "error", err)
+	return false
+}
+
+// retireMessage removes the keyboard of a message that could not be
+// replaced, so only the new message can be navigated from
+func retireMessage(target *tgbotapi.Message) {
+	if target.ReplyMarkup == nil {
+		return
+	}
+	bot.Send(tgbotapi.NewEditMessageReplyMarkup(target.Chat.ID, target.MessageID,
+		tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}))
+}
+
+// screenKey identifies the screen call shows, whatever page of it
+func screenKey(call callbackCall) string {
+	args := call.args
+	if route, ok := callbackRoutes[call.action]; ok && route.paged && len(args) > 0 {
+		kinds := strings.Trim(strings.Replace(route.args, "?", "", 1), "*")
+		if len(args) == len(kinds) {
+			args = args[:len(args)-1]
+		}
+	}
+	return call.action + "\x00" + strings.Join(args, "\x00")
+}
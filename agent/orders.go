@@ -0,0 +1,248 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// orderStatusActions is the admin button label that moves an order to a status
+var orderStatusActions = map[string]string{
+	"preparing": "order.btn_preparing",
+	"ready":     "order.btn_ready",
+	"completed": "order.btn_completed",
+	"cancelled": "order.btn_cancelled",
+}
+
+// customerStatusMessages is sent to the customer when their order changes
+var customerStatusMessages = map[string]string{
+	"preparing": "order.customer_preparing",
+	"ready":     "order.customer_ready",
+	"completed": "order.customer_completed",
+	"cancelled": "order.customer_cancelled",
+}
+
+// CUSTOMER ORDERS
+
+func placeOrder(chatID int64, user *tgbotapi.User, quantity int) {
+	sel, ok := userSelections[user.ID]
+	if !ok {
+		sendMessage(chatID, tm("options.session_expired"), nil)
+		return
+	}
+
+	resp, err := httpClient.Post(orderServiceURL, shared.Request{
+		Action: "create",
+		Payload: map[string]interface{}{
+			"telegram_id":   strconv.FormatInt(user.ID, 10),
+			"customer_name": user.FirstName,
+			"branch_id":     sel.BranchID,
+			"items": []map[string]interface{}{
+				{
+					"menu_id":    sel.MenuID,
+					"option_ids": sel.optionIDs(),
+					"quantity":   quantity,
+				},
+			},
+		},
+	})
+
+	if err != nil || !resp.Success {
+		errMsg := tm("order.create_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		sendMessage(chatID, errMsg, nil)
+		return
+	}
+
+	delete(userSelections, user.ID)
+
+	order := resp.Data.(map[string]interface{})["order"].(map[string]interface{})
+	queueNumber := int(order["queue_number"].(float64))
+
+	text := tm("order.created", queueNumber) + "\n"
+	text += formatOrderItems(order)
+	text += "\n" + tm("order.total", price(money(order["total"]))) + "\n\n" + tm("order.created_hint")
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("order.btn_my_orders"), "my_orders"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("common.btn_home"), "back", "start"),
+		),
+	)
+	sendMessage(chatID, text, keyboard)
+
+	inLocale(shared.DefaultLocale, func() {
+		notifyAdmins(tm("order.admin_new", queueNumber, user.FirstName, branchName(sel.BranchID)) + "\n" +
+			formatOrderItems(order) + "\n" + tm("order.admin_new_hint"))
+	})
+}
+
+func showMyOrders(chatID int64, userID int64) {
+	resp, err := httpClient.Post(orderServiceURL, shared.Request{
+		Action: "list",
+		Payload: map[string]interface{}{
+			"telegram_id": strconv.FormatInt(userID, 10),
+			"limit":       5,
+		},
+	})
+
+	if err != nil || !resp.Success {
+		sendMessage(chatID, tm("order.load_failed"), nil)
+		return
+	}
+
+	orders, _ := resp.Data.(map[string]interface{})["orders"].([]interface{})
+	if len(orders) == 0 {
+		sendMessage(chatID, tm("order.mine_empty"), nil)
+		return
+	}
+
+	text := tm("order.mine_title") + "\n\n"
+	for _, item := range orders {
+		order := item.(map[string]interface{})
+		text += md("*#%d* — %s\n", int(order["queue_number"].(float64)), t("order.status_"+order["status"].(string)))
+		text += formatOrderItems(order)
+		text += md("💰 %s\n\n", price(money(order["total"])))
+	}
+
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("common.btn_home"), "back", "start"),
+		),
+	)
+	sendMessage(chatID, text, keyboard)
+}
+
+// formatOrderItems lists the lines of an order, one per line
+func formatOrderItems(order map[string]interface{}) markup {
+	text := markup("")
+	items, _ := order["items"].([]interface{})
+	for _, i := range items {
+		item := i.(map[string]interface{})
+		text += md("• %dx %s", int(item["quantity"].(float64)), item["menu_name"].(string))
+		if options, ok := item["options"].(string); ok && options != "" {
+			text += " (" + esc(options) + ")"
+		}
+		text += "\n"
+	}
+	return text
+}
+
+// ADMIN ORDERS
+
+func showAdminOrders(chatID int64, branchID int) {
+	resp, err := httpClient.Post(orderServiceURL, shared.Request{
+		Action: "list",
+		Payload: map[string]interface{}{
+			"status":    "active",
+			"branch_id": branchID,
+		},
+	})
+
+	if err != nil || !resp.Success {
+		sendMessage(chatID, tm("order.load_failed"), nil)
+		return
+	}
+
+	orders, _ := resp.Data.(map[string]interface{})["orders"].([]interface{})
+	text := tm("order.admin_title") + "\n\n"
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+
+	if len(orders) == 0 {
+		text += tm("order.admin_empty") + "\n"
+	}
+
+	// Oldest first so the queue reads top to bottom
+	for i := len(orders) - 1; i >= 0; i-- {
+		order := orders[i].(map[string]interface{})
+		id := int(order["id"].(float64))
+		queueNumber := int(order["queue_number"].(float64))
+		status := order["status"].(string)
+
+		text += md("*#%d* %s — %s", queueNumber, order["customer_name"].(string), t("order.status_"+status))
+		if branchID == 0 {
+			text += md(" (%s)", branchName(int(order["branch_id"].(float64))))
+		}
+		text += "\n" + formatOrderItems(order) + "\n"
+
+		var row []tgbotapi.InlineKeyboardButton
+		for _, next := range nextOrderStatuses(status) {
+			row = append(row, button(
+				fmt.Sprintf("#%d %s", queueNumber, t(orderStatusActions[next])),
+				"order_status", id, next))
+		}
+		if len(row) > 0 {
+			keyboard = append(keyboard, row)
+		}
+	}
+
+	keyboard = append(keyboard,
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("order.btn_refresh"), "admin_orders"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("admin.btn_back_panel"), "back", "admin"),
+		),
+	)
+
+	sendMessage(chatID, text, tgbotapi.NewInlineKeyboardMarkup(keyboard...))
+}
+
+// nextOrderStatuses mirrors the transitions allowed by order-service
+func nextOrderStatuses(status string) []string {
+	switch status {
+	case "pending":
+		return []string{"preparing", "cancelled"}
+	case "preparing":
+		return []string{"ready", "cancelled"}
+	case "ready":
+		return []string{"completed", "cancelled"}
+	}
+	return nil
+}
+
+func updateOrderStatus(chatID int64, orderID int, status string, branchID int) {
+	resp, err := httpClient.Post(orderServiceURL, shared.Request{
+		Action: "update_status",
+		Payload: map[string]interface{}{
+			"id":     orderID,
+			"status": status,
+		},
+	})
+
+	if err != nil || !resp.Success {
+		errMsg := tm("order.status_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		sendMessage(chatID, errMsg, nil)
+		return
+	}
+
+	data := resp.Data.(map[string]interface{})
+	order := data["order"].(map[string]interface{})
+
+	// Let the customer know in their own language; orders are placed from
+	// private chats, so the Telegram ID is also the chat ID
+	if customerID, err := strconv.ParseInt(order["telegram_id"].(string), 10, 64); err == nil {
+		if key, ok := customerStatusMessages[status]; ok {
+			inLocale(userLocale(&tgbotapi.User{ID: customerID}), func() {
+				sendMessage(customerID, tm(key, int(order["queue_number"].(float64))), nil)
+			})
+		}
+	}
+
+	if stock, ok := data["stock"].(map[string]interface{}); ok {
+		reportStockChanges(int(order["branch_id"].(float64)), stock)
+	} else if status == "completed" && !order["stock_deducted"].(bool) {
+		sendMessage(chatID, tm("order.stock_pending"), nil)
+	}
+
+	showAdminOrders(chatID, branchID)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Rows shown per page of a list screen; a screen lists one button per row,
+// so this also bounds its keyboard
+const listPageSize = 8
+
+// pagePayload adds the limit and offset of page to a list request
+func pagePayload(payload map[string]interface{}, page int) map[string]interface{} {
+	payload["limit"] = listPageSize
+	payload["offset"] = page * listPageSize
+	return payload
+}
+
+// pageCount is the number of pages total rows take, at least one
+func pageCount(total int) int {
+	if total <= listPageSize {
+		return 1
+	}
+	return (total + listPageSize - 1) / listPageSize
+}
+
+// pageFooter returns the page line of a list screen and its previous/next
+// buttons, which press action with args and another page. Lists that fit on
+// one page get neither.
+func pageFooter(page, total int, action string, args ...interface{}) (markup, []tgbotapi.InlineKeyboardButton) {
+	pages := pageCount(total)
+	if pages == 1 {
+		return "", nil
+	}
+
+	pageButton := func(label string, to int) tgbotapi.InlineKeyboardButton {
+		return button(label, action, append(args[:len(args):len(args)], to)...)
+	}
+	var row []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		row = append(row, pageButton(t("common.btn_prev"), page-1))
+	}
+	if page < pages-1 {
+		row = append(row, pageButton(t("common.btn_next"), page+1))
+	}
+	return "\n" + tm("common.page", page+1, pages), row
+}
+
+// listTotal reads the total of a list response, falling back to the rows
+// it holds
+func listTotal(data map[string]interface{}, rows []interface{}) int {
+	if total, ok := data["total"].(float64); ok {
+		return int(total)
+	}
+	return len(rows)
+}
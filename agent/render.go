@@ -0,0 +1,111 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/alrescha79-cmd/bot-cafe/agent/render"
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// markup is message text, see package render. The send functions only take
+// markup, so values from users or services must go through esc, md or tm.
+type markup = render.Markup
+
+// esc escapes a value for use in message text
+func esc(value string) markup {
+	return render.Esc(value)
+}
+
+// md formats message text like fmt.Sprintf, escaping every string argument
+func md(format markup, args ...interface{}) markup {
+	return render.Format(format, args...)
+}
+
+// tm returns the catalogue text of key for message text, escaping every
+// string argument
+func tm(key string, args ...interface{}) markup {
+	return markup(t(key, render.EscapeArgs(args)...))
+}
+
+// tnm is tn for message text, escaping every string argument
+func tnm(key string, n int, args ...interface{}) markup {
+	return markup(tn(key, n, render.EscapeArgs(args)...))
+}
+
+func sendMessage(chatID int64, text markup, keyboard interface{}) {
+	parts := render.Split(text, render.MaxMessageLength)
+	// A screen opened by a button replaces the message the button was on
+	if target := takeNavMessage(chatID); target != nil {
+		if len(parts) == 1 && replaceText(target, parts[0], keyboard) {
+			return
+		}
+		retireMessage(target)
+	}
+	for i, part := range parts {
+		msg := tgbotapi.NewMessage(chatID, render.HTML(part))
+		msg.ParseMode = tgbotapi.ModeHTML
+		// The keyboard goes under the last part, where users read on
+		if keyboard != nil && i == len(parts)-1 {
+			msg.ReplyMarkup = keyboard
+		}
+		sendRendered(msg, part)
+	}
+}
+
+// sendRendered sends a rendered message, falling back to plain text if
+// Telegram rejects its formatting
+func sendRendered(msg tgbotapi.MessageConfig, source markup) {
+	if _, err := bot.Send(msg); err != nil && strings.Contains(err.Error(), "can't parse entities") {
+		shared.Logger(requestCtx).Warn("message formatting rejected, sending plain text", "error", err)
+		msg.Text = render.Plain(source)
+		msg.ParseMode = ""
+		bot.Send(msg)
+	}
+}
+
+// editMessage replaces the text and inline keyboard of a message the bot sent
+// earlier, e.g. after a toggle on that message was tapped. Text beyond the
+// message limit follows as new messages.
+func editMessage(chatID int64, messageID int, text markup, keyboard tgbotapi.InlineKeyboardMarkup) {
+	parts := render.Split(text, render.MaxMessageLength)
+	edit := tgbotapi.NewEditMessageTextAndMarkup(chatID, messageID, render.HTML(parts[0]), keyboard)
+	edit.ParseMode = tgbotapi.ModeHTML
+	bot.Send(edit)
+	for _, part := range parts[1:] {
+		sendMessage(chatID, part, nil)
+	}
+}
+
+// sendPhoto sends an image with a caption in message markup
+func sendPhoto(chatID int64, file tgbotapi.RequestFileData, caption markup, keyboard interface{}) {
+	caption, rest := render.Caption(caption)
+	if target := takeNavMessage(chatID); target != nil {
+		if rest == "" && replacePhoto(target, file, caption, keyboard) {
+			return
+		}
+		retireMessage(target)
+	}
+	photo := tgbotapi.NewPhoto(chatID, file)
+	photo.Caption = render.HTML(caption)
+	photo.ParseMode = tgbotapi.ModeHTML
+	if keyboard != nil && rest == "" {
+		photo.ReplyMarkup = keyboard
+	}
+	bot.Send(photo)
+	if rest != "" {
+		sendMessage(chatID, rest, keyboard)
+	}
+}
+
+// sendDocument sends a file with a caption in message markup
+func sendDocument(chatID int64, file tgbotapi.RequestFileData, caption markup) {
+	caption, rest := render.Caption(caption)
+	doc := tgbotapi.NewDocument(chatID, file)
+	doc.Caption = render.HTML(caption)
+	doc.ParseMode = tgbotapi.ModeHTML
+	bot.Send(doc)
+	if rest != "" {
+		sendMessage(chatID, rest, nil)
+	}
+}
@@ -0,0 +1,307 @@
+// Package render builds Telegram message text.
+//
+// Messages are written in a small markup: *bold*, _italic_ and `code`, with
+// a backslash in front of a marker to show it as is. HTML renders that
+// markup to Telegram HTML, so a marker without its closing pair is shown as
+// text instead of failing the message. Markup is its own type so that a
+// value from users or services cannot be sent without going through Esc or
+// Format, and a menu called "Kopi_Susu*Spesial" is shown as it is.
+package render
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Telegram limits, counted in UTF-16 code units of the visible text
+const (
+	MaxMessageLength = 4096
+	MaxCaptionLength = 1024
+)
+
+const markers = "\\*_`"
+
+// Markup is message text in which every value is escaped
+type Markup string
+
+// Esc escapes a value for use in message text
+func Esc(value string) Markup {
+	if !strings.ContainsAny(value, markers) {
+		return Markup(value)
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if strings.IndexByte(markers, value[i]) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(value[i])
+	}
+	return Markup(b.String())
+}
+
+// Format formats message text like fmt.Sprintf. The format is markup;
+// string and error arguments are escaped and Markup arguments are inserted
+// as they are.
+func Format(format Markup, args ...interface{}) Markup {
+	return Markup(fmt.Sprintf(string(format), EscapeArgs(args)...))
+}
+
+// EscapeArgs escapes the arguments of a format, for formatters other than
+// Format such as the message catalogue
+func EscapeArgs(args []interface{}) []interface{} {
+	escaped := make([]interface{}, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case Markup:
+			escaped[i] = string(v)
+		case string:
+			escaped[i] = string(Esc(v))
+		case error:
+			escaped[i] = string(Esc(v.Error()))
+		default:
+			escaped[i] = arg
+		}
+	}
+	return escaped
+}
+
+// Join concatenates message text with sep between the parts
+func Join(parts []Markup, sep Markup) Markup {
+	s := make([]string, len(parts))
+	for i, part := range parts {
+		s[i] = string(part)
+	}
+	return Markup(strings.Join(s, string(sep)))
+}
+
+// HTML converts message markup to Telegram HTML
+func HTML(text Markup) string {
+	var b strings.Builder
+	writeHTML(&b, string(text))
+	return b.String()
+}
+
+func writeHTML(b *strings.Builder, text string) {
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch c {
+		case '\\':
+			if i+1 < len(text) && strings.IndexByte(markers, text[i+1]) >= 0 {
+				i++
+				b.WriteByte(text[i])
+				continue
+			}
+			b.WriteByte(c)
+		case '*', '_', '`':
+			end := closingMarker(text, i+1, c)
+			if end < 0 {
+				b.WriteByte(c)
+				continue
+			}
+			inner := text[i+1 : end]
+			switch c {
+			case '*':
+				b.WriteString("<b>")
+				writeHTML(b, inner)
+				b.WriteString("</b>")
+			case '_':
+				b.WriteString("<i>")
+				writeHTML(b, inner)
+				b.WriteString("</i>")
+			default:
+				b.WriteString("<code>")
+				b.WriteString(html.EscapeString(unescape(inner)))
+				b.WriteString("</code>")
+			}
+			i = end
+		case '<', '>', '&', '"':
+			b.WriteString(html.EscapeString(string(c)))
+		default:
+			b.WriteByte(c)
+		}
+	}
+}
+
+// closingMarker finds the unescaped marker closing a span opened just
+// before from, or -1. Spans are never empty.
+func closingMarker(text string, from int, marker byte) int {
+	for i := from; i < len(text); i++ {
+		switch text[i] {
+		case '\\':
+			i++
+		case marker:
+			if i == from {
+				return -1
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// unescape removes the backslashes Esc added
+func unescape(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+1 < len(text) && strings.IndexByte(markers, text[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
+
+// Plain returns message markup as it reads, without any formatting
+func Plain(text Markup) string {
+	rendered := HTML(text)
+	for _, tag := range []string{"<b>", "</b>", "<i>", "</i>", "<code>", "</code>"} {
+		rendered = strings.ReplaceAll(rendered, tag, "")
+	}
+	return html.UnescapeString(rendered)
+}
+
+// Length is the length Telegram counts for text
+func Length(text string) int {
+	n := 0
+	for _, r := range text {
+		n += len(utf16.Encode([]rune{r}))
+	}
+	return n
+}
+
+// Split cuts message markup into parts of at most limit visible
+// characters, at paragraph or line breaks where possible. The markup
+// source is measured, which is never shorter than what it renders to.
+func Split(text Markup, limit int) []Markup {
+	var parts []Markup
+	s := string(text)
+	for Length(s) > limit {
+		var head string
+		head, s = cut(s, limit)
+		parts = append(parts, Markup(head))
+	}
+	if s != "" || len(parts) == 0 {
+		parts = append(parts, Markup(s))
+	}
+	return parts
+}
+
+// Caption splits caption markup into a caption within Telegram's caption
+// limit and the rest, which is sent as a message after the file
+func Caption(caption Markup) (Markup, Markup) {
+	s := string(caption)
+	if Length(s) <= MaxCaptionLength {
+		return caption, ""
+	}
+	head, rest := cut(s, MaxCaptionLength)
+	return Markup(head), Markup(rest)
+}
+
+// cut cuts text into a head of at most limit visible characters and the
+// rest. A span cut through, when no break outside one is near the limit, is
+// closed at the end of the head and opened again at the start of the rest,
+// so both keep its formatting.
+func cut(text string, limit int) (string, string) {
+	var at int
+	var open []span
+	// Leave room for the markers closing the spans cut through
+	for reserve := 0; ; reserve = len(open) {
+		at = cutPoint(text, max(limit-reserve, 1))
+		if open = openSpans(text, at); len(open) <= reserve {
+			break
+		}
+	}
+
+	head := strings.TrimRight(text[:at], "\n ")
+	rest := strings.TrimLeft(text[at:], "\n ")
+	for i := len(open) - 1; i >= 0; i-- {
+		head += string(open[i].marker)
+	}
+	for i := len(open) - 1; i >= 0; i-- {
+		rest = string(open[i].marker) + rest
+	}
+	return head, rest
+}
+
+// cutPoint returns the byte offset to cut text at so the first part has at
+// most limit visible characters
+func cutPoint(text string, limit int) int {
+	end, n := 0, 0
+	for i, r := range text {
+		n += len(utf16.Encode([]rune{r}))
+		if n > limit {
+			break
+		}
+		end = i + utf8.RuneLen(r)
+	}
+	head := text[:end]
+
+	// Prefer a break in the second half, so parts are not tiny, and outside
+	// formatted spans, so they need not be closed and opened again
+	for _, outside := range []bool{true, false} {
+		for _, sep := range []string{"\n\n", "\n", " "} {
+			for i := strings.LastIndex(head, sep); i > end/2; i = strings.LastIndex(head[:i], sep) {
+				if !outside || len(openSpans(text, i+len(sep))) == 0 {
+					return i + len(sep)
+				}
+			}
+		}
+	}
+
+	// Never leave a dangling escape at the end of a part
+	if end > 0 && head[end-1] == '\\' {
+		end--
+	}
+	// nor a span opened just before the cut, with nothing of it in the part
+	if open := openSpans(text, end); len(open) > 0 && open[len(open)-1].start == end-1 && end > 1 {
+		end--
+	}
+	return end
+}
+
+// span is a formatted span of markup, from its opening marker at start
+type span struct {
+	start  int
+	marker byte
+}
+
+// openSpans returns the spans a cut at offset at goes through, outermost
+// first, pairing markers the way HTML does
+func openSpans(text string, at int) []span {
+	var open []span
+	from, to := 0, len(text)
+	for from < to {
+		inside := false
+		for i := from; i < to; i++ {
+			c := text[i]
+			if c == '\\' {
+				i++
+				continue
+			}
+			if c != '*' && c != '_' && c != '`' {
+				continue
+			}
+			end := closingMarker(text[:to], i+1, c)
+			if end < 0 {
+				continue
+			}
+			if i < at && at <= end {
+				open = append(open, span{start: i, marker: c})
+				// Code is shown as it is, so nothing opens inside it
+				if c == '`' {
+					return open
+				}
+				from, to, inside = i+1, end, true
+				break
+			}
+			i = end
+		}
+		if !inside {
+			break
+		}
+	}
+	return open
+}
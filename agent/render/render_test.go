@@ -0,0 +1,177 @@
+package render
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFormatEscapesValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Markup
+		args   []interface{}
+		want   Markup
+	}{
+		{"plain value", "*%s*", []interface{}{"Kopi Susu"}, "*Kopi Susu*"},
+		{"markers in value", "*%s*", []interface{}{"Kopi_Susu*Spesial"}, `*Kopi\_Susu\*Spesial*`},
+		{"backslash in value", "%s", []interface{}{`a\b`}, `a\\b`},
+		{"error value", "⚠️ %s", []interface{}{errors.New("bad `x`")}, "⚠️ bad \\`x\\`"},
+		{"markup kept", "%s!", []interface{}{Markup("*Bold*")}, "*Bold*!"},
+		{"numbers", "#%d", []interface{}{12}, "#12"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Format(tt.format, tt.args...); got != tt.want {
+				t.Errorf("Format() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		text Markup
+		want string
+	}{
+		{"bold", "*Menu*", "<b>Menu</b>"},
+		{"italic in bold", "*a _b_ c*", "<b>a <i>b</i> c</b>"},
+		{"code", "`x_y*`", "<code>x_y*</code>"},
+		{"escaped markers", `Kopi\_Susu\*Spesial`, "Kopi_Susu*Spesial"},
+		{"escaped value in bold", Format("*%s*", "a*b"), "<b>a*b</b>"},
+		{"unpaired marker", "5 * 3", "5 * 3"},
+		{"empty span", "**", "**"},
+		{"html characters", `<a href="x">&`, "&lt;a href=&#34;x&#34;&gt;&amp;"},
+		{"html in code", "`<b>`", "<code>&lt;b&gt;</code>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HTML(tt.text); got != tt.want {
+				t.Errorf("HTML(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlain(t *testing.T) {
+	tests := []struct {
+		text Markup
+		want string
+	}{
+		{"*Pesanan #12* — _siap_", "Pesanan #12 — siap"},
+		{Format("*%s*", "<Kopi & Susu>"), "<Kopi & Susu>"},
+		{"`a\\_b`", "a_b"},
+	}
+	for _, tt := range tests {
+		if got := Plain(tt.text); got != tt.want {
+			t.Errorf("Plain(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestLength(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"abc", 3},
+		{"Rp 12.500", 9},
+		{"é", 1},
+		{"☕", 1},
+		{"🍕", 2},
+	}
+	for _, tt := range tests {
+		if got := Length(tt.text); got != tt.want {
+			t.Errorf("Length(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  Markup
+		limit int
+		want  []Markup
+	}{
+		{"short", "halo", 10, []Markup{"halo"}},
+		{"empty", "", 10, []Markup{""}},
+		{"at paragraph", "aaaa\n\nbbbb", 8, []Markup{"aaaa", "bbbb"}},
+		{"at line", "aaaa\nbbbb\ncc", 10, []Markup{"aaaa\nbbbb", "cc"}},
+		{"at space", "aaaa bbbb cc", 10, []Markup{"aaaa bbbb", "cc"}},
+		{"hard cut", "aaaaaaaaaa", 4, []Markup{"aaaa", "aaaa", "aa"}},
+		{"no dangling escape", `aaa\*b`, 4, []Markup{"aaa", `\*b`}},
+		{"surrogate pairs", "🍕🍕🍕", 4, []Markup{"🍕🍕", "🍕"}},
+		{"before span", "aaaa bbbb *cc dd*", 14, []Markup{"aaaa bbbb", "*cc dd*"}},
+		{"through span", "*aaa bbb ccc ddd*", 10, []Markup{"*aaa bbb*", "*ccc ddd*"}},
+		{"through nested spans", "*aa _bb cc_ dd*", 9, []Markup{"*aa _bb_*", "*_cc_ dd*"}},
+		{"hard cut in span", "*aaaaaaaa*", 5, []Markup{"*aaa*", "*aaa*", "*aa*"}},
+		{"no dangling marker", "ab *cdef*", 4, []Markup{"ab", "*cd*", "*ef*"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Split(tt.text, tt.limit)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Split() = %q, want %q", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Split() = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitKeepsMessageLimit(t *testing.T) {
+	line := Markup(strings.Repeat("☕ Kopi Susu ", 20) + "\n")
+	text := Markup(strings.Repeat(string(line), 40))
+	parts := Split(text, MaxMessageLength)
+	if len(parts) < 2 {
+		t.Fatalf("Split() returned %d parts, want at least 2", len(parts))
+	}
+	for i, part := range parts {
+		if n := Length(string(part)); n > MaxMessageLength {
+			t.Errorf("part %d has length %d, over %d", i, n, MaxMessageLength)
+		}
+	}
+}
+
+func TestSplitKeepsBoldSpan(t *testing.T) {
+	text := Markup("*" + strings.Repeat("Kopi Susu Gula Aren ", 300) + "*")
+	parts := Split(text, MaxMessageLength)
+	if len(parts) < 2 {
+		t.Fatalf("Split() returned %d parts, want at least 2", len(parts))
+	}
+	for i, part := range parts {
+		if n := Length(string(part)); n > MaxMessageLength {
+			t.Errorf("part %d has length %d, over %d", i, n, MaxMessageLength)
+		}
+		html := HTML(part)
+		if !strings.HasPrefix(html, "<b>") || !strings.HasSuffix(html, "</b>") || strings.Contains(html, "*") {
+			t.Errorf("part %d renders as %.40q…, want a whole bold span", i, html)
+		}
+	}
+}
+
+func TestCaption(t *testing.T) {
+	long := Markup(strings.Repeat("a", MaxCaptionLength-5) + "\n" + strings.Repeat("b", 20))
+	tests := []struct {
+		name        string
+		caption     Markup
+		wantCaption Markup
+		wantRest    Markup
+	}{
+		{"fits", "*Kopi*", "*Kopi*", ""},
+		{"too long", long, Markup(strings.Repeat("a", MaxCaptionLength-5)), Markup(strings.Repeat("b", 20))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			caption, rest := Caption(tt.caption)
+			if caption != tt.wantCaption || rest != tt.wantRest {
+				t.Errorf("Caption() = %q, %q, want %q, %q", caption, rest, tt.wantCaption, tt.wantRest)
+			}
+		})
+	}
+}
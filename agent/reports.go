@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/base64"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alrescha79-cmd/bot-cafe/agent/render"
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const reportDateLayout = "2006-01-02"
+
+// reportCharts are the chart types under a report, in display order; the
+// button label is the catalogue key report.chart_<type>
+var reportCharts = []string{"revenue", "top_items", "category_mix", "heatmap"}
+
+// reportWeekdays are the catalogue keys of the heatmap rows, from Monday
+var reportWeekdays = []string{
+	"report.weekday_monday", "report.weekday_tuesday", "report.weekday_wednesday", "report.weekday_thursday",
+	"report.weekday_friday", "report.weekday_saturday", "report.weekday_sunday",
+}
+
+// Longer revenue series are only shown as a chart
+const reportSeriesLines = 14
+
+func showReportMenu(chatID int64, branchID int) {
+	today := time.Now()
+	date := func(t time.Time) string { return t.Format(reportDateLayout) }
+	firstOfMonth := time.Date(today.Year(), today.Month(), 1, 0, 0, 0, 0, time.Local)
+
+	presets := []struct {
+		Label    string
+		From, To time.Time
+	}{
+		{"report.preset_today", today, today},
+		{"report.preset_7_days", today.AddDate(0, 0, -6), today},
+		{"report.preset_30_days", today.AddDate(0, 0, -29), today},
+		{"report.preset_this_month", firstOfMonth, today},
+		{"report.preset_last_month", firstOfMonth.AddDate(0, -1, 0), firstOfMonth.AddDate(0, 0, -1)},
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, p := range presets {
+		row = append(row, button(t(p.Label), "report", date(p.From), date(p.To)))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("report.btn_custom"), "report_custom"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("admin.btn_back_panel"), "back", "admin"),
+		),
+	)
+
+	text := tm("report.menu_title", reportBranchLabel(branchID))
+	sendMessage(chatID, text, tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+func reportBranchLabel(branchID int) string {
+	if branchID == 0 {
+		return t("report.all_branches")
+	}
+	return branchName(branchID)
+}
+
+func startReportRangeDialog(chatID int64, userID int64, branchID int) {
+	userStates[userID] = "report_range"
+	userTempData[userID] = map[string]interface{}{
+		"branch_id": branchID,
+	}
+	sendMessage(chatID, tm("report.range_prompt")+"\n\n"+tm("common.cancel_hint"), nil)
+}
+
+func handleReportRange(msg *tgbotapi.Message, userID int64) {
+	fields := strings.Fields(msg.Text)
+	if len(fields) != 2 {
+		sendMessage(msg.Chat.ID, tm("report.range_two_dates"), nil)
+		return
+	}
+
+	from, errFrom := time.Parse(reportDateLayout, fields[0])
+	to, errTo := time.Parse(reportDateLayout, fields[1])
+	if errFrom != nil || errTo != nil {
+		sendMessage(msg.Chat.ID, tm("report.range_date_invalid"), nil)
+		return
+	}
+	if to.Before(from) {
+		sendMessage(msg.Chat.ID, tm("report.range_order"), nil)
+		return
+	}
+
+	branchID := userTempData[userID]["branch_id"].(int)
+	delete(userStates, userID)
+	delete(userTempData, userID)
+
+	showReport(msg.Chat.ID, fields[0], fields[1], branchID)
+}
+
+func showReport(chatID int64, from, to string, branchID int) {
+	resp, err := httpClient.Post(reportServiceURL, shared.Request{
+		Action: "summary",
+		Payload: map[string]interface{}{
+			"from":      from,
+			"to":        to,
+			"branch_id": branchID,
+		},
+	})
+
+	if err != nil || !resp.Success {
+		errMsg := tm("report.load_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		sendMessage(chatID, errMsg, nil)
+		return
+	}
+
+	report := resp.Data.(map[string]interface{})["report"].(map[string]interface{})
+	amount := func(key string) string { return price(money(report[key])) }
+
+	text := tm("report.title") + "\n"
+	text += tm("report.scope", reportBranchLabel(branchID), from, to) + "\n\n"
+	text += tm("report.revenue", amount("revenue")) + "\n"
+	text += tm("report.orders", int(report["orders"].(float64)), int(report["cancelled_orders"].(float64))) + "\n"
+	text += tm("report.average_order", amount("average_order")) + "\n"
+	text += tm("report.items_sold", int(report["items_sold"].(float64))) + "\n"
+
+	if series, _ := report["series"].([]interface{}); len(series) > 1 && len(series) <= reportSeriesLines {
+		text += "\n" + tm("report.series_title", t("report.period_"+report["period"].(string))) + "\n"
+		for _, item := range series {
+			point := item.(map[string]interface{})
+			text += tm("report.series_line", point["label"].(string),
+				price(money(point["revenue"])), int(point["orders"].(float64))) + "\n"
+		}
+	}
+
+	if items, _ := report["top_items"].([]interface{}); len(items) > 0 {
+		text += "\n" + tm("report.top_items_title") + "\n"
+		for i, item := range items {
+			if i == 5 {
+				break
+			}
+			stat := item.(map[string]interface{})
+			text += tm("report.top_item_line", i+1, stat["name"].(string),
+				int(stat["quantity"].(float64)), price(money(stat["revenue"]))) + "\n"
+		}
+	}
+
+	if categories, _ := report["categories"].([]interface{}); len(categories) > 0 {
+		text += "\n" + tm("report.categories_title") + "\n"
+		for _, item := range categories {
+			stat := item.(map[string]interface{})
+			text += tm("report.category_line", stat["category"].(string), stat["share"].(float64),
+				price(money(stat["revenue"]))) + "\n"
+		}
+	}
+
+	if busiest := busiestHours(report["heatmap"], 3); len(busiest) > 0 {
+		text += "\n" + tm("report.busiest_title") + "\n" + render.Join(busiest, "\n") + "\n"
+	}
+
+	if promos, _ := report["promos"].([]interface{}); len(promos) > 0 {
+		text += "\n" + tm("report.promos_title") + "\n"
+		for _, item := range promos {
+			stat := item.(map[string]interface{})
+			text += tm("report.promo_line", stat["title"].(string), int(stat["days_active"].(float64)),
+				price(money(stat["avg_daily_revenue"])))
+			if lift, ok := stat["lift_percent"].(float64); ok {
+				text += tm("report.promo_lift",
+					price(money(stat["avg_daily_revenue_other"])), lift)
+			}
+			text += "\n"
+		}
+	}
+
+	var chartRow []tgbotapi.InlineKeyboardButton
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, chart := range reportCharts {
+		chartRow = append(chartRow, button(t("report.chart_"+chart),
+			"report_chart", chart, from, to))
+		if len(chartRow) == 2 {
+			rows = append(rows, chartRow)
+			chartRow = nil
+		}
+	}
+	rows = append(rows,
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("report.btn_change_range"), "admin_report"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("admin.btn_back_panel"), "back", "admin"),
+		),
+	)
+
+	sendMessage(chatID, text, tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+// busiestHours lists the weekday/hour slots with the most orders
+func busiestHours(raw interface{}, limit int) []markup {
+	type slot struct{ day, hour, orders int }
+	var slots []slot
+
+	days, _ := raw.([]interface{})
+	for day, row := range days {
+		hours, _ := row.([]interface{})
+		for hour, count := range hours {
+			if n := int(count.(float64)); n > 0 {
+				slots = append(slots, slot{day, hour, n})
+			}
+		}
+	}
+	sort.SliceStable(slots, func(i, j int) bool { return slots[i].orders > slots[j].orders })
+
+	var lines []markup
+	for i, s := range slots {
+		if i == limit {
+			break
+		}
+		lines = append(lines, tnm("report.busiest_line", s.orders, t(reportWeekdays[s.day]), s.hour, s.hour+1))
+	}
+	return lines
+}
+
+func sendReportChart(chatID int64, chartType, from, to string, branchID int) {
+	resp, err := httpClient.Post(reportServiceURL, shared.Request{
+		Action: "chart",
+		Payload: map[string]interface{}{
+			"type":      chartType,
+			"from":      from,
+			"to":        to,
+			"branch_id": branchID,
+		},
+	})
+
+	if err != nil || !resp.Success {
+		errMsg := tm("report.chart_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		sendMessage(chatID, errMsg, nil)
+		return
+	}
+
+	encoded, _ := resp.Data.(map[string]interface{})["image"].(string)
+	image, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		sendMessage(chatID, tm("report.chart_invalid"), nil)
+		return
+	}
+
+	sendPhoto(chatID, tgbotapi.FileBytes{Name: chartType + ".png", Bytes: image},
+		tm("report.chart_caption", from, to, reportBranchLabel(branchID)), nil)
+}
@@ -0,0 +1,655 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	reservationDateLayout = "2006-01-02"
+
+	// Days offered when booking, starting today; reservation-service decides
+	// how far ahead a booking may really be
+	reservationDaysShown = 7
+
+	// Party sizes offered as buttons; larger parties type their size
+	reservationPartySizes = 8
+
+	reservationReminderInterval = time.Minute
+)
+
+// reservationActions is the admin button label that moves a reservation to
+// a status
+var reservationActions = map[string]string{
+	"confirmed": "reservation.btn_confirm",
+	"rejected":  "reservation.btn_reject",
+	"completed": "reservation.btn_arrived",
+	"no_show":   "reservation.btn_no_show",
+	"cancelled": "reservation.btn_cancel",
+}
+
+// reservationCustomerMessages is sent to the customer when the café changes
+// their reservation
+var reservationCustomerMessages = map[string]string{
+	"confirmed": "reservation.customer_confirmed",
+	"rejected":  "reservation.customer_rejected",
+	"cancelled": "reservation.customer_cancelled",
+}
+
+// nextReservationStatuses mirrors the transitions allowed by
+// reservation-service
+func nextReservationStatuses(status string) []string {
+	switch status {
+	case "pending":
+		return []string{"confirmed", "rejected"}
+	case "confirmed":
+		return []string{"completed", "no_show", "cancelled"}
+	}
+	return nil
+}
+
+// reservationDay names the day of a date: today, tomorrow, or its weekday
+// and date
+func reservationDay(date string) string {
+	day, err := time.ParseInLocation(reservationDateLayout, date, time.Local)
+	if err != nil {
+		return date
+	}
+	today := time.Now()
+	switch date {
+	case today.Format(reservationDateLayout):
+		return t("reservation.today", day.Format("02/01"))
+	case today.AddDate(0, 0, 1).Format(reservationDateLayout):
+		return t("reservation.tomorrow", day.Format("02/01"))
+	}
+	weekdays := strings.Split(t("reservation.weekdays"), ",")
+	if len(weekdays) != 7 {
+		return day.Format("02/01/2006")
+	}
+	return weekdays[day.Weekday()] + " " + day.Format("02/01")
+}
+
+// formatReservation describes a reservation, one detail per line
+func formatReservation(reservation map[string]interface{}) markup {
+	text := tm("reservation.line_when", reservationDay(reservation["date"].(string)), reservation["time"].(string)) + "\n"
+	text += tm("reservation.line_party", tn("reservation.people", int(reservation["party_size"].(float64)))) + "\n"
+	table := reservation["table_name"].(string)
+	if area, _ := reservation["table_area"].(string); area != "" {
+		table += " (" + area + ")"
+	}
+	text += tm("reservation.line_table", table) + "\n"
+	if note, _ := reservation["note"].(string); note != "" {
+		text += tm("reservation.line_note", note) + "\n"
+	}
+	return text
+}
+
+// CUSTOMER RESERVATIONS
+
+// startReservation books a table at the user's branch
+func startReservation(chatID int64, user *tgbotapi.User) {
+	startWizard(chatID, user.ID, "reservation", map[string]interface{}{
+		"branch_id":     getUserBranch(user.ID),
+		"customer_name": user.FirstName,
+	})
+}
+
+func reservationDateChoices(map[string]interface{}) []wizardChoice {
+	var choices []wizardChoice
+	for i := 0; i < reservationDaysShown; i++ {
+		date := time.Now().AddDate(0, 0, i).Format(reservationDateLayout)
+		choices = append(choices, wizardChoice{value: date, label: reservationDay(date)})
+	}
+	return choices
+}
+
+func reservationPartyChoices(map[string]interface{}) []wizardChoice {
+	var choices []wizardChoice
+	for size := 1; size <= reservationPartySizes; size++ {
+		choices = append(choices, wizardChoice{value: strconv.Itoa(size), label: tn("reservation.people", size)})
+	}
+	return choices
+}
+
+// reservationSlots returns the free start times for the date and party size
+// picked so far, or the text of why they could not be loaded. They are kept
+// with the answers so the prompt and its buttons ask reservation-service
+// once.
+func reservationSlots(data map[string]interface{}) ([]string, markup) {
+	date, _ := data["date"].(string)
+	partySize, _ := strconv.Atoi(fmt.Sprint(data["party_size"]))
+	key := fmt.Sprintf("%s/%d", date, partySize)
+	if slots, ok := data["slots"].([]string); ok && data["slots_for"] == key {
+		return slots, ""
+	}
+
+	resp, err := httpClient.Post(reservationServiceURL, shared.Request{
+		Action: "slots",
+		Payload: map[string]interface{}{
+			"branch_id":  data["branch_id"],
+			"date":       date,
+			"party_size": partySize,
+		},
+	})
+	if err != nil || !resp.Success {
+		errMsg := tm("reservation.slots_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		return nil, errMsg
+	}
+
+	result := resp.Data.(map[string]interface{})
+	slots := []string{}
+	items, _ := result["slots"].([]interface{})
+	for _, item := range items {
+		slot := item.(map[string]interface{})
+		if available, _ := slot["available"].(bool); available {
+			slots = append(slots, slot["time"].(string))
+		}
+	}
+	if len(slots) == 0 {
+		if largest, _ := result["max_party_size"].(float64); int(largest) < partySize {
+			return nil, tm("reservation.party_too_large", tnm("reservation.people", int(largest)))
+		}
+	}
+	data["slots"] = slots
+	data["slots_for"] = key
+	return slots, ""
+}
+
+func reservationTimePrompt(data map[string]interface{}) markup {
+	slots, failed := reservationSlots(data)
+	switch {
+	case failed != "":
+		return failed + "\n\n" + tm("reservation.pick_other")
+	case len(slots) == 0:
+		return tm("reservation.no_slots", reservationDay(data["date"].(string))) + "\n\n" + tm("reservation.pick_other")
+	}
+	return tm("reservation.prompt_time")
+}
+
+func reservationTimeChoices(data map[string]interface{}) []wizardChoice {
+	slots, _ := reservationSlots(data)
+	var choices []wizardChoice
+	for _, slot := range slots {
+		choices = append(choices, wizardChoice{value: slot, label: slot})
+	}
+	return choices
+}
+
+func finishReservation(chatID, userID int64, data map[string]interface{}) {
+	partySize, _ := strconv.Atoi(data["party_size"].(string))
+	resp, err := httpClient.Post(reservationServiceURL, shared.Request{
+		Action: "create",
+		Payload: map[string]interface{}{
+			"branch_id":     data["branch_id"],
+			"telegram_id":   strconv.FormatInt(userID, 10),
+			"customer_name": data["customer_name"],
+			"date":          data["date"],
+			"time":          data["time"],
+			"party_size":    partySize,
+			"note":          data["note"],
+		},
+	})
+
+	if err != nil || !resp.Success {
+		errMsg := tm("reservation.create_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				button(t("reservation.btn_new"), "reservation_new"),
+			),
+		)
+		sendMessage(chatID, errMsg, keyboard)
+		return
+	}
+
+	reservation := resp.Data.(map[string]interface{})["reservation"].(map[string]interface{})
+	id := int(reservation["id"].(float64))
+
+	text := tm("reservation.created", id) + "\n\n" + formatReservation(reservation) + "\n" + tm("reservation.created_pending")
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("reservation.btn_mine"), "my_reservations"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("common.btn_home"), "back", "start"),
+		),
+	)
+	sendMessage(chatID, text, keyboard)
+
+	inLocale(shared.DefaultLocale, func() {
+		notifyAdmins(tm("reservation.admin_new", id, reservation["customer_name"].(string),
+			branchName(int(reservation["branch_id"].(float64)))) + "\n" + formatReservation(reservation) +
+			reservationNoShows(reservation) + "\n" + tm("reservation.admin_new_hint"))
+	})
+}
+
+func showMyReservations(chatID int64, userID int64) {
+	resp, err := httpClient.Post(reservationServiceURL, shared.Request{
+		Action: "list",
+		Payload: map[string]interface{}{
+			"telegram_id": strconv.FormatInt(userID, 10),
+			"status":      "active",
+			"upcoming":    true,
+		},
+	})
+
+	if err != nil || !resp.Success {
+		sendMessage(chatID, tm("reservation.load_failed"), nil)
+		return
+	}
+
+	reservations, _ := resp.Data.(map[string]interface{})["reservations"].([]interface{})
+	text := tm("reservation.mine_title") + "\n\n"
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+
+	if len(reservations) == 0 {
+		text += tm("reservation.mine_empty") + "\n"
+	}
+	for _, item := range reservations {
+		reservation := item.(map[string]interface{})
+		id := int(reservation["id"].(float64))
+		text += md("*#%d* — %s\n", id, tm("reservation.status_"+reservation["status"].(string)))
+		text += formatReservation(reservation) + "\n"
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			button(t("reservation.btn_cancel_own", id), "confirm_cancel_reservation", id),
+		))
+	}
+
+	keyboard = append(keyboard,
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("reservation.btn_new"), "reservation_new"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("common.btn_home"), "back", "start"),
+		),
+	)
+	sendMessage(chatID, text, tgbotapi.NewInlineKeyboardMarkup(keyboard...))
+}
+
+// cancelMyReservation cancels a reservation of the user and tells the admins
+func cancelMyReservation(chatID int64, userID int64, id int) {
+	resp, err := httpClient.Post(reservationServiceURL, shared.Request{
+		Action: "update_status",
+		Payload: map[string]interface{}{
+			"id":          id,
+			"status":      "cancelled",
+			"telegram_id": strconv.FormatInt(userID, 10),
+		},
+	})
+
+	if err != nil || !resp.Success {
+		errMsg := tm("reservation.cancel_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		sendMessage(chatID, errMsg, nil)
+		return
+	}
+
+	reservation := resp.Data.(map[string]interface{})["reservation"].(map[string]interface{})
+	sendMessage(chatID, tm("reservation.cancelled_own", id), nil)
+	inLocale(shared.DefaultLocale, func() {
+		notifyAdmins(tm("reservation.admin_cancelled", id, reservation["customer_name"].(string)) + "\n" +
+			formatReservation(reservation))
+	})
+
+	showMyReservations(chatID, userID)
+}
+
+// sendReservationReminders reminds customers of their confirmed bookings
+// shortly before they start. The agent runs it periodically.
+func sendReservationReminders() {
+	resp, err := httpClient.Post(reservationServiceURL, shared.Request{Action: "due_reminders"})
+	if err != nil || !resp.Success {
+		return
+	}
+
+	reservations, _ := resp.Data.(map[string]interface{})["reservations"].([]interface{})
+	for _, item := range reservations {
+		reservation := item.(map[string]interface{})
+		// Reservations are made from private chats, so the Telegram ID is
+		// also the chat ID
+		customerID, err := strconv.ParseInt(reservation["telegram_id"].(string), 10, 64)
+		if err != nil {
+			continue
+		}
+		inLocale(userLocale(&tgbotapi.User{ID: customerID}), func() {
+			sendMessage(customerID, tm("reservation.reminder")+"\n\n"+formatReservation(reservation)+
+				"\n"+tm("reservation.reminder_branch", branchName(int(reservation["branch_id"].(float64)))), nil)
+		})
+		httpClient.Post(reservationServiceURL, shared.Request{
+			Action:  "mark_reminded",
+			Payload: map[string]interface{}{"id": reservation["id"]},
+		})
+	}
+}
+
+// ADMIN RESERVATIONS
+
+func showAdminReservations(chatID int64, branchID int, page int) {
+	payload := map[string]interface{}{
+		"status":   "active",
+		"upcoming": true,
+	}
+	if branchID != 0 {
+		payload["branch_id"] = branchID
+	}
+	resp, err := httpClient.Post(reservationServiceURL, shared.Request{
+		Action:  "list",
+		Payload: pagePayload(payload, page),
+	})
+
+	if err != nil || !resp.Success {
+		sendMessage(chatID, tm("reservation.load_failed"), nil)
+		return
+	}
+
+	data := resp.Data.(map[string]interface{})
+	reservations, _ := data["reservations"].([]interface{})
+	text := tm("reservation.admin_title") + "\n\n"
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+
+	if len(reservations) == 0 {
+		text += tm("reservation.admin_empty") + "\n"
+	}
+
+	for _, item := range reservations {
+		reservation := item.(map[string]interface{})
+		id := int(reservation["id"].(float64))
+		status := reservation["status"].(string)
+
+		text += md("*#%d* %s — %s", id, reservation["customer_name"].(string), tm("reservation.status_"+status))
+		if branchID == 0 {
+			text += md(" (%s)", branchName(int(reservation["branch_id"].(float64))))
+		}
+		text += "\n" + formatReservation(reservation) + reservationNoShows(reservation) + "\n"
+
+		var row []tgbotapi.InlineKeyboardButton
+		for _, next := range nextReservationStatuses(status) {
+			row = append(row, button(
+				fmt.Sprintf("#%d %s", id, t(reservationActions[next])),
+				"reservation_status", id, next))
+		}
+		if len(row) > 0 {
+			keyboard = append(keyboard, row)
+		}
+	}
+
+	footer, pageRow := pageFooter(page, listTotal(data, reservations), "admin_reservations")
+	text += footer
+	if pageRow != nil {
+		keyboard = append(keyboard, pageRow)
+	}
+
+	keyboard = append(keyboard,
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("reservation.btn_tables"), "reservation_tables"),
+			button(t("reservation.btn_refresh"), "admin_reservations", page),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("admin.btn_back_panel"), "back", "admin"),
+		),
+	)
+
+	sendMessage(chatID, text, tgbotapi.NewInlineKeyboardMarkup(keyboard...))
+}
+
+// reservationNoShows warns about customers who did not come to earlier
+// bookings, or is empty
+func reservationNoShows(reservation map[string]interface{}) markup {
+	noShows, _ := reservation["no_shows"].(float64)
+	if noShows == 0 {
+		return ""
+	}
+	return tnm("reservation.no_shows", int(noShows)) + "\n"
+}
+
+// setReservationStatus moves a reservation to status; rejecting asks for a
+// reason first
+func setReservationStatus(chatID int64, userID int64, id int, status string, branchID int) {
+	if status == "rejected" {
+		startWizard(chatID, userID, "reject_reservation", map[string]interface{}{
+			"id":        id,
+			"branch_id": branchID,
+		})
+		return
+	}
+	updateReservationStatus(chatID, id, status, "", branchID)
+}
+
+func finishRejectReservation(chatID, _ int64, data map[string]interface{}) {
+	updateReservationStatus(chatID, data["id"].(int), "rejected", data["reason"].(string), data["branch_id"].(int))
+}
+
+func updateReservationStatus(chatID int64, id int, status, reason string, branchID int) {
+	resp, err := httpClient.Post(reservationServiceURL, shared.Request{
+		Action: "update_status",
+		Payload: map[string]interface{}{
+			"id":     id,
+			"status": status,
+			"reason": reason,
+		},
+	})
+
+	if err != nil || !resp.Success {
+		errMsg := tm("reservation.status_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		sendMessage(chatID, errMsg, nil)
+		return
+	}
+
+	reservation := resp.Data.(map[string]interface{})["reservation"].(map[string]interface{})
+
+	// Let the customer know in their own language
+	if key, ok := reservationCustomerMessages[status]; ok {
+		if customerID, err := strconv.ParseInt(reservation["telegram_id"].(string), 10, 64); err == nil {
+			inLocale(userLocale(&tgbotapi.User{ID: customerID}), func() {
+				text := tm(key, id) + "\n\n" + formatReservation(reservation)
+				if reason != "" {
+					text += tm("reservation.reason", reason) + "\n"
+				}
+				sendMessage(customerID, text, nil)
+			})
+		}
+	}
+
+	sendMessage(chatID, tm("reservation.status_changed", id, t("reservation.status_"+status)), nil)
+	showAdminReservations(chatID, branchID, 0)
+}
+
+// ADMIN TABLES
+
+func showReservationTables(chatID int64, branchID int) {
+	resp, err := httpClient.Post(reservationServiceURL, shared.Request{
+		Action:  "list_tables",
+		Payload: map[string]interface{}{"branch_id": branchID},
+	})
+
+	if err != nil || !resp.Success {
+		sendMessage(chatID, tm("reservation.tables_load_failed"), nil)
+		return
+	}
+
+	tables, _ := resp.Data.(map[string]interface{})["tables"].([]interface{})
+	text := tm("reservation.tables_title", branchName(branchID)) + "\n\n"
+	var keyboard [][]tgbotapi.InlineKeyboardButton
+
+	if len(tables) == 0 {
+		text += tm("reservation.tables_empty") + "\n"
+	}
+	for _, item := range tables {
+		table := item.(map[string]interface{})
+		name := table["name"].(string)
+		text += md("• *%s* — %s", name, tn("reservation.seats", int(table["capacity"].(float64))))
+		if area, _ := table["area"].(string); area != "" {
+			text += md(", %s", area)
+		}
+		text += "\n"
+		keyboard = append(keyboard, tgbotapi.NewInlineKeyboardRow(
+			button(t("common.btn_delete", name), "confirm_delete_table", int(table["id"].(float64))),
+		))
+	}
+
+	keyboard = append(keyboard,
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("reservation.btn_table_add"), "table_create"),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			backButton("admin_reservations"),
+		),
+	)
+	sendMessage(chatID, text, tgbotapi.NewInlineKeyboardMarkup(keyboard...))
+}
+
+func finishAddTable(chatID, _ int64, data map[string]interface{}) {
+	capacity, _ := strconv.Atoi(data["capacity"].(string))
+	resp, err := httpClient.Post(reservationServiceURL, shared.Request{
+		Action: "create_table",
+		Payload: map[string]interface{}{
+			"branch_id": data["branch_id"],
+			"name":      data["name"],
+			"capacity":  capacity,
+			"area":      data["area"],
+		},
+	})
+
+	if err != nil || !resp.Success {
+		errMsg := tm("reservation.table_add_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		sendMessage(chatID, errMsg, nil)
+		return
+	}
+
+	sendMessage(chatID, tm("reservation.table_added", data["name"].(string)), nil)
+	showReservationTables(chatID, data["branch_id"].(int))
+}
+
+func deleteTable(chatID int64, id int, branchID int) {
+	resp, err := httpClient.Post(reservationServiceURL, shared.Request{
+		Action:  "delete_table",
+		Payload: map[string]interface{}{"id": id},
+	})
+
+	if err != nil || !resp.Success {
+		errMsg := tm("reservation.table_delete_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		sendMessage(chatID, errMsg, nil)
+		return
+	}
+
+	sendMessage(chatID, tm("reservation.table_deleted"), nil)
+	showReservationTables(chatID, branchID)
+}
+
+func init() {
+	registerWizard(&wizard{
+		name:    "reservation",
+		title:   "reservation.title",
+		confirm: true,
+		finish:  finishReservation,
+		steps: []wizardStep{
+			{
+				key:     "date",
+				prompt:  textPrompt("reservation.prompt_date"),
+				choices: reservationDateChoices,
+				label:   "reservation.label_date",
+				show: func(data map[string]interface{}) string {
+					return reservationDay(data["date"].(string))
+				},
+			},
+			{
+				key:     "party_size",
+				prompt:  textPrompt("reservation.prompt_party"),
+				choices: reservationPartyChoices,
+				parse: func(msg *tgbotapi.Message, _ map[string]interface{}) (interface{}, markup) {
+					size, err := strconv.Atoi(strings.TrimSpace(msg.Text))
+					if err != nil || size < 1 {
+						return nil, tm("reservation.party_invalid")
+					}
+					return strconv.Itoa(size), ""
+				},
+				label: "reservation.label_party",
+				show: func(data map[string]interface{}) string {
+					size, _ := strconv.Atoi(data["party_size"].(string))
+					return tn("reservation.people", size)
+				},
+			},
+			{
+				key:     "time",
+				prompt:  reservationTimePrompt,
+				choices: reservationTimeChoices,
+				label:   "reservation.label_time",
+			},
+			{
+				key:      "note",
+				prompt:   textPrompt("reservation.prompt_note"),
+				parse:    anyText,
+				optional: true,
+				label:    "reservation.label_note",
+			},
+		},
+	})
+
+	registerWizard(&wizard{
+		name:   "reject_reservation",
+		finish: finishRejectReservation,
+		steps: []wizardStep{
+			{
+				key:      "reason",
+				prompt:   textPrompt("reservation.prompt_reject_reason"),
+				parse:    anyText,
+				optional: true,
+			},
+		},
+	})
+
+	registerWizard(&wizard{
+		name:    "add_table",
+		title:   "reservation.table_add_title",
+		confirm: true,
+		finish:  finishAddTable,
+		steps: []wizardStep{
+			{
+				key:    "name",
+				prompt: textPrompt("reservation.prompt_table_name"),
+				parse:  requiredText("reservation.table_name_empty"),
+				label:  "reservation.label_table_name",
+			},
+			{
+				key:    "capacity",
+				prompt: textPrompt("reservation.prompt_table_capacity"),
+				parse: func(msg *tgbotapi.Message, _ map[string]interface{}) (interface{}, markup) {
+					capacity, err := strconv.Atoi(strings.TrimSpace(msg.Text))
+					if err != nil || capacity < 1 {
+						return nil, tm("reservation.table_capacity_invalid")
+					}
+					return strconv.Itoa(capacity), ""
+				},
+				label: "reservation.label_table_capacity",
+			},
+			{
+				key:      "area",
+				prompt:   textPrompt("reservation.prompt_table_area"),
+				parse:    anyText,
+				optional: true,
+				label:    "reservation.label_table_area",
+			},
+		},
+	})
+}
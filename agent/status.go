@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+)
+
+// Each service gets this long to answer /ready
+const statusTimeout = 5 * time.Second
+
+// StatusReport is the aggregated readiness of every service, served by the
+// agent's /status and shown by the /status command
+type StatusReport struct {
+	Status   string          `json:"status"`
+	Services []ServiceStatus `json:"services"`
+}
+
+// ServiceStatus is the readiness report of one service. A service that
+// cannot be reached is down, with the error.
+type ServiceStatus struct {
+	shared.HealthReport
+	URL   string `json:"url"`
+	Error string `json:"error,omitempty"`
+}
+
+// collectStatus asks every service for its readiness concurrently. It may run
+// outside the update loop, so it uses its own client.
+func collectStatus(ctx context.Context) StatusReport {
+	client := &http.Client{Timeout: statusTimeout}
+	report := StatusReport{Status: shared.HealthOK, Services: make([]ServiceStatus, len(shared.Services))}
+
+	var wg sync.WaitGroup
+	for i, service := range shared.Services {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			report.Services[i] = serviceStatus(ctx, client, name)
+		}(i, service.Name)
+	}
+	wg.Wait()
+
+	for _, service := range report.Services {
+		if service.Status != shared.HealthOK {
+			report.Status = shared.HealthDegraded
+		}
+	}
+	return report
+}
+
+func serviceStatus(ctx context.Context, client *http.Client, name string) ServiceStatus {
+	status := ServiceStatus{URL: shared.ServiceURL(name)}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, status.URL+"/ready", nil)
+	if err == nil {
+		var resp *http.Response
+		if resp, err = client.Do(req); err == nil {
+			defer resp.Body.Close()
+			// Services that are down answer 503 with their report
+			err = json.NewDecoder(resp.Body).Decode(&status.HealthReport)
+		}
+	}
+	if err != nil {
+		status.Status = shared.HealthDown
+		status.Error = shared.Redact(err.Error())
+	}
+	// The configured name wins over whatever the service reports
+	status.Service = name
+	return status
+}
+
+// handleStatus serves the aggregated status as JSON
+func handleStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(collectStatus(r.Context()))
+}
+
+// showServiceStatus sends admins which services are degraded or down
+func showServiceStatus(chatID int64) {
+	report := collectStatus(requestCtx)
+
+	text := tm("status.title") + "\n\n"
+	for _, service := range report.Services {
+		switch service.Status {
+		case shared.HealthOK:
+			text += tm("status.ok", service.Service)
+		case shared.HealthDegraded:
+			text += tm("status.degraded", service.Service)
+		default:
+			if service.Error != "" {
+				text += tm("status.unreachable", service.Service)
+			} else {
+				text += tm("status.down", service.Service)
+			}
+		}
+		if service.SchemaVersion > 0 {
+			text += tm("status.schema", service.SchemaVersion)
+		}
+		text += "\n"
+
+		for _, check := range service.Checks {
+			if check.Status != shared.HealthOK {
+				text += tm("status.check_failed", check.Name) + "\n"
+			}
+		}
+	}
+
+	if report.Status == shared.HealthOK {
+		text += "\n" + tm("status.all_ok")
+	}
+	sendMessage(chatID, text, nil)
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// translatable describes the records whose texts admins translate: the
+// service holding them and their translatable fields
+type translatable struct {
+	serviceURL func() string
+	key        string // field holding the record in a read response
+	titleField string
+	fields     []string
+	backAction string
+}
+
+var translatables = map[string]translatable{
+	"menu": {
+		serviceURL: func() string { return menuServiceURL },
+		key:        "menu",
+		titleField: "name",
+		fields:     []string{"name", "description"},
+		backAction: "menu_translate_list",
+	},
+	"promo": {
+		serviceURL: func() string { return promoServiceURL },
+		key:        "promo",
+		titleField: "title",
+		fields:     []string{"title", "description"},
+		backAction: "promo_translate_list",
+	},
+}
+
+// TRANSLATION MANAGEMENT
+
+// showTranslations shows the translations of a menu or promo, with a button
+// per locale and field to change them
+func showTranslations(chatID int64, kind string, id int) {
+	record, ok := translatables[kind]
+	if !ok {
+		return
+	}
+
+	resp, err := httpClient.Post(record.serviceURL(), shared.Request{
+		Action:  "read",
+		Payload: map[string]interface{}{"id": id},
+	})
+	if err != nil || !resp.Success {
+		errMsg := tm("translation.load_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		sendMessage(chatID, errMsg, nil)
+		return
+	}
+
+	data := resp.Data.(map[string]interface{})
+	item := data[record.key].(map[string]interface{})
+	translations, _ := data["translations"].(map[string]interface{})
+
+	text := tm("translation.title", item[record.titleField].(string)) + "\n\n"
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for _, locale := range shared.Locales {
+		if locale == shared.DefaultLocale {
+			continue
+		}
+		fields, _ := translations[locale].(map[string]interface{})
+
+		text += md("*%s*\n", languageNames[locale])
+		var buttons []tgbotapi.InlineKeyboardButton
+		for _, field := range record.fields {
+			value, _ := fields[field].(string)
+			shown := esc(value)
+			if value == "" {
+				shown = "_" + tm("translation.missing") + "_"
+			}
+			text += md("• %s: %s\n", tm("translation.field_"+field), shown)
+			buttons = append(buttons, button(
+				fmt.Sprintf("✏️ %s (%s)", t("translation.field_"+field), strings.ToUpper(locale)),
+				"tr_edit", kind, id, locale, field,
+			))
+		}
+		text += "\n"
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(buttons...))
+	}
+	text += "_" + tm("translation.fallback_hint") + "_"
+
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		backButton(record.backAction),
+	))
+
+	sendMessage(chatID, text, tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+func startTranslationDialog(chatID int64, userID int64, kind string, id int, locale, field string) {
+	if _, ok := translatables[kind]; !ok || !shared.IsSupportedLocale(locale) {
+		return
+	}
+
+	userStates[userID] = "set_translation"
+	userTempData[userID] = map[string]interface{}{
+		"kind":   kind,
+		"id":     id,
+		"locale": locale,
+		"field":  field,
+	}
+
+	text := tm("translation.prompt", tm("translation.field_"+field), languageNames[locale])
+	sendMessage(chatID, text+"\n\n"+tm("common.cancel_hint"), nil)
+}
+
+func handleSetTranslation(msg *tgbotapi.Message, userID int64) {
+	value := strings.TrimSpace(msg.Text)
+	if value == "" {
+		sendMessage(msg.Chat.ID, tm("translation.value_empty"), nil)
+		return
+	}
+	if value == "-" {
+		value = ""
+	}
+
+	data := userTempData[userID]
+	kind := data["kind"].(string)
+	id := data["id"].(int)
+
+	resp, err := httpClient.Post(translatables[kind].serviceURL(), shared.Request{
+		Action: "set_translation",
+		Payload: map[string]interface{}{
+			"id":     id,
+			"locale": data["locale"],
+			"field":  data["field"],
+			"value":  value,
+		},
+	})
+
+	delete(userStates, userID)
+	delete(userTempData, userID)
+
+	if err != nil || !resp.Success {
+		errMsg := tm("translation.save_failed")
+		if resp != nil && resp.Error != nil {
+			errMsg += "\n" + errorText(resp.Error)
+		}
+		sendMessage(msg.Chat.ID, errMsg, nil)
+		return
+	}
+
+	sendMessage(msg.Chat.ID, tm("translation.saved"), nil)
+	showTranslations(msg.Chat.ID, kind, id)
+}
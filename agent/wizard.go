@@ -0,0 +1,344 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Multi-step dialogs are declared as wizards: steps that each ask for one
+// value. A wizard keeps its answers in userTempData and its step in
+// userStates as "<wizard>_<step>", so /cancel and the dialog metrics work as
+// for hand-written dialogs. Every prompt has buttons to cancel, go back a
+// step and skip optional steps; wizards that confirm show a summary of the
+// answers before they are saved.
+
+// Value kept for a skipped optional step
+const skippedValue = ""
+
+// Choice buttons per keyboard row
+const wizardChoicesPerRow = 2
+
+var (
+	wizards = make(map[string]*wizard)
+
+	// wizardStates maps the dialog states of wizards to their step
+	wizardStates = make(map[string]wizardPosition)
+)
+
+// wizard is a multi-step dialog
+type wizard struct {
+	name string
+	// title is shown above the first prompt and the summary, if set
+	title string
+	steps []wizardStep
+	// confirm shows a summary of the answers to confirm before finish
+	confirm bool
+	// finish saves the answers; the dialog has ended when it is called
+	finish func(chatID, userID int64, data map[string]interface{})
+}
+
+// wizardStep asks for one value, kept in the wizard data under key
+type wizardStep struct {
+	key    string
+	prompt func(data map[string]interface{}) markup
+
+	// parse reads a typed answer. It returns the value to keep, or the
+	// message to answer with when the input is not valid.
+	parse func(msg *tgbotapi.Message, data map[string]interface{}) (interface{}, markup)
+	// choices are offered as buttons; typing the value or label of one picks
+	// it too. Steps with choices but no parse only take a choice.
+	choices func(data map[string]interface{}) []wizardChoice
+	// optional steps are skipped with "-" or a button and keep skippedValue
+	optional bool
+	// when, if set, asks the step only when it returns true
+	when func(data map[string]interface{}) bool
+
+	// label names the value in the summary; steps without one are left out.
+	// show formats the value, by default as it was kept.
+	label string
+	show  func(data map[string]interface{}) string
+}
+
+// wizardChoice is an answer offered as a button
+type wizardChoice struct {
+	value string
+	label string
+}
+
+// wizardPosition is a step of a wizard; step is len(steps) for its summary
+type wizardPosition struct {
+	wizard *wizard
+	step   int
+}
+
+// registerWizard adds a wizard, started with startWizard
+func registerWizard(w *wizard) {
+	if _, ok := wizards[w.name]; ok {
+		panic("wizard registered twice: " + w.name)
+	}
+	wizards[w.name] = w
+	for i, step := range w.steps {
+		wizardStates[w.name+"_"+step.key] = wizardPosition{wizard: w, step: i}
+	}
+	if w.confirm {
+		wizardStates[w.name+"_confirm"] = wizardPosition{wizard: w, step: len(w.steps)}
+	}
+}
+
+// startWizard starts wizard name for a user, with data holding what its
+// steps and finish need besides the answers
+func startWizard(chatID, userID int64, name string, data map[string]interface{}) {
+	w := wizards[name]
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	userTempData[userID] = data
+	askWizardStep(chatID, userID, w, 0)
+}
+
+// textPrompt returns a prompt showing the text of key
+func textPrompt(key string) func(map[string]interface{}) markup {
+	return func(map[string]interface{}) markup { return tm(key) }
+}
+
+// requiredText reads a text answer, refused with the text of emptyKey when
+// it is empty
+func requiredText(emptyKey string) func(*tgbotapi.Message, map[string]interface{}) (interface{}, markup) {
+	return func(msg *tgbotapi.Message, _ map[string]interface{}) (interface{}, markup) {
+		text := strings.TrimSpace(msg.Text)
+		if text == "" {
+			return nil, tm(emptyKey)
+		}
+		return text, ""
+	}
+}
+
+// anyText reads a text answer as it is typed, trimmed
+func anyText(msg *tgbotapi.Message, _ map[string]interface{}) (interface{}, markup) {
+	return strings.TrimSpace(msg.Text), ""
+}
+
+// asks reports whether step is asked with data
+func (step *wizardStep) asks(data map[string]interface{}) bool {
+	return step.when == nil || step.when(data)
+}
+
+// nextStep is the first step from i on that is asked, or len(steps)
+func (w *wizard) nextStep(data map[string]interface{}, i int) int {
+	for i < len(w.steps) && !w.steps[i].asks(data) {
+		i++
+	}
+	return i
+}
+
+// previousStep is the last step before i that is asked, or -1
+func (w *wizard) previousStep(data map[string]interface{}, i int) int {
+	for i--; i >= 0 && !w.steps[i].asks(data); i-- {
+	}
+	return i
+}
+
+// askWizardStep asks the first step from i on, or ends the wizard when all
+// were answered
+func askWizardStep(chatID, userID int64, w *wizard, i int) {
+	data := userTempData[userID]
+	i = w.nextStep(data, i)
+	if i == len(w.steps) {
+		if w.confirm {
+			showWizardSummary(chatID, userID, w)
+		} else {
+			finishWizard(chatID, userID, w)
+		}
+		return
+	}
+
+	step := &w.steps[i]
+	state := w.name + "_" + step.key
+	userStates[userID] = state
+
+	previous := w.previousStep(data, i)
+	text := step.prompt(data)
+	if previous < 0 && w.title != "" {
+		text = tm(w.title) + "\n\n" + text
+	}
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if step.choices != nil {
+		var row []tgbotapi.InlineKeyboardButton
+		for _, choice := range step.choices(data) {
+			row = append(row, button(choice.label, "wizard_pick", state, choice.value))
+			if len(row) == wizardChoicesPerRow {
+				rows = append(rows, row)
+				row = nil
+			}
+		}
+		if row != nil {
+			rows = append(rows, row)
+		}
+	}
+	if step.optional {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			button(t("wizard.btn_skip"), "wizard_skip", state),
+		))
+	}
+	var row []tgbotapi.InlineKeyboardButton
+	if previous >= 0 {
+		row = append(row, button(t("wizard.btn_back"), "wizard_back", state))
+	}
+	row = append(row, button(t("common.btn_cancel"), "wizard_cancel", state))
+	rows = append(rows, row)
+
+	sendMessage(chatID, text, tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+// handleWizardInput takes a typed answer to the step at pos
+func handleWizardInput(msg *tgbotapi.Message, userID int64, pos wizardPosition) {
+	w := pos.wizard
+	if pos.step == len(w.steps) {
+		sendMessage(msg.Chat.ID, tm("wizard.use_buttons"), nil)
+		return
+	}
+
+	step := &w.steps[pos.step]
+	data := userTempData[userID]
+	text := strings.TrimSpace(msg.Text)
+	if step.optional && text == "-" {
+		answerWizardStep(msg.Chat.ID, userID, pos, skippedValue)
+		return
+	}
+	if step.choices != nil {
+		for _, choice := range step.choices(data) {
+			if strings.EqualFold(text, choice.value) || strings.EqualFold(text, choice.label) {
+				answerWizardStep(msg.Chat.ID, userID, pos, choice.value)
+				return
+			}
+		}
+		if step.parse == nil {
+			sendMessage(msg.Chat.ID, tm("wizard.pick_choice"), nil)
+			return
+		}
+	}
+
+	value, invalid := step.parse(msg, data)
+	if invalid != "" {
+		sendMessage(msg.Chat.ID, invalid, nil)
+		return
+	}
+	answerWizardStep(msg.Chat.ID, userID, pos, value)
+}
+
+// answerWizardStep keeps the answer to the step at pos and asks the next one
+func answerWizardStep(chatID, userID int64, pos wizardPosition, value interface{}) {
+	userTempData[userID][pos.wizard.steps[pos.step].key] = value
+	askWizardStep(chatID, userID, pos.wizard, pos.step+1)
+}
+
+// showWizardSummary shows the answers of a wizard to confirm them
+func showWizardSummary(chatID, userID int64, w *wizard) {
+	userStates[userID] = w.name + "_confirm"
+	data := userTempData[userID]
+
+	var text markup
+	if w.title != "" {
+		text = tm(w.title) + "\n\n"
+	}
+	text += tm("wizard.summary") + "\n\n"
+	for i := range w.steps {
+		step := &w.steps[i]
+		if step.label == "" || !step.asks(data) {
+			continue
+		}
+		value := fmt.Sprint(data[step.key])
+		if step.show != nil {
+			value = step.show(data)
+		}
+		if value == skippedValue {
+			value = "-"
+		}
+		text += md("*%s:* %s\n", tm(step.label), value)
+	}
+
+	state := w.name + "_confirm"
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("wizard.btn_confirm"), "wizard_confirm", state),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			button(t("wizard.btn_back"), "wizard_back", state),
+			button(t("common.btn_cancel"), "wizard_cancel", state),
+		),
+	)
+	sendMessage(chatID, text, keyboard)
+}
+
+// finishWizard ends the dialog and hands its answers to finish
+func finishWizard(chatID, userID int64, w *wizard) {
+	data := userTempData[userID]
+	delete(userStates, userID)
+	delete(userTempData, userID)
+	w.finish(chatID, userID, data)
+}
+
+// wizardButton returns the step of a wizard button of c, or false when the
+// user has moved on from the step it was sent with
+func wizardButton(c *callbackContext) (wizardPosition, bool) {
+	state := c.str(0)
+	pos, ok := wizardStates[state]
+	if !ok || userStates[c.userID] != state {
+		// Leave the message pressed on, e.g. the result of the wizard, as it is
+		navMessage = nil
+		retireMessage(c.query.Message)
+		sendMessage(c.chatID, tm("common.button_expired"), nil)
+		return wizardPosition{}, false
+	}
+	return pos, true
+}
+
+func init() {
+	register("wizard_pick", callbackRoute{code: "wp", args: "ss", inPlace: true, handle: func(c *callbackContext) {
+		pos, ok := wizardButton(c)
+		if !ok || pos.step == len(pos.wizard.steps) {
+			return
+		}
+		step := &pos.wizard.steps[pos.step]
+		for _, choice := range step.choices(userTempData[c.userID]) {
+			if choice.value == c.str(1) {
+				answerWizardStep(c.chatID, c.userID, pos, choice.value)
+				return
+			}
+		}
+		sendMessage(c.chatID, tm("common.button_expired"), nil)
+	}})
+	register("wizard_skip", callbackRoute{code: "wk", args: "s", inPlace: true, handle: func(c *callbackContext) {
+		pos, ok := wizardButton(c)
+		if !ok || pos.step == len(pos.wizard.steps) || !pos.wizard.steps[pos.step].optional {
+			return
+		}
+		answerWizardStep(c.chatID, c.userID, pos, skippedValue)
+	}})
+	register("wizard_back", callbackRoute{code: "wb", args: "s", inPlace: true, handle: func(c *callbackContext) {
+		pos, ok := wizardButton(c)
+		if !ok {
+			return
+		}
+		previous := pos.wizard.previousStep(userTempData[c.userID], pos.step)
+		if previous < 0 {
+			previous = 0
+		}
+		askWizardStep(c.chatID, c.userID, pos.wizard, previous)
+	}})
+	register("wizard_confirm", callbackRoute{code: "wc", args: "s", inPlace: true, handle: func(c *callbackContext) {
+		if pos, ok := wizardButton(c); ok {
+			finishWizard(c.chatID, c.userID, pos.wizard)
+		}
+	}})
+	register("wizard_cancel", callbackRoute{code: "wx", args: "s", inPlace: true, handle: func(c *callbackContext) {
+		if _, ok := wizardButton(c); ok {
+			delete(userStates, c.userID)
+			delete(userTempData, c.userID)
+			sendMessage(c.chatID, tm("common.cancelled"), nil)
+		}
+	}})
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// withTestBot points the bot at a fake Telegram that takes every request,
+// and returns the texts of the messages sent
+func withTestBot(t *testing.T) func() []string {
+	t.Helper()
+	var mu sync.Mutex
+	var sent []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if text := r.FormValue("text"); text != "" {
+			mu.Lock()
+			sent = append(sent, text)
+			mu.Unlock()
+		}
+		w.Write([]byte(`{"ok":true,"result":{"id":1,"is_bot":true,"username":"test_bot","message_id":1,"chat":{"id":1}}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	previous := bot
+	testBot, err := tgbotapi.NewBotAPIWithClient("token", server.URL+"/bot%s/%s", server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot = testBot
+	t.Cleanup(func() { bot = previous })
+
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), sent...)
+	}
+}
+
+// withTestWizard registers an order wizard whose sugar step is asked only
+// for tea and whose note step is optional
+func withTestWizard(t *testing.T, finish func(chatID, userID int64, data map[string]interface{})) *wizard {
+	t.Helper()
+	w := &wizard{
+		name:    "test_order",
+		confirm: true,
+		finish:  finish,
+		steps: []wizardStep{
+			{
+				key:    "kind",
+				prompt: textPrompt("menu.prompt_name"),
+				choices: func(map[string]interface{}) []wizardChoice {
+					return []wizardChoice{{value: "coffee", label: "Kopi"}, {value: "tea", label: "Teh"}}
+				},
+				label: "menu.label_name",
+			},
+			{
+				key:    "sugar",
+				prompt: textPrompt("menu.prompt_name"),
+				parse:  requiredText("menu.name_empty"),
+				when:   func(data map[string]interface{}) bool { return data["kind"] == "tea" },
+				label:  "menu.label_name",
+			},
+			{
+				key:      "note",
+				prompt:   textPrompt("menu.prompt_description"),
+				parse:    anyText,
+				optional: true,
+				label:    "menu.label_description",
+			},
+			{
+				key:    "name",
+				prompt: textPrompt("menu.prompt_name"),
+				parse:  requiredText("menu.name_empty"),
+				label:  "menu.label_name",
+			},
+		},
+	}
+	registerWizard(w)
+	t.Cleanup(func() {
+		delete(wizards, w.name)
+		for state, pos := range wizardStates {
+			if pos.wizard == w {
+				delete(wizardStates, state)
+			}
+		}
+	})
+	return w
+}
+
+func TestWizardSteps(t *testing.T) {
+	w := withTestWizard(t, nil)
+	coffee := map[string]interface{}{"kind": "coffee"}
+	tea := map[string]interface{}{"kind": "tea"}
+	summary := len(w.steps)
+
+	tests := []struct {
+		name         string
+		data         map[string]interface{}
+		from         int
+		wantNext     int
+		wantPrevious int
+	}{
+		{"first step", coffee, 0, 0, -1},
+		{"skipped step", coffee, 1, 2, 0},
+		{"asked step", tea, 1, 1, 0},
+		{"after skipped step", coffee, 2, 2, 0},
+		{"after asked step", tea, 2, 2, 1},
+		{"summary", coffee, summary, summary, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := w.nextStep(tt.data, tt.from); got != tt.wantNext {
+				t.Errorf("nextStep(%d) = %d, want %d", tt.from, got, tt.wantNext)
+			}
+			if got := w.previousStep(tt.data, tt.from); got != tt.wantPrevious {
+				t.Errorf("previousStep(%d) = %d, want %d", tt.from, got, tt.wantPrevious)
+			}
+		})
+	}
+}
+
+func TestWizardFlow(t *testing.T) {
+	sent := withTestBot(t)
+	var finished map[string]interface{}
+	withTestWizard(t, func(chatID, userID int64, data map[string]interface{}) {
+		finished = data
+	})
+
+	const userID = 7
+	t.Cleanup(func() {
+		delete(userStates, userID)
+		delete(userTempData, userID)
+	})
+	input := func(text string) {
+		t.Helper()
+		handleDialogState(&tgbotapi.Message{Chat: &tgbotapi.Chat{ID: userID}, From: &tgbotapi.User{ID: userID}, Text: text}, userStates[userID])
+	}
+	press := func(action string) {
+		t.Helper()
+		c := &callbackContext{query: &tgbotapi.CallbackQuery{}, chatID: userID, userID: userID, args: []string{userStates[userID]}}
+		callbackRoutes[action].handle(c)
+	}
+	expectState := func(want string) {
+		t.Helper()
+		if got := userStates[userID]; got != want {
+			t.Fatalf("state = %q, want %q", got, want)
+		}
+	}
+
+	startWizard(userID, userID, "test_order", nil)
+	expectState("test_order_kind")
+
+	// Only optional steps can be skipped
+	press("wizard_skip")
+	expectState("test_order_kind")
+
+	// Coffee is not asked for sugar
+	input("kopi")
+	expectState("test_order_note")
+	if kind := userTempData[userID]["kind"]; kind != "coffee" {
+		t.Errorf("kind = %v, want the value of the typed choice", kind)
+	}
+
+	press("wizard_skip")
+	expectState("test_order_name")
+	if note, ok := userTempData[userID]["note"]; !ok || note != skippedValue {
+		t.Errorf("skipped note = %q, %v; want skippedValue", note, ok)
+	}
+
+	input("Budi")
+	expectState("test_order_confirm")
+
+	// Back from the summary goes to the last step, and on past the skipped one
+	press("wizard_back")
+	expectState("test_order_name")
+	press("wizard_back")
+	expectState("test_order_note")
+	press("wizard_back")
+	expectState("test_order_kind")
+
+	// Typing "-" skips an optional step too
+	input("tea")
+	expectState("test_order_sugar")
+	input("less")
+	expectState("test_order_note")
+	input("-")
+	expectState("test_order_name")
+	input("Budi")
+	press("wizard_confirm")
+
+	if _, ok := userStates[userID]; ok {
+		t.Errorf("dialog still in state %q after confirming", userStates[userID])
+	}
+	want := map[string]interface{}{"kind": "tea", "sugar": "less", "note": skippedValue, "name": "Budi"}
+	for key, value := range want {
+		if finished[key] != value {
+			t.Errorf("finished with %s = %v, want %v", key, finished[key], value)
+		}
+	}
+	if len(sent()) == 0 {
+		t.Error("no prompts sent")
+	}
+}
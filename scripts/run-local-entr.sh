@@ -87,6 +87,21 @@ run_with_entr "info-service" "8084" "INFO" &
 sleep 1
 
 run_with_entr "media-service" "8085" "MEDIA" &
+sleep 1
+
+run_with_entr "order-service" "8086" "ORDER" &
+sleep 1
+
+run_with_entr "report-service" "8087" "REPORT" &
+sleep 1
+
+run_with_entr "backup-service" "8088" "BACKUP" &
+sleep 1
+
+run_with_entr "reservation-service" "8089" "RESERVATION" &
+sleep 1
+
+run_with_entr "display-service" "8090" "DISPLAY" &
 sleep 2
 
 # Start agent with entr
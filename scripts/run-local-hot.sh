@@ -51,6 +51,11 @@ mkdir -p tmp/menu-service
 mkdir -p tmp/promo-service
 mkdir -p tmp/info-service
 mkdir -p tmp/media-service
+mkdir -p tmp/order-service
+mkdir -p tmp/report-service
+mkdir -p tmp/backup-service
+mkdir -p tmp/reservation-service
+mkdir -p tmp/display-service
 mkdir -p tmp/agent
 
 # Load environment variables
@@ -124,6 +129,11 @@ create_air_config "menu-service" "8082" "services/menu-service"
 create_air_config "promo-service" "8083" "services/promo-service"
 create_air_config "info-service" "8084" "services/info-service"
 create_air_config "media-service" "8085" "services/media-service"
+create_air_config "order-service" "8086" "services/order-service"
+create_air_config "report-service" "8087" "services/report-service"
+create_air_config "backup-service" "8088" "services/backup-service"
+create_air_config "reservation-service" "8089" "services/reservation-service"
+create_air_config "display-service" "8090" "services/display-service"
 create_air_config "agent" "" "agent"
 
 # Function to cleanup on exit
@@ -152,6 +162,21 @@ echo -e "${GREEN}Starting info-service on port 8084 with hot reload...${NC}"
 echo -e "${GREEN}Starting media-service on port 8085 with hot reload...${NC}"
 (cd services/media-service && MEDIA_SERVICE_PORT=8085 air 2>&1 | sed 's/^/[MEDIA] /') &
 
+echo -e "${GREEN}Starting order-service on port 8086 with hot reload...${NC}"
+(cd services/order-service && ORDER_SERVICE_PORT=8086 MENU_SERVICE_URL=http://localhost:8082 air 2>&1 | sed 's/^/[ORDER] /') &
+
+echo -e "${GREEN}Starting report-service on port 8087 with hot reload...${NC}"
+(cd services/report-service && REPORT_SERVICE_PORT=8087 air 2>&1 | sed 's/^/[REPORT] /') &
+
+echo -e "${GREEN}Starting backup-service on port 8088 with hot reload...${NC}"
+(cd services/backup-service && BACKUP_SERVICE_PORT=8088 air 2>&1 | sed 's/^/[BACKUP] /') &
+
+echo -e "${GREEN}Starting reservation-service on port 8089 with hot reload...${NC}"
+(cd services/reservation-service && RESERVATION_SERVICE_PORT=8089 INFO_SERVICE_URL=http://localhost:8084 air 2>&1 | sed 's/^/[RESERVATION] /') &
+
+echo -e "${GREEN}Starting display-service on port 8090 with hot reload...${NC}"
+(cd services/display-service && DISPLAY_SERVICE_PORT=8090 ORDER_SERVICE_URL=http://localhost:8086 INFO_SERVICE_URL=http://localhost:8084 air 2>&1 | sed 's/^/[DISPLAY] /') &
+
 # Wait for services to be ready
 echo -e "${YELLOW}Waiting for services to be ready...${NC}"
 sleep 3
@@ -111,6 +111,21 @@ watch_and_run "info-service" "INFO_SERVICE_PORT=8084" "INFO" &
 sleep 0.5
 
 watch_and_run "media-service" "MEDIA_SERVICE_PORT=8085" "MEDIA" &
+sleep 0.5
+
+watch_and_run "order-service" "ORDER_SERVICE_PORT=8086" "ORDER" &
+sleep 0.5
+
+watch_and_run "report-service" "REPORT_SERVICE_PORT=8087" "REPORT" &
+sleep 0.5
+
+watch_and_run "backup-service" "BACKUP_SERVICE_PORT=8088" "BACKUP" &
+sleep 0.5
+
+watch_and_run "reservation-service" "RESERVATION_SERVICE_PORT=8089" "RESERVATION" &
+sleep 0.5
+
+watch_and_run "display-service" "DISPLAY_SERVICE_PORT=8090" "DISPLAY" &
 sleep 1
 
 # Start agent with watcher
@@ -65,6 +65,26 @@ echo -e "${GREEN}Starting media-service on port 8085...${NC}"
 (cd services/media-service && MEDIA_SERVICE_PORT=8085 go run . 2>&1 | sed 's/^/[MEDIA] /') &
 MEDIA_PID=$!
 
+echo -e "${GREEN}Starting order-service on port 8086...${NC}"
+(cd services/order-service && ORDER_SERVICE_PORT=8086 MENU_SERVICE_URL=http://localhost:8082 go run . 2>&1 | sed 's/^/[ORDER] /') &
+ORDER_PID=$!
+
+echo -e "${GREEN}Starting report-service on port 8087...${NC}"
+(cd services/report-service && REPORT_SERVICE_PORT=8087 go run . 2>&1 | sed 's/^/[REPORT] /') &
+REPORT_PID=$!
+
+echo -e "${GREEN}Starting backup-service on port 8088...${NC}"
+(cd services/backup-service && BACKUP_SERVICE_PORT=8088 go run . 2>&1 | sed 's/^/[BACKUP] /') &
+BACKUP_PID=$!
+
+echo -e "${GREEN}Starting reservation-service on port 8089...${NC}"
+(cd services/reservation-service && RESERVATION_SERVICE_PORT=8089 INFO_SERVICE_URL=http://localhost:8084 go run . 2>&1 | sed 's/^/[RESERVATION] /') &
+RESERVATION_PID=$!
+
+echo -e "${GREEN}Starting display-service on port 8090...${NC}"
+(cd services/display-service && DISPLAY_SERVICE_PORT=8090 ORDER_SERVICE_URL=http://localhost:8086 INFO_SERVICE_URL=http://localhost:8084 go run . 2>&1 | sed 's/^/[DISPLAY] /') &
+DISPLAY_PID=$!
+
 # Wait for services to be ready
 echo -e "${YELLOW}Waiting for services to be ready...${NC}"
 sleep 3
@@ -81,6 +101,11 @@ echo "  MENU:  $MENU_PID"
 echo "  PROMO: $PROMO_PID"
 echo "  INFO:  $INFO_PID"
 echo "  MEDIA: $MEDIA_PID"
+echo "  ORDER: $ORDER_PID"
+echo "  REPORT: $REPORT_PID"
+echo "  BACKUP: $BACKUP_PID"
+echo "  RESERVATION: $RESERVATION_PID"
+echo "  DISPLAY: $DISPLAY_PID"
 echo "  AGENT: $AGENT_PID"
 echo ""
 echo -e "${YELLOW}Press Ctrl+C to stop all services${NC}"
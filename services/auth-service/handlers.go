@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 
@@ -31,6 +32,9 @@ func (h *Handler) HandleRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Queries are traced as part of this request
+	h = &Handler{repo: h.repo.WithContext(r.Context())}
+
 	var response *shared.Response
 
 	switch req.Action {
@@ -46,6 +50,8 @@ func (h *Handler) HandleRequest(w http.ResponseWriter, r *http.Request) {
 		response = h.registerAdmin(req.Payload)
 	case "update_status":
 		response = h.updateStatus(req.Payload)
+	case "update_scope":
+		response = h.updateScope(req.Payload)
 	default:
 		sendErrorResponse(w, shared.NewError(shared.ErrCodeInvalidInput, "Unknown action", nil))
 		return
@@ -160,7 +166,12 @@ func (h *Handler) registerAdmin(payload interface{}) *shared.Response {
 		return errorResponse(shared.NewInvalidInputError("telegram_id dan username diperlukan"))
 	}
 
-	admin, err := h.repo.CreateAdmin(telegramID, username)
+	role, branchID, appErr := scopeFromPayload(data)
+	if appErr != nil {
+		return errorResponse(appErr)
+	}
+
+	admin, err := h.repo.CreateAdmin(telegramID, username, role, branchID)
 	if err != nil {
 		return errorResponse(err.(*shared.AppError))
 	}
@@ -193,7 +204,83 @@ func (h *Handler) updateStatus(payload interface{}) *shared.Response {
 	})
 }
 
+// updateScope updates admin role and branch
+func (h *Handler) updateScope(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	telegramID, _ := data["telegram_id"].(string)
+	if telegramID == "" {
+		return errorResponse(shared.NewInvalidInputError("telegram_id diperlukan"))
+	}
+
+	role, branchID, appErr := scopeFromPayload(data)
+	if appErr != nil {
+		return errorResponse(appErr)
+	}
+
+	if err := h.repo.UpdateAdminScope(telegramID, role, branchID); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"message": "Hak akses admin berhasil diperbarui",
+	})
+}
+
+// HandleEvent deactivates the managers of branches deleted in info-service.
+// They are not moved to another branch or made owners; an owner can give
+// them a new branch with update_scope and reactivate them with
+// update_status. Deactivating them again does nothing.
+func (h *Handler) HandleEvent(ctx context.Context, event shared.Event) error {
+	if event.Service != "info-service" || event.Type != "branch.deleted" {
+		return nil
+	}
+	branchID, ok := event.Data["branch_id"].(float64)
+	if !ok {
+		shared.Logger(ctx).Warn("event without branch id")
+		return nil
+	}
+
+	deactivated, err := h.repo.WithContext(ctx).DeactivateBranchManagers(int(branchID))
+	if err != nil {
+		return err
+	}
+	if deactivated > 0 {
+		shared.Logger(ctx).Info("deactivated managers of removed branch", "branch_id", int(branchID), "count", deactivated)
+	}
+	return nil
+}
+
 // Helper functions
+
+// scopeFromPayload reads role and branch_id. Owners are not bound to a
+// branch; managers must name one.
+func scopeFromPayload(data map[string]interface{}) (string, int, *shared.AppError) {
+	role, _ := data["role"].(string)
+	if role == "" {
+		role = RoleOwner
+	}
+
+	branchID := 0
+	if id, ok := data["branch_id"].(float64); ok && id > 0 {
+		branchID = int(id)
+	}
+
+	switch role {
+	case RoleOwner:
+		return role, 0, nil
+	case RoleManager:
+		if branchID == 0 {
+			return "", 0, shared.NewInvalidInputError("branch_id diperlukan untuk role manager")
+		}
+		return role, branchID, nil
+	default:
+		return "", 0, shared.NewInvalidInputError("Role harus 'owner' atau 'manager'")
+	}
+}
 func successResponse(data interface{}) *shared.Response {
 	return &shared.Response{
 		Success: true,
@@ -204,10 +291,7 @@ func successResponse(data interface{}) *shared.Response {
 func errorResponse(err *shared.AppError) *shared.Response {
 	return &shared.Response{
 		Success: false,
-		Error: &shared.ErrorInfo{
-			Code:    err.Code,
-			Message: err.Message,
-		},
+		Error:   err.Info(),
 	}
 }
 
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -14,6 +15,8 @@ import (
 func main() {
 	// Load environment variables
 	godotenv.Load()
+	shared.ConfigureLogger("auth-service")
+	shared.ConfigureTracing("auth-service")
 
 	port := os.Getenv("AUTH_SERVICE_PORT")
 	if port == "" {
@@ -40,28 +43,35 @@ func main() {
 
 	// Cleanup expired sessions periodically
 	go func() {
+		ctx := shared.WithLogFields(context.Background(), "job", "cleanup_sessions")
+		repo := repo.WithContext(ctx)
 		ticker := time.NewTicker(1 * time.Hour)
 		defer ticker.Stop()
 		for range ticker.C {
 			if err := repo.CleanupExpiredSessions(); err != nil {
-				shared.LogError("Failed to cleanup sessions: %v", err)
+				shared.Logger(ctx).Error("failed to clean up sessions", "error", err)
 			}
 		}
 	}()
 
+	// Events are handled once, even when delivered again
+	dedup, err := shared.NewDBDeduper(db)
+	if err != nil {
+		log.Fatalf("Failed to initialize event log: %v", err)
+	}
+
 	// Initialize handler
 	handler := NewHandler(repo)
 
 	// Setup routes
-	http.HandleFunc("/", handler.HandleRequest)
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
+	http.HandleFunc("/", shared.InstrumentRequests(handler.HandleRequest))
+	http.HandleFunc("/events", shared.HandleEvents(dedup, handler.HandleEvent))
+	shared.NewHealth("auth-service").WithDatabase(db).Register()
+	http.HandleFunc("/metrics", shared.MetricsHandler)
 
 	// Start server
 	addr := fmt.Sprintf(":%s", port)
-	shared.LogInfo("Auth service starting on %s", addr)
+	shared.Logger(context.Background()).Info("service starting", "addr", addr)
 	if err := http.ListenAndServe(addr, nil); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
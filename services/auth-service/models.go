@@ -2,11 +2,21 @@ package main
 
 import "time"
 
+// Admin roles
+const (
+	// RoleOwner manages every branch
+	RoleOwner = "owner"
+	// RoleManager manages only the branch in Admin.BranchID
+	RoleManager = "manager"
+)
+
 // Admin represents an admin user
 type Admin struct {
 	ID         int       `json:"id"`
 	TelegramID string    `json:"telegram_id"`
 	Username   string    `json:"username"`
+	Role       string    `json:"role"`
+	BranchID   int       `json:"branch_id"` // 0 for owners
 	IsActive   bool      `json:"is_active"`
 	CreatedAt  time.Time `json:"created_at"`
 }
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/rand"
 	"database/sql"
 	"encoding/hex"
@@ -11,14 +12,23 @@ import (
 
 // Repository handles database operations
 type Repository struct {
-	db *sql.DB
+	shared.Store
 }
 
 // NewRepository creates a new repository
 func NewRepository(db *sql.DB) *Repository {
-	return &Repository{db: db}
+	return &Repository{shared.NewStore(db)}
 }
 
+// WithContext returns the repository running its queries with ctx
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{r.Store.WithContext(ctx)}
+}
+
+// schemaVersion is recorded in the database by InitSchema and reported by
+// /ready; bump it with every schema change
+const schemaVersion = 3
+
 // InitSchema initializes database schema
 func (r *Repository) InitSchema() error {
 	schema := `
@@ -42,13 +52,24 @@ func (r *Repository) InitSchema() error {
 	CREATE INDEX IF NOT EXISTS idx_telegram_id ON admins(telegram_id);
 	CREATE INDEX IF NOT EXISTS idx_token ON sessions(token);
 	`
-	return shared.ExecuteSchema(r.db, schema)
+	if err := shared.ExecuteSchema(r.DB, schema); err != nil {
+		return err
+	}
+
+	// Columns added after the first release
+	if err := shared.AddColumnIfNotExists(r.DB, "admins", "role", "TEXT NOT NULL DEFAULT '"+RoleOwner+"'"); err != nil {
+		return err
+	}
+	if err := shared.AddColumnIfNotExists(r.DB, "admins", "branch_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	return shared.SetSchemaVersion(r.DB, schemaVersion)
 }
 
 // CreateAdmin creates a new admin
-func (r *Repository) CreateAdmin(telegramID, username string) (*Admin, error) {
-	query := `INSERT INTO admins (telegram_id, username) VALUES (?, ?)`
-	result, err := r.db.Exec(query, telegramID, username)
+func (r *Repository) CreateAdmin(telegramID, username, role string, branchID int) (*Admin, error) {
+	query := `INSERT INTO admins (telegram_id, username, role, branch_id) VALUES (?, ?, ?, ?)`
+	result, err := r.DB.ExecContext(r.Ctx, query, telegramID, username, role, branchID)
 	if err != nil {
 		return nil, shared.NewDatabaseError(err)
 	}
@@ -58,6 +79,8 @@ func (r *Repository) CreateAdmin(telegramID, username string) (*Admin, error) {
 		ID:         int(id),
 		TelegramID: telegramID,
 		Username:   username,
+		Role:       role,
+		BranchID:   branchID,
 		IsActive:   true,
 		CreatedAt:  time.Now(),
 	}, nil
@@ -65,10 +88,10 @@ func (r *Repository) CreateAdmin(telegramID, username string) (*Admin, error) {
 
 // GetAdminByTelegramID gets admin by telegram ID
 func (r *Repository) GetAdminByTelegramID(telegramID string) (*Admin, error) {
-	query := `SELECT id, telegram_id, username, is_active, created_at FROM admins WHERE telegram_id = ?`
+	query := `SELECT id, telegram_id, username, role, branch_id, is_active, created_at FROM admins WHERE telegram_id = ?`
 	var admin Admin
-	err := r.db.QueryRow(query, telegramID).Scan(
-		&admin.ID, &admin.TelegramID, &admin.Username, &admin.IsActive, &admin.CreatedAt,
+	err := r.DB.QueryRowContext(r.Ctx, query, telegramID).Scan(
+		&admin.ID, &admin.TelegramID, &admin.Username, &admin.Role, &admin.BranchID, &admin.IsActive, &admin.CreatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, shared.NewNotFoundError("Admin")
@@ -81,10 +104,10 @@ func (r *Repository) GetAdminByTelegramID(telegramID string) (*Admin, error) {
 
 // GetAdminByUsername gets admin by username
 func (r *Repository) GetAdminByUsername(username string) (*Admin, error) {
-	query := `SELECT id, telegram_id, username, is_active, created_at FROM admins WHERE username = ?`
+	query := `SELECT id, telegram_id, username, role, branch_id, is_active, created_at FROM admins WHERE username = ?`
 	var admin Admin
-	err := r.db.QueryRow(query, username).Scan(
-		&admin.ID, &admin.TelegramID, &admin.Username, &admin.IsActive, &admin.CreatedAt,
+	err := r.DB.QueryRowContext(r.Ctx, query, username).Scan(
+		&admin.ID, &admin.TelegramID, &admin.Username, &admin.Role, &admin.BranchID, &admin.IsActive, &admin.CreatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, shared.NewNotFoundError("Admin")
@@ -97,8 +120,8 @@ func (r *Repository) GetAdminByUsername(username string) (*Admin, error) {
 
 // ListAdmins lists all admins
 func (r *Repository) ListAdmins() ([]Admin, error) {
-	query := `SELECT id, telegram_id, username, is_active, created_at FROM admins ORDER BY created_at DESC`
-	rows, err := r.db.Query(query)
+	query := `SELECT id, telegram_id, username, role, branch_id, is_active, created_at FROM admins ORDER BY created_at DESC`
+	rows, err := r.DB.QueryContext(r.Ctx, query)
 	if err != nil {
 		return nil, shared.NewDatabaseError(err)
 	}
@@ -107,7 +130,7 @@ func (r *Repository) ListAdmins() ([]Admin, error) {
 	var admins []Admin
 	for rows.Next() {
 		var admin Admin
-		if err := rows.Scan(&admin.ID, &admin.TelegramID, &admin.Username, &admin.IsActive, &admin.CreatedAt); err != nil {
+		if err := rows.Scan(&admin.ID, &admin.TelegramID, &admin.Username, &admin.Role, &admin.BranchID, &admin.IsActive, &admin.CreatedAt); err != nil {
 			return nil, shared.NewDatabaseError(err)
 		}
 		admins = append(admins, admin)
@@ -121,7 +144,7 @@ func (r *Repository) CreateSession(adminID int) (*Session, error) {
 	expiresAt := time.Now().Add(24 * time.Hour)
 
 	query := `INSERT INTO sessions (admin_id, token, expires_at) VALUES (?, ?, ?)`
-	result, err := r.db.Exec(query, adminID, token, expiresAt)
+	result, err := r.DB.ExecContext(r.Ctx, query, adminID, token, expiresAt)
 	if err != nil {
 		return nil, shared.NewDatabaseError(err)
 	}
@@ -139,14 +162,14 @@ func (r *Repository) CreateSession(adminID int) (*Session, error) {
 // VerifySession verifies a session token
 func (r *Repository) VerifySession(token string) (*Admin, error) {
 	query := `
-		SELECT a.id, a.telegram_id, a.username, a.is_active, a.created_at 
+		SELECT a.id, a.telegram_id, a.username, a.role, a.branch_id, a.is_active, a.created_at 
 		FROM admins a
 		JOIN sessions s ON a.id = s.admin_id
 		WHERE s.token = ? AND s.expires_at > datetime('now') AND a.is_active = 1
 	`
 	var admin Admin
-	err := r.db.QueryRow(query, token).Scan(
-		&admin.ID, &admin.TelegramID, &admin.Username, &admin.IsActive, &admin.CreatedAt,
+	err := r.DB.QueryRowContext(r.Ctx, query, token).Scan(
+		&admin.ID, &admin.TelegramID, &admin.Username, &admin.Role, &admin.BranchID, &admin.IsActive, &admin.CreatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, shared.NewUnauthorizedError()
@@ -160,7 +183,7 @@ func (r *Repository) VerifySession(token string) (*Admin, error) {
 // DeleteSession deletes a session
 func (r *Repository) DeleteSession(token string) error {
 	query := `DELETE FROM sessions WHERE token = ?`
-	_, err := r.db.Exec(query, token)
+	_, err := r.DB.ExecContext(r.Ctx, query, token)
 	if err != nil {
 		return shared.NewDatabaseError(err)
 	}
@@ -170,7 +193,7 @@ func (r *Repository) DeleteSession(token string) error {
 // CleanupExpiredSessions removes expired sessions
 func (r *Repository) CleanupExpiredSessions() error {
 	query := `DELETE FROM sessions WHERE expires_at < datetime('now')`
-	_, err := r.db.Exec(query)
+	_, err := r.DB.ExecContext(r.Ctx, query)
 	if err != nil {
 		return shared.NewDatabaseError(err)
 	}
@@ -180,13 +203,40 @@ func (r *Repository) CleanupExpiredSessions() error {
 // UpdateAdminStatus updates admin active status
 func (r *Repository) UpdateAdminStatus(telegramID string, isActive bool) error {
 	query := `UPDATE admins SET is_active = ? WHERE telegram_id = ?`
-	_, err := r.db.Exec(query, isActive, telegramID)
+	_, err := r.DB.ExecContext(r.Ctx, query, isActive, telegramID)
 	if err != nil {
 		return shared.NewDatabaseError(err)
 	}
 	return nil
 }
 
+// UpdateAdminScope updates admin role and branch
+func (r *Repository) UpdateAdminScope(telegramID, role string, branchID int) error {
+	query := `UPDATE admins SET role = ?, branch_id = ? WHERE telegram_id = ?`
+	result, err := r.DB.ExecContext(r.Ctx, query, role, branchID, telegramID)
+	if err != nil {
+		return shared.NewDatabaseError(err)
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return shared.NewNotFoundError("Admin")
+	}
+	return nil
+}
+
+// DeactivateBranchManagers deactivates the managers of a deleted branch and
+// returns how many there were. Owners are bound to no branch.
+func (r *Repository) DeactivateBranchManagers(branchID int) (int, error) {
+	result, err := r.DB.ExecContext(r.Ctx, `UPDATE admins SET is_active = 0 WHERE role = ? AND branch_id = ? AND is_active = 1`,
+		RoleManager, branchID)
+	if err != nil {
+		return 0, shared.NewDatabaseError(err)
+	}
+	affected, _ := result.RowsAffected()
+	return int(affected), nil
+}
+
 // generateToken generates a random token
 func generateToken() string {
 	b := make([]byte, 32)
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+const (
+	archivePrefix     = "backup-"
+	archiveExt        = ".tar.gz"
+	checksumExt       = ".sha256"
+	manifestFile      = "manifest.json"
+	archiveTimeLayout = "20060102-150405"
+
+	// A snapshot waits this long in total for a writer to release its lock
+	backupRetries    = 50
+	backupRetryDelay = 100 * time.Millisecond
+)
+
+// errNoDatabases is returned when none of the configured databases exist yet
+var errNoDatabases = errors.New("no database files found")
+
+// Backupper snapshots the service databases into rotated archives
+type Backupper struct {
+	dir       string
+	keep      int
+	databases []Database
+	mu        sync.Mutex
+}
+
+// NewBackupper creates a backupper writing to dir and keeping the newest
+// keep archives
+func NewBackupper(dir string, keep int, databases []Database) *Backupper {
+	return &Backupper{dir: dir, keep: keep, databases: databases}
+}
+
+// CheckDir checks that archives can be written to the backup directory
+func (b *Backupper) CheckDir(ctx context.Context) error {
+	if err := os.MkdirAll(b.dir, 0755); err != nil {
+		return fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	f, err := os.CreateTemp(b.dir, ".health-")
+	if err != nil {
+		return fmt.Errorf("backup directory is not writable: %w", err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// Run snapshots every database into a new archive. Each snapshot is taken
+// with the SQLite online backup API, so services keep running and every file
+// in the archive is a consistent copy of its database.
+func (b *Backupper) Run(ctx context.Context, label string) (*Archive, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.run(ctx, label)
+}
+
+func (b *Backupper) run(ctx context.Context, label string) (*Archive, error) {
+	if err := os.MkdirAll(b.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	// Names have a resolution of one second; wait rather than overwrite
+	createdAt := time.Now()
+	for {
+		if _, err := os.Stat(filepath.Join(b.dir, archiveName(createdAt, label))); os.IsNotExist(err) {
+			break
+		}
+		time.Sleep(time.Until(createdAt.Truncate(time.Second).Add(time.Second)))
+		createdAt = time.Now()
+	}
+	name := archiveName(createdAt, label)
+	path := filepath.Join(b.dir, name)
+
+	tmpDir, err := os.MkdirTemp(b.dir, ".snapshot-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	manifest := Manifest{CreatedAt: createdAt, Label: label}
+	for _, db := range b.databases {
+		if _, err := os.Stat(db.Path); os.IsNotExist(err) {
+			manifest.Skipped = append(manifest.Skipped, db.Name)
+			continue
+		}
+
+		file := db.Name + ".db"
+		dest := filepath.Join(tmpDir, file)
+		if err := copyDatabase(db.Path, dest); err != nil {
+			return nil, fmt.Errorf("failed to back up %s: %w", db.Name, err)
+		}
+
+		sum, size, err := fileChecksum(dest)
+		if err != nil {
+			return nil, err
+		}
+		manifest.Files = append(manifest.Files, ArchiveFile{Database: db.Name, File: file, Size: size, SHA256: sum})
+	}
+	if len(manifest.Files) == 0 {
+		return nil, errNoDatabases
+	}
+
+	// Write under a temporary name so a partial archive is never listed
+	if err := writeArchive(path+".tmp", tmpDir, manifest); err != nil {
+		os.Remove(path + ".tmp")
+		return nil, err
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return nil, fmt.Errorf("failed to save archive: %w", err)
+	}
+
+	// The sidecar uses the sha256sum format, so `sha256sum -c` can check it
+	sum, size, err := fileChecksum(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path+checksumExt, []byte(fmt.Sprintf("%s  %s\n", sum, name)), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write checksum: %w", err)
+	}
+
+	if err := b.rotate(ctx, label); err != nil {
+		shared.Logger(ctx).Error("failed to rotate backups", "error", err)
+	}
+
+	shared.Logger(ctx).Info("backup created", "name", name, "databases", len(manifest.Files), "bytes", size)
+	if len(manifest.Skipped) > 0 {
+		shared.Logger(ctx).Info("backup skipped missing databases", "name", name, "skipped", strings.Join(manifest.Skipped, ", "))
+	}
+
+	return &Archive{
+		Name:      name,
+		Label:     label,
+		Size:      size,
+		Checksum:  sum,
+		CreatedAt: manifest.CreatedAt,
+		Files:     manifest.Files,
+	}, nil
+}
+
+// List returns the archives in the backup directory, newest first
+func (b *Backupper) List() ([]Archive, error) {
+	entries, err := os.ReadDir(b.dir)
+	if os.IsNotExist(err) {
+		return []Archive{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup directory: %w", err)
+	}
+
+	archives := []Archive{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, archivePrefix) || !strings.HasSuffix(name, archiveExt) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		archive := Archive{Name: name, Size: info.Size(), CreatedAt: info.ModTime()}
+		stamp := strings.TrimSuffix(strings.TrimPrefix(name, archivePrefix), archiveExt)
+		if len(stamp) >= len(archiveTimeLayout) {
+			if t, err := time.ParseInLocation(archiveTimeLayout, stamp[:len(archiveTimeLayout)], time.Local); err == nil {
+				archive.CreatedAt = t
+			}
+			archive.Label = strings.TrimPrefix(stamp[len(archiveTimeLayout):], "-")
+		}
+		if sidecar, err := os.ReadFile(filepath.Join(b.dir, name+checksumExt)); err == nil {
+			if fields := strings.Fields(string(sidecar)); len(fields) > 0 {
+				archive.Checksum = fields[0]
+			}
+		}
+		archives = append(archives, archive)
+	}
+
+	sort.Slice(archives, func(i, j int) bool {
+		return archives[i].CreatedAt.After(archives[j].CreatedAt)
+	})
+	return archives, nil
+}
+
+// Read returns the content of an archive listed by List
+func (b *Backupper) Read(name string) ([]byte, error) {
+	archives, err := b.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, archive := range archives {
+		if archive.Name == name {
+			return os.ReadFile(filepath.Join(b.dir, name))
+		}
+	}
+	return nil, os.ErrNotExist
+}
+
+// archiveName names an archive after its creation time and label
+func archiveName(createdAt time.Time, label string) string {
+	name := archivePrefix + createdAt.Format(archiveTimeLayout)
+	if label != "" {
+		name += "-" + label
+	}
+	return name + archiveExt
+}
+
+// rotate deletes the oldest archives with the given label beyond the number
+// to keep. Labels rotate separately, so the safety copies taken by restores
+// never push out regular backups.
+func (b *Backupper) rotate(ctx context.Context, label string) error {
+	if b.keep <= 0 {
+		return nil
+	}
+	archives, err := b.List()
+	if err != nil {
+		return err
+	}
+	var group []Archive
+	for _, archive := range archives {
+		if archive.Label == label {
+			group = append(group, archive)
+		}
+	}
+	for _, archive := range group[min(b.keep, len(group)):] {
+		path := filepath.Join(b.dir, archive.Name)
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+		os.Remove(path + checksumExt)
+		shared.Logger(ctx).Info("backup removed by rotation", "name", archive.Name)
+	}
+	return nil
+}
+
+// copyDatabase copies the SQLite database at srcPath into destPath with the
+// online backup API and checks the integrity of the copy. The destination is
+// overwritten page by page, so it may be a database other connections use.
+func copyDatabase(srcPath, destPath string) error {
+	src, err := sql.Open("sqlite3", srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest, err := sql.Open("sqlite3", destPath)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	ctx := context.Background()
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+
+	err = destConn.Raw(func(destDriver interface{}) error {
+		return srcConn.Raw(func(srcDriver interface{}) error {
+			backup, err := destDriver.(*sqlite3.SQLiteConn).Backup("main", srcDriver.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return err
+			}
+
+			// Step(-1) copies every page under one read lock. It reports
+			// not done while another process holds a write lock.
+			for attempt := 0; ; attempt++ {
+				done, err := backup.Step(-1)
+				if err != nil {
+					backup.Finish()
+					return err
+				}
+				if done {
+					break
+				}
+				if attempt == backupRetries {
+					backup.Finish()
+					return errors.New("database is busy")
+				}
+				time.Sleep(backupRetryDelay)
+			}
+			return backup.Finish()
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	var result string
+	if err := destConn.QueryRowContext(ctx, "PRAGMA integrity_check").Scan(&result); err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check failed: %s", result)
+	}
+	return nil
+}
+
+// writeArchive writes the manifest and the snapshots in dir to a gzipped tar
+func writeArchive(path, dir string, manifest Manifest) error {
+	out, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer out.Close()
+
+	gz := gzip.NewWriter(out)
+	tw := tar.NewWriter(gz)
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, manifestFile, manifestData, manifest.CreatedAt); err != nil {
+		return err
+	}
+	for _, file := range manifest.Files {
+		data, err := os.ReadFile(filepath.Join(dir, file.File))
+		if err != nil {
+			return err
+		}
+		if err := writeTarFile(tw, file.File, data, manifest.CreatedAt); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	header := &tar.Header{Name: name, Mode: 0644, Size: int64(len(data)), ModTime: modTime}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// fileChecksum returns the hex SHA-256 and size of a file
+func fileChecksum(path string) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+)
+
+// Largest archive returned by download; Telegram bots may send up to 50 MB
+const maxDownloadBytes = 45 << 20
+
+// Handler handles HTTP requests. Restores are only possible through the
+// restore command, never over HTTP.
+type Handler struct {
+	backups *Backupper
+}
+
+// NewHandler creates a new handler
+func NewHandler(backups *Backupper) *Handler {
+	return &Handler{backups: backups}
+}
+
+// HandleRequest handles all incoming requests
+func (h *Handler) HandleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, shared.NewError(shared.ErrCodeInvalidInput, "Method not allowed", nil))
+		return
+	}
+
+	var req shared.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, shared.NewError(shared.ErrCodeInvalidInput, "Invalid request format", err))
+		return
+	}
+
+	var response *shared.Response
+
+	switch req.Action {
+	case "create":
+		response = h.createBackup(r.Context())
+	case "list":
+		response = h.listBackups()
+	case "download":
+		response = h.downloadBackup(req.Payload)
+	default:
+		sendErrorResponse(w, shared.NewError(shared.ErrCodeInvalidInput, "Unknown action", nil))
+		return
+	}
+
+	sendResponse(w, response)
+}
+
+// createBackup backs up every database now
+func (h *Handler) createBackup(ctx context.Context) *shared.Response {
+	archive, err := h.backups.Run(ctx, "")
+	if err != nil {
+		shared.Logger(ctx).Error("backup failed", "error", err)
+		return errorResponse(shared.NewKeyedError(shared.ErrCodeInternalError, "error.backup_failed", err))
+	}
+
+	return successResponse(map[string]interface{}{
+		"backup": archive,
+	})
+}
+
+// listBackups lists the archives, newest first
+func (h *Handler) listBackups() *shared.Response {
+	archives, err := h.backups.List()
+	if err != nil {
+		return errorResponse(shared.NewInternalError(err))
+	}
+
+	return successResponse(map[string]interface{}{
+		"backups": archives,
+	})
+}
+
+// downloadBackup returns an archive as base64, the newest one when no name
+// is given
+func (h *Handler) downloadBackup(payload interface{}) *shared.Response {
+	name := ""
+	if data, ok := payload.(map[string]interface{}); ok {
+		name, _ = data["name"].(string)
+	}
+
+	archives, err := h.backups.List()
+	if err != nil {
+		return errorResponse(shared.NewInternalError(err))
+	}
+
+	var archive *Archive
+	for i := range archives {
+		if name == "" || archives[i].Name == name {
+			archive = &archives[i]
+			break
+		}
+	}
+	if archive == nil {
+		return errorResponse(shared.NewNotFoundError("Backup"))
+	}
+	if archive.Size > maxDownloadBytes {
+		return errorResponse(shared.NewKeyedInvalidInputError("error.backup_too_large"))
+	}
+
+	content, err := h.backups.Read(archive.Name)
+	if os.IsNotExist(err) {
+		return errorResponse(shared.NewNotFoundError("Backup"))
+	}
+	if err != nil {
+		return errorResponse(shared.NewInternalError(err))
+	}
+
+	return successResponse(map[string]interface{}{
+		"backup":  archive,
+		"content": base64.StdEncoding.EncodeToString(content),
+	})
+}
+
+// Helper functions
+
+func successResponse(data interface{}) *shared.Response {
+	return &shared.Response{
+		Success: true,
+		Data:    data,
+	}
+}
+
+func errorResponse(err *shared.AppError) *shared.Response {
+	return &shared.Response{
+		Success: false,
+		Error:   err.Info(),
+	}
+}
+
+func sendResponse(w http.ResponseWriter, response *shared.Response) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func sendErrorResponse(w http.ResponseWriter, err *shared.AppError) {
+	w.Header().Set("Content-Type", "application/json")
+	response := errorResponse(err)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+	"github.com/joho/godotenv"
+)
+
+// defaultDatabases are the service databases as laid out by the local run
+// scripts, relative to this service's directory
+var defaultDatabases = []string{"auth", "menu", "promo", "info", "media", "order", "reservation"}
+
+const usage = `Usage:
+  backup-service                            run the service with scheduled backups
+  backup-service backup                     create a backup now
+  backup-service list                       list backups
+  backup-service restore <archive> [db...]  restore all or some databases from an archive`
+
+func main() {
+	// Load environment variables
+	godotenv.Load()
+	shared.ConfigureLogger("backup-service")
+	shared.ConfigureTracing("backup-service")
+
+	port := os.Getenv("BACKUP_SERVICE_PORT")
+	if port == "" {
+		port = "8088"
+	}
+
+	backupDir := os.Getenv("BACKUP_DIR")
+	if backupDir == "" {
+		backupDir = "./backups"
+	}
+
+	keep := 14
+	if value := os.Getenv("BACKUP_KEEP"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			log.Fatalf("Invalid BACKUP_KEEP: %q", value)
+		}
+		keep = n
+	}
+
+	interval := 24 * time.Hour
+	if value := os.Getenv("BACKUP_INTERVAL"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < time.Minute {
+			log.Fatalf("Invalid BACKUP_INTERVAL: %q (e.g. 6h, minimum 1m)", value)
+		}
+		interval = d
+	}
+
+	databases, err := parseDatabases(os.Getenv("BACKUP_DATABASES"))
+	if err != nil {
+		log.Fatalf("Invalid BACKUP_DATABASES: %v", err)
+	}
+
+	backups := NewBackupper(backupDir, keep, databases)
+
+	if len(os.Args) > 1 {
+		runCommand(backups, os.Args[1:])
+		return
+	}
+
+	go scheduleBackups(backups, interval)
+
+	// Initialize handler
+	handler := NewHandler(backups)
+
+	// Setup routes
+	http.HandleFunc("/", shared.InstrumentRequests(handler.HandleRequest))
+	shared.NewHealth("backup-service").WithCheck("backup_dir", true, backups.CheckDir).Register()
+	http.HandleFunc("/metrics", shared.MetricsHandler)
+
+	// Start server
+	addr := fmt.Sprintf(":%s", port)
+	shared.Logger(context.Background()).Info("service starting", "addr", addr, "interval", interval, "keep", keep, "dir", backupDir)
+	if err := http.ListenAndServe(addr, nil); err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+}
+
+// runCommand runs a one-off command instead of the service
+func runCommand(backups *Backupper, args []string) {
+	switch args[0] {
+	case "backup":
+		archive, err := backups.Run(context.Background(), "")
+		if err != nil {
+			log.Fatalf("Backup failed: %v", err)
+		}
+		fmt.Printf("%s  %s (%d bytes)\n", archive.Checksum, archive.Name, archive.Size)
+	case "list":
+		archives, err := backups.List()
+		if err != nil {
+			log.Fatalf("Failed to list backups: %v", err)
+		}
+		for _, archive := range archives {
+			fmt.Printf("%s  %10d  %s\n", archive.CreatedAt.Format("2006-01-02 15:04:05"), archive.Size, archive.Name)
+		}
+	case "restore":
+		if len(args) < 2 {
+			log.Fatal(usage)
+		}
+		manifest, err := backups.Restore(context.Background(), args[1], args[2:])
+		if err != nil {
+			log.Fatalf("Restore failed: %v", err)
+		}
+		for _, file := range manifest.Files {
+			fmt.Printf("restored %s (%d bytes)\n", file.Database, file.Size)
+		}
+		fmt.Println("Restart the services to make sure they pick up the restored data.")
+	default:
+		log.Fatal(usage)
+	}
+}
+
+// scheduleBackups runs a backup every interval. A backup runs at startup
+// when the newest archive is already older than the interval.
+func scheduleBackups(backups *Backupper, interval time.Duration) {
+	next := interval
+	if archives, err := backups.List(); err == nil {
+		if len(archives) == 0 {
+			next = 0
+		} else if age := time.Since(archives[0].CreatedAt); age < interval {
+			next = interval - age
+		} else {
+			next = 0
+		}
+	}
+
+	ctx := shared.WithLogFields(context.Background(), "job", "scheduled_backup")
+	for {
+		time.Sleep(next)
+		if _, err := backups.Run(ctx, ""); err != nil {
+			shared.Logger(ctx).Error("scheduled backup failed", "error", err)
+		}
+		next = interval
+	}
+}
+
+// parseDatabases reads "name=path" pairs separated by commas. An empty
+// value uses the default layout of the local run scripts.
+func parseDatabases(value string) ([]Database, error) {
+	var databases []Database
+	if strings.TrimSpace(value) == "" {
+		for _, name := range defaultDatabases {
+			databases = append(databases, Database{
+				Name: name,
+				Path: fmt.Sprintf("../%s-service/data/%s.db", name, name),
+			})
+		}
+		return databases, nil
+	}
+
+	seen := map[string]bool{}
+	for _, pair := range strings.Split(value, ",") {
+		name, path, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		name, path = strings.TrimSpace(name), strings.TrimSpace(path)
+		if !ok || name == "" || path == "" {
+			return nil, fmt.Errorf("expected name=path, got %q", pair)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("database %s listed twice", name)
+		}
+		seen[name] = true
+		databases = append(databases, Database{Name: name, Path: path})
+	}
+	return databases, nil
+}
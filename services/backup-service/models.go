@@ -0,0 +1,37 @@
+package main
+
+import "time"
+
+// Database is a service database included in backups
+type Database struct {
+	Name string `json:"name"` // e.g. "menu", also the file name inside archives
+	Path string `json:"path"`
+}
+
+// Archive is a backup archive in the backup directory
+type Archive struct {
+	Name      string        `json:"name"`
+	Label     string        `json:"label,omitempty"` // e.g. "pre-restore"; empty for regular backups
+	Size      int64         `json:"size"`
+	Checksum  string        `json:"checksum"` // SHA-256 of the archive file
+	CreatedAt time.Time     `json:"created_at"`
+	Files     []ArchiveFile `json:"files,omitempty"`
+}
+
+// ArchiveFile is a database snapshot inside an archive
+type ArchiveFile struct {
+	Database string `json:"database"`
+	File     string `json:"file"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+}
+
+// Manifest is stored as manifest.json in every archive and lists the
+// snapshots with their checksums. Skipped lists databases that did not
+// exist yet when the backup ran.
+type Manifest struct {
+	CreatedAt time.Time     `json:"created_at"`
+	Label     string        `json:"label,omitempty"`
+	Files     []ArchiveFile `json:"files"`
+	Skipped   []string      `json:"skipped,omitempty"`
+}
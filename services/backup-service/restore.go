@@ -0,0 +1,155 @@
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+)
+
+// Restore verifies an archive and copies its snapshots back over the live
+// databases. only limits the restore to the named databases. The current
+// databases are backed up first with the "pre-restore" label, so a restore
+// can itself be undone.
+func (b *Backupper) Restore(ctx context.Context, archivePath string, only []string) (*Manifest, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	tmpDir, err := os.MkdirTemp("", "cafe-restore-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	manifest, err := extractArchive(archivePath, tmpDir)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := map[string]string{}
+	for _, db := range b.databases {
+		paths[db.Name] = db.Path
+	}
+
+	var files []ArchiveFile
+	for _, file := range manifest.Files {
+		if len(only) == 0 || shared.Contains(only, file.Database) {
+			files = append(files, file)
+		}
+	}
+	for _, name := range only {
+		found := false
+		for _, file := range files {
+			found = found || file.Database == name
+		}
+		if !found {
+			return nil, fmt.Errorf("database %s is not in the archive", name)
+		}
+	}
+	for _, file := range files {
+		if _, ok := paths[file.Database]; !ok {
+			return nil, fmt.Errorf("database %s is not configured in BACKUP_DATABASES", file.Database)
+		}
+	}
+
+	if _, err := b.run(ctx, "pre-restore"); err != nil && !errors.Is(err, errNoDatabases) {
+		return nil, fmt.Errorf("failed to back up current databases: %w", err)
+	}
+
+	for _, file := range files {
+		dest := paths[file.Database]
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return nil, err
+		}
+		if err := copyDatabase(filepath.Join(tmpDir, file.File), dest); err != nil {
+			return nil, fmt.Errorf("failed to restore %s: %w", file.Database, err)
+		}
+		shared.Logger(ctx).Info("database restored", "database", file.Database, "archive", filepath.Base(archivePath))
+	}
+
+	manifest.Files = files
+	return manifest, nil
+}
+
+// extractArchive checks the archive against its .sha256 sidecar when there
+// is one, extracts it into dir and checks every snapshot against the
+// manifest
+func extractArchive(path, dir string) (*Manifest, error) {
+	if sidecar, err := os.ReadFile(path + checksumExt); err == nil {
+		fields := strings.Fields(string(sidecar))
+		sum, _, err := fileChecksum(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) == 0 || fields[0] != sum {
+			return nil, errors.New("archive checksum does not match " + filepath.Base(path) + checksumExt)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("not a backup archive: %w", err)
+	}
+	tr := tar.NewReader(gz)
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		// Archives only hold flat files; anything else is not ours
+		if header.Typeflag != tar.TypeReg || filepath.Base(header.Name) != header.Name {
+			return nil, fmt.Errorf("unexpected entry %q in archive", header.Name)
+		}
+
+		out, err := os.Create(filepath.Join(dir, header.Name))
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(out, tr)
+		out.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, manifestFile))
+	if err != nil {
+		return nil, errors.New("archive has no manifest")
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	for _, file := range manifest.Files {
+		if filepath.Base(file.File) != file.File {
+			return nil, fmt.Errorf("unexpected file %q in manifest", file.File)
+		}
+		sum, _, err := fileChecksum(filepath.Join(dir, file.File))
+		if err != nil {
+			return nil, fmt.Errorf("snapshot %s is missing", file.File)
+		}
+		if sum != file.SHA256 {
+			return nil, fmt.Errorf("checksum of %s does not match the manifest", file.File)
+		}
+	}
+	return &manifest, nil
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Board keeps the orders of the day and pushes the view of a branch to its
+// screens whenever one of its orders changes. Finished orders stay on the
+// board, out of sight, so a late event cannot bring them back.
+type Board struct {
+	mu      sync.Mutex
+	tickets map[int]*boardEntry
+	screens map[chan View]*screen
+}
+
+// screen is a connected page and the view it was sent last
+type screen struct {
+	branchID int
+	last     View
+}
+
+type boardEntry struct {
+	Ticket
+	seen time.Time // when the board last heard of the order
+}
+
+// NewBoard creates an empty board
+func NewBoard() *Board {
+	return &Board{
+		tickets: map[int]*boardEntry{},
+		screens: map[chan View]*screen{},
+	}
+}
+
+// Apply records a change of an order and reports whether the board changed.
+// Changes older than what the board knows are ignored.
+func (b *Board) Apply(ticket Ticket) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.apply(ticket, time.Now()) {
+		return false
+	}
+	b.notify(map[int]bool{ticket.BranchID: true})
+	return true
+}
+
+// Replace brings the board in line with the unfinished orders of the day as
+// order-service listed them at since. Orders missing from the list have been
+// finished, or are from an earlier day, unless the board heard of them after
+// the list was taken.
+func (b *Board) Replace(tickets []Ticket, since time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	changed := map[int]bool{}
+	listed := map[int]bool{}
+	for _, ticket := range tickets {
+		listed[ticket.OrderID] = true
+		if b.apply(ticket, now) {
+			changed[ticket.BranchID] = true
+		}
+	}
+	for id, entry := range b.tickets {
+		if !listed[id] && entry.seen.Before(since) {
+			delete(b.tickets, id)
+			changed[entry.BranchID] = true
+		}
+	}
+	b.notify(changed)
+}
+
+// Subscribe returns a channel receiving the view of a branch, starting with
+// the current one and then whenever it changes, and a function to stop. A
+// slow screen skips to the latest view instead of holding the board up.
+func (b *Board) Subscribe(branchID int) (<-chan View, func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	views := make(chan View, 1)
+	view := b.view(branchID)
+	views <- view
+	b.screens[views] = &screen{branchID: branchID, last: view}
+	screensConnected.Set(float64(len(b.screens)))
+
+	return views, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.screens, views)
+		screensConnected.Set(float64(len(b.screens)))
+	}
+}
+
+// apply records a change of an order; b.mu must be held
+func (b *Board) apply(ticket Ticket, now time.Time) bool {
+	rank, ok := statusRanks[ticket.Status]
+	if !ok {
+		return false
+	}
+	entry, known := b.tickets[ticket.OrderID]
+	if known {
+		entry.seen = now
+		if rank <= statusRanks[entry.Status] {
+			return false
+		}
+	}
+	b.tickets[ticket.OrderID] = &boardEntry{Ticket: ticket, seen: now}
+	return true
+}
+
+// view builds the view of a branch; b.mu must be held
+func (b *Board) view(branchID int) View {
+	var preparing, ready []Ticket
+	for _, entry := range b.tickets {
+		if entry.BranchID != branchID {
+			continue
+		}
+		switch entry.Status {
+		case StatusPreparing:
+			preparing = append(preparing, entry.Ticket)
+		case StatusReady:
+			ready = append(ready, entry.Ticket)
+		}
+	}
+	return View{
+		BranchID:  branchID,
+		Preparing: queueNumbers(preparing),
+		Ready:     queueNumbers(ready),
+	}
+}
+
+// notify sends the new view to the screens of the changed branches whose
+// view differs from the one they have; b.mu must be held
+func (b *Board) notify(branches map[int]bool) {
+	for views, screen := range b.screens {
+		if !branches[screen.branchID] {
+			continue
+		}
+		view := b.view(screen.branchID)
+		if reflect.DeepEqual(view, screen.last) {
+			continue
+		}
+		screen.last = view
+
+		// Drop a view the screen has not taken yet; only the board sends,
+		// so the buffer is free afterwards
+		select {
+		case <-views:
+		default:
+		}
+		views <- view
+	}
+}
+
+// queueNumbers lists the queue numbers of tickets, oldest change first
+func queueNumbers(tickets []Ticket) []int {
+	sort.Slice(tickets, func(i, j int) bool {
+		if !tickets[i].UpdatedAt.Equal(tickets[j].UpdatedAt) {
+			return tickets[i].UpdatedAt.Before(tickets[j].UpdatedAt)
+		}
+		return tickets[i].QueueNumber < tickets[j].QueueNumber
+	})
+	numbers := make([]int, len(tickets))
+	for i, ticket := range tickets {
+		numbers[i] = ticket.QueueNumber
+	}
+	return numbers
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func ticket(orderID, queueNumber int, status string) Ticket {
+	return Ticket{
+		OrderID:     orderID,
+		QueueNumber: queueNumber,
+		BranchID:    1,
+		Status:      status,
+		UpdatedAt:   time.Date(2025, 3, 10, 12, 0, orderID, 0, time.UTC),
+	}
+}
+
+func checkView(t *testing.T, board *Board, preparing, ready []int) {
+	t.Helper()
+	board.mu.Lock()
+	view := board.view(1)
+	board.mu.Unlock()
+	if fmt.Sprint(view.Preparing) != fmt.Sprint(preparing) || fmt.Sprint(view.Ready) != fmt.Sprint(ready) {
+		t.Errorf("view = preparing %v ready %v, want preparing %v ready %v",
+			view.Preparing, view.Ready, preparing, ready)
+	}
+}
+
+func TestBoardApply(t *testing.T) {
+	tests := []struct {
+		name      string
+		changes   []Ticket
+		applied   []bool
+		preparing []int
+		ready     []int
+	}{
+		{
+			name:    "moves forward",
+			changes: []Ticket{ticket(1, 1, StatusPending), ticket(1, 1, StatusPreparing), ticket(1, 1, StatusReady)},
+			applied: []bool{true, true, true},
+			ready:   []int{1},
+		},
+		{
+			name:    "late change ignored",
+			changes: []Ticket{ticket(1, 1, StatusReady), ticket(1, 1, StatusPreparing)},
+			applied: []bool{true, false},
+			ready:   []int{1},
+		},
+		{
+			name:      "same change twice",
+			changes:   []Ticket{ticket(1, 1, StatusPreparing), ticket(1, 1, StatusPreparing)},
+			applied:   []bool{true, false},
+			preparing: []int{1},
+		},
+		{
+			name:    "finished order leaves the board",
+			changes: []Ticket{ticket(1, 1, StatusReady), ticket(1, 1, StatusCompleted)},
+			applied: []bool{true, true},
+		},
+		{
+			name:    "finished order stays gone after a late change",
+			changes: []Ticket{ticket(1, 1, StatusCancelled), ticket(1, 1, StatusReady)},
+			applied: []bool{true, false},
+		},
+		{
+			name:    "unknown status ignored",
+			changes: []Ticket{ticket(1, 1, "lost")},
+			applied: []bool{false},
+		},
+		{
+			name:      "oldest change first",
+			changes:   []Ticket{ticket(3, 7, StatusPreparing), ticket(2, 9, StatusPreparing), ticket(1, 8, StatusReady)},
+			applied:   []bool{true, true, true},
+			preparing: []int{9, 7},
+			ready:     []int{8},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			board := NewBoard()
+			for i, change := range tt.changes {
+				if got := board.Apply(change); got != tt.applied[i] {
+					t.Errorf("Apply(%s) = %v, want %v", change.Status, got, tt.applied[i])
+				}
+			}
+			checkView(t, board, tt.preparing, tt.ready)
+		})
+	}
+}
+
+func TestBoardReplace(t *testing.T) {
+	board := NewBoard()
+	board.Apply(ticket(1, 1, StatusPreparing))
+	board.Apply(ticket(2, 2, StatusPreparing))
+	board.Apply(ticket(3, 3, StatusReady))
+
+	// The display reconnects: while it was away order 1 was picked up,
+	// order 2 became ready and order 4 came in. Order 5 arrives as an event
+	// after the list was taken.
+	time.Sleep(time.Millisecond)
+	since := time.Now()
+	time.Sleep(time.Millisecond)
+	board.Apply(ticket(5, 5, StatusPreparing))
+
+	board.Replace([]Ticket{
+		ticket(2, 2, StatusReady),
+		ticket(3, 3, StatusReady),
+		ticket(4, 4, StatusPreparing),
+	}, since)
+	checkView(t, board, []int{4, 5}, []int{2, 3})
+
+	// The next list drops order 3, picked up in the meantime, and does not
+	// move order 2 back
+	board.Replace([]Ticket{ticket(2, 2, StatusPreparing), ticket(4, 4, StatusPreparing), ticket(5, 5, StatusPreparing)}, time.Now())
+	checkView(t, board, []int{4, 5}, []int{2})
+}
+
+func TestBoardSubscribe(t *testing.T) {
+	board := NewBoard()
+	views, stop := board.Subscribe(1)
+	defer stop()
+
+	if view := <-views; len(view.Preparing) != 0 || len(view.Ready) != 0 {
+		t.Fatalf("first view = %+v, want an empty board", view)
+	}
+
+	board.Apply(ticket(1, 1, StatusPreparing))
+	board.Apply(ticket(1, 1, StatusReady))
+	// A screen that has not kept up gets the latest view only
+	if view := <-views; !reflect.DeepEqual(view.Ready, []int{1}) {
+		t.Errorf("view = %+v, want order 1 ready", view)
+	}
+
+	other := ticket(2, 2, StatusPreparing)
+	other.BranchID = 2
+	board.Apply(other)
+	select {
+	case view := <-views:
+		t.Errorf("got %+v for a change in another branch", view)
+	default:
+	}
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+)
+
+// defaultBranchID is shown when the page does not name a branch
+const defaultBranchID = 1
+
+var (
+	screensConnected = shared.NewGauge("cafe_display_screens",
+		"Counter screens connected to the live stream.")
+	boardSyncs = shared.NewCounter("cafe_display_syncs_total",
+		"Reloads of the board from order-service, by result (ok or failed).", "result")
+)
+
+// Handler serves the counter page and its live stream
+type Handler struct {
+	board           *Board
+	client          *shared.HTTPClient
+	orderServiceURL string
+	infoServiceURL  string
+	keepAlive       time.Duration
+}
+
+// NewHandler creates a new handler
+func NewHandler(board *Board, orderServiceURL, infoServiceURL string, keepAlive time.Duration) *Handler {
+	return &Handler{
+		board:           board,
+		client:          shared.NewHTTPClient(),
+		orderServiceURL: orderServiceURL,
+		infoServiceURL:  infoServiceURL,
+		keepAlive:       keepAlive,
+	}
+}
+
+// HandlePage serves the counter page of a branch, e.g.
+// /?branch_id=2&lang=en. The page follows the board through /stream.
+func (h *Handler) HandlePage(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	branchID := branchFromQuery(r)
+	locale := shared.NormalizeLocale(r.URL.Query().Get("lang"))
+	page := pageData{
+		Lang:      locale,
+		Title:     shared.T(locale, "display.title"),
+		Branch:    h.branchName(r.Context(), locale, branchID),
+		Preparing: shared.T(locale, "display.preparing"),
+		Ready:     shared.T(locale, "display.ready"),
+		Empty:     shared.T(locale, "display.empty"),
+		Offline:   shared.T(locale, "display.offline"),
+		StreamURL: fmt.Sprintf("stream?branch_id=%d", branchID),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := pageTemplate.Execute(w, page); err != nil {
+		shared.Logger(r.Context()).Error("failed to render page", "error", err)
+	}
+}
+
+// HandleStream sends the view of a branch as server-sent events: a "board"
+// event with the current view right away and another one on every change.
+// Comments keep idle connections open through proxies.
+func (h *Handler) HandleStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	views, stop := h.board.Subscribe(branchFromQuery(r))
+	defer stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // nginx would hold events back
+	fmt.Fprint(w, "retry: 3000\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(h.keepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case view := <-views:
+			data, _ := json.Marshal(view)
+			if _, err := fmt.Fprintf(w, "event: board\ndata: %s\n\n", data); err != nil {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// HandleEvent puts the order changes order-service announces on the board.
+// Applying a change twice does no harm.
+func (h *Handler) HandleEvent(ctx context.Context, event shared.Event) error {
+	if event.Service != "order-service" || !strings.HasPrefix(event.Type, "order.") {
+		return nil
+	}
+	ticket, ok := ticketFromData(event.Data)
+	if !ok {
+		shared.Logger(ctx).Warn("event without an order")
+		return nil
+	}
+	if h.board.Apply(ticket) {
+		shared.Logger(ctx).Debug("board updated", "order_id", ticket.OrderID, "status", ticket.Status)
+	}
+	return nil
+}
+
+// Sync reloads the unfinished orders of the day from order-service, making
+// up for events the service missed while it was down
+func (h *Handler) Sync(ctx context.Context) error {
+	since := time.Now()
+	today := since.Format("2006-01-02")
+	resp, err := h.client.WithContext(ctx).Post(h.orderServiceURL, shared.Request{
+		Action: "list",
+		Payload: map[string]interface{}{
+			"status": "active",
+			"from":   today,
+			"to":     today,
+		},
+	})
+	if err != nil {
+		boardSyncs.Inc("failed")
+		return err
+	}
+	if !resp.Success {
+		boardSyncs.Inc("failed")
+		if resp.Error != nil {
+			return fmt.Errorf("%s: %s", resp.Error.Code, resp.Error.Message)
+		}
+		return fmt.Errorf("list orders failed")
+	}
+
+	data, ok := resp.Data.(map[string]interface{})
+	if !ok {
+		boardSyncs.Inc("failed")
+		return fmt.Errorf("list orders returned no orders")
+	}
+	orders, _ := data["orders"].([]interface{})
+	tickets := make([]Ticket, 0, len(orders))
+	for _, item := range orders {
+		order, _ := item.(map[string]interface{})
+		if ticket, ok := ticketFromData(order); ok {
+			tickets = append(tickets, ticket)
+		}
+	}
+	h.board.Replace(tickets, since)
+	boardSyncs.Inc("ok")
+	return nil
+}
+
+// branchName returns the name of a branch as info-service knows it
+func (h *Handler) branchName(ctx context.Context, locale string, branchID int) string {
+	resp, err := h.client.WithContext(ctx).Post(h.infoServiceURL, shared.Request{Action: "list"})
+	if err == nil && resp.Success {
+		data, _ := resp.Data.(map[string]interface{})
+		branches, _ := data["branches"].([]interface{})
+		for _, item := range branches {
+			branch, _ := item.(map[string]interface{})
+			if id, _ := branch["id"].(float64); int(id) == branchID {
+				if name, _ := branch["name"].(string); name != "" {
+					return name
+				}
+			}
+		}
+	}
+	return shared.T(locale, "display.branch", branchID)
+}
+
+// Helper functions
+
+// branchFromQuery reads the branch_id query parameter
+func branchFromQuery(r *http.Request) int {
+	if id, err := strconv.Atoi(r.URL.Query().Get("branch_id")); err == nil && id > 0 {
+		return id
+	}
+	return defaultBranchID
+}
+
+// ticketFromData reads an order as order-service describes it in events and
+// in its list action
+func ticketFromData(data map[string]interface{}) (Ticket, bool) {
+	id, ok := data["id"].(float64)
+	if !ok {
+		return Ticket{}, false
+	}
+	ticket := Ticket{OrderID: int(id), BranchID: defaultBranchID}
+	if v, ok := data["queue_number"].(float64); ok {
+		ticket.QueueNumber = int(v)
+	}
+	if v, ok := data["branch_id"].(float64); ok && v > 0 {
+		ticket.BranchID = int(v)
+	}
+	ticket.Status, _ = data["status"].(string)
+	if v, ok := data["updated_at"].(string); ok {
+		ticket.UpdatedAt, _ = time.Parse(time.RFC3339Nano, v)
+	}
+	return ticket, true
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	// Load environment variables
+	godotenv.Load()
+	shared.ConfigureLogger("display-service")
+	shared.ConfigureTracing("display-service")
+
+	port := os.Getenv("DISPLAY_SERVICE_PORT")
+	if port == "" {
+		port = "8090"
+	}
+
+	syncInterval := durationSetting("DISPLAY_SYNC_INTERVAL", time.Minute)
+	keepAlive := durationSetting("DISPLAY_KEEPALIVE", 15*time.Second)
+
+	orderServiceURL := shared.ServiceURL("order-service")
+	infoServiceURL := shared.ServiceURL("info-service")
+
+	// Initialize handler
+	handler := NewHandler(NewBoard(), orderServiceURL, infoServiceURL, keepAlive)
+
+	// The board follows order events and is reloaded now and then in case
+	// some were missed
+	go syncBoard(handler, syncInterval)
+
+	// Putting a change on the board twice does no harm, so handled events
+	// are only remembered in memory
+	dedup := shared.NewMemoryDeduper(10000)
+
+	// Setup routes
+	http.HandleFunc("/", handler.HandlePage)
+	http.HandleFunc("/stream", handler.HandleStream)
+	http.HandleFunc("/events", shared.HandleEvents(dedup, handler.HandleEvent))
+	shared.NewHealth("display-service").
+		DependsOn("order-service", orderServiceURL).
+		DependsOn("info-service", infoServiceURL).Register()
+	http.HandleFunc("/metrics", shared.MetricsHandler)
+
+	// Start server
+	addr := fmt.Sprintf(":%s", port)
+	shared.Logger(context.Background()).Info("service starting", "addr", addr)
+	if err := http.ListenAndServe(addr, nil); err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+}
+
+// syncBoard reloads the board from order-service every interval
+func syncBoard(handler *Handler, interval time.Duration) {
+	ctx := shared.WithLogFields(context.Background(), "job", "sync_board")
+	for {
+		if err := handler.Sync(ctx); err != nil {
+			shared.Logger(ctx).Error("failed to load orders", "error", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// durationSetting reads a duration such as "30s" from env
+func durationSetting(env string, fallback time.Duration) time.Duration {
+	value := os.Getenv(env)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < time.Second {
+		log.Fatalf("Invalid %s: %q (e.g. 30s, minimum 1s)", env, value)
+	}
+	return d
+}
@@ -0,0 +1,40 @@
+package main
+
+import "time"
+
+// Order statuses as order-service names them
+const (
+	StatusPending   = "pending"
+	StatusPreparing = "preparing"
+	StatusReady     = "ready"
+	StatusCompleted = "completed"
+	StatusCancelled = "cancelled"
+)
+
+// statusRanks orders the statuses an order moves through. Orders never move
+// back, so a change ranked below what the board already knows arrived late
+// and is ignored. Completed and cancelled orders share the last rank.
+var statusRanks = map[string]int{
+	StatusPending:   0,
+	StatusPreparing: 1,
+	StatusReady:     2,
+	StatusCompleted: 3,
+	StatusCancelled: 3,
+}
+
+// Ticket is an order as the counter display knows it
+type Ticket struct {
+	OrderID     int       `json:"order_id"`
+	QueueNumber int       `json:"queue_number"` // restarts at 1 every day per branch
+	BranchID    int       `json:"branch_id"`
+	Status      string    `json:"status"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// View is what a screen of one branch shows: the queue numbers being
+// prepared and those ready for pickup, oldest change first
+type View struct {
+	BranchID  int   `json:"branch_id"`
+	Preparing []int `json:"preparing"`
+	Ready     []int `json:"ready"`
+}
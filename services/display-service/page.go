@@ -0,0 +1,95 @@
+package main
+
+import "html/template"
+
+// pageData fills the counter page in the language of the screen
+type pageData struct {
+	Lang      string
+	Title     string
+	Branch    string
+	Preparing string
+	Ready     string
+	Empty     string
+	Offline   string
+	StreamURL string
+}
+
+// pageTemplate is the counter page. It needs nothing but the browser:
+// EventSource reconnects on its own and every event carries the whole view.
+// Numbers that just became ready blink for a while.
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}} — {{.Branch}}</title>
+<style>
+  * { box-sizing: border-box; }
+  body { margin: 0; min-height: 100vh; display: flex; flex-direction: column;
+         background: #1b1712; color: #f5efe6; font-family: system-ui, sans-serif; }
+  header { display: flex; justify-content: space-between; align-items: baseline;
+           padding: 1.5vh 3vw; background: #2a231b; }
+  header h1 { margin: 0; font-size: 4vh; }
+  header span { font-size: 3vh; opacity: .8; }
+  main { flex: 1; display: grid; grid-template-columns: 1fr 1fr; gap: 2vw; padding: 2vh 3vw; }
+  section h2 { margin: 0 0 2vh; padding-bottom: 1vh; font-size: 4.5vh; border-bottom: .4vh solid currentColor; }
+  #preparing { color: #f2c14e; }
+  #ready { color: #7bd389; }
+  ul { list-style: none; margin: 0; padding: 0; display: flex; flex-wrap: wrap; gap: 1.5vh 2vw; }
+  li { min-width: 12vw; padding: 1vh 1.5vw; border-radius: 1vh; text-align: center;
+       font-size: 9vh; font-weight: 700; background: rgba(255, 255, 255, .07); }
+  #ready li { font-size: 12vh; }
+  li.empty { min-width: 0; background: none; font-size: 4vh; font-weight: 400; opacity: .5; }
+  li.new { animation: blink 1s ease-in-out 8; }
+  @keyframes blink { 50% { background: #7bd389; color: #1b1712; } }
+  #offline { display: none; padding: 1vh 3vw; background: #b3412f; font-size: 2.5vh; }
+  body.offline #offline { display: block; }
+</style>
+</head>
+<body class="offline">
+<header><h1>{{.Title}}</h1><span>{{.Branch}}</span></header>
+<div id="offline">{{.Offline}}</div>
+<main>
+  <section id="preparing"><h2>{{.Preparing}}</h2><ul></ul></section>
+  <section id="ready"><h2>{{.Ready}}</h2><ul></ul></section>
+</main>
+<script>
+  var emptyText = {{.Empty}};
+  var shown = null;
+
+  function render(id, numbers, highlight) {
+    var list = document.querySelector("#" + id + " ul");
+    list.innerHTML = "";
+    if (numbers.length === 0) {
+      var empty = document.createElement("li");
+      empty.className = "empty";
+      empty.textContent = emptyText;
+      list.appendChild(empty);
+      return;
+    }
+    numbers.forEach(function (number) {
+      var item = document.createElement("li");
+      item.textContent = "#" + number;
+      if (highlight && highlight.indexOf(number) < 0) {
+        item.className = "new";
+      }
+      list.appendChild(item);
+    });
+  }
+
+  var stream = new EventSource({{.StreamURL}});
+  stream.addEventListener("board", function (message) {
+    var view = JSON.parse(message.data);
+    var ready = view.ready || [];
+    render("preparing", view.preparing || [], null);
+    render("ready", ready, shown);
+    shown = ready;
+    document.body.classList.remove("offline");
+  });
+  stream.onerror = function () {
+    document.body.classList.add("offline");
+  };
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+// earthRadiusKm is the mean Earth radius used by the haversine formula
+const earthRadiusKm = 6371.0
+
+// haversineKm returns the great-circle distance between two points in km
+func haversineKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+// isOpenAt reports whether a branch with the given "HH:MM" hours is open at t.
+// Closing hours before the opening hour are treated as past midnight.
+func isOpenAt(openingHour, closingHour string, t time.Time) bool {
+	open, err := time.Parse("15:04", openingHour)
+	if err != nil {
+		return false
+	}
+	closing, err := time.Parse("15:04", closingHour)
+	if err != nil {
+		return false
+	}
+
+	now := t.Hour()*60 + t.Minute()
+	from := open.Hour()*60 + open.Minute()
+	to := closing.Hour()*60 + closing.Minute()
+
+	if from == to {
+		return true // open 24 hours
+	}
+	if from < to {
+		return now >= from && now < to
+	}
+	return now >= from || now < to
+}
+
+// validCoordinates checks latitude/longitude ranges
+func validCoordinates(lat, lon float64) bool {
+	return lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180
+}
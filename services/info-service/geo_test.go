@@ -0,0 +1,87 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestHaversineKm(t *testing.T) {
+	tests := []struct {
+		name                   string
+		lat1, lon1, lat2, lon2 float64
+		want                   float64
+	}{
+		{"same point", -6.1754, 106.8272, -6.1754, 106.8272, 0},
+		{"one degree of latitude", 0, 0, 1, 0, 111.19},
+		{"quarter of the equator", 0, 0, 0, 90, 10007.54},
+		{"antipodes", 0, 0, 0, 180, 20015.09},
+		{"across the date line", 0, 179.5, 0, -179.5, 111.19},
+		{"Monas to Gedung Sate", -6.1754, 106.8272, -6.9025, 107.6187, 119.09},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := haversineKm(tt.lat1, tt.lon1, tt.lat2, tt.lon2)
+			if math.Abs(got-tt.want) > 0.01 {
+				t.Errorf("haversineKm() = %.2f, want %.2f", got, tt.want)
+			}
+			if back := haversineKm(tt.lat2, tt.lon2, tt.lat1, tt.lon1); math.Abs(back-got) > 1e-9 {
+				t.Errorf("haversineKm() is %.6f one way and %.6f back", got, back)
+			}
+		})
+	}
+}
+
+func TestHaversineOrdering(t *testing.T) {
+	// A customer at Monas, Jakarta
+	const lat, lon = -6.1754, 106.8272
+	branches := []struct {
+		name     string
+		lat, lon float64
+	}{
+		{"Surabaya", -7.2575, 112.7521},
+		{"Bogor", -6.5971, 106.8060},
+		{"Bandung", -6.9175, 107.6191},
+		{"Kemang", -6.2607, 106.8137},
+		{"Tangerang", -6.1783, 106.6319},
+	}
+	want := []string{"Kemang", "Tangerang", "Bogor", "Bandung", "Surabaya"}
+
+	sort.Slice(branches, func(i, j int) bool {
+		return haversineKm(lat, lon, branches[i].lat, branches[i].lon) <
+			haversineKm(lat, lon, branches[j].lat, branches[j].lon)
+	})
+	for i, branch := range branches {
+		if branch.name != want[i] {
+			t.Fatalf("branch %d is %s, want %s (order %v)", i, branch.name, want[i], want)
+		}
+	}
+}
+
+func TestIsOpenAt(t *testing.T) {
+	clock := func(value string) time.Time {
+		at, _ := time.Parse("15:04", value)
+		return at
+	}
+	tests := []struct {
+		opening, closing, at string
+		want                 bool
+	}{
+		{"08:00", "22:00", "08:00", true},
+		{"08:00", "22:00", "21:59", true},
+		{"08:00", "22:00", "22:00", false},
+		{"08:00", "22:00", "07:59", false},
+		{"18:00", "02:00", "23:30", true},
+		{"18:00", "02:00", "01:59", true},
+		{"18:00", "02:00", "02:00", false},
+		{"18:00", "02:00", "12:00", false},
+		{"00:00", "00:00", "03:00", true},
+		{"8 pagi", "22:00", "12:00", false},
+	}
+	for _, tt := range tests {
+		if got := isOpenAt(tt.opening, tt.closing, clock(tt.at)); got != tt.want {
+			t.Errorf("isOpenAt(%s, %s, %s) = %v, want %v", tt.opening, tt.closing, tt.at, got, tt.want)
+		}
+	}
+}
@@ -1,20 +1,32 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
 	"net/http"
+	"sort"
+	"time"
 
 	"github.com/alrescha79-cmd/bot-cafe/shared"
 )
 
 // Handler handles HTTP requests
 type Handler struct {
-	repo *Repository
+	repo   *Repository
+	events *shared.Outbox
+}
+
+// Events published after a successful action. A preference only matters to
+// its own user, so it publishes none.
+var actionEvents = map[string]string{
+	"update": "info.updated",
+	"create": "branch.created",
+	"delete": "branch.deleted",
 }
 
 // NewHandler creates a new handler
-func NewHandler(repo *Repository) *Handler {
-	return &Handler{repo: repo}
+func NewHandler(repo *Repository, events *shared.Outbox) *Handler {
+	return &Handler{repo: repo, events: events}
 }
 
 // HandleRequest handles all incoming requests
@@ -30,31 +42,67 @@ func (h *Handler) HandleRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Queries are traced as part of this request
+	h = &Handler{repo: h.repo.WithContext(r.Context()), events: h.events}
+
+	// An action changing data runs in a transaction that also stores the
+	// event announcing the change, so the event is kept exactly when the
+	// change is
+	eventType, announced := actionEvents[req.Action]
+	var tx *sql.Tx
+	if announced {
+		var err error
+		if tx, err = h.repo.Begin(); err != nil {
+			sendErrorResponse(w, shared.NewDatabaseError(err))
+			return
+		}
+		defer tx.Rollback()
+		h = &Handler{repo: h.repo.WithTx(tx), events: h.events}
+	}
+
 	var response *shared.Response
 
 	switch req.Action {
 	case "read":
-		response = h.getCafeInfo()
+		response = h.getCafeInfo(req.Payload)
 	case "update":
 		response = h.updateCafeInfo(req.Payload)
+	case "list":
+		response = h.listBranches()
+	case "nearest":
+		response = h.nearestBranches(req.Payload)
+	case "create":
+		response = h.createBranch(req.Payload)
+	case "delete":
+		response = h.deleteBranch(req.Payload)
+	case "get_preference":
+		response = h.getPreference(req.Payload)
+	case "set_preference":
+		response = h.setPreference(req.Payload)
 	default:
 		sendErrorResponse(w, shared.NewError(shared.ErrCodeInvalidInput, "Unknown action", nil))
 		return
 	}
 
+	if tx != nil && response.Success {
+		if err := h.commit(tx, eventType, eventData(req.Payload, response)); err != nil {
+			response = errorResponse(err)
+		}
+	}
+
 	sendResponse(w, response)
 }
 
-// getCafeInfo gets café information
-
-func (h *Handler) getCafeInfo() *shared.Response {
-	info, err := h.repo.GetCafeInfo()
+// getCafeInfo gets café information of a branch
+func (h *Handler) getCafeInfo(payload interface{}) *shared.Response {
+	info, err := h.repo.GetCafeInfo(branchIDFromPayload(payload))
 	if err != nil {
 		return errorResponse(err.(*shared.AppError))
 	}
 
 	return successResponse(map[string]interface{}{
-		"info": info,
+		"info":    info,
+		"is_open": isOpenAt(info.OpeningHour, info.ClosingHour, time.Now()),
 	})
 }
 
@@ -66,7 +114,7 @@ func (h *Handler) updateCafeInfo(payload interface{}) *shared.Response {
 	}
 
 	// Get existing info
-	info, err := h.repo.GetCafeInfo()
+	info, err := h.repo.GetCafeInfo(branchIDFromPayload(payload))
 	if err != nil {
 		return errorResponse(err.(*shared.AppError))
 	}
@@ -93,6 +141,13 @@ func (h *Handler) updateCafeInfo(payload interface{}) *shared.Response {
 	if description, ok := data["description"].(string); ok {
 		info.Description = shared.SanitizeInput(description)
 	}
+	if _, ok := data["latitude"]; ok {
+		lat, lon, err := coordinatesFromPayload(data)
+		if err != nil {
+			return errorResponse(err)
+		}
+		info.Latitude, info.Longitude = lat, lon
+	}
 
 	if err := h.repo.UpdateCafeInfo(info); err != nil {
 		return errorResponse(err.(*shared.AppError))
@@ -103,7 +158,269 @@ func (h *Handler) updateCafeInfo(payload interface{}) *shared.Response {
 	})
 }
 
+// listBranches lists all branches
+func (h *Handler) listBranches() *shared.Response {
+	branches, err := h.repo.ListBranches()
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"branches": branches,
+	})
+}
+
+// nearestBranches lists branches with coordinates sorted by distance from
+// the given point
+func (h *Handler) nearestBranches(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	lat, lon, appErr := coordinatesFromPayload(data)
+	if appErr != nil {
+		return errorResponse(appErr)
+	}
+	if lat == nil {
+		return errorResponse(shared.NewInvalidInputError("latitude dan longitude diperlukan"))
+	}
+
+	limit := 0
+	if l, ok := data["limit"].(float64); ok && l > 0 {
+		limit = int(l)
+	}
+
+	branches, err := h.repo.ListBranches()
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	now := time.Now()
+	nearby := []NearbyBranch{}
+	for _, branch := range branches {
+		if !branch.HasLocation() {
+			continue
+		}
+		nearby = append(nearby, NearbyBranch{
+			CafeInfo:   branch,
+			DistanceKm: haversineKm(*lat, *lon, *branch.Latitude, *branch.Longitude),
+			IsOpen:     isOpenAt(branch.OpeningHour, branch.ClosingHour, now),
+		})
+	}
+
+	sort.Slice(nearby, func(i, j int) bool {
+		return nearby[i].DistanceKm < nearby[j].DistanceKm
+	})
+	if limit > 0 && len(nearby) > limit {
+		nearby = nearby[:limit]
+	}
+
+	return successResponse(map[string]interface{}{
+		"branches": nearby,
+	})
+}
+
+// createBranch creates a new branch
+func (h *Handler) createBranch(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	name, _ := data["name"].(string)
+	address, _ := data["address"].(string)
+	phone, _ := data["phone"].(string)
+	email, _ := data["email"].(string)
+	openingHour, _ := data["opening_hour"].(string)
+	closingHour, _ := data["closing_hour"].(string)
+	description, _ := data["description"].(string)
+
+	// Validate inputs
+	if err := shared.ValidateNotEmpty(name, "Nama cabang"); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+	if err := shared.ValidateNotEmpty(address, "Alamat"); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+	if err := shared.ValidateNotEmpty(phone, "Telepon"); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+	if openingHour == "" {
+		openingHour = "08:00"
+	}
+	if closingHour == "" {
+		closingHour = "22:00"
+	}
+	if _, err := time.Parse("15:04", openingHour); err != nil {
+		return errorResponse(shared.NewKeyedInvalidInputError("error.hours_opening"))
+	}
+	if _, err := time.Parse("15:04", closingHour); err != nil {
+		return errorResponse(shared.NewKeyedInvalidInputError("error.hours_closing"))
+	}
+
+	lat, lon, appErr := coordinatesFromPayload(data)
+	if appErr != nil {
+		return errorResponse(appErr)
+	}
+
+	info := &CafeInfo{
+		Latitude:    lat,
+		Longitude:   lon,
+		Name:        shared.SanitizeInput(name),
+		Address:     shared.SanitizeInput(address),
+		Phone:       shared.SanitizeInput(phone),
+		Email:       shared.SanitizeInput(email),
+		OpeningHour: openingHour,
+		ClosingHour: closingHour,
+		Description: shared.SanitizeInput(description),
+	}
+
+	result, err := h.repo.CreateBranch(info)
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"info": result,
+	})
+}
+
+// deleteBranch deletes a branch
+func (h *Handler) deleteBranch(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	id, ok := data["branch_id"].(float64)
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("branch_id diperlukan"))
+	}
+
+	if err := h.repo.DeleteBranch(int(id)); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"message": "Cabang berhasil dihapus",
+	})
+}
+
+// getPreference gets the stored preference of a user
+func (h *Handler) getPreference(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	telegramID, ok := data["telegram_id"].(string)
+	if !ok || telegramID == "" {
+		return errorResponse(shared.NewInvalidInputError("telegram_id is required"))
+	}
+
+	pref, err := h.repo.GetUserPreference(telegramID)
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"preference": pref,
+	})
+}
+
+// setPreference stores the branch and/or language selected by a user
+func (h *Handler) setPreference(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	telegramID, ok := data["telegram_id"].(string)
+	if !ok || telegramID == "" {
+		return errorResponse(shared.NewInvalidInputError("telegram_id is required"))
+	}
+
+	_, hasBranch := data["branch_id"]
+	language, hasLanguage := data["language"].(string)
+	if !hasBranch && !hasLanguage {
+		return errorResponse(shared.NewInvalidInputError("branch_id or language is required"))
+	}
+	if hasLanguage && !shared.IsSupportedLocale(language) {
+		return errorResponse(shared.NewKeyedInvalidInputError("error.language_unsupported"))
+	}
+
+	if hasBranch {
+		branchID := branchIDFromPayload(payload)
+		if _, err := h.repo.GetCafeInfo(branchID); err != nil {
+			return errorResponse(err.(*shared.AppError))
+		}
+		if err := h.repo.SetUserBranch(telegramID, branchID); err != nil {
+			return errorResponse(err.(*shared.AppError))
+		}
+	}
+	if hasLanguage {
+		if err := h.repo.SetUserLanguage(telegramID, language); err != nil {
+			return errorResponse(err.(*shared.AppError))
+		}
+	}
+
+	pref, err := h.repo.GetUserPreference(telegramID)
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"preference": pref,
+	})
+}
+
+// commit stores the event of a successful action and commits its
+// transaction, then has the outbox deliver the event right away
+func (h *Handler) commit(tx *sql.Tx, eventType string, data map[string]interface{}) *shared.AppError {
+	if err := h.events.Publish(h.repo.Ctx, tx, eventType, data); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	h.events.Wake()
+	return nil
+}
+
 // Helper functions
+
+// coordinatesFromPayload reads the optional latitude/longitude pair. Both
+// must be given together; null values clear the location.
+func coordinatesFromPayload(data map[string]interface{}) (*float64, *float64, *shared.AppError) {
+	latRaw, hasLat := data["latitude"]
+	lonRaw, hasLon := data["longitude"]
+	if (!hasLat && !hasLon) || (latRaw == nil && lonRaw == nil) {
+		return nil, nil, nil
+	}
+
+	lat, latOK := latRaw.(float64)
+	lon, lonOK := lonRaw.(float64)
+	if !latOK || !lonOK {
+		return nil, nil, shared.NewInvalidInputError("latitude dan longitude harus berupa angka")
+	}
+	if !validCoordinates(lat, lon) {
+		return nil, nil, shared.NewKeyedInvalidInputError("error.coordinates_invalid")
+	}
+	return &lat, &lon, nil
+}
+
+// branchIDFromPayload reads the optional branch_id field, falling back to the
+// default branch so single-branch clients keep working unchanged
+func branchIDFromPayload(payload interface{}) int {
+	if data, ok := payload.(map[string]interface{}); ok {
+		if id, ok := data["branch_id"].(float64); ok && id > 0 {
+			return int(id)
+		}
+	}
+	return DefaultBranchID
+}
+
 func successResponse(data interface{}) *shared.Response {
 	return &shared.Response{
 		Success: true,
@@ -114,10 +431,7 @@ func successResponse(data interface{}) *shared.Response {
 func errorResponse(err *shared.AppError) *shared.Response {
 	return &shared.Response{
 		Success: false,
-		Error: &shared.ErrorInfo{
-			Code:    err.Code,
-			Message: err.Message,
-		},
+		Error:   err.Info(),
 	}
 }
 
@@ -131,3 +445,18 @@ func sendErrorResponse(w http.ResponseWriter, err *shared.AppError) {
 	response := errorResponse(err)
 	json.NewEncoder(w).Encode(response)
 }
+
+// eventData is the payload of an action, plus the branch_id of the branch
+// it created or updated
+func eventData(payload interface{}, response *shared.Response) map[string]interface{} {
+	data, _ := payload.(map[string]interface{})
+	result := make(map[string]interface{}, len(data)+1)
+	for key, value := range data {
+		result[key] = value
+	}
+	answer, _ := response.Data.(map[string]interface{})
+	if info, ok := answer["info"].(*CafeInfo); ok && info != nil {
+		result["branch_id"] = info.ID
+	}
+	return result
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -13,6 +14,8 @@ import (
 func main() {
 	// Load environment variables
 	godotenv.Load()
+	shared.ConfigureLogger("info-service")
+	shared.ConfigureTracing("info-service")
 
 	port := os.Getenv("INFO_SERVICE_PORT")
 	if port == "" {
@@ -37,19 +40,24 @@ func main() {
 		log.Fatalf("Failed to initialize schema: %v", err)
 	}
 
+	// Changes are announced to other services through the outbox
+	events, err := shared.NewOutbox(db, "info-service")
+	if err != nil {
+		log.Fatalf("Failed to initialize outbox: %v", err)
+	}
+	go events.Run()
+
 	// Initialize handler
-	handler := NewHandler(repo)
+	handler := NewHandler(repo, events)
 
 	// Setup routes
-	http.HandleFunc("/", handler.HandleRequest)
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
+	http.HandleFunc("/", shared.InstrumentRequests(handler.HandleRequest))
+	shared.NewHealth("info-service").WithDatabase(db).Register()
+	http.HandleFunc("/metrics", shared.MetricsHandler)
 
 	// Start server
 	addr := fmt.Sprintf(":%s", port)
-	shared.LogInfo("Info service starting on %s", addr)
+	shared.Logger(context.Background()).Info("service starting", "addr", addr)
 	if err := http.ListenAndServe(addr, nil); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
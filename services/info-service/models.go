@@ -2,7 +2,11 @@ package main
 
 import "time"
 
-// CafeInfo represents café information
+// DefaultBranchID is the branch created on first start and used whenever a
+// request does not name a branch
+const DefaultBranchID = 1
+
+// CafeInfo represents café information for a single branch
 type CafeInfo struct {
 	ID          int       `json:"id"`
 	Name        string    `json:"name"`
@@ -12,5 +16,28 @@ type CafeInfo struct {
 	OpeningHour string    `json:"opening_hour"`
 	ClosingHour string    `json:"closing_hour"`
 	Description string    `json:"description"`
+	Latitude    *float64  `json:"latitude,omitempty"`
+	Longitude   *float64  `json:"longitude,omitempty"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
+
+// HasLocation reports whether the branch has coordinates
+func (c *CafeInfo) HasLocation() bool {
+	return c.Latitude != nil && c.Longitude != nil
+}
+
+// NearbyBranch is a branch with its distance from a given point
+type NearbyBranch struct {
+	CafeInfo
+	DistanceKm float64 `json:"distance_km"`
+	IsOpen     bool    `json:"is_open"`
+}
+
+// UserPreference stores per-user settings: the selected branch and the
+// language picked with /bahasa (empty until they pick one)
+type UserPreference struct {
+	TelegramID string    `json:"telegram_id"`
+	BranchID   int       `json:"branch_id"`
+	Language   string    `json:"language"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
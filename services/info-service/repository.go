@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"time"
 
@@ -9,14 +10,28 @@ import (
 
 // Repository handles database operations
 type Repository struct {
-	db *sql.DB
+	shared.Store
 }
 
 // NewRepository creates a new repository
 func NewRepository(db *sql.DB) *Repository {
-	return &Repository{db: db}
+	return &Repository{shared.NewStore(db)}
 }
 
+// WithContext returns the repository running its queries with ctx
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{r.Store.WithContext(ctx)}
+}
+
+// WithTx returns the repository running its queries in tx
+func (r *Repository) WithTx(tx *sql.Tx) *Repository {
+	return &Repository{r.Store.WithTx(tx)}
+}
+
+// schemaVersion is recorded in the database by InitSchema and reported by
+// /ready; bump it with every schema change
+const schemaVersion = 3
+
 // InitSchema initializes database schema
 func (r *Repository) InitSchema() error {
 	schema := `
@@ -35,18 +50,38 @@ func (r *Repository) InitSchema() error {
 	-- Insert default café info if not exists
 	INSERT OR IGNORE INTO cafe_info (id, name, address, phone, opening_hour, closing_hour, description)
 	VALUES (1, 'Café/Resto Bot', 'Jl. Contoh No. 123', '081234567890', '08:00', '22:00', 'Selamat datang di Café kami!');
+
+	CREATE TABLE IF NOT EXISTS user_preferences (
+		telegram_id TEXT PRIMARY KEY,
+		branch_id INTEGER NOT NULL DEFAULT 1,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
 	`
-	return shared.ExecuteSchema(r.db, schema)
+	if err := shared.ExecuteSchema(r.DB, schema); err != nil {
+		return err
+	}
+
+	// Columns added after the first release
+	if err := shared.AddColumnIfNotExists(r.DB, "cafe_info", "latitude", "REAL"); err != nil {
+		return err
+	}
+	if err := shared.AddColumnIfNotExists(r.DB, "cafe_info", "longitude", "REAL"); err != nil {
+		return err
+	}
+	if err := shared.AddColumnIfNotExists(r.DB, "user_preferences", "language", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	return shared.SetSchemaVersion(r.DB, schemaVersion)
 }
 
-// GetCafeInfo gets café information
-func (r *Repository) GetCafeInfo() (*CafeInfo, error) {
-	query := `SELECT id, name, address, phone, email, opening_hour, closing_hour, description, updated_at 
-			  FROM cafe_info WHERE id = 1`
+// GetCafeInfo gets café information of a branch
+func (r *Repository) GetCafeInfo(id int) (*CafeInfo, error) {
+	query := `SELECT id, name, address, phone, email, opening_hour, closing_hour, description, latitude, longitude, updated_at 
+			  FROM cafe_info WHERE id = ?`
 	var info CafeInfo
-	err := r.db.QueryRow(query).Scan(
+	err := r.Q.QueryRowContext(r.Ctx, query, id).Scan(
 		&info.ID, &info.Name, &info.Address, &info.Phone, &info.Email,
-		&info.OpeningHour, &info.ClosingHour, &info.Description, &info.UpdatedAt,
+		&info.OpeningHour, &info.ClosingHour, &info.Description, &info.Latitude, &info.Longitude, &info.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, shared.NewNotFoundError("Informasi café")
@@ -57,17 +92,129 @@ func (r *Repository) GetCafeInfo() (*CafeInfo, error) {
 	return &info, nil
 }
 
-// UpdateCafeInfo updates café information
-
+// UpdateCafeInfo updates café information of a branch
 func (r *Repository) UpdateCafeInfo(info *CafeInfo) error {
 	query := `UPDATE cafe_info SET name = ?, address = ?, phone = ?, email = ?, 
-			  opening_hour = ?, closing_hour = ?, description = ?, updated_at = CURRENT_TIMESTAMP 
-			  WHERE id = 1`
-	_, err := r.db.Exec(query, info.Name, info.Address, info.Phone, info.Email,
-		info.OpeningHour, info.ClosingHour, info.Description)
+			  opening_hour = ?, closing_hour = ?, description = ?, latitude = ?, longitude = ?, updated_at = CURRENT_TIMESTAMP 
+			  WHERE id = ?`
+	result, err := r.Q.ExecContext(r.Ctx, query, info.Name, info.Address, info.Phone, info.Email,
+		info.OpeningHour, info.ClosingHour, info.Description, info.Latitude, info.Longitude, info.ID)
 	if err != nil {
 		return shared.NewDatabaseError(err)
 	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return shared.NewNotFoundError("Cabang")
+	}
+	info.UpdatedAt = time.Now()
+	return nil
+}
+
+// ListBranches lists all branches
+func (r *Repository) ListBranches() ([]CafeInfo, error) {
+	query := `SELECT id, name, address, phone, email, opening_hour, closing_hour, description, latitude, longitude, updated_at 
+			  FROM cafe_info ORDER BY id`
+	rows, err := r.Q.QueryContext(r.Ctx, query)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	defer rows.Close()
+
+	var branches []CafeInfo
+	for rows.Next() {
+		var info CafeInfo
+		if err := rows.Scan(&info.ID, &info.Name, &info.Address, &info.Phone, &info.Email,
+			&info.OpeningHour, &info.ClosingHour, &info.Description, &info.Latitude, &info.Longitude, &info.UpdatedAt); err != nil {
+			return nil, shared.NewDatabaseError(err)
+		}
+		branches = append(branches, info)
+	}
+	return branches, nil
+}
+
+// CreateBranch creates a new branch
+func (r *Repository) CreateBranch(info *CafeInfo) (*CafeInfo, error) {
+	query := `INSERT INTO cafe_info (name, address, phone, email, opening_hour, closing_hour, description, latitude, longitude) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := r.Q.ExecContext(r.Ctx, query, info.Name, info.Address, info.Phone, info.Email,
+		info.OpeningHour, info.ClosingHour, info.Description, info.Latitude, info.Longitude)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+
+	id, _ := result.LastInsertId()
+	info.ID = int(id)
 	info.UpdatedAt = time.Now()
+	return info, nil
+}
+
+// DeleteBranch deletes a branch and moves the users who picked it to the
+// default branch, in one transaction. The default branch cannot be deleted.
+// Other services drop what they keep per branch on branch.deleted.
+func (r *Repository) DeleteBranch(id int) error {
+	if id == DefaultBranchID {
+		return shared.NewKeyedInvalidInputError("error.main_branch_delete")
+	}
+
+	tx, err := shared.Begin(r.Ctx, r.Q)
+	if err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(r.Ctx, `DELETE FROM cafe_info WHERE id = ?`, id)
+	if err != nil {
+		return shared.NewDatabaseError(err)
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return shared.NewNotFoundError("Cabang")
+	}
+
+	// Users who picked the deleted branch fall back to the default one
+	_, err = tx.ExecContext(r.Ctx, `UPDATE user_preferences SET branch_id = ?, updated_at = CURRENT_TIMESTAMP WHERE branch_id = ?`,
+		DefaultBranchID, id)
+	if err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	return nil
+}
+
+// GetUserPreference gets the stored preference of a user
+func (r *Repository) GetUserPreference(telegramID string) (*UserPreference, error) {
+	query := `SELECT telegram_id, branch_id, language, updated_at FROM user_preferences WHERE telegram_id = ?`
+	var pref UserPreference
+	err := r.Q.QueryRowContext(r.Ctx, query, telegramID).Scan(&pref.TelegramID, &pref.BranchID, &pref.Language, &pref.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, shared.NewNotFoundError("Preferensi pengguna")
+	}
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	return &pref, nil
+}
+
+// SetUserBranch stores the branch selected by a user
+func (r *Repository) SetUserBranch(telegramID string, branchID int) error {
+	query := `INSERT INTO user_preferences (telegram_id, branch_id) VALUES (?, ?)
+			  ON CONFLICT(telegram_id) DO UPDATE SET branch_id = excluded.branch_id, updated_at = CURRENT_TIMESTAMP`
+	if _, err := r.Q.ExecContext(r.Ctx, query, telegramID, branchID); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	return nil
+}
+
+// SetUserLanguage stores the language selected by a user
+func (r *Repository) SetUserLanguage(telegramID string, language string) error {
+	query := `INSERT INTO user_preferences (telegram_id, branch_id, language) VALUES (?, ?, ?)
+			  ON CONFLICT(telegram_id) DO UPDATE SET language = excluded.language, updated_at = CURRENT_TIMESTAMP`
+	if _, err := r.Q.ExecContext(r.Ctx, query, telegramID, DefaultBranchID, language); err != nil {
+		return shared.NewDatabaseError(err)
+	}
 	return nil
 }
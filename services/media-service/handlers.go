@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"net/http"
 
@@ -30,6 +31,9 @@ func (h *Handler) HandleRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Queries are traced as part of this request
+	h = &Handler{repo: h.repo.WithContext(r.Context())}
+
 	var response *shared.Response
 
 	switch req.Action {
@@ -151,6 +155,35 @@ func (h *Handler) deleteMedia(payload interface{}) *shared.Response {
 	})
 }
 
+// Media of these events' entities is deleted with them
+var deletedEntities = map[string]string{
+	"menu.deleted":  "menu",
+	"promo.deleted": "promo",
+}
+
+// HandleEvent deletes the media of menus and promos deleted in other
+// services. Deleting them again does nothing, so redelivery is harmless.
+func (h *Handler) HandleEvent(ctx context.Context, event shared.Event) error {
+	entityType, ok := deletedEntities[event.Type]
+	if !ok {
+		return nil
+	}
+	id, ok := event.Data["id"].(float64)
+	if !ok {
+		shared.Logger(ctx).Warn("event without entity id")
+		return nil
+	}
+
+	deleted, err := h.repo.WithContext(ctx).DeleteMediaByEntity(int(id), entityType)
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		shared.Logger(ctx).Info("deleted media of removed entity", "entity_type", entityType, "entity_id", int(id), "count", deleted)
+	}
+	return nil
+}
+
 // Helper functions
 func successResponse(data interface{}) *shared.Response {
 	return &shared.Response{
@@ -162,10 +195,7 @@ func successResponse(data interface{}) *shared.Response {
 func errorResponse(err *shared.AppError) *shared.Response {
 	return &shared.Response{
 		Success: false,
-		Error: &shared.ErrorInfo{
-			Code:    err.Code,
-			Message: err.Message,
-		},
+		Error:   err.Info(),
 	}
 }
 
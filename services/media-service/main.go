@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -13,6 +14,8 @@ import (
 func main() {
 	// Load environment variables
 	godotenv.Load()
+	shared.ConfigureLogger("media-service")
+	shared.ConfigureTracing("media-service")
 
 	port := os.Getenv("MEDIA_SERVICE_PORT")
 	if port == "" {
@@ -37,19 +40,24 @@ func main() {
 		log.Fatalf("Failed to initialize schema: %v", err)
 	}
 
+	// Events are handled once, even when delivered again
+	dedup, err := shared.NewDBDeduper(db)
+	if err != nil {
+		log.Fatalf("Failed to initialize event log: %v", err)
+	}
+
 	// Initialize handler
 	handler := NewHandler(repo)
 
 	// Setup routes
-	http.HandleFunc("/", handler.HandleRequest)
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
+	http.HandleFunc("/", shared.InstrumentRequests(handler.HandleRequest))
+	http.HandleFunc("/events", shared.HandleEvents(dedup, handler.HandleEvent))
+	shared.NewHealth("media-service").WithDatabase(db).Register()
+	http.HandleFunc("/metrics", shared.MetricsHandler)
 
 	// Start server
 	addr := fmt.Sprintf(":%s", port)
-	shared.LogInfo("Media service starting on %s", addr)
+	shared.Logger(context.Background()).Info("service starting", "addr", addr)
 	if err := http.ListenAndServe(addr, nil); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
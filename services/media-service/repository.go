@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"time"
 
@@ -9,14 +10,23 @@ import (
 
 // Repository handles database operations
 type Repository struct {
-	db *sql.DB
+	shared.Store
 }
 
 // NewRepository creates a new repository
 func NewRepository(db *sql.DB) *Repository {
-	return &Repository{db: db}
+	return &Repository{shared.NewStore(db)}
 }
 
+// WithContext returns the repository running its queries with ctx
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{r.Store.WithContext(ctx)}
+}
+
+// schemaVersion is recorded in the database by InitSchema and reported by
+// /ready; bump it with every schema change
+const schemaVersion = 1
+
 // InitSchema initializes database schema
 func (r *Repository) InitSchema() error {
 	schema := `
@@ -32,14 +42,17 @@ func (r *Repository) InitSchema() error {
 
 	CREATE INDEX IF NOT EXISTS idx_entity ON media(entity_id, entity_type);
 	`
-	return shared.ExecuteSchema(r.db, schema)
+	if err := shared.ExecuteSchema(r.DB, schema); err != nil {
+		return err
+	}
+	return shared.SetSchemaVersion(r.DB, schemaVersion)
 }
 
 // CreateMedia creates a new media record
 func (r *Repository) CreateMedia(media *Media) (*Media, error) {
 	query := `INSERT INTO media (file_name, file_url, file_type, entity_id, entity_type) 
 			  VALUES (?, ?, ?, ?, ?)`
-	result, err := r.db.Exec(query, media.FileName, media.FileURL, media.FileType, media.EntityID, media.EntityType)
+	result, err := r.DB.ExecContext(r.Ctx, query, media.FileName, media.FileURL, media.FileType, media.EntityID, media.EntityType)
 	if err != nil {
 		return nil, shared.NewDatabaseError(err)
 	}
@@ -55,7 +68,7 @@ func (r *Repository) GetMediaByID(id int) (*Media, error) {
 	query := `SELECT id, file_name, file_url, file_type, entity_id, entity_type, created_at 
 			  FROM media WHERE id = ?`
 	var media Media
-	err := r.db.QueryRow(query, id).Scan(
+	err := r.DB.QueryRowContext(r.Ctx, query, id).Scan(
 		&media.ID, &media.FileName, &media.FileURL, &media.FileType,
 		&media.EntityID, &media.EntityType, &media.CreatedAt,
 	)
@@ -72,7 +85,7 @@ func (r *Repository) GetMediaByID(id int) (*Media, error) {
 func (r *Repository) ListMediaByEntity(entityID int, entityType string) ([]Media, error) {
 	query := `SELECT id, file_name, file_url, file_type, entity_id, entity_type, created_at 
 			  FROM media WHERE entity_id = ? AND entity_type = ? ORDER BY created_at DESC`
-	rows, err := r.db.Query(query, entityID, entityType)
+	rows, err := r.DB.QueryContext(r.Ctx, query, entityID, entityType)
 	if err != nil {
 		return nil, shared.NewDatabaseError(err)
 	}
@@ -93,7 +106,7 @@ func (r *Repository) ListMediaByEntity(entityID int, entityType string) ([]Media
 // DeleteMedia deletes a media
 func (r *Repository) DeleteMedia(id int) error {
 	query := `DELETE FROM media WHERE id = ?`
-	result, err := r.db.Exec(query, id)
+	result, err := r.DB.ExecContext(r.Ctx, query, id)
 	if err != nil {
 		return shared.NewDatabaseError(err)
 	}
@@ -104,3 +117,15 @@ func (r *Repository) DeleteMedia(id int) error {
 	}
 	return nil
 }
+
+// DeleteMediaByEntity deletes every media of an entity and returns how many
+// it deleted
+func (r *Repository) DeleteMediaByEntity(entityID int, entityType string) (int, error) {
+	query := `DELETE FROM media WHERE entity_id = ? AND entity_type = ?`
+	result, err := r.DB.ExecContext(r.Ctx, query, entityID, entityType)
+	if err != nil {
+		return 0, shared.NewDatabaseError(err)
+	}
+	affected, _ := result.RowsAffected()
+	return int(affected), nil
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+)
+
+// Limits of a single import file
+const (
+	maxImportBytes = 2 << 20
+	maxImportRows  = 1000
+)
+
+// catalogColumns are the columns of an exported catalogue, in file order.
+// Import matches headers case-insensitively and also accepts the Indonesian
+// names in catalogColumnAliases.
+var catalogColumns = []string{"sku", "name", "category", "price", "description", "is_available", "photo_url"}
+
+var catalogColumnAliases = map[string]string{
+	"kode":      "sku",
+	"nama":      "name",
+	"kategori":  "category",
+	"harga":     "price",
+	"deskripsi": "description",
+	"tersedia":  "is_available",
+	"foto":      "photo_url",
+}
+
+// Columns that must be present in the header of an import file
+var requiredImportColumns = []string{"name", "category", "price"}
+
+// readSpreadsheet decodes an import file in the given format
+func readSpreadsheet(format string, content []byte) ([]sheetRow, error) {
+	switch format {
+	case FormatCSV:
+		return readCSV(content)
+	case FormatXLSX:
+		return readXLSX(content)
+	}
+	return nil, fmt.Errorf("format harus csv atau xlsx")
+}
+
+// catalogRows returns menus as spreadsheet rows, header first. Availability
+// is the one set by hand, so a menu out of stock is not imported back as
+// switched off.
+func catalogRows(menus []Menu) [][]string {
+	rows := [][]string{catalogColumns}
+	for _, menu := range menus {
+		available := "tidak"
+		if menu.availableByHand {
+			available = "ya"
+		}
+		rows = append(rows, []string{menu.SKU, menu.Name, menu.Category, strconv.FormatInt(int64(menu.Price), 10),
+			menu.Description, available, menu.PhotoURL})
+	}
+	return rows
+}
+
+// parseAvailability reads a yes/no cell. ok is false for unrecognised values.
+func parseAvailability(value string) (available, ok bool) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "true", "ya", "y", "yes", "tersedia":
+		return true, true
+	case "0", "false", "tidak", "t", "n", "no", "habis":
+		return false, true
+	}
+	return false, false
+}
+
+// importPlan is the validated result of an import file
+type importPlan struct {
+	creates       []Menu
+	updates       []Menu
+	newCategories []string
+	rows          int
+	errors        []ImportRowError
+}
+
+// planImport validates rows against the current catalogue. A row updates the
+// menu with the same SKU; rows without a match by SKU update the menu with
+// the same name that has no SKU yet, otherwise a new menu is created.
+// Missing categories are an error unless createCategories is set.
+func planImport(rows []sheetRow, existing []Menu, categories []Category, createCategories bool) *importPlan {
+	plan := &importPlan{}
+	rowError := func(row int, field string, err *shared.AppError) {
+		plan.errors = append(plan.errors, ImportRowError{
+			Row: row, Field: field, Message: err.Message, Key: err.Key, Params: err.Params,
+		})
+	}
+
+	if len(rows) == 0 {
+		rowError(1, "", shared.NewKeyedInvalidInputError("error.import_empty"))
+		return plan
+	}
+
+	// Map header columns to catalogue fields
+	columns := map[string]int{}
+	for i, cell := range rows[0].Cells {
+		name := strings.ToLower(strings.TrimSpace(cell))
+		if alias, ok := catalogColumnAliases[name]; ok {
+			name = alias
+		}
+		if name == "" {
+			continue
+		}
+		if _, dup := columns[name]; dup {
+			rowError(rows[0].Number, name, shared.NewKeyedInvalidInputError("error.import_column_repeated", name))
+			continue
+		}
+		columns[name] = i
+	}
+	for _, name := range requiredImportColumns {
+		if _, ok := columns[name]; !ok {
+			rowError(rows[0].Number, name, shared.NewKeyedInvalidInputError("error.import_column_missing", name))
+		}
+	}
+	if len(plan.errors) > 0 {
+		return plan
+	}
+
+	bySKU := map[string]*Menu{}
+	byName := map[string][]*Menu{}
+	for i := range existing {
+		menu := &existing[i]
+		if menu.SKU != "" {
+			bySKU[strings.ToLower(menu.SKU)] = menu
+		} else {
+			key := strings.ToLower(menu.Name)
+			byName[key] = append(byName[key], menu)
+		}
+	}
+
+	knownCategories := map[string]bool{}
+	for _, category := range categories {
+		knownCategories[category.Name] = true
+	}
+
+	seenSKU := map[string]int{}
+	seenName := map[string]int{}
+	matched := map[int]int{}
+
+	for _, row := range rows[1:] {
+		cell := func(name string) (string, bool) {
+			i, ok := columns[name]
+			if !ok {
+				return "", false
+			}
+			if i >= len(row.Cells) {
+				return "", true
+			}
+			return strings.TrimSpace(row.Cells[i]), true
+		}
+
+		// Skip blank lines, which spreadsheets often leave at the end
+		blank := true
+		for _, value := range row.Cells {
+			if strings.TrimSpace(value) != "" {
+				blank = false
+				break
+			}
+		}
+		if blank {
+			continue
+		}
+
+		plan.rows++
+		if plan.rows > maxImportRows {
+			rowError(row.Number, "", shared.NewKeyedInvalidInputError("error.import_too_many_rows", strconv.Itoa(maxImportRows)))
+			break
+		}
+		errorCount := len(plan.errors)
+
+		sku, _ := cell("sku")
+		name, _ := cell("name")
+		category, _ := cell("category")
+		priceText, _ := cell("price")
+
+		if err := shared.ValidateNotEmpty(name, "Nama menu"); err != nil {
+			rowError(row.Number, "name", err.(*shared.AppError))
+		}
+		if err := shared.ValidateNotEmpty(category, "Kategori"); err != nil {
+			rowError(row.Number, "category", err.(*shared.AppError))
+		} else if !knownCategories[category] && !createCategories {
+			rowError(row.Number, "category", shared.NewKeyedInvalidInputError("error.import_category_missing", category))
+		}
+		price, err := shared.ValidatePrice(priceText)
+		if err != nil {
+			rowError(row.Number, "price", err.(*shared.AppError))
+		}
+
+		description, hasDescription := cell("description")
+		photoURL, hasPhoto := cell("photo_url")
+		if hasPhoto {
+			if err := shared.ValidatePhotoURL(photoURL); err != nil {
+				rowError(row.Number, "photo_url", err.(*shared.AppError))
+			}
+		}
+		availableText, _ := cell("is_available")
+		available, availableOK := parseAvailability(availableText)
+		if availableText != "" && !availableOK {
+			rowError(row.Number, "is_available", shared.NewKeyedInvalidInputError("error.import_availability"))
+		}
+
+		// The same menu may appear only once per file
+		if sku != "" {
+			if first, dup := seenSKU[strings.ToLower(sku)]; dup {
+				rowError(row.Number, "sku", shared.NewKeyedInvalidInputError("error.import_sku_repeated", sku, strconv.Itoa(first)))
+			}
+			seenSKU[strings.ToLower(sku)] = row.Number
+		}
+
+		var target *Menu
+		if menu, ok := bySKU[strings.ToLower(sku)]; ok && sku != "" {
+			target = menu
+		} else if name != "" {
+			switch candidates := byName[strings.ToLower(name)]; len(candidates) {
+			case 0:
+			case 1:
+				target = candidates[0]
+			default:
+				rowError(row.Number, "name", shared.NewKeyedInvalidInputError("error.import_name_ambiguous", name))
+			}
+		}
+		if target != nil {
+			if first, dup := matched[target.ID]; dup {
+				rowError(row.Number, "", shared.NewKeyedInvalidInputError("error.import_menu_repeated", target.Name, strconv.Itoa(first)))
+			}
+			matched[target.ID] = row.Number
+		} else if sku == "" && name != "" {
+			if first, dup := seenName[strings.ToLower(name)]; dup {
+				rowError(row.Number, "name", shared.NewKeyedInvalidInputError("error.import_name_repeated", name, strconv.Itoa(first)))
+			}
+			seenName[strings.ToLower(name)] = row.Number
+		}
+
+		if len(plan.errors) > errorCount {
+			continue
+		}
+
+		if category != "" && !knownCategories[category] {
+			knownCategories[category] = true
+			plan.newCategories = append(plan.newCategories, category)
+		}
+
+		if target == nil {
+			menu := Menu{
+				SKU:         sku,
+				Name:        shared.SanitizeInput(name),
+				Description: shared.SanitizeInput(description),
+				Price:       price,
+				Category:    category,
+				PhotoURL:    photoURL,
+				IsAvailable: true,
+			}
+			if availableText != "" {
+				menu.IsAvailable = available
+			}
+			plan.creates = append(plan.creates, menu)
+			continue
+		}
+
+		// Columns left out of the file keep their current values
+		menu := *target
+		menu.IsAvailable = menu.availableByHand
+		if sku != "" {
+			menu.SKU = sku
+		}
+		menu.Name = shared.SanitizeInput(name)
+		menu.Category = category
+		menu.Price = price
+		if hasDescription {
+			menu.Description = shared.SanitizeInput(description)
+		}
+		if hasPhoto {
+			menu.PhotoURL = photoURL
+		}
+		if availableText != "" {
+			menu.IsAvailable = available
+		}
+		plan.updates = append(plan.updates, menu)
+	}
+
+	return plan
+}
@@ -1,20 +1,52 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"math"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/alrescha79-cmd/bot-cafe/shared"
 )
 
 // Handler handles HTTP requests
 type Handler struct {
-	repo *Repository
+	repo   *Repository
+	events *shared.Outbox
+}
+
+// Events published after a successful action
+var actionEvents = map[string]string{
+	"create":                "menu.created",
+	"update":                "menu.updated",
+	"delete":                "menu.deleted",
+	"import":                "menu.imported",
+	"set_branch_override":   "menu.updated",
+	"clear_branch_override": "menu.updated",
+	"set_translation":       "menu.updated",
+	"set_availability":      "menu.availability_changed",
+	"create_category":       "category.created",
+	"delete_category":       "category.deleted",
+	"create_option_group":   "menu.options_changed",
+	"delete_option_group":   "menu.options_changed",
+	"create_option":         "menu.options_changed",
+	"delete_option":         "menu.options_changed",
+	"create_ingredient":     "stock.changed",
+	"update_ingredient":     "stock.changed",
+	"delete_ingredient":     "stock.changed",
+	"adjust_stock":          "stock.changed",
+	"set_recipe":            "stock.changed",
+	"consume_stock":         "stock.changed",
 }
 
 // NewHandler creates a new handler
-func NewHandler(repo *Repository) *Handler {
-	return &Handler{repo: repo}
+func NewHandler(repo *Repository, events *shared.Outbox) *Handler {
+	return &Handler{repo: repo, events: events}
 }
 
 // HandleRequest handles all incoming requests
@@ -30,6 +62,24 @@ func (h *Handler) HandleRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Queries are traced as part of this request
+	h = &Handler{repo: h.repo.WithContext(r.Context()), events: h.events}
+
+	// An action changing data runs in a transaction that also stores the
+	// event announcing the change, so the event is kept exactly when the
+	// change is
+	eventType, announced := actionEvents[req.Action]
+	var tx *sql.Tx
+	if announced {
+		var err error
+		if tx, err = h.repo.Begin(); err != nil {
+			sendErrorResponse(w, shared.NewDatabaseError(err))
+			return
+		}
+		defer tx.Rollback()
+		h = &Handler{repo: h.repo.WithTx(tx), events: h.events}
+	}
+
 	var response *shared.Response
 
 	switch req.Action {
@@ -43,20 +93,118 @@ func (h *Handler) HandleRequest(w http.ResponseWriter, r *http.Request) {
 		response = h.deleteMenu(req.Payload)
 	case "list":
 		response = h.listMenus(req.Payload)
+	case "import":
+		response = h.importMenus(req.Payload)
+	case "export":
+		response = h.exportMenus(req.Payload)
+	case "set_translation":
+		response = h.setTranslation(req.Payload)
 	case "list_categories":
-		response = h.listCategories()
+		response = h.listCategories(req.Payload)
 	case "create_category":
 		response = h.createCategory(req.Payload)
 	case "delete_category":
 		response = h.deleteCategory(req.Payload)
+	case "set_branch_override":
+		response = h.setBranchOverride(req.Payload)
+	case "clear_branch_override":
+		response = h.clearBranchOverride(req.Payload)
+	case "set_availability":
+		response = h.setAvailability(req.Payload)
+	case "list_options":
+		response = h.listOptions(req.Payload)
+	case "create_option_group":
+		response = h.createOptionGroup(req.Payload)
+	case "delete_option_group":
+		response = h.deleteOptionGroup(req.Payload)
+	case "create_option":
+		response = h.createOption(req.Payload)
+	case "delete_option":
+		response = h.deleteOption(req.Payload)
+	case "quote":
+		response = h.quote(req.Payload)
+	case "list_ingredients":
+		response = h.listIngredients(req.Payload)
+	case "create_ingredient":
+		response = h.createIngredient(req.Payload)
+	case "update_ingredient":
+		response = h.updateIngredient(req.Payload)
+	case "delete_ingredient":
+		response = h.deleteIngredient(req.Payload)
+	case "adjust_stock":
+		response = h.adjustStock(req.Payload)
+	case "list_stock_movements":
+		response = h.listStockMovements(req.Payload)
+	case "get_recipe":
+		response = h.getRecipe(req.Payload)
+	case "set_recipe":
+		response = h.setRecipe(req.Payload)
+	case "consume_stock":
+		response = h.consumeStock(req.Payload)
 	default:
 		sendErrorResponse(w, shared.NewError(shared.ErrCodeInvalidInput, "Unknown action", nil))
 		return
 	}
 
+	if tx != nil && response.Success {
+		if err := h.commit(tx, eventType, eventData(req.Payload, response)); err != nil {
+			response = errorResponse(err)
+		}
+	}
+
 	sendResponse(w, response)
 }
 
+// commit stores the event of a successful action and commits its
+// transaction, then has the outbox deliver the event right away
+func (h *Handler) commit(tx *sql.Tx, eventType string, data map[string]interface{}) *shared.AppError {
+	if err := h.events.Publish(h.repo.Ctx, tx, eventType, data); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	h.events.Wake()
+	return nil
+}
+
+// HandleEvent deletes the branch overrides and stock of branches deleted in
+// info-service and announces it as menu.updated. Deleting them again does
+// nothing, so redelivery is harmless.
+func (h *Handler) HandleEvent(ctx context.Context, event shared.Event) error {
+	if event.Service != "info-service" || event.Type != "branch.deleted" {
+		return nil
+	}
+	branchID, ok := event.Data["branch_id"].(float64)
+	if !ok {
+		shared.Logger(ctx).Warn("event without branch id")
+		return nil
+	}
+
+	h = &Handler{repo: h.repo.WithContext(ctx), events: h.events}
+	tx, err := h.repo.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := h.repo.WithTx(tx).DeleteBranchStock(int(branchID)); err != nil {
+		return err
+	}
+	deleted, err := h.repo.WithTx(tx).DeleteBranchOverrides(int(branchID))
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return tx.Commit()
+	}
+	if err := h.commit(tx, "menu.updated", map[string]interface{}{"branch_id": int(branchID)}); err != nil {
+		return err
+	}
+	shared.Logger(ctx).Info("deleted overrides of removed branch", "branch_id", int(branchID), "count", deleted)
+	return nil
+}
+
 // createMenu creates a new menu
 func (h *Handler) createMenu(payload interface{}) *shared.Response {
 	data, ok := payload.(map[string]interface{})
@@ -64,6 +212,7 @@ func (h *Handler) createMenu(payload interface{}) *shared.Response {
 		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
 	}
 
+	sku, _ := data["sku"].(string)
 	name, _ := data["name"].(string)
 	description, _ := data["description"].(string)
 	priceRaw := data["price"]
@@ -92,6 +241,7 @@ func (h *Handler) createMenu(payload interface{}) *shared.Response {
 	}
 
 	menu := &Menu{
+		SKU:         strings.TrimSpace(sku),
 		Name:        shared.SanitizeInput(name),
 		Description: shared.SanitizeInput(description),
 		Price:       price,
@@ -122,13 +272,29 @@ func (h *Handler) getMenu(payload interface{}) *shared.Response {
 		return errorResponse(shared.NewInvalidInputError("ID diperlukan"))
 	}
 
-	menu, err := h.repo.GetMenuByID(int(id))
+	menu, err := h.repo.GetMenuByID(int(id), branchIDFromPayload(data))
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	groups, err := h.repo.ListOptionGroups(menu.ID)
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	translations, err := h.repo.GetMenuTranslations(menu.ID)
 	if err != nil {
 		return errorResponse(err.(*shared.AppError))
 	}
+	if locale, _ := data["locale"].(string); locale != "" {
+		menu.Name = translations.Text(locale, "name", menu.Name)
+		menu.Description = translations.Text(locale, "description", menu.Description)
+	}
 
 	return successResponse(map[string]interface{}{
-		"menu": menu,
+		"menu":          menu,
+		"option_groups": groups,
+		"translations":  translations,
 	})
 }
 
@@ -144,13 +310,18 @@ func (h *Handler) updateMenu(payload interface{}) *shared.Response {
 		return errorResponse(shared.NewInvalidInputError("ID diperlukan"))
 	}
 
-	// Get existing menu
-	menu, err := h.repo.GetMenuByID(int(id))
+	// Get existing menu; branch overrides are changed via set_branch_override
+	menu, err := h.repo.GetMenuByID(int(id), 0)
 	if err != nil {
 		return errorResponse(err.(*shared.AppError))
 	}
 
-	// Update fields if provided
+	// Update fields if provided. Availability is the one set by hand; being
+	// out of stock is not stored with it.
+	menu.IsAvailable = menu.availableByHand
+	if sku, ok := data["sku"].(string); ok {
+		menu.SKU = strings.TrimSpace(sku)
+	}
 	if name, ok := data["name"].(string); ok && name != "" {
 		menu.Name = shared.SanitizeInput(name)
 	}
@@ -180,6 +351,7 @@ func (h *Handler) updateMenu(payload interface{}) *shared.Response {
 	if err := h.repo.UpdateMenu(menu); err != nil {
 		return errorResponse(err.(*shared.AppError))
 	}
+	menu.IsAvailable = menu.IsAvailable && !menu.OutOfStock
 
 	return successResponse(map[string]interface{}{
 		"menu": menu,
@@ -207,10 +379,14 @@ func (h *Handler) deleteMenu(payload interface{}) *shared.Response {
 	})
 }
 
-// listMenus lists menus with optional filters
+// listMenus lists menus with optional filters, a page at a time when a limit
+// is given
 func (h *Handler) listMenus(payload interface{}) *shared.Response {
 	category := ""
 	availableOnly := false
+	branchID := 0
+	locale := ""
+	var page shared.Page
 
 	if data, ok := payload.(map[string]interface{}); ok {
 		if cat, ok := data["category"].(string); ok {
@@ -219,27 +395,219 @@ func (h *Handler) listMenus(payload interface{}) *shared.Response {
 		if avail, ok := data["available_only"].(bool); ok {
 			availableOnly = avail
 		}
+		branchID = branchIDFromPayload(data)
+		locale, _ = data["locale"].(string)
+
+		var err error
+		if page, err = shared.PageFromPayload(data); err != nil {
+			return errorResponse(err.(*shared.AppError))
+		}
 	}
 
-	menus, err := h.repo.ListMenus(category, availableOnly)
+	menus, total, err := h.repo.ListMenus(category, availableOnly, branchID, page)
 	if err != nil {
 		return errorResponse(err.(*shared.AppError))
 	}
+	if err := h.localizeMenus(menus, locale); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
 
 	return successResponse(map[string]interface{}{
 		"menus": menus,
+		"total": total,
+	})
+}
+
+// localizeMenus shows menus in locale: names and descriptions are replaced by
+// their translation where there is one
+func (h *Handler) localizeMenus(menus []Menu, locale string) error {
+	if locale == "" || locale == shared.DefaultLocale {
+		return nil
+	}
+	translations, err := h.repo.ListMenuTranslations(locale)
+	if err != nil {
+		return err
+	}
+	for i := range menus {
+		menu := &menus[i]
+		menu.Name = translations[menu.ID].Text(locale, "name", menu.Name)
+		menu.Description = translations[menu.ID].Text(locale, "description", menu.Description)
+	}
+	return nil
+}
+
+// setTranslation stores the translation of a menu name or description in a
+// locale; an empty value removes it
+func (h *Handler) setTranslation(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	id, ok := data["id"].(float64)
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("ID diperlukan"))
+	}
+	locale, _ := data["locale"].(string)
+	field, _ := data["field"].(string)
+	value, _ := data["value"].(string)
+
+	if err := shared.ValidateTranslation(locale, field, translatableFields...); err != nil {
+		return errorResponse(err)
+	}
+	if _, err := h.repo.GetMenuByID(int(id), 0); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	if err := h.repo.SetMenuTranslation(int(id), locale, field, shared.SanitizeInput(value)); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	translations, err := h.repo.GetMenuTranslations(int(id))
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"translations": translations,
+	})
+}
+
+// importMenus creates and updates menus from a CSV or XLSX file sent as
+// base64. Every row is validated first; nothing is written when a row has
+// errors or when dry_run is set.
+func (h *Handler) importMenus(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	format, _ := data["format"].(string)
+	format = strings.ToLower(format)
+	encoded, _ := data["content"].(string)
+	dryRun, _ := data["dry_run"].(bool)
+	createCategories, _ := data["create_categories"].(bool)
+
+	if format != FormatCSV && format != FormatXLSX {
+		return errorResponse(shared.NewKeyedInvalidInputError("error.import_format"))
+	}
+	if err := shared.ValidateNotEmpty(encoded, "File"); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+	content, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return errorResponse(shared.NewInvalidInputError("Isi file harus base64"))
+	}
+	if len(content) > maxImportBytes {
+		return errorResponse(shared.NewKeyedInvalidInputError("error.import_too_large"))
+	}
+
+	rows, err := readSpreadsheet(format, content)
+	if err != nil {
+		return errorResponse(shared.NewKeyedInvalidInputError("error.import_unreadable", err.Error()))
+	}
+
+	existing, _, err := h.repo.ListMenus("", false, 0, shared.Page{})
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+	categories, _, err := h.repo.ListCategories(shared.Page{})
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	plan := planImport(rows, existing, categories, createCategories)
+	result := &ImportResult{
+		DryRun:        dryRun,
+		Rows:          plan.rows,
+		Created:       len(plan.creates),
+		Updated:       len(plan.updates),
+		NewCategories: plan.newCategories,
+		Errors:        plan.errors,
+	}
+	if result.NewCategories == nil {
+		result.NewCategories = []string{}
+	}
+	if result.Errors == nil {
+		result.Errors = []ImportRowError{}
+	}
+
+	if !dryRun && len(plan.errors) == 0 {
+		if err := h.repo.ImportMenus(plan.newCategories, plan.creates, plan.updates); err != nil {
+			return errorResponse(err.(*shared.AppError))
+		}
+		result.Applied = true
+		shared.Logger(h.repo.Ctx).Info("imported menus", "created", result.Created, "updated", result.Updated)
+	}
+
+	return successResponse(map[string]interface{}{
+		"result": result,
+	})
+}
+
+// exportMenus returns the catalogue as a base64 encoded CSV or XLSX file in
+// the same layout accepted by import
+func (h *Handler) exportMenus(payload interface{}) *shared.Response {
+	format := FormatCSV
+	category := ""
+	if data, ok := payload.(map[string]interface{}); ok {
+		if f, ok := data["format"].(string); ok && f != "" {
+			format = strings.ToLower(f)
+		}
+		category, _ = data["category"].(string)
+	}
+
+	menus, _, err := h.repo.ListMenus(category, false, 0, shared.Page{})
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+	rows := catalogRows(menus)
+
+	var (
+		content     []byte
+		contentType string
+		writeErr    error
+	)
+	switch format {
+	case FormatCSV:
+		content, writeErr = writeCSV(rows)
+		contentType = "text/csv"
+	case FormatXLSX:
+		content, writeErr = writeXLSX("Menu", rows)
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return errorResponse(shared.NewKeyedInvalidInputError("error.import_format"))
+	}
+	if writeErr != nil {
+		return errorResponse(shared.NewInternalError(writeErr))
+	}
+
+	return successResponse(map[string]interface{}{
+		"filename":     fmt.Sprintf("menu-%s.%s", time.Now().Format("20060102"), format),
+		"content_type": contentType,
+		"content":      base64.StdEncoding.EncodeToString(content),
+		"count":        len(menus),
 	})
 }
 
-// listCategories lists all categories
-func (h *Handler) listCategories() *shared.Response {
-	categories, err := h.repo.ListCategories()
+// listCategories lists categories, a page at a time when a limit is given
+func (h *Handler) listCategories(payload interface{}) *shared.Response {
+	var page shared.Page
+	if data, ok := payload.(map[string]interface{}); ok {
+		var err error
+		if page, err = shared.PageFromPayload(data); err != nil {
+			return errorResponse(err.(*shared.AppError))
+		}
+	}
+
+	categories, total, err := h.repo.ListCategories(page)
 	if err != nil {
 		return errorResponse(err.(*shared.AppError))
 	}
 
 	return successResponse(map[string]interface{}{
 		"categories": categories,
+		"total":      total,
 	})
 }
 
@@ -272,35 +640,794 @@ func (h *Handler) deleteCategory(payload interface{}) *shared.Response {
 		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
 	}
 
-	name, _ := data["name"].(string)
-	if err := shared.ValidateNotEmpty(name, "Nama kategori"); err != nil {
+	// The agent names the category by id, older callers by name
+	category := &Category{}
+	if id, ok := data["id"].(float64); ok {
+		found, err := h.repo.GetCategory(int(id))
+		if err != nil {
+			return errorResponse(err.(*shared.AppError))
+		}
+		category = found
+	} else {
+		category.Name, _ = data["name"].(string)
+		if err := shared.ValidateNotEmpty(category.Name, "Nama kategori"); err != nil {
+			return errorResponse(err.(*shared.AppError))
+		}
+	}
+
+	if err := h.repo.DeleteCategory(category.Name); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"message":  "Kategori berhasil dihapus",
+		"category": category,
+	})
+}
+
+// setBranchOverride sets the price and/or availability of a menu in a branch
+func (h *Handler) setBranchOverride(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	menuID, ok := data["menu_id"].(float64)
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("menu_id diperlukan"))
+	}
+	branchID := branchIDFromPayload(data)
+	if branchID == 0 {
+		return errorResponse(shared.NewInvalidInputError("branch_id diperlukan"))
+	}
+
+	if _, err := h.repo.GetMenuByID(int(menuID), 0); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	// Start from the existing override so fields not sent are kept
+	override, err := h.repo.GetBranchOverride(int(menuID), branchID)
+	if err != nil {
+		if appErr := err.(*shared.AppError); appErr.Code != shared.ErrCodeNotFound {
+			return errorResponse(appErr)
+		}
+		override = &BranchOverride{MenuID: int(menuID), BranchID: branchID}
+	}
+
+	if priceRaw, ok := data["price"]; ok {
+		if priceRaw == nil {
+			override.Price = nil
+		} else {
+			price, err := shared.ValidatePrice(priceRaw)
+			if err != nil {
+				return errorResponse(err.(*shared.AppError))
+			}
+			override.Price = &price
+		}
+	}
+	if availRaw, ok := data["is_available"]; ok {
+		if availRaw == nil {
+			override.IsAvailable = nil
+		} else if avail, ok := availRaw.(bool); ok {
+			override.IsAvailable = &avail
+		} else {
+			return errorResponse(shared.NewInvalidInputError("is_available harus boolean"))
+		}
+	}
+
+	if err := h.repo.SaveBranchOverride(override); err != nil {
 		return errorResponse(err.(*shared.AppError))
 	}
 
-	if err := h.repo.DeleteCategory(name); err != nil {
+	menu, err := h.repo.GetMenuByID(int(menuID), branchID)
+	if err != nil {
 		return errorResponse(err.(*shared.AppError))
 	}
 
 	return successResponse(map[string]interface{}{
-		"message": "Kategori berhasil dihapus",
+		"override": override,
+		"menu":     menu,
 	})
 }
 
-// Helper functions
-func successResponse(data interface{}) *shared.Response {
-	return &shared.Response{
-		Success: true,
-		Data:    data,
+// clearBranchOverride removes the override of a menu in a branch
+func (h *Handler) clearBranchOverride(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	menuID, ok := data["menu_id"].(float64)
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("menu_id diperlukan"))
+	}
+	branchID := branchIDFromPayload(data)
+	if branchID == 0 {
+		return errorResponse(shared.NewInvalidInputError("branch_id diperlukan"))
+	}
+
+	if err := h.repo.DeleteBranchOverride(int(menuID), branchID); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"message": "Pengaturan cabang berhasil dihapus",
+	})
+}
+
+// setAvailability marks one menu (menu_id) or several (menu_ids) available or
+// sold out, optionally in a single branch and until a given time
+func (h *Handler) setAvailability(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	var menuIDs []int
+	if id, ok := data["menu_id"].(float64); ok {
+		menuIDs = append(menuIDs, int(id))
+	}
+	if ids, ok := data["menu_ids"].([]interface{}); ok {
+		for _, raw := range ids {
+			id, ok := raw.(float64)
+			if !ok {
+				return errorResponse(shared.NewInvalidInputError("menu_ids harus berupa daftar ID"))
+			}
+			menuIDs = append(menuIDs, int(id))
+		}
+	}
+	if len(menuIDs) == 0 {
+		return errorResponse(shared.NewInvalidInputError("menu_id atau menu_ids diperlukan"))
+	}
+
+	available, ok := data["is_available"].(bool)
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("is_available harus boolean"))
+	}
+
+	var againAt *time.Time
+	if raw, ok := data["available_again_at"].(string); ok && raw != "" {
+		if available {
+			return errorResponse(shared.NewInvalidInputError("available_again_at hanya untuk menu yang habis"))
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return errorResponse(shared.NewInvalidInputError("Format available_again_at harus RFC3339"))
+		}
+		if !t.After(time.Now()) {
+			return errorResponse(shared.NewKeyedInvalidInputError("error.available_again_past"))
+		}
+		againAt = &t
+	}
+
+	branchID := branchIDFromPayload(data)
+	if err := h.repo.SetAvailability(menuIDs, branchID, available, againAt); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	menus := make([]*Menu, 0, len(menuIDs))
+	for _, id := range menuIDs {
+		menu, err := h.repo.GetMenuByID(id, branchID)
+		if err != nil {
+			return errorResponse(err.(*shared.AppError))
+		}
+		menus = append(menus, menu)
+	}
+
+	return successResponse(map[string]interface{}{
+		"menus": menus,
+	})
+}
+
+// listOptions lists the option groups of a menu
+func (h *Handler) listOptions(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	menuID, ok := data["menu_id"].(float64)
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("menu_id diperlukan"))
+	}
+
+	groups, err := h.repo.ListOptionGroups(int(menuID))
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"option_groups": groups,
+	})
+}
+
+// createOptionGroup creates a variant/modifier group for a menu
+func (h *Handler) createOptionGroup(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	menuID, ok := data["menu_id"].(float64)
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("menu_id diperlukan"))
+	}
+	name, _ := data["name"].(string)
+	if err := shared.ValidateNotEmpty(name, "Nama grup"); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	minSelect, maxSelect := 0, 1
+	if v, ok := data["min_select"].(float64); ok {
+		minSelect = int(v)
+	}
+	if v, ok := data["max_select"].(float64); ok {
+		maxSelect = int(v)
+	}
+	if minSelect < 0 || maxSelect < 1 || minSelect > maxSelect {
+		return errorResponse(shared.NewKeyedInvalidInputError("error.option_rule_invalid"))
+	}
+
+	if _, err := h.repo.GetMenuByID(int(menuID), 0); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	group, err := h.repo.CreateOptionGroup(&OptionGroup{
+		MenuID:    int(menuID),
+		Name:      shared.SanitizeInput(name),
+		MinSelect: minSelect,
+		MaxSelect: maxSelect,
+	})
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"option_group": group,
+	})
+}
+
+// deleteOptionGroup deletes an option group
+func (h *Handler) deleteOptionGroup(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	id, ok := data["id"].(float64)
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("ID diperlukan"))
+	}
+
+	if err := h.repo.DeleteOptionGroup(int(id)); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"message": "Grup opsi berhasil dihapus",
+	})
+}
+
+// createOption adds an option to a group
+func (h *Handler) createOption(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	groupID, ok := data["group_id"].(float64)
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("group_id diperlukan"))
+	}
+	name, _ := data["name"].(string)
+	if err := shared.ValidateNotEmpty(name, "Nama opsi"); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	// Price deltas may be negative, so ValidatePrice does not apply here
+	var priceDelta shared.Money
+	if v, ok := data["price_delta"]; ok {
+		delta, err := shared.MoneyFromPayload(v)
+		if err != nil {
+			return errorResponse(err.(*shared.AppError))
+		}
+		priceDelta = delta
+	}
+	isAvailable := true
+	if v, ok := data["is_available"].(bool); ok {
+		isAvailable = v
+	}
+
+	if _, err := h.repo.GetOptionGroup(int(groupID)); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	option, err := h.repo.CreateOption(&Option{
+		GroupID:     int(groupID),
+		Name:        shared.SanitizeInput(name),
+		PriceDelta:  priceDelta,
+		IsAvailable: isAvailable,
+	})
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"option": option,
+	})
+}
+
+// deleteOption deletes an option
+func (h *Handler) deleteOption(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	id, ok := data["id"].(float64)
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("ID diperlukan"))
+	}
+
+	if err := h.repo.DeleteOption(int(id)); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"message": "Opsi berhasil dihapus",
+	})
+}
+
+// quote validates selected options and prices a menu line
+func (h *Handler) quote(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	menuID, ok := data["menu_id"].(float64)
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("menu_id diperlukan"))
+	}
+	optionIDs, ok := intsFromPayload(data["option_ids"])
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("option_ids harus berupa daftar ID"))
+	}
+	quantity := 1
+	if raw, present := data["quantity"]; present {
+		v, ok := raw.(float64)
+		if !ok || v != math.Trunc(v) || v <= 0 {
+			return errorResponse(shared.NewKeyedInvalidInputError("error.quantity_invalid"))
+		}
+		quantity = int(v)
+	}
+
+	menu, err := h.repo.GetMenuByID(int(menuID), branchIDFromPayload(data))
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+	if !menu.IsAvailable {
+		return errorResponse(shared.NewKeyedInvalidInputError("error.menu_unavailable", menu.Name))
+	}
+
+	groups, err := h.repo.ListOptionGroups(menu.ID)
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	quote, appErr := buildQuote(menu, groups, optionIDs, quantity)
+	if appErr != nil {
+		return errorResponse(appErr)
+	}
+
+	return successResponse(map[string]interface{}{
+		"quote": quote,
+	})
+}
+
+// listIngredients lists ingredients with their stock in a branch
+func (h *Handler) listIngredients(payload interface{}) *shared.Response {
+	lowOnly, branchID := false, 0
+	if data, ok := payload.(map[string]interface{}); ok {
+		lowOnly, _ = data["low_only"].(bool)
+		branchID = branchIDFromPayload(data)
+	}
+
+	ingredients, err := h.repo.ListIngredients(branchID, lowOnly)
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"ingredients": ingredients,
+	})
+}
+
+// createIngredient creates a stocked ingredient
+func (h *Handler) createIngredient(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	name, _ := data["name"].(string)
+	unit, _ := data["unit"].(string)
+	stock, _ := data["stock"].(float64)
+	threshold, _ := data["low_stock_threshold"].(float64)
+
+	if err := shared.ValidateNotEmpty(name, "Nama bahan"); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+	if err := shared.ValidateNotEmpty(unit, "Satuan"); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+	if stock < 0 || threshold < 0 {
+		return errorResponse(shared.NewKeyedInvalidInputError("error.stock_negative"))
+	}
+	branchID := branchIDFromPayload(data)
+	if stock != 0 && branchID == 0 {
+		return errorResponse(shared.NewKeyedInvalidInputError("error.stock_branch_required"))
+	}
+
+	ingredient, err := h.repo.CreateIngredient(&Ingredient{
+		BranchID:          branchID,
+		Name:              shared.SanitizeInput(name),
+		Unit:              shared.SanitizeInput(unit),
+		Stock:             stock,
+		LowStockThreshold: threshold,
+	})
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"ingredient": ingredient,
+	})
+}
+
+// updateIngredient updates an ingredient's name, unit or threshold
+func (h *Handler) updateIngredient(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	id, ok := data["id"].(float64)
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("ID diperlukan"))
+	}
+
+	ingredient, err := h.repo.GetIngredient(int(id), branchIDFromPayload(data))
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	if name, ok := data["name"].(string); ok && name != "" {
+		ingredient.Name = shared.SanitizeInput(name)
+	}
+	if unit, ok := data["unit"].(string); ok && unit != "" {
+		ingredient.Unit = shared.SanitizeInput(unit)
+	}
+	if threshold, ok := data["low_stock_threshold"].(float64); ok {
+		if threshold < 0 {
+			return errorResponse(shared.NewKeyedInvalidInputError("error.threshold_negative"))
+		}
+		ingredient.LowStockThreshold = threshold
+	}
+
+	if err := h.repo.UpdateIngredient(ingredient); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	updated, err := h.repo.GetIngredient(ingredient.ID, ingredient.BranchID)
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"ingredient": updated,
+	})
+}
+
+// deleteIngredient deletes an ingredient and drops it from recipes
+func (h *Handler) deleteIngredient(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	id, ok := data["id"].(float64)
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("ID diperlukan"))
+	}
+
+	if err := h.repo.DeleteIngredient(int(id)); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	changes, err := h.repo.SyncAvailability()
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"message":              "Bahan berhasil dihapus",
+		"availability_changes": changes,
+	})
+}
+
+// adjustStock records a manual stock change in a branch such as a delivery
+// or waste
+func (h *Handler) adjustStock(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	ingredientID, ok := data["ingredient_id"].(float64)
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("ingredient_id diperlukan"))
+	}
+	branchID := branchIDFromPayload(data)
+	if branchID == 0 {
+		return errorResponse(shared.NewKeyedInvalidInputError("error.stock_branch_required"))
+	}
+	change, ok := data["change"].(float64)
+	if !ok || change == 0 {
+		return errorResponse(shared.NewKeyedInvalidInputError("error.stock_change_zero"))
+	}
+	reason, _ := data["reason"].(string)
+	if err := shared.ValidateNotEmpty(reason, "Alasan"); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+	if reason == ReasonOrder || reason == ReasonInitial {
+		return errorResponse(shared.NewKeyedInvalidInputError("error.stock_reason_reserved"))
+	}
+
+	ingredient, err := h.repo.AdjustStock(int(ingredientID), branchID, change, shared.SanitizeInput(reason))
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	changes, err := h.repo.SyncAvailability()
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"ingredient":           ingredient,
+		"availability_changes": changes,
+	})
+}
+
+// listStockMovements lists the stock history, optionally of one ingredient
+// and one branch
+func (h *Handler) listStockMovements(payload interface{}) *shared.Response {
+	ingredientID, branchID, limit := 0, 0, 20
+	if data, ok := payload.(map[string]interface{}); ok {
+		if v, ok := data["ingredient_id"].(float64); ok {
+			ingredientID = int(v)
+		}
+		branchID = branchIDFromPayload(data)
+		if v, ok := data["limit"].(float64); ok && v > 0 {
+			limit = int(v)
+		}
+	}
+
+	movements, err := h.repo.ListStockMovements(ingredientID, branchID, limit)
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"movements": movements,
+	})
+}
+
+// getRecipe gets the recipe of a menu, or of an option when option_id is
+// given instead
+func (h *Handler) getRecipe(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	var (
+		items []RecipeItem
+		err   error
+	)
+	if optionID, ok := data["option_id"].(float64); ok {
+		items, err = h.repo.GetOptionRecipe(int(optionID))
+	} else if menuID, ok := data["menu_id"].(float64); ok {
+		items, err = h.repo.GetRecipe(int(menuID))
+	} else {
+		return errorResponse(shared.NewInvalidInputError("menu_id atau option_id diperlukan"))
+	}
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"recipe": items,
+	})
+}
+
+// setRecipe replaces the recipe of a menu, or the ingredients an option adds
+// when option_id is given instead; an empty list removes it
+func (h *Handler) setRecipe(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	optionID, isOption := data["option_id"].(float64)
+	menuID, isMenu := data["menu_id"].(float64)
+	if !isOption && !isMenu {
+		return errorResponse(shared.NewInvalidInputError("menu_id atau option_id diperlukan"))
+	}
+	rawItems, ok := data["items"].([]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("items harus berupa daftar bahan"))
+	}
+
+	if isOption {
+		if _, err := h.repo.GetOption(int(optionID)); err != nil {
+			return errorResponse(err.(*shared.AppError))
+		}
+	} else if _, err := h.repo.GetMenuByID(int(menuID), 0); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	var items []RecipeItem
+	seen := map[int]bool{}
+	for _, raw := range rawItems {
+		item, _ := raw.(map[string]interface{})
+		ingredientID, okID := item["ingredient_id"].(float64)
+		quantity, okQty := item["quantity"].(float64)
+		if !okID || !okQty || quantity <= 0 {
+			return errorResponse(shared.NewInvalidInputError("Setiap bahan membutuhkan ingredient_id dan quantity > 0"))
+		}
+		if seen[int(ingredientID)] {
+			return errorResponse(shared.NewKeyedInvalidInputError("error.recipe_duplicate"))
+		}
+		if _, err := h.repo.GetIngredient(int(ingredientID), 0); err != nil {
+			return errorResponse(err.(*shared.AppError))
+		}
+		seen[int(ingredientID)] = true
+		items = append(items, RecipeItem{IngredientID: int(ingredientID), Quantity: quantity})
+	}
+
+	var (
+		recipe []RecipeItem
+		err    error
+	)
+	if isOption {
+		if err := h.repo.SetOptionRecipe(int(optionID), items); err != nil {
+			return errorResponse(err.(*shared.AppError))
+		}
+		recipe, err = h.repo.GetOptionRecipe(int(optionID))
+	} else {
+		if err := h.repo.SetRecipe(int(menuID), items); err != nil {
+			return errorResponse(err.(*shared.AppError))
+		}
+		recipe, err = h.repo.GetRecipe(int(menuID))
+	}
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+	changes, err := h.repo.SyncAvailability()
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"recipe":               recipe,
+		"availability_changes": changes,
+	})
+}
+
+// consumeStock deducts the ingredients of completed order lines, with those
+// of their chosen options, from the stock of the order's branch
+func (h *Handler) consumeStock(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+	branchID := branchIDFromPayload(data)
+	if branchID == 0 {
+		return errorResponse(shared.NewKeyedInvalidInputError("error.stock_branch_required"))
+	}
+
+	reference, _ := data["reference"].(string)
+	if err := shared.ValidateNotEmpty(reference, "Referensi"); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+	rawItems, ok := data["items"].([]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("items harus berupa daftar menu"))
+	}
+
+	var usages []StockUsage
+	for _, raw := range rawItems {
+		item, _ := raw.(map[string]interface{})
+		menuID, okID := item["menu_id"].(float64)
+		quantity, okQty := item["quantity"].(float64)
+		if !okID || !okQty || quantity <= 0 || quantity != math.Trunc(quantity) {
+			return errorResponse(shared.NewInvalidInputError("Setiap item membutuhkan menu_id dan quantity bulat > 0"))
+		}
+		optionIDs, ok := intsFromPayload(item["option_ids"])
+		if !ok {
+			return errorResponse(shared.NewInvalidInputError("option_ids harus berupa daftar ID"))
+		}
+		usages = append(usages, StockUsage{MenuID: int(menuID), OptionIDs: optionIDs, Quantity: int(quantity)})
+	}
+
+	consumed, err := h.repo.ConsumeStock(branchID, usages, reference)
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	changes, err := h.repo.SyncAvailability()
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+	lowStock, err := h.repo.ListIngredients(branchID, true)
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"consumed":             consumed,
+		"availability_changes": changes,
+		"low_stock":            lowStock,
+	})
+}
+
+// Helper functions
+
+// branchIDFromPayload reads the optional branch_id field. 0 means no branch,
+// i.e. the menu's own price and availability are used.
+func branchIDFromPayload(data map[string]interface{}) int {
+	if id, ok := data["branch_id"].(float64); ok && id > 0 {
+		return int(id)
+	}
+	return 0
+}
+
+func successResponse(data interface{}) *shared.Response {
+	return &shared.Response{
+		Success: true,
+		Data:    data,
+	}
+}
+
+// eventData is the payload of an action without imported files, e.g. the
+// id of a deleted menu, plus the id and name of the menu or category the
+// action created or deleted
+func eventData(payload interface{}, response *shared.Response) map[string]interface{} {
+	data, _ := payload.(map[string]interface{})
+	result := make(map[string]interface{}, len(data)+2)
+	for key, value := range data {
+		if key != "content" {
+			result[key] = value
+		}
+	}
+
+	answer, _ := response.Data.(map[string]interface{})
+	if menu, ok := answer["menu"].(*Menu); ok && menu != nil {
+		result["id"] = menu.ID
+	}
+	if category, ok := answer["category"].(*Category); ok && category != nil {
+		result["id"] = category.ID
+		result["name"] = category.Name
 	}
+	return result
 }
 
 func errorResponse(err *shared.AppError) *shared.Response {
 	return &shared.Response{
 		Success: false,
-		Error: &shared.ErrorInfo{
-			Code:    err.Code,
-			Message: err.Message,
-		},
+		Error:   err.Info(),
 	}
 }
 
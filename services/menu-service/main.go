@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/alrescha79-cmd/bot-cafe/shared"
 	"github.com/joho/godotenv"
@@ -13,6 +15,8 @@ import (
 func main() {
 	// Load environment variables
 	godotenv.Load()
+	shared.ConfigureLogger("menu-service")
+	shared.ConfigureTracing("menu-service")
 
 	port := os.Getenv("MENU_SERVICE_PORT")
 	if port == "" {
@@ -37,20 +41,80 @@ func main() {
 		log.Fatalf("Failed to initialize schema: %v", err)
 	}
 
+	// Changes are announced to other services through the outbox
+	events, err := shared.NewOutbox(db, "menu-service")
+	if err != nil {
+		log.Fatalf("Failed to initialize outbox: %v", err)
+	}
+	go events.Run()
+
+	// Bring sold out menus back once their restore time has passed
+	go restoreAvailability(repo, events, time.Minute)
+
+	// Events are handled once, even when delivered again
+	dedup, err := shared.NewDBDeduper(db)
+	if err != nil {
+		log.Fatalf("Failed to initialize event log: %v", err)
+	}
+
 	// Initialize handler
-	handler := NewHandler(repo)
+	handler := NewHandler(repo, events)
 
 	// Setup routes
-	http.HandleFunc("/", handler.HandleRequest)
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
+	http.HandleFunc("/", shared.InstrumentRequests(handler.HandleRequest))
+	http.HandleFunc("/events", shared.HandleEvents(dedup, handler.HandleEvent))
+	shared.NewHealth("menu-service").WithDatabase(db).Register()
+	http.HandleFunc("/metrics", shared.MetricsHandler)
 
 	// Start server
 	addr := fmt.Sprintf(":%s", port)
-	shared.LogInfo("Menu service starting on %s", addr)
+	shared.Logger(context.Background()).Info("service starting", "addr", addr)
 	if err := http.ListenAndServe(addr, nil); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// restoreAvailability periodically re-enables sold out menus whose
+// available_again_at has passed
+func restoreAvailability(repo *Repository, events *shared.Outbox, interval time.Duration) {
+	ctx := shared.WithLogFields(context.Background(), "job", "restore_availability")
+	repo = repo.WithContext(ctx)
+	for {
+		if err := restoreDueAvailability(ctx, repo, events); err != nil {
+			shared.Logger(ctx).Error("failed to restore menu availability", "error", err)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// restoreDueAvailability re-enables the menus due and stores the event
+// announcing them in the same transaction
+func restoreDueAvailability(ctx context.Context, repo *Repository, events *shared.Outbox) error {
+	tx, err := repo.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	changes, err := repo.WithTx(tx).RestoreDueAvailability(time.Now())
+	if err != nil || len(changes) == 0 {
+		return err
+	}
+	// A restored menu may still be missing ingredients
+	if _, err := repo.WithTx(tx).SyncAvailability(); err != nil {
+		return err
+	}
+	data := map[string]interface{}{"changes": changes}
+	if err := events.Publish(ctx, tx, "menu.availability_changed", data); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	events.Wake()
+
+	for _, change := range changes {
+		shared.Logger(ctx).Info("menu available again", "menu_id", change.MenuID, "menu", change.MenuName, "branch_id", change.BranchID)
+	}
+	return nil
+}
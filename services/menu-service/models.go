@@ -1,18 +1,51 @@
 package main
 
-import "time"
+import (
+	"time"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+)
 
 // Menu represents a menu item
 type Menu struct {
-	ID          int       `json:"id"`
-	Name        string    `json:"name"`
-	Description string    `json:"description"`
-	Price       int       `json:"price"`
-	Category    string    `json:"category"`
-	PhotoURL    string    `json:"photo_url,omitempty"`
-	IsAvailable bool      `json:"is_available"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          int          `json:"id"`
+	SKU         string       `json:"sku,omitempty"` // optional code, unique when set
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Price       shared.Money `json:"price"`
+	Category    string       `json:"category"`
+	PhotoURL    string       `json:"photo_url,omitempty"`
+	IsAvailable bool         `json:"is_available"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+
+	// HasBranchOverride is set when Price/IsAvailable come from a branch override
+	HasBranchOverride bool `json:"has_branch_override,omitempty"`
+
+	// OutOfStock is set while the branch has run out of an ingredient of the
+	// menu's recipe. Such a menu is unavailable in that branch, whatever
+	// IsAvailable was set to by hand, until the stock is back.
+	OutOfStock bool `json:"out_of_stock,omitempty"`
+
+	// availableByHand is IsAvailable before stock is taken into account
+	availableByHand bool
+
+	// AvailableAgainAt is when a sold out menu becomes available again on its
+	// own, as set through set_availability
+	AvailableAgainAt *time.Time `json:"available_again_at,omitempty"`
+}
+
+// translatableFields are the menu fields that set_translation translates
+var translatableFields = []string{"name", "description"}
+
+// BranchOverride holds per-branch price and availability of a menu.
+// Nil fields fall back to the values stored on the menu itself.
+type BranchOverride struct {
+	MenuID      int           `json:"menu_id"`
+	BranchID    int           `json:"branch_id"`
+	Price       *shared.Money `json:"price"`
+	IsAvailable *bool         `json:"is_available"`
+	UpdatedAt   time.Time     `json:"updated_at"`
 }
 
 // Category represents a menu category
@@ -21,3 +54,131 @@ type Category struct {
 	Name      string    `json:"name"`
 	CreatedAt time.Time `json:"created_at"`
 }
+
+// OptionGroup is a set of choices for a menu, e.g. "Ukuran" (pick exactly
+// one) or "Topping" (pick up to three)
+type OptionGroup struct {
+	ID         int       `json:"id"`
+	MenuID     int       `json:"menu_id"`
+	Name       string    `json:"name"`
+	MinSelect  int       `json:"min_select"`
+	MaxSelect  int       `json:"max_select"`
+	IsRequired bool      `json:"is_required"`
+	SortOrder  int       `json:"sort_order"`
+	Options    []Option  `json:"options"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Option is a single choice inside an option group
+type Option struct {
+	ID          int          `json:"id"`
+	GroupID     int          `json:"group_id"`
+	Name        string       `json:"name"`
+	PriceDelta  shared.Money `json:"price_delta"` // added to the menu price, may be negative
+	IsAvailable bool         `json:"is_available"`
+	SortOrder   int          `json:"sort_order"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+// QuotedOption is a selected option as priced in a quote
+type QuotedOption struct {
+	ID         int          `json:"id"`
+	GroupName  string       `json:"group_name"`
+	Name       string       `json:"name"`
+	PriceDelta shared.Money `json:"price_delta"`
+}
+
+// Quote is the validated price of a menu with selected options
+type Quote struct {
+	MenuID    int            `json:"menu_id"`
+	MenuName  string         `json:"menu_name"`
+	BasePrice shared.Money   `json:"base_price"`
+	Options   []QuotedOption `json:"options"`
+	UnitPrice shared.Money   `json:"unit_price"`
+	Quantity  int            `json:"quantity"`
+	Total     shared.Money   `json:"total"`
+}
+
+// Ingredient is a stocked raw material used by menu recipes. Stock is
+// counted per branch; Stock and IsLow are those of BranchID.
+type Ingredient struct {
+	ID                int       `json:"id"`
+	BranchID          int       `json:"branch_id"`
+	Name              string    `json:"name"`
+	Unit              string    `json:"unit"` // e.g. "gr", "ml", "pcs"
+	Stock             float64   `json:"stock"`
+	LowStockThreshold float64   `json:"low_stock_threshold"`
+	IsLow             bool      `json:"is_low"`
+	CreatedAt         time.Time `json:"created_at"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+// RecipeItem is the amount of an ingredient used by one serving of a menu,
+// or added to it by an option
+type RecipeItem struct {
+	MenuID         int     `json:"menu_id,omitempty"`
+	OptionID       int     `json:"option_id,omitempty"`
+	IngredientID   int     `json:"ingredient_id"`
+	IngredientName string  `json:"ingredient_name"`
+	Unit           string  `json:"unit"`
+	Quantity       float64 `json:"quantity"`
+}
+
+// StockMovement records a single change to an ingredient's stock in a branch
+type StockMovement struct {
+	ID           int       `json:"id"`
+	IngredientID int       `json:"ingredient_id"`
+	BranchID     int       `json:"branch_id"`
+	Change       float64   `json:"change"`
+	StockAfter   float64   `json:"stock_after"`
+	Reason       string    `json:"reason"`
+	Reference    string    `json:"reference,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// StockUsage is a number of servings of a menu, made with the chosen
+// options, to deduct from stock
+type StockUsage struct {
+	MenuID    int
+	OptionIDs []int
+	Quantity  int
+}
+
+// Stock movement reasons set by the service itself
+const (
+	ReasonInitial = "initial"
+	ReasonOrder   = "order"
+)
+
+// AvailabilityChange reports a menu switched on or off automatically, either
+// by stock levels or by a scheduled restore. BranchID is set when the change
+// applies to a branch override only.
+type AvailabilityChange struct {
+	MenuID      int    `json:"menu_id"`
+	MenuName    string `json:"menu_name"`
+	BranchID    int    `json:"branch_id,omitempty"`
+	IsAvailable bool   `json:"is_available"`
+}
+
+// ImportRowError is a problem with one row of an imported file. Row is the
+// spreadsheet row number, the header being row 1. Key and Params name the
+// catalogue text of Message, as in shared.ErrorInfo.
+type ImportRowError struct {
+	Row     int      `json:"row"`
+	Field   string   `json:"field,omitempty"`
+	Message string   `json:"message"`
+	Key     string   `json:"key,omitempty"`
+	Params  []string `json:"params,omitempty"`
+}
+
+// ImportResult summarises an import. Nothing is written when there are
+// errors or when DryRun is set.
+type ImportResult struct {
+	DryRun        bool             `json:"dry_run"`
+	Applied       bool             `json:"applied"`
+	Rows          int              `json:"rows"`
+	Created       int              `json:"created"`
+	Updated       int              `json:"updated"`
+	NewCategories []string         `json:"new_categories"`
+	Errors        []ImportRowError `json:"errors"`
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+)
+
+// buildQuote validates the selected options against the menu's option groups
+// and prices one line. menu must already carry the branch price.
+func buildQuote(menu *Menu, groups []OptionGroup, optionIDs []int, quantity int) (*Quote, *shared.AppError) {
+	selected := make(map[int]bool, len(optionIDs))
+	for _, id := range optionIDs {
+		if selected[id] {
+			return nil, shared.NewKeyedInvalidInputError("error.option_repeated")
+		}
+		selected[id] = true
+	}
+
+	quote := &Quote{
+		MenuID:    menu.ID,
+		MenuName:  menu.Name,
+		BasePrice: menu.Price,
+		Options:   []QuotedOption{},
+		Quantity:  quantity,
+	}
+
+	unitPrice := menu.Price
+	matched := 0
+	for _, group := range groups {
+		count := 0
+		for _, option := range group.Options {
+			if !selected[option.ID] {
+				continue
+			}
+			if !option.IsAvailable {
+				return nil, shared.NewKeyedInvalidInputError("error.option_unavailable", option.Name)
+			}
+			count++
+			unitPrice = unitPrice.Add(option.PriceDelta)
+			quote.Options = append(quote.Options, QuotedOption{
+				ID:         option.ID,
+				GroupName:  group.Name,
+				Name:       option.Name,
+				PriceDelta: option.PriceDelta,
+			})
+		}
+		matched += count
+
+		if count < group.MinSelect {
+			return nil, shared.NewKeyedInvalidInputError("error.option_min", strconv.Itoa(group.MinSelect), group.Name)
+		}
+		if group.MaxSelect > 0 && count > group.MaxSelect {
+			return nil, shared.NewKeyedInvalidInputError("error.option_max", strconv.Itoa(group.MaxSelect), group.Name)
+		}
+	}
+
+	if matched != len(selected) {
+		return nil, shared.NewKeyedInvalidInputError("error.option_invalid")
+	}
+
+	// Negative deltas (e.g. "tanpa susu") never make an item free of charge
+	if unitPrice < 0 {
+		unitPrice = 0
+	}
+	quote.UnitPrice = unitPrice
+	quote.Total = unitPrice.Mul(quantity)
+	return quote, nil
+}
+
+// intsFromPayload reads a JSON array of whole numbers
+func intsFromPayload(raw interface{}) ([]int, bool) {
+	if raw == nil {
+		return []int{}, true
+	}
+	items, ok := raw.([]interface{})
+	if !ok {
+		return nil, false
+	}
+
+	result := make([]int, 0, len(items))
+	for _, item := range items {
+		v, ok := item.(float64)
+		if !ok || v != math.Trunc(v) {
+			return nil, false
+		}
+		result = append(result, int(v))
+	}
+	return result, true
+}
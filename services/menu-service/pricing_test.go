@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+)
+
+func TestBuildQuote(t *testing.T) {
+	menu := &Menu{ID: 1, Name: "Kopi Susu", Price: 20000}
+	groups := []OptionGroup{
+		{Name: "Ukuran", MinSelect: 1, MaxSelect: 1, Options: []Option{
+			{ID: 1, Name: "Regular", IsAvailable: true},
+			{ID: 2, Name: "Large", PriceDelta: 5000, IsAvailable: true},
+		}},
+		{Name: "Topping", MaxSelect: 2, Options: []Option{
+			{ID: 3, Name: "Boba", PriceDelta: 4000, IsAvailable: true},
+			{ID: 4, Name: "Jelly", PriceDelta: 3000, IsAvailable: true},
+			{ID: 5, Name: "Keju", PriceDelta: 6000, IsAvailable: true},
+			{ID: 6, Name: "Cincau", PriceDelta: 2000},
+		}},
+		{Name: "Susu", Options: []Option{
+			{ID: 7, Name: "Tanpa susu", PriceDelta: -30000, IsAvailable: true},
+		}},
+	}
+
+	tests := []struct {
+		name      string
+		optionIDs []int
+		quantity  int
+		wantUnit  shared.Money
+		wantTotal shared.Money
+		wantKey   string
+	}{
+		{"required option", []int{1}, 1, 20000, 20000, ""},
+		{"deltas added", []int{2, 3, 4}, 2, 32000, 64000, ""},
+		{"below min", []int{3}, 1, 0, 0, "error.option_min"},
+		{"above max", []int{1, 3, 4, 5}, 1, 0, 0, "error.option_max"},
+		{"above max of single choice", []int{1, 2}, 1, 0, 0, "error.option_max"},
+		{"repeated option", []int{1, 3, 3}, 1, 0, 0, "error.option_repeated"},
+		{"unknown option", []int{1, 99}, 1, 0, 0, "error.option_invalid"},
+		{"unavailable option", []int{1, 6}, 1, 0, 0, "error.option_unavailable"},
+		{"negative delta floored at zero", []int{1, 7}, 3, 0, 0, ""},
+		{"negative delta offset by others", []int{2, 3, 7}, 1, 0, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quote, err := buildQuote(menu, groups, tt.optionIDs, tt.quantity)
+			if tt.wantKey != "" {
+				if err == nil || err.Key != tt.wantKey {
+					t.Fatalf("buildQuote() error = %v, want %s", err, tt.wantKey)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("buildQuote() error = %v", err)
+			}
+			if quote.UnitPrice != tt.wantUnit || quote.Total != tt.wantTotal {
+				t.Errorf("unit %d, total %d; want %d, %d", quote.UnitPrice, quote.Total, tt.wantUnit, tt.wantTotal)
+			}
+			if len(quote.Options) != len(tt.optionIDs) || quote.Quantity != tt.quantity {
+				t.Errorf("quote has %d options and quantity %d", len(quote.Options), quote.Quantity)
+			}
+		})
+	}
+}
+
+func TestIntsFromPayload(t *testing.T) {
+	tests := []struct {
+		raw    interface{}
+		want   []int
+		wantOK bool
+	}{
+		{nil, []int{}, true},
+		{[]interface{}{}, []int{}, true},
+		{[]interface{}{1.0, 2.0}, []int{1, 2}, true},
+		{[]interface{}{1.5}, nil, false},
+		{[]interface{}{"1"}, nil, false},
+		{"1,2", nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := intsFromPayload(tt.raw)
+		if ok != tt.wantOK || fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("intsFromPayload(%v) = %v, %v; want %v, %v", tt.raw, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/alrescha79-cmd/bot-cafe/shared"
@@ -9,15 +12,32 @@ import (
 
 // Repository handles database operations
 type Repository struct {
-	db *sql.DB
+	shared.Store
 }
 
 // NewRepository creates a new repository
-
 func NewRepository(db *sql.DB) *Repository {
-	return &Repository{db: db}
+	return &Repository{shared.NewStore(db)}
+}
+
+// WithContext returns the repository running its queries with ctx
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{r.Store.WithContext(ctx)}
+}
+
+// WithTx returns the repository running its queries in tx
+func (r *Repository) WithTx(tx *sql.Tx) *Repository {
+	return &Repository{r.Store.WithTx(tx)}
 }
 
+// schemaVersion is recorded in the database by InitSchema and reported by
+// /ready; bump it with every schema change
+const schemaVersion = 7
+
+// legacyStockBranch receives the stock kept before stock was counted per
+// branch: branch 1 is the default branch, where single-branch cafes order
+const legacyStockBranch = 1
+
 // InitSchema initializes database schema
 func (r *Repository) InitSchema() error {
 	schema := `
@@ -43,22 +63,221 @@ func (r *Repository) InitSchema() error {
 	CREATE INDEX IF NOT EXISTS idx_category ON menus(category);
 	CREATE INDEX IF NOT EXISTS idx_available ON menus(is_available);
 
+	CREATE TABLE IF NOT EXISTS menu_branch_overrides (
+		menu_id INTEGER NOT NULL,
+		branch_id INTEGER NOT NULL,
+		price INTEGER,
+		is_available BOOLEAN,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (menu_id, branch_id),
+		FOREIGN KEY (menu_id) REFERENCES menus(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS option_groups (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		menu_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		min_select INTEGER NOT NULL DEFAULT 0,
+		max_select INTEGER NOT NULL DEFAULT 1,
+		sort_order INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (menu_id) REFERENCES menus(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS options (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		group_id INTEGER NOT NULL,
+		name TEXT NOT NULL,
+		price_delta INTEGER NOT NULL DEFAULT 0,
+		is_available BOOLEAN DEFAULT 1,
+		sort_order INTEGER NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (group_id) REFERENCES option_groups(id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_option_groups_menu ON option_groups(menu_id);
+	CREATE INDEX IF NOT EXISTS idx_options_group ON options(group_id);
+
+	CREATE TABLE IF NOT EXISTS ingredients (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT UNIQUE NOT NULL,
+		unit TEXT NOT NULL,
+		stock REAL NOT NULL DEFAULT 0,
+		low_stock_threshold REAL NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS recipes (
+		menu_id INTEGER NOT NULL,
+		ingredient_id INTEGER NOT NULL,
+		quantity REAL NOT NULL,
+		PRIMARY KEY (menu_id, ingredient_id),
+		FOREIGN KEY (menu_id) REFERENCES menus(id),
+		FOREIGN KEY (ingredient_id) REFERENCES ingredients(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS ingredient_stock (
+		ingredient_id INTEGER NOT NULL,
+		branch_id INTEGER NOT NULL,
+		stock REAL NOT NULL DEFAULT 0,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (ingredient_id, branch_id),
+		FOREIGN KEY (ingredient_id) REFERENCES ingredients(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS option_recipes (
+		option_id INTEGER NOT NULL,
+		ingredient_id INTEGER NOT NULL,
+		quantity REAL NOT NULL,
+		PRIMARY KEY (option_id, ingredient_id),
+		FOREIGN KEY (option_id) REFERENCES options(id),
+		FOREIGN KEY (ingredient_id) REFERENCES ingredients(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS menu_stock_outs (
+		menu_id INTEGER NOT NULL,
+		branch_id INTEGER NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (menu_id, branch_id),
+		FOREIGN KEY (menu_id) REFERENCES menus(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS stock_movements (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		ingredient_id INTEGER NOT NULL,
+		change REAL NOT NULL,
+		stock_after REAL NOT NULL,
+		reason TEXT NOT NULL,
+		reference TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (ingredient_id) REFERENCES ingredients(id)
+	);
+
+	CREATE TABLE IF NOT EXISTS menu_translations (
+		menu_id INTEGER NOT NULL,
+		locale TEXT NOT NULL,
+		field TEXT NOT NULL,
+		value TEXT NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (menu_id, locale, field),
+		FOREIGN KEY (menu_id) REFERENCES menus(id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_recipes_ingredient ON recipes(ingredient_id);
+	CREATE INDEX IF NOT EXISTS idx_option_recipes_ingredient ON option_recipes(ingredient_id);
+	CREATE INDEX IF NOT EXISTS idx_movements_ingredient ON stock_movements(ingredient_id);
+	CREATE INDEX IF NOT EXISTS idx_movements_reference ON stock_movements(reference);
+
 	-- Insert default categories
 	INSERT OR IGNORE INTO categories (name) VALUES ('Makanan');
 	INSERT OR IGNORE INTO categories (name) VALUES ('Minuman');
 	INSERT OR IGNORE INTO categories (name) VALUES ('Snack');
 	INSERT OR IGNORE INTO categories (name) VALUES ('Coffee');
 	`
-	return shared.ExecuteSchema(r.db, schema)
+	if err := shared.ExecuteSchema(r.DB, schema); err != nil {
+		return err
+	}
+	if err := shared.AddColumnIfNotExists(r.DB, "menus", "auto_unavailable", "BOOLEAN DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := shared.AddColumnIfNotExists(r.DB, "menus", "available_again_at", "DATETIME"); err != nil {
+		return err
+	}
+	if err := shared.AddColumnIfNotExists(r.DB, "menus", "sku", "TEXT NOT NULL DEFAULT ''"); err != nil {
+		return err
+	}
+	if _, err := r.Q.ExecContext(r.Ctx, `CREATE UNIQUE INDEX IF NOT EXISTS idx_menus_sku ON menus(sku) WHERE sku != ''`); err != nil {
+		return err
+	}
+	if err := shared.AddColumnIfNotExists(r.DB, "menu_branch_overrides", "available_again_at", "DATETIME"); err != nil {
+		return err
+	}
+	// menus.out_of_stock and ingredients.stock are only read by migrate;
+	// stock is kept per branch in ingredient_stock and menu_stock_outs
+	if err := shared.AddColumnIfNotExists(r.DB, "menus", "out_of_stock", "BOOLEAN NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := shared.AddColumnIfNotExists(r.DB, "stock_movements", "branch_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if _, err := r.Q.ExecContext(r.Ctx, `CREATE INDEX IF NOT EXISTS idx_movements_branch ON stock_movements(branch_id, ingredient_id)`); err != nil {
+		return err
+	}
+
+	version, err := shared.SchemaVersion(r.Ctx, r.DB)
+	if err != nil {
+		return err
+	}
+	if err := r.migrate(version); err != nil {
+		return err
+	}
+	return shared.SetSchemaVersion(r.DB, schemaVersion)
+}
+
+// migrate moves the data of a database last initialised at version to the
+// current schema. Each step runs once, as the version is recorded after it.
+func (r *Repository) migrate(version int) error {
+	tx, err := r.DB.BeginTx(r.Ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if version < 6 {
+		// Running out of stock used to switch is_available off, which
+		// branch overrides could hide; it is a separate flag now
+		if _, err := tx.ExecContext(r.Ctx, `UPDATE menus SET out_of_stock = 1, is_available = 1, auto_unavailable = 0
+			  WHERE auto_unavailable = 1`); err != nil {
+			return err
+		}
+	}
+	if version < 7 {
+		// Stock and the out of stock flag used to be shared by every branch
+		for _, query := range []string{
+			`INSERT OR IGNORE INTO ingredient_stock (ingredient_id, branch_id, stock) SELECT id, ?, stock FROM ingredients`,
+			`UPDATE stock_movements SET branch_id = ? WHERE branch_id = 0`,
+			`INSERT OR IGNORE INTO menu_stock_outs (menu_id, branch_id) SELECT id, ? FROM menus WHERE out_of_stock = 1`,
+		} {
+			if _, err := tx.ExecContext(r.Ctx, query, legacyStockBranch); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(r.Ctx, `UPDATE menus SET out_of_stock = 0`); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+const insertMenuQuery = `INSERT INTO menus (sku, name, description, price, category, photo_url, is_available) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+// updateMenuQuery updates a menu. Changing availability by hand cancels a
+// scheduled restore.
+const updateMenuQuery = `UPDATE menus SET sku = ?, name = ?, description = ?, price = ?, category = ?, photo_url = ?,
+			  available_again_at = CASE WHEN is_available = ? THEN available_again_at ELSE NULL END,
+			  is_available = ?, updated_at = CURRENT_TIMESTAMP 
+			  WHERE id = ?`
+
+func updateMenuArgs(menu *Menu) []interface{} {
+	return []interface{}{menu.SKU, menu.Name, menu.Description, menu.Price, menu.Category,
+		menu.PhotoURL, menu.IsAvailable, menu.IsAvailable, menu.ID}
+}
+
+// menuWriteError maps a failed menu insert or update to an AppError
+func menuWriteError(err error) error {
+	if strings.Contains(err.Error(), "UNIQUE") {
+		return shared.NewKeyedError(shared.ErrCodeDuplicateEntry, "error.duplicate_sku", err)
+	}
+	return shared.NewDatabaseError(err)
 }
 
 // CreateMenu creates a new menu
 func (r *Repository) CreateMenu(menu *Menu) (*Menu, error) {
-	query := `INSERT INTO menus (name, description, price, category, photo_url, is_available) 
-			  VALUES (?, ?, ?, ?, ?, ?)`
-	result, err := r.db.Exec(query, menu.Name, menu.Description, menu.Price, menu.Category, menu.PhotoURL, menu.IsAvailable)
+	result, err := r.Q.ExecContext(r.Ctx, insertMenuQuery, menu.SKU, menu.Name, menu.Description, menu.Price, menu.Category, menu.PhotoURL, menu.IsAvailable)
 	if err != nil {
-		return nil, shared.NewDatabaseError(err)
+		return nil, menuWriteError(err)
 	}
 
 	id, _ := result.LastInsertId()
@@ -68,68 +287,120 @@ func (r *Repository) CreateMenu(menu *Menu) (*Menu, error) {
 	return menu, nil
 }
 
-// GetMenuByID gets menu by ID
-func (r *Repository) GetMenuByID(id int) (*Menu, error) {
-	query := `SELECT id, name, description, price, category, photo_url, is_available, created_at, updated_at 
-			  FROM menus WHERE id = ?`
-	var menu Menu
-	err := r.db.QueryRow(query, id).Scan(
-		&menu.ID, &menu.Name, &menu.Description, &menu.Price, &menu.Category,
-		&menu.PhotoURL, &menu.IsAvailable, &menu.CreatedAt, &menu.UpdatedAt,
+// availableColumn is whether a menu can be ordered: available by hand, in the
+// branch override when there is one, and not out of stock in the branch.
+// Running out of stock beats any override.
+const availableColumn = `(COALESCE(o.is_available, m.is_available) AND so.menu_id IS NULL)`
+
+// menuColumns selects a menu with its branch override and stock applied. The
+// joins are bound to a branch ID; branch 0 never matches so base values are used.
+const menuColumns = `m.id, m.sku, m.name, m.description, COALESCE(o.price, m.price), m.category, m.photo_url,
+			  ` + availableColumn + `, m.created_at, m.updated_at, o.menu_id IS NOT NULL,
+			  so.menu_id IS NOT NULL, COALESCE(o.is_available, m.is_available), o.is_available IS NOT NULL,
+			  o.available_again_at, m.available_again_at
+			  FROM menus m CROSS JOIN (SELECT ? AS id) b
+			  LEFT JOIN menu_branch_overrides o ON o.menu_id = m.id AND o.branch_id = b.id
+			  LEFT JOIN menu_stock_outs so ON so.menu_id = m.id AND so.branch_id = b.id`
+
+// scanMenu scans a row selected with menuColumns. The restore time follows
+// whichever availability is in effect: the branch override's when it sets one,
+// otherwise the menu's own.
+func scanMenu(row interface{ Scan(...interface{}) error }) (*Menu, error) {
+	var (
+		menu                       Menu
+		branchAvailability         bool
+		branchAgainAt, menuAgainAt sql.NullTime
 	)
+	err := row.Scan(&menu.ID, &menu.SKU, &menu.Name, &menu.Description, &menu.Price, &menu.Category,
+		&menu.PhotoURL, &menu.IsAvailable, &menu.CreatedAt, &menu.UpdatedAt, &menu.HasBranchOverride,
+		&menu.OutOfStock, &menu.availableByHand, &branchAvailability, &branchAgainAt, &menuAgainAt)
+	if err != nil {
+		return nil, err
+	}
+	againAt := menuAgainAt
+	if branchAvailability {
+		againAt = branchAgainAt
+	}
+	if againAt.Valid {
+		menu.AvailableAgainAt = &againAt.Time
+	}
+	return &menu, nil
+}
+
+// GetMenuByID gets menu by ID, with the override of branchID applied
+func (r *Repository) GetMenuByID(id, branchID int) (*Menu, error) {
+	query := `SELECT ` + menuColumns + ` WHERE m.id = ?`
+	menu, err := scanMenu(r.Q.QueryRowContext(r.Ctx, query, branchID, id))
 	if err == sql.ErrNoRows {
 		return nil, shared.NewNotFoundError("Menu")
 	}
 	if err != nil {
 		return nil, shared.NewDatabaseError(err)
 	}
-	return &menu, nil
+	return menu, nil
 }
 
-// ListMenus lists all menus with optional filters
-func (r *Repository) ListMenus(category string, availableOnly bool) ([]Menu, error) {
-	query := `SELECT id, name, description, price, category, photo_url, is_available, created_at, updated_at 
-			  FROM menus WHERE 1=1`
-	args := []interface{}{}
+// ListMenus lists the menus on page with optional filters, with the override
+// of branchID applied, and the number of menus matching the filters
+func (r *Repository) ListMenus(category string, availableOnly bool, branchID int, page shared.Page) ([]Menu, int, error) {
+	query := `SELECT ` + menuColumns + ` WHERE 1=1`
+	args := []interface{}{branchID}
 
 	if category != "" {
-		query += ` AND category = ?`
+		query += ` AND m.category = ?`
 		args = append(args, category)
 	}
 
 	if availableOnly {
-		query += ` AND is_available = 1`
+		query += ` AND ` + availableColumn
 	}
 
-	query += ` ORDER BY category, name`
+	query += ` ORDER BY m.category, m.name, m.id`
 
-	rows, err := r.db.Query(query, args...)
+	total, err := r.countRows(query, args, page)
 	if err != nil {
-		return nil, shared.NewDatabaseError(err)
+		return nil, 0, err
+	}
+
+	query, args = page.Apply(query, args)
+	rows, err := r.Q.QueryContext(r.Ctx, query, args...)
+	if err != nil {
+		return nil, 0, shared.NewDatabaseError(err)
 	}
 	defer rows.Close()
 
 	var menus []Menu
 	for rows.Next() {
-		var menu Menu
-		if err := rows.Scan(&menu.ID, &menu.Name, &menu.Description, &menu.Price, &menu.Category,
-			&menu.PhotoURL, &menu.IsAvailable, &menu.CreatedAt, &menu.UpdatedAt); err != nil {
-			return nil, shared.NewDatabaseError(err)
+		menu, err := scanMenu(rows)
+		if err != nil {
+			return nil, 0, shared.NewDatabaseError(err)
 		}
-		menus = append(menus, menu)
+		menus = append(menus, *menu)
 	}
-	return menus, nil
+	if page.Limit == 0 {
+		total = len(menus)
+	}
+	return menus, total, nil
+}
+
+// countRows counts the rows query selects when only a page of them is
+// read; for a whole list the caller counts what it read
+func (r *Repository) countRows(query string, args []interface{}, page shared.Page) (int, error) {
+	if page.Limit == 0 {
+		return 0, nil
+	}
+	var total int
+	if err := r.Q.QueryRowContext(r.Ctx, shared.CountQuery(query), args...).Scan(&total); err != nil {
+		return 0, shared.NewDatabaseError(err)
+	}
+	return total, nil
 }
 
 // UpdateMenu updates a menu
 func (r *Repository) UpdateMenu(menu *Menu) error {
-	query := `UPDATE menus SET name = ?, description = ?, price = ?, category = ?, 
-			  photo_url = ?, is_available = ?, updated_at = CURRENT_TIMESTAMP 
-			  WHERE id = ?`
-	result, err := r.db.Exec(query, menu.Name, menu.Description, menu.Price, menu.Category,
-		menu.PhotoURL, menu.IsAvailable, menu.ID)
+	result, err := r.Q.ExecContext(r.Ctx, updateMenuQuery, updateMenuArgs(menu)...)
 	if err != nil {
-		return shared.NewDatabaseError(err)
+		return menuWriteError(err)
 	}
 
 	affected, _ := result.RowsAffected()
@@ -139,10 +410,43 @@ func (r *Repository) UpdateMenu(menu *Menu) error {
 	return nil
 }
 
+// ImportMenus creates the missing categories and writes an imported catalogue
+// in a single transaction
+func (r *Repository) ImportMenus(categories []string, creates, updates []Menu) error {
+	tx, err := shared.Begin(r.Ctx, r.Q)
+	if err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	defer tx.Rollback()
+
+	for _, name := range categories {
+		if _, err := tx.ExecContext(r.Ctx, `INSERT OR IGNORE INTO categories (name) VALUES (?)`, name); err != nil {
+			return shared.NewDatabaseError(err)
+		}
+	}
+	for i := range creates {
+		menu := &creates[i]
+		if _, err := tx.ExecContext(r.Ctx, insertMenuQuery, menu.SKU, menu.Name, menu.Description, menu.Price,
+			menu.Category, menu.PhotoURL, menu.IsAvailable); err != nil {
+			return menuWriteError(err)
+		}
+	}
+	for i := range updates {
+		if _, err := tx.ExecContext(r.Ctx, updateMenuQuery, updateMenuArgs(&updates[i])...); err != nil {
+			return menuWriteError(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	return nil
+}
+
 // DeleteMenu deletes a menu
 func (r *Repository) DeleteMenu(id int) error {
 	query := `DELETE FROM menus WHERE id = ?`
-	result, err := r.db.Exec(query, id)
+	result, err := r.Q.ExecContext(r.Ctx, query, id)
 	if err != nil {
 		return shared.NewDatabaseError(err)
 	}
@@ -151,33 +455,274 @@ func (r *Repository) DeleteMenu(id int) error {
 	if affected == 0 {
 		return shared.NewNotFoundError("Menu")
 	}
+
+	if _, err := r.Q.ExecContext(r.Ctx, `DELETE FROM menu_branch_overrides WHERE menu_id = ?`, id); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	if _, err := r.Q.ExecContext(r.Ctx, `DELETE FROM option_recipes WHERE option_id IN 
+			  (SELECT o.id FROM options o JOIN option_groups g ON g.id = o.group_id WHERE g.menu_id = ?)`, id); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	if _, err := r.Q.ExecContext(r.Ctx, `DELETE FROM options WHERE group_id IN (SELECT id FROM option_groups WHERE menu_id = ?)`, id); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	if _, err := r.Q.ExecContext(r.Ctx, `DELETE FROM option_groups WHERE menu_id = ?`, id); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	if _, err := r.Q.ExecContext(r.Ctx, `DELETE FROM recipes WHERE menu_id = ?`, id); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	if _, err := r.Q.ExecContext(r.Ctx, `DELETE FROM menu_stock_outs WHERE menu_id = ?`, id); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	if _, err := r.Q.ExecContext(r.Ctx, `DELETE FROM menu_translations WHERE menu_id = ?`, id); err != nil {
+		return shared.NewDatabaseError(err)
+	}
 	return nil
 }
 
-// ListCategories lists all categories
-func (r *Repository) ListCategories() ([]Category, error) {
-	query := `SELECT id, name, created_at FROM categories ORDER BY name`
-	rows, err := r.db.Query(query)
+// GetMenuTranslations gets every translation of a menu
+func (r *Repository) GetMenuTranslations(menuID int) (shared.Translations, error) {
+	rows, err := r.Q.QueryContext(r.Ctx, `SELECT locale, field, value FROM menu_translations WHERE menu_id = ?`, menuID)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	defer rows.Close()
+
+	translations := shared.Translations{}
+	for rows.Next() {
+		var locale, field, value string
+		if err := rows.Scan(&locale, &field, &value); err != nil {
+			return nil, shared.NewDatabaseError(err)
+		}
+		translations.Set(locale, field, value)
+	}
+	return translations, nil
+}
+
+// ListMenuTranslations gets the translations of all menus in one locale, by
+// menu ID
+func (r *Repository) ListMenuTranslations(locale string) (map[int]shared.Translations, error) {
+	rows, err := r.Q.QueryContext(r.Ctx, `SELECT menu_id, field, value FROM menu_translations WHERE locale = ?`, locale)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	defer rows.Close()
+
+	translations := make(map[int]shared.Translations)
+	for rows.Next() {
+		var menuID int
+		var field, value string
+		if err := rows.Scan(&menuID, &field, &value); err != nil {
+			return nil, shared.NewDatabaseError(err)
+		}
+		if translations[menuID] == nil {
+			translations[menuID] = shared.Translations{}
+		}
+		translations[menuID].Set(locale, field, value)
+	}
+	return translations, nil
+}
+
+// SetMenuTranslation stores the translation of a menu field; an empty value
+// removes it, so the field falls back to the menu's own text
+func (r *Repository) SetMenuTranslation(menuID int, locale, field, value string) error {
+	var err error
+	if value == "" {
+		_, err = r.Q.ExecContext(r.Ctx, `DELETE FROM menu_translations WHERE menu_id = ? AND locale = ? AND field = ?`,
+			menuID, locale, field)
+	} else {
+		_, err = r.Q.ExecContext(r.Ctx, `INSERT INTO menu_translations (menu_id, locale, field, value) VALUES (?, ?, ?, ?)
+			  ON CONFLICT(menu_id, locale, field) DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP`,
+			menuID, locale, field, value)
+	}
+	if err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	return nil
+}
+
+// GetBranchOverride gets the override of a menu in a branch
+func (r *Repository) GetBranchOverride(menuID, branchID int) (*BranchOverride, error) {
+	query := `SELECT menu_id, branch_id, price, is_available, updated_at 
+			  FROM menu_branch_overrides WHERE menu_id = ? AND branch_id = ?`
+	var (
+		override    BranchOverride
+		price       sql.NullInt64
+		isAvailable sql.NullBool
+	)
+	err := r.Q.QueryRowContext(r.Ctx, query, menuID, branchID).Scan(
+		&override.MenuID, &override.BranchID, &price, &isAvailable, &override.UpdatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, shared.NewNotFoundError("Pengaturan cabang")
+	}
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	if price.Valid {
+		p := shared.Money(price.Int64)
+		override.Price = &p
+	}
+	if isAvailable.Valid {
+		a := isAvailable.Bool
+		override.IsAvailable = &a
+	}
+	return &override, nil
+}
+
+// SaveBranchOverride creates or replaces the override of a menu in a branch.
+// A scheduled restore is cancelled when the availability changes.
+func (r *Repository) SaveBranchOverride(override *BranchOverride) error {
+	query := `INSERT INTO menu_branch_overrides (menu_id, branch_id, price, is_available) VALUES (?, ?, ?, ?)
+			  ON CONFLICT(menu_id, branch_id) DO UPDATE SET price = excluded.price,
+			  available_again_at = CASE WHEN is_available IS excluded.is_available THEN available_again_at ELSE NULL END,
+			  is_available = excluded.is_available, updated_at = CURRENT_TIMESTAMP`
+	_, err := r.Q.ExecContext(r.Ctx, query, override.MenuID, override.BranchID, override.Price, override.IsAvailable)
+	if err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	override.UpdatedAt = time.Now()
+	return nil
+}
+
+// DeleteBranchOverride removes the override of a menu in a branch
+func (r *Repository) DeleteBranchOverride(menuID, branchID int) error {
+	result, err := r.Q.ExecContext(r.Ctx, `DELETE FROM menu_branch_overrides WHERE menu_id = ? AND branch_id = ?`, menuID, branchID)
+	if err != nil {
+		return shared.NewDatabaseError(err)
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return shared.NewNotFoundError("Pengaturan cabang")
+	}
+	return nil
+}
+
+// SetAvailability marks menus available or sold out, for every branch when
+// branchID is 0 or as an override of that branch otherwise. againAt schedules
+// the menus to become available again and is only kept for sold out menus.
+// A menu that is out of stock stays unavailable whatever is set here.
+func (r *Repository) SetAvailability(menuIDs []int, branchID int, available bool, againAt *time.Time) error {
+	if available {
+		againAt = nil
+	}
+
+	tx, err := shared.Begin(r.Ctx, r.Q)
+	if err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	defer tx.Rollback()
+
+	for _, menuID := range menuIDs {
+		var exists bool
+		if err := tx.QueryRowContext(r.Ctx, `SELECT EXISTS(SELECT 1 FROM menus WHERE id = ?)`, menuID).Scan(&exists); err != nil {
+			return shared.NewDatabaseError(err)
+		}
+		if !exists {
+			return shared.NewKeyedError(shared.ErrCodeNotFound, "error.menu_missing", nil, strconv.Itoa(menuID))
+		}
+
+		if branchID == 0 {
+			_, err = tx.ExecContext(r.Ctx, `UPDATE menus SET is_available = ?, available_again_at = ?,
+				  updated_at = CURRENT_TIMESTAMP WHERE id = ?`, available, againAt, menuID)
+		} else {
+			_, err = tx.ExecContext(r.Ctx, `INSERT INTO menu_branch_overrides (menu_id, branch_id, is_available, available_again_at)
+				  VALUES (?, ?, ?, ?)
+				  ON CONFLICT(menu_id, branch_id) DO UPDATE SET is_available = excluded.is_available,
+				  available_again_at = excluded.available_again_at, updated_at = CURRENT_TIMESTAMP`,
+				menuID, branchID, available, againAt)
+		}
+		if err != nil {
+			return shared.NewDatabaseError(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	return nil
+}
+
+// RestoreDueAvailability makes sold out menus whose restore time has passed
+// available again, both on the menu itself and in branch overrides
+func (r *Repository) RestoreDueAvailability(now time.Time) ([]AvailabilityChange, error) {
+	changes := []AvailabilityChange{}
+
+	rows, err := r.Q.QueryContext(r.Ctx, `SELECT id, name, 0, available_again_at FROM menus WHERE available_again_at IS NOT NULL
+			  UNION ALL
+			  SELECT o.menu_id, m.name, o.branch_id, o.available_again_at FROM menu_branch_overrides o
+			  JOIN menus m ON m.id = o.menu_id WHERE o.available_again_at IS NOT NULL`)
 	if err != nil {
 		return nil, shared.NewDatabaseError(err)
 	}
 	defer rows.Close()
 
+	for rows.Next() {
+		var (
+			change  AvailabilityChange
+			againAt time.Time
+		)
+		if err := rows.Scan(&change.MenuID, &change.MenuName, &change.BranchID, &againAt); err != nil {
+			return nil, shared.NewDatabaseError(err)
+		}
+		if againAt.After(now) {
+			continue
+		}
+		change.IsAvailable = true
+		changes = append(changes, change)
+	}
+	rows.Close()
+
+	for _, change := range changes {
+		if change.BranchID == 0 {
+			_, err = r.Q.ExecContext(r.Ctx, `UPDATE menus SET is_available = 1, available_again_at = NULL,
+				  updated_at = CURRENT_TIMESTAMP WHERE id = ?`, change.MenuID)
+		} else {
+			_, err = r.Q.ExecContext(r.Ctx, `UPDATE menu_branch_overrides SET is_available = 1, available_again_at = NULL,
+				  updated_at = CURRENT_TIMESTAMP WHERE menu_id = ? AND branch_id = ?`, change.MenuID, change.BranchID)
+		}
+		if err != nil {
+			return nil, shared.NewDatabaseError(err)
+		}
+	}
+	return changes, nil
+}
+
+// ListCategories lists the categories on page and the number of categories
+func (r *Repository) ListCategories(page shared.Page) ([]Category, int, error) {
+	query := `SELECT id, name, created_at FROM categories ORDER BY name`
+	total, err := r.countRows(query, nil, page)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query, args := page.Apply(query, nil)
+	rows, err := r.Q.QueryContext(r.Ctx, query, args...)
+	if err != nil {
+		return nil, 0, shared.NewDatabaseError(err)
+	}
+	defer rows.Close()
+
 	var categories []Category
 	for rows.Next() {
 		var category Category
 		if err := rows.Scan(&category.ID, &category.Name, &category.CreatedAt); err != nil {
-			return nil, shared.NewDatabaseError(err)
+			return nil, 0, shared.NewDatabaseError(err)
 		}
 		categories = append(categories, category)
 	}
-	return categories, nil
+	if page.Limit == 0 {
+		total = len(categories)
+	}
+	return categories, total, nil
 }
 
 // CreateCategory creates a new category
 func (r *Repository) CreateCategory(name string) (*Category, error) {
 	query := `INSERT INTO categories (name) VALUES (?)`
-	result, err := r.db.Exec(query, name)
+	result, err := r.Q.ExecContext(r.Ctx, query, name)
 	if err != nil {
 		return nil, shared.NewDatabaseError(err)
 	}
@@ -190,20 +735,56 @@ func (r *Repository) CreateCategory(name string) (*Category, error) {
 	}, nil
 }
 
+// DeleteBranchOverrides deletes the overrides of a deleted branch and
+// returns how many there were
+func (r *Repository) DeleteBranchOverrides(branchID int) (int, error) {
+	result, err := r.Q.ExecContext(r.Ctx, `DELETE FROM menu_branch_overrides WHERE branch_id = ?`, branchID)
+	if err != nil {
+		return 0, shared.NewDatabaseError(err)
+	}
+	affected, _ := result.RowsAffected()
+	return int(affected), nil
+}
+
+// DeleteBranchStock deletes the stock, stock history and out of stock marks
+// of a deleted branch
+func (r *Repository) DeleteBranchStock(branchID int) error {
+	for _, table := range []string{"ingredient_stock", "stock_movements", "menu_stock_outs"} {
+		if _, err := r.Q.ExecContext(r.Ctx, `DELETE FROM `+table+` WHERE branch_id = ?`, branchID); err != nil {
+			return shared.NewDatabaseError(err)
+		}
+	}
+	return nil
+}
+
+// GetCategory gets a category by ID
+func (r *Repository) GetCategory(id int) (*Category, error) {
+	var category Category
+	err := r.Q.QueryRowContext(r.Ctx, `SELECT id, name, created_at FROM categories WHERE id = ?`, id).
+		Scan(&category.ID, &category.Name, &category.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, shared.NewNotFoundError("Kategori")
+	}
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	return &category, nil
+}
+
 // DeleteCategory deletes a category
 func (r *Repository) DeleteCategory(name string) error {
 	// Check if category has menus
 	var count int
-	err := r.db.QueryRow(`SELECT COUNT(*) FROM menus WHERE category = ?`, name).Scan(&count)
+	err := r.Q.QueryRowContext(r.Ctx, `SELECT COUNT(*) FROM menus WHERE category = ?`, name).Scan(&count)
 	if err != nil {
 		return shared.NewDatabaseError(err)
 	}
 	if count > 0 {
-		return shared.NewInvalidInputError("Kategori masih memiliki menu, tidak dapat dihapus")
+		return shared.NewKeyedInvalidInputError("error.category_has_menus")
 	}
 
 	query := `DELETE FROM categories WHERE name = ?`
-	result, err := r.db.Exec(query, name)
+	result, err := r.Q.ExecContext(r.Ctx, query, name)
 	if err != nil {
 		return shared.NewDatabaseError(err)
 	}
@@ -214,3 +795,567 @@ func (r *Repository) DeleteCategory(name string) error {
 	}
 	return nil
 }
+
+// ListOptionGroups lists the option groups of a menu with their options
+func (r *Repository) ListOptionGroups(menuID int) ([]OptionGroup, error) {
+	query := `SELECT id, menu_id, name, min_select, max_select, sort_order, created_at 
+			  FROM option_groups WHERE menu_id = ? ORDER BY sort_order, id`
+	rows, err := r.Q.QueryContext(r.Ctx, query, menuID)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	defer rows.Close()
+
+	groups := []OptionGroup{}
+	index := map[int]int{}
+	for rows.Next() {
+		var group OptionGroup
+		if err := rows.Scan(&group.ID, &group.MenuID, &group.Name, &group.MinSelect, &group.MaxSelect,
+			&group.SortOrder, &group.CreatedAt); err != nil {
+			return nil, shared.NewDatabaseError(err)
+		}
+		group.IsRequired = group.MinSelect > 0
+		group.Options = []Option{}
+		index[group.ID] = len(groups)
+		groups = append(groups, group)
+	}
+	rows.Close()
+
+	if len(groups) == 0 {
+		return groups, nil
+	}
+
+	optionQuery := `SELECT o.id, o.group_id, o.name, o.price_delta, o.is_available, o.sort_order, o.created_at 
+			  FROM options o JOIN option_groups g ON g.id = o.group_id 
+			  WHERE g.menu_id = ? ORDER BY o.sort_order, o.id`
+	optionRows, err := r.Q.QueryContext(r.Ctx, optionQuery, menuID)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	defer optionRows.Close()
+
+	for optionRows.Next() {
+		var option Option
+		if err := optionRows.Scan(&option.ID, &option.GroupID, &option.Name, &option.PriceDelta,
+			&option.IsAvailable, &option.SortOrder, &option.CreatedAt); err != nil {
+			return nil, shared.NewDatabaseError(err)
+		}
+		if i, ok := index[option.GroupID]; ok {
+			groups[i].Options = append(groups[i].Options, option)
+		}
+	}
+	return groups, nil
+}
+
+// GetOptionGroup gets an option group by ID (without options)
+func (r *Repository) GetOptionGroup(id int) (*OptionGroup, error) {
+	query := `SELECT id, menu_id, name, min_select, max_select, sort_order, created_at 
+			  FROM option_groups WHERE id = ?`
+	var group OptionGroup
+	err := r.Q.QueryRowContext(r.Ctx, query, id).Scan(&group.ID, &group.MenuID, &group.Name, &group.MinSelect,
+		&group.MaxSelect, &group.SortOrder, &group.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, shared.NewNotFoundError("Grup opsi")
+	}
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	group.IsRequired = group.MinSelect > 0
+	return &group, nil
+}
+
+// CreateOptionGroup creates a new option group
+func (r *Repository) CreateOptionGroup(group *OptionGroup) (*OptionGroup, error) {
+	query := `INSERT INTO option_groups (menu_id, name, min_select, max_select, sort_order) 
+			  VALUES (?, ?, ?, ?, (SELECT COALESCE(MAX(sort_order), 0) + 1 FROM option_groups WHERE menu_id = ?))`
+	result, err := r.Q.ExecContext(r.Ctx, query, group.MenuID, group.Name, group.MinSelect, group.MaxSelect, group.MenuID)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+
+	id, _ := result.LastInsertId()
+	group.ID = int(id)
+	group.IsRequired = group.MinSelect > 0
+	group.Options = []Option{}
+	group.CreatedAt = time.Now()
+	return group, nil
+}
+
+// DeleteOptionGroup deletes an option group and its options
+func (r *Repository) DeleteOptionGroup(id int) error {
+	result, err := r.Q.ExecContext(r.Ctx, `DELETE FROM option_groups WHERE id = ?`, id)
+	if err != nil {
+		return shared.NewDatabaseError(err)
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return shared.NewNotFoundError("Grup opsi")
+	}
+
+	if _, err := r.Q.ExecContext(r.Ctx, `DELETE FROM option_recipes WHERE option_id IN (SELECT id FROM options WHERE group_id = ?)`, id); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	if _, err := r.Q.ExecContext(r.Ctx, `DELETE FROM options WHERE group_id = ?`, id); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	return nil
+}
+
+// CreateOption creates a new option in a group
+func (r *Repository) CreateOption(option *Option) (*Option, error) {
+	query := `INSERT INTO options (group_id, name, price_delta, is_available, sort_order) 
+			  VALUES (?, ?, ?, ?, (SELECT COALESCE(MAX(sort_order), 0) + 1 FROM options WHERE group_id = ?))`
+	result, err := r.Q.ExecContext(r.Ctx, query, option.GroupID, option.Name, option.PriceDelta, option.IsAvailable, option.GroupID)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+
+	id, _ := result.LastInsertId()
+	option.ID = int(id)
+	option.CreatedAt = time.Now()
+	return option, nil
+}
+
+// GetOption gets an option by ID
+func (r *Repository) GetOption(id int) (*Option, error) {
+	query := `SELECT id, group_id, name, price_delta, is_available, sort_order, created_at FROM options WHERE id = ?`
+	var option Option
+	err := r.Q.QueryRowContext(r.Ctx, query, id).Scan(&option.ID, &option.GroupID, &option.Name, &option.PriceDelta,
+		&option.IsAvailable, &option.SortOrder, &option.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, shared.NewNotFoundError("Opsi")
+	}
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	return &option, nil
+}
+
+// DeleteOption deletes an option
+func (r *Repository) DeleteOption(id int) error {
+	result, err := r.Q.ExecContext(r.Ctx, `DELETE FROM options WHERE id = ?`, id)
+	if err != nil {
+		return shared.NewDatabaseError(err)
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return shared.NewNotFoundError("Opsi")
+	}
+
+	if _, err := r.Q.ExecContext(r.Ctx, `DELETE FROM option_recipes WHERE option_id = ?`, id); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	return nil
+}
+
+// ingredientColumns selects an ingredient with its stock in a branch. The
+// stock join is bound to a branch ID; a branch that never stocked the
+// ingredient has none.
+const ingredientColumns = `i.id, b.id, i.name, i.unit, COALESCE(s.stock, 0), i.low_stock_threshold,
+			  COALESCE(s.stock, 0) <= i.low_stock_threshold, i.created_at, i.updated_at
+			  FROM ingredients i CROSS JOIN (SELECT ? AS id) b
+			  LEFT JOIN ingredient_stock s ON s.ingredient_id = i.id AND s.branch_id = b.id`
+
+func scanIngredient(row interface{ Scan(...interface{}) error }) (*Ingredient, error) {
+	var ingredient Ingredient
+	err := row.Scan(&ingredient.ID, &ingredient.BranchID, &ingredient.Name, &ingredient.Unit, &ingredient.Stock,
+		&ingredient.LowStockThreshold, &ingredient.IsLow, &ingredient.CreatedAt, &ingredient.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &ingredient, nil
+}
+
+// ListIngredients lists ingredients with their stock in a branch, optionally
+// only those at or below their low-stock threshold. Ingredients the branch
+// never stocked are not reported low.
+func (r *Repository) ListIngredients(branchID int, lowOnly bool) ([]Ingredient, error) {
+	query := `SELECT ` + ingredientColumns
+	if lowOnly {
+		query += ` WHERE s.ingredient_id IS NOT NULL AND s.stock <= i.low_stock_threshold`
+	}
+	query += ` ORDER BY i.name`
+
+	rows, err := r.Q.QueryContext(r.Ctx, query, branchID)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	defer rows.Close()
+
+	ingredients := []Ingredient{}
+	for rows.Next() {
+		ingredient, err := scanIngredient(rows)
+		if err != nil {
+			return nil, shared.NewDatabaseError(err)
+		}
+		ingredients = append(ingredients, *ingredient)
+	}
+	return ingredients, nil
+}
+
+// GetIngredient gets an ingredient by ID with its stock in a branch
+func (r *Repository) GetIngredient(id, branchID int) (*Ingredient, error) {
+	ingredient, err := scanIngredient(r.Q.QueryRowContext(r.Ctx, `SELECT `+ingredientColumns+` WHERE i.id = ?`, branchID, id))
+	if err == sql.ErrNoRows {
+		return nil, shared.NewNotFoundError("Bahan")
+	}
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	return ingredient, nil
+}
+
+// CreateIngredient creates an ingredient and records its opening stock in
+// the ingredient's branch
+func (r *Repository) CreateIngredient(ingredient *Ingredient) (*Ingredient, error) {
+	tx, err := shared.Begin(r.Ctx, r.Q)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(r.Ctx, `INSERT INTO ingredients (name, unit, low_stock_threshold) VALUES (?, ?, ?)`,
+		ingredient.Name, ingredient.Unit, ingredient.LowStockThreshold)
+	if err != nil {
+		if strings.Contains(err.Error(), "UNIQUE") {
+			return nil, shared.NewKeyedError(shared.ErrCodeDuplicateEntry, "error.duplicate_ingredient", err)
+		}
+		return nil, shared.NewDatabaseError(err)
+	}
+
+	id, _ := result.LastInsertId()
+	if ingredient.BranchID != 0 {
+		if _, err := tx.ExecContext(r.Ctx, `INSERT INTO ingredient_stock (ingredient_id, branch_id, stock) VALUES (?, ?, ?)`,
+			id, ingredient.BranchID, ingredient.Stock); err != nil {
+			return nil, shared.NewDatabaseError(err)
+		}
+	}
+	if ingredient.Stock != 0 {
+		if _, err := tx.ExecContext(r.Ctx, `INSERT INTO stock_movements (ingredient_id, branch_id, change, stock_after, reason) 
+				  VALUES (?, ?, ?, ?, ?)`,
+			id, ingredient.BranchID, ingredient.Stock, ingredient.Stock, ReasonInitial); err != nil {
+			return nil, shared.NewDatabaseError(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+
+	ingredient.ID = int(id)
+	ingredient.IsLow = ingredient.Stock <= ingredient.LowStockThreshold
+	ingredient.CreatedAt = time.Now()
+	ingredient.UpdatedAt = time.Now()
+	return ingredient, nil
+}
+
+// UpdateIngredient updates the name, unit and threshold of an ingredient.
+// Stock is only changed through AdjustStock so every change is recorded.
+func (r *Repository) UpdateIngredient(ingredient *Ingredient) error {
+	query := `UPDATE ingredients SET name = ?, unit = ?, low_stock_threshold = ?, updated_at = CURRENT_TIMESTAMP 
+			  WHERE id = ?`
+	result, err := r.Q.ExecContext(r.Ctx, query, ingredient.Name, ingredient.Unit, ingredient.LowStockThreshold, ingredient.ID)
+	if err != nil {
+		return shared.NewDatabaseError(err)
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return shared.NewNotFoundError("Bahan")
+	}
+	return nil
+}
+
+// DeleteIngredient deletes an ingredient with its stock, recipe lines and history
+func (r *Repository) DeleteIngredient(id int) error {
+	result, err := r.Q.ExecContext(r.Ctx, `DELETE FROM ingredients WHERE id = ?`, id)
+	if err != nil {
+		return shared.NewDatabaseError(err)
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return shared.NewNotFoundError("Bahan")
+	}
+
+	for _, table := range []string{"ingredient_stock", "recipes", "option_recipes", "stock_movements"} {
+		if _, err := r.Q.ExecContext(r.Ctx, `DELETE FROM `+table+` WHERE ingredient_id = ?`, id); err != nil {
+			return shared.NewDatabaseError(err)
+		}
+	}
+	return nil
+}
+
+// adjustStock changes the stock of an ingredient in a branch inside tx and
+// records the movement
+func adjustStock(ctx context.Context, tx shared.Querier, ingredientID, branchID int, change float64, reason, reference string) error {
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM ingredients WHERE id = ?)`, ingredientID).Scan(&exists); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	if !exists {
+		return shared.NewNotFoundError("Bahan")
+	}
+
+	_, err := tx.ExecContext(ctx, `INSERT INTO ingredient_stock (ingredient_id, branch_id, stock) VALUES (?, ?, ?)
+			  ON CONFLICT(ingredient_id, branch_id) DO UPDATE SET stock = stock + excluded.stock,
+			  updated_at = CURRENT_TIMESTAMP`,
+		ingredientID, branchID, change)
+	if err != nil {
+		return shared.NewDatabaseError(err)
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO stock_movements (ingredient_id, branch_id, change, stock_after, reason, reference) 
+			  SELECT ingredient_id, branch_id, ?, stock, ?, ? FROM ingredient_stock WHERE ingredient_id = ? AND branch_id = ?`,
+		change, reason, reference, ingredientID, branchID)
+	if err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	return nil
+}
+
+// AdjustStock manually changes the stock of an ingredient in a branch
+func (r *Repository) AdjustStock(ingredientID, branchID int, change float64, reason string) (*Ingredient, error) {
+	tx, err := shared.Begin(r.Ctx, r.Q)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	defer tx.Rollback()
+
+	if err := adjustStock(r.Ctx, tx, ingredientID, branchID, change, reason, ""); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	return r.GetIngredient(ingredientID, branchID)
+}
+
+// ListStockMovements lists the latest stock movements, optionally of one
+// ingredient and of one branch
+func (r *Repository) ListStockMovements(ingredientID, branchID, limit int) ([]StockMovement, error) {
+	query := `SELECT id, ingredient_id, branch_id, change, stock_after, reason, COALESCE(reference, ''), created_at 
+			  FROM stock_movements WHERE 1=1`
+	args := []interface{}{}
+	if ingredientID > 0 {
+		query += ` AND ingredient_id = ?`
+		args = append(args, ingredientID)
+	}
+	if branchID > 0 {
+		query += ` AND branch_id = ?`
+		args = append(args, branchID)
+	}
+	query += ` ORDER BY id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := r.Q.QueryContext(r.Ctx, query, args...)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	defer rows.Close()
+
+	movements := []StockMovement{}
+	for rows.Next() {
+		var movement StockMovement
+		if err := rows.Scan(&movement.ID, &movement.IngredientID, &movement.BranchID, &movement.Change,
+			&movement.StockAfter, &movement.Reason, &movement.Reference, &movement.CreatedAt); err != nil {
+			return nil, shared.NewDatabaseError(err)
+		}
+		movements = append(movements, movement)
+	}
+	return movements, nil
+}
+
+// GetRecipe gets the ingredients used by one serving of a menu
+func (r *Repository) GetRecipe(menuID int) ([]RecipeItem, error) {
+	items, err := r.listRecipe("recipes", "menu_id", menuID)
+	for i := range items {
+		items[i].MenuID = menuID
+	}
+	return items, err
+}
+
+// GetOptionRecipe gets the ingredients an option adds to one serving
+func (r *Repository) GetOptionRecipe(optionID int) ([]RecipeItem, error) {
+	items, err := r.listRecipe("option_recipes", "option_id", optionID)
+	for i := range items {
+		items[i].OptionID = optionID
+	}
+	return items, err
+}
+
+// listRecipe lists the lines of table whose column is id
+func (r *Repository) listRecipe(table, column string, id int) ([]RecipeItem, error) {
+	query := `SELECT r.ingredient_id, i.name, i.unit, r.quantity 
+			  FROM ` + table + ` r JOIN ingredients i ON i.id = r.ingredient_id 
+			  WHERE r.` + column + ` = ? ORDER BY i.name`
+	rows, err := r.Q.QueryContext(r.Ctx, query, id)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	defer rows.Close()
+
+	items := []RecipeItem{}
+	for rows.Next() {
+		var item RecipeItem
+		if err := rows.Scan(&item.IngredientID, &item.IngredientName, &item.Unit, &item.Quantity); err != nil {
+			return nil, shared.NewDatabaseError(err)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// SetRecipe replaces the recipe of a menu
+func (r *Repository) SetRecipe(menuID int, items []RecipeItem) error {
+	return r.replaceRecipe("recipes", "menu_id", menuID, items)
+}
+
+// SetOptionRecipe replaces the ingredients an option adds
+func (r *Repository) SetOptionRecipe(optionID int, items []RecipeItem) error {
+	return r.replaceRecipe("option_recipes", "option_id", optionID, items)
+}
+
+// replaceRecipe replaces the lines of table whose column is id
+func (r *Repository) replaceRecipe(table, column string, id int, items []RecipeItem) error {
+	tx, err := shared.Begin(r.Ctx, r.Q)
+	if err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(r.Ctx, `DELETE FROM `+table+` WHERE `+column+` = ?`, id); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	for _, item := range items {
+		if _, err := tx.ExecContext(r.Ctx, `INSERT INTO `+table+` (`+column+`, ingredient_id, quantity) VALUES (?, ?, ?)`,
+			id, item.IngredientID, item.Quantity); err != nil {
+			return shared.NewDatabaseError(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	return nil
+}
+
+// ConsumeStock deducts the recipe ingredients of the given servings, with
+// those of their chosen options, from the stock of a branch. The reference
+// (e.g. "order:12") makes the call idempotent: it returns false without
+// touching stock when the reference was already consumed.
+func (r *Repository) ConsumeStock(branchID int, usages []StockUsage, reference string) (bool, error) {
+	tx, err := shared.Begin(r.Ctx, r.Q)
+	if err != nil {
+		return false, shared.NewDatabaseError(err)
+	}
+	defer tx.Rollback()
+
+	var exists bool
+	err = tx.QueryRowContext(r.Ctx, `SELECT EXISTS(SELECT 1 FROM stock_movements WHERE reason = ? AND reference = ?)`,
+		ReasonOrder, reference).Scan(&exists)
+	if err != nil {
+		return false, shared.NewDatabaseError(err)
+	}
+	if exists {
+		return false, nil
+	}
+
+	// Sum per ingredient so an order records one movement per ingredient
+	totals := map[int]float64{}
+	var order []int
+	for _, usage := range usages {
+		query := `SELECT ingredient_id, quantity FROM recipes WHERE menu_id = ?`
+		args := []interface{}{usage.MenuID}
+		if len(usage.OptionIDs) > 0 {
+			query += ` UNION ALL SELECT ingredient_id, quantity FROM option_recipes 
+				  WHERE option_id IN (?` + strings.Repeat(`, ?`, len(usage.OptionIDs)-1) + `)`
+			for _, optionID := range usage.OptionIDs {
+				args = append(args, optionID)
+			}
+		}
+
+		rows, err := tx.QueryContext(r.Ctx, query, args...)
+		if err != nil {
+			return false, shared.NewDatabaseError(err)
+		}
+		for rows.Next() {
+			var (
+				ingredientID int
+				quantity     float64
+			)
+			if err := rows.Scan(&ingredientID, &quantity); err != nil {
+				rows.Close()
+				return false, shared.NewDatabaseError(err)
+			}
+			if _, seen := totals[ingredientID]; !seen {
+				order = append(order, ingredientID)
+			}
+			totals[ingredientID] += quantity * float64(usage.Quantity)
+		}
+		rows.Close()
+	}
+
+	for _, ingredientID := range order {
+		if err := adjustStock(r.Ctx, tx, ingredientID, branchID, -totals[ingredientID], ReasonOrder, reference); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, shared.NewDatabaseError(err)
+	}
+	return true, nil
+}
+
+// SyncAvailability marks menus that a branch can no longer make from its
+// stock as out of stock there, and clears the mark once every ingredient is
+// back. The mark is kept apart from availability set by hand, which it beats.
+// Branches that never stocked any ingredient are left alone.
+func (r *Repository) SyncAvailability() ([]AvailabilityChange, error) {
+	query := `SELECT m.id, m.name, b.branch_id,
+			  EXISTS(SELECT 1 FROM menu_stock_outs so WHERE so.menu_id = m.id AND so.branch_id = b.branch_id),
+			  EXISTS(SELECT 1 FROM recipes r LEFT JOIN ingredient_stock s 
+			         ON s.ingredient_id = r.ingredient_id AND s.branch_id = b.branch_id
+			         WHERE r.menu_id = m.id AND COALESCE(s.stock, 0) < r.quantity)
+			  FROM menus m CROSS JOIN (SELECT branch_id FROM ingredient_stock UNION SELECT branch_id FROM menu_stock_outs) b
+			  WHERE m.id IN (SELECT menu_id FROM recipes) 
+			     OR m.id IN (SELECT menu_id FROM menu_stock_outs WHERE branch_id = b.branch_id)`
+	rows, err := r.Q.QueryContext(r.Ctx, query)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	defer rows.Close()
+
+	changes := []AvailabilityChange{}
+	for rows.Next() {
+		var (
+			change             AvailabilityChange
+			marked, outOfStock bool
+		)
+		if err := rows.Scan(&change.MenuID, &change.MenuName, &change.BranchID, &marked, &outOfStock); err != nil {
+			return nil, shared.NewDatabaseError(err)
+		}
+		if marked == outOfStock {
+			continue
+		}
+		change.IsAvailable = !outOfStock
+		changes = append(changes, change)
+	}
+	rows.Close()
+
+	for _, change := range changes {
+		if change.IsAvailable {
+			_, err = r.Q.ExecContext(r.Ctx, `DELETE FROM menu_stock_outs WHERE menu_id = ? AND branch_id = ?`,
+				change.MenuID, change.BranchID)
+		} else {
+			_, err = r.Q.ExecContext(r.Ctx, `INSERT OR IGNORE INTO menu_stock_outs (menu_id, branch_id) VALUES (?, ?)`,
+				change.MenuID, change.BranchID)
+		}
+		if err != nil {
+			return nil, shared.NewDatabaseError(err)
+		}
+	}
+	return changes, nil
+}
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+)
+
+// Handler handles HTTP requests
+type Handler struct {
+	repo           *Repository
+	events         *shared.Outbox
+	client         *shared.HTTPClient
+	menuServiceURL string
+}
+
+// NewHandler creates a new handler
+func NewHandler(repo *Repository, events *shared.Outbox, menuServiceURL string) *Handler {
+	return &Handler{
+		repo:           repo,
+		events:         events,
+		client:         shared.NewHTTPClient(),
+		menuServiceURL: menuServiceURL,
+	}
+}
+
+// withContext returns a copy of the handler making its queries and service
+// calls with ctx. An order is updated and its stock deducted even when the
+// caller gives up, so cancellation is not passed on.
+func (h *Handler) withContext(ctx context.Context) *Handler {
+	ctx = context.WithoutCancel(ctx)
+	handler := *h
+	handler.repo = h.repo.WithContext(ctx)
+	handler.client = h.client.WithContext(ctx)
+	return &handler
+}
+
+// HandleRequest handles all incoming requests
+func (h *Handler) HandleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, shared.NewError(shared.ErrCodeInvalidInput, "Method not allowed", nil))
+		return
+	}
+
+	var req shared.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, shared.NewError(shared.ErrCodeInvalidInput, "Invalid request format", err))
+		return
+	}
+
+	// Queries and calls to menu-service are traced as part of this request
+	h = h.withContext(r.Context())
+
+	var response *shared.Response
+
+	switch req.Action {
+	case "create":
+		response = h.createOrder(req.Payload)
+	case "read":
+		response = h.getOrder(req.Payload)
+	case "list":
+		response = h.listOrders(req.Payload)
+	case "update_status":
+		response = h.updateStatus(req.Payload)
+	default:
+		sendErrorResponse(w, shared.NewError(shared.ErrCodeInvalidInput, "Unknown action", nil))
+		return
+	}
+
+	sendResponse(w, response)
+}
+
+// createOrder prices each line through menu-service and stores the order
+func (h *Handler) createOrder(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	telegramID, _ := data["telegram_id"].(string)
+	customerName, _ := data["customer_name"].(string)
+	note, _ := data["note"].(string)
+	branchID := 1
+	if v, ok := data["branch_id"].(float64); ok && v > 0 {
+		branchID = int(v)
+	}
+
+	if err := shared.ValidateNotEmpty(telegramID, "Telegram ID"); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+	rawItems, ok := data["items"].([]interface{})
+	if !ok || len(rawItems) == 0 {
+		return errorResponse(shared.NewKeyedInvalidInputError("error.order_empty"))
+	}
+
+	order := &Order{
+		TelegramID:   telegramID,
+		CustomerName: shared.SanitizeInput(customerName),
+		BranchID:     branchID,
+		Note:         shared.SanitizeInput(note),
+	}
+
+	for _, raw := range rawItems {
+		item, _ := raw.(map[string]interface{})
+		menuID, ok := item["menu_id"].(float64)
+		if !ok {
+			return errorResponse(shared.NewInvalidInputError("Setiap item membutuhkan menu_id"))
+		}
+		quantity := 1
+		if raw, present := item["quantity"]; present {
+			v, ok := raw.(float64)
+			if !ok || v != math.Trunc(v) || v <= 0 {
+				return errorResponse(shared.NewKeyedInvalidInputError("error.quantity_invalid"))
+			}
+			quantity = int(v)
+		}
+		optionIDs, _ := item["option_ids"].([]interface{})
+
+		line, appErr := h.quoteItem(int(menuID), branchID, optionIDs, quantity)
+		if appErr != nil {
+			return errorResponse(appErr)
+		}
+		order.Items = append(order.Items, *line)
+		order.Total = order.Total.Add(line.Subtotal)
+	}
+
+	// The order and order.created are stored in one transaction, taken only
+	// now so it is not held while menu-service quotes
+	tx, err := h.repo.Begin()
+	if err != nil {
+		return errorResponse(shared.NewDatabaseError(err))
+	}
+	defer tx.Rollback()
+
+	result, err := h.repo.WithTx(tx).CreateOrder(order)
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+	if appErr := h.commit(tx, "order.created", eventData(result)); appErr != nil {
+		return errorResponse(appErr)
+	}
+
+	return successResponse(map[string]interface{}{
+		"order": result,
+	})
+}
+
+// quoteItem asks menu-service for the validated price of an order line
+func (h *Handler) quoteItem(menuID, branchID int, optionIDs []interface{}, quantity int) (*OrderItem, *shared.AppError) {
+	if optionIDs == nil {
+		optionIDs = []interface{}{}
+	}
+
+	resp, err := h.client.Post(h.menuServiceURL, shared.Request{
+		Action: "quote",
+		Payload: map[string]interface{}{
+			"menu_id":    menuID,
+			"branch_id":  branchID,
+			"option_ids": optionIDs,
+			"quantity":   quantity,
+		},
+	})
+	if err != nil {
+		return nil, shared.NewKeyedError(shared.ErrCodeServiceError, "error.service_unreachable", err, "Menu service")
+	}
+	if !resp.Success {
+		if resp.Error != nil {
+			return nil, shared.ErrorFromInfo(resp.Error)
+		}
+		return nil, shared.NewKeyedError(shared.ErrCodeServiceError, "error.pricing_failed", nil)
+	}
+
+	quote, _ := resp.Data.(map[string]interface{})["quote"].(map[string]interface{})
+	var (
+		options []string
+		chosen  []int
+	)
+	if selected, ok := quote["options"].([]interface{}); ok {
+		for _, o := range selected {
+			option, _ := o.(map[string]interface{})
+			name, _ := option["name"].(string)
+			id, _ := option["id"].(float64)
+			options = append(options, name)
+			chosen = append(chosen, int(id))
+		}
+	}
+
+	menuName, _ := quote["menu_name"].(string)
+	unitPrice, err := shared.MoneyFromPayload(quote["unit_price"])
+	if err != nil {
+		return nil, shared.NewKeyedError(shared.ErrCodeServiceError, "error.service_invalid_data", err, "Menu service")
+	}
+	total, err := shared.MoneyFromPayload(quote["total"])
+	if err != nil {
+		return nil, shared.NewKeyedError(shared.ErrCodeServiceError, "error.service_invalid_data", err, "Menu service")
+	}
+	return &OrderItem{
+		MenuID:    menuID,
+		MenuName:  menuName,
+		Options:   strings.Join(options, ", "),
+		OptionIDs: chosen,
+		UnitPrice: unitPrice,
+		Quantity:  quantity,
+		Subtotal:  total,
+	}, nil
+}
+
+// getOrder gets an order by ID
+func (h *Handler) getOrder(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	id, ok := data["id"].(float64)
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("ID diperlukan"))
+	}
+
+	order, err := h.repo.GetOrder(int(id))
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"order":         order,
+		"next_statuses": allowedNextStatuses(order.Status),
+	})
+}
+
+// listOrders lists orders. status may be a single status or "active" for
+// every order that is not completed or cancelled yet. A date range (from/to,
+// YYYY-MM-DD) returns every order in the range unless a limit is given.
+func (h *Handler) listOrders(payload interface{}) *shared.Response {
+	filter := OrderFilter{Limit: 50}
+	if data, ok := payload.(map[string]interface{}); ok {
+		for field, target := range map[string]*string{"from": &filter.From, "to": &filter.To} {
+			value, _ := data[field].(string)
+			if value == "" {
+				continue
+			}
+			if _, err := time.Parse("2006-01-02", value); err != nil {
+				return errorResponse(shared.NewKeyedInvalidInputError("error.field_date_format", field))
+			}
+			*target = value
+			filter.Limit = 0
+		}
+
+		switch status, _ := data["status"].(string); status {
+		case "":
+		case "active":
+			filter.Statuses = []string{StatusPending, StatusPreparing, StatusReady}
+		default:
+			filter.Statuses = []string{status}
+		}
+		if v, ok := data["branch_id"].(float64); ok {
+			filter.BranchID = int(v)
+		}
+		filter.TelegramID, _ = data["telegram_id"].(string)
+		if v, ok := data["limit"].(float64); ok && v > 0 {
+			filter.Limit = int(v)
+		}
+	}
+
+	orders, err := h.repo.ListOrders(filter)
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"orders": orders,
+	})
+}
+
+// updateStatus advances an order. Completing an order deducts its
+// ingredients from stock in menu-service.
+func (h *Handler) updateStatus(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	id, ok := data["id"].(float64)
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("ID diperlukan"))
+	}
+	status, _ := data["status"].(string)
+
+	order, err := h.repo.GetOrder(int(id))
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+	if !shared.Contains(nextStatuses[order.Status], status) {
+		return errorResponse(shared.NewKeyedInvalidInputError(
+			"error.status_transition", order.Status, status))
+	}
+
+	// The status and order.status_changed are stored in one transaction,
+	// committed before menu-service is asked to deduct stock
+	tx, err := h.repo.Begin()
+	if err != nil {
+		return errorResponse(shared.NewDatabaseError(err))
+	}
+	defer tx.Rollback()
+
+	if err := h.repo.WithTx(tx).UpdateStatus(order.ID, order.Status, status); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+	changed, err := h.repo.WithTx(tx).GetOrder(order.ID)
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+	if appErr := h.commit(tx, "order.status_changed", eventData(changed)); appErr != nil {
+		return errorResponse(appErr)
+	}
+
+	result := map[string]interface{}{}
+	if status == StatusCompleted {
+		stock, err := h.deductStock(order)
+		if err != nil {
+			// The order is done either way; RetryStockDeductions tries
+			// again with the same reference, which menu-service counts once
+			shared.Logger(h.repo.Ctx).Error("failed to deduct stock", "order_id", order.ID, "error", err)
+		} else {
+			result["stock"] = stock
+		}
+	}
+
+	updated, err := h.repo.GetOrder(order.ID)
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+	result["order"] = updated
+	result["next_statuses"] = allowedNextStatuses(updated.Status)
+
+	return successResponse(result)
+}
+
+// commit stores an event announcing a change of an order and commits the
+// transaction of the change, then has the outbox deliver the event right
+// away. Subscribers such as the counter display get it at least once.
+func (h *Handler) commit(tx *sql.Tx, eventType string, data map[string]interface{}) *shared.AppError {
+	if err := h.events.Publish(h.repo.Ctx, tx, eventType, data); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	h.events.Wake()
+	return nil
+}
+
+// RetryStockDeductions deducts the stock of completed orders whose deduction
+// failed, e.g. because menu-service was down. It returns how many succeeded.
+func (h *Handler) RetryStockDeductions(ctx context.Context) (int, error) {
+	h = h.withContext(ctx)
+	orders, err := h.repo.ListUndeductedOrders()
+	if err != nil {
+		return 0, err
+	}
+
+	deducted := 0
+	for _, order := range orders {
+		if _, err := h.deductStock(order); err != nil {
+			shared.Logger(ctx).Error("failed to deduct stock again", "order_id", order.ID, "error", err)
+			continue
+		}
+		deducted++
+	}
+	return deducted, nil
+}
+
+// deductStock asks menu-service to consume the ingredients of an order, with
+// those of its chosen options, from its branch's stock and returns its stock
+// report (low stock, availability changes)
+func (h *Handler) deductStock(order *Order) (interface{}, error) {
+	var items []map[string]interface{}
+	for _, item := range order.Items {
+		optionIDs := item.OptionIDs
+		if optionIDs == nil {
+			optionIDs = []int{}
+		}
+		items = append(items, map[string]interface{}{
+			"menu_id":    item.MenuID,
+			"option_ids": optionIDs,
+			"quantity":   item.Quantity,
+		})
+	}
+
+	resp, err := h.client.Post(h.menuServiceURL, shared.Request{
+		Action: "consume_stock",
+		Payload: map[string]interface{}{
+			"reference": fmt.Sprintf("order:%d", order.ID),
+			"branch_id": order.BranchID,
+			"items":     items,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		if resp.Error != nil {
+			return nil, fmt.Errorf("%s: %s", resp.Error.Code, resp.Error.Message)
+		}
+		return nil, fmt.Errorf("consume_stock failed")
+	}
+
+	if err := h.repo.MarkStockDeducted(order.ID); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// Helper functions
+
+// eventData describes an order for subscribers, without its items
+func eventData(order *Order) map[string]interface{} {
+	return map[string]interface{}{
+		"id":            order.ID,
+		"queue_number":  order.QueueNumber,
+		"customer_name": order.CustomerName,
+		"branch_id":     order.BranchID,
+		"status":        order.Status,
+		"created_at":    order.CreatedAt,
+		"updated_at":    order.UpdatedAt,
+	}
+}
+
+// allowedNextStatuses returns the statuses an order may move to, never nil so
+// final orders encode as an empty list
+func allowedNextStatuses(status string) []string {
+	if next, ok := nextStatuses[status]; ok {
+		return next
+	}
+	return []string{}
+}
+
+func successResponse(data interface{}) *shared.Response {
+	return &shared.Response{
+		Success: true,
+		Data:    data,
+	}
+}
+
+func errorResponse(err *shared.AppError) *shared.Response {
+	return &shared.Response{
+		Success: false,
+		Error:   err.Info(),
+	}
+}
+
+func sendResponse(w http.ResponseWriter, response *shared.Response) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func sendErrorResponse(w http.ResponseWriter, err *shared.AppError) {
+	w.Header().Set("Content-Type", "application/json")
+	response := errorResponse(err)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	// Load environment variables
+	godotenv.Load()
+	shared.ConfigureLogger("order-service")
+	shared.ConfigureTracing("order-service")
+
+	port := os.Getenv("ORDER_SERVICE_PORT")
+	if port == "" {
+		port = "8086"
+	}
+
+	dbPath := os.Getenv("ORDER_DB_PATH")
+	if dbPath == "" {
+		dbPath = "./data/order.db"
+	}
+
+	menuServiceURL := shared.ServiceURL("menu-service")
+
+	// Initialize database
+	db, err := shared.InitDB(dbPath)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	// Initialize repository
+	repo := NewRepository(db)
+	if err := repo.InitSchema(); err != nil {
+		log.Fatalf("Failed to initialize schema: %v", err)
+	}
+
+	// Status changes are announced to other services through the outbox,
+	// e.g. to the counter display
+	events, err := shared.NewOutbox(db, "order-service")
+	if err != nil {
+		log.Fatalf("Failed to initialize outbox: %v", err)
+	}
+	go events.Run()
+
+	// Initialize handler
+	handler := NewHandler(repo, events, menuServiceURL)
+
+	// Completed orders whose stock deduction failed are retried
+	go retryStockDeductions(handler, time.Minute)
+
+	// Setup routes
+	http.HandleFunc("/", shared.InstrumentRequests(handler.HandleRequest))
+	shared.NewHealth("order-service").WithDatabase(db).DependsOn("menu-service", menuServiceURL).Register()
+	http.HandleFunc("/metrics", shared.MetricsHandler)
+
+	// Start server
+	addr := fmt.Sprintf(":%s", port)
+	shared.Logger(context.Background()).Info("service starting", "addr", addr)
+	if err := http.ListenAndServe(addr, nil); err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+}
+
+// retryStockDeductions periodically deducts the stock of completed orders
+// that menu-service could not take it for
+func retryStockDeductions(handler *Handler, interval time.Duration) {
+	ctx := shared.WithLogFields(context.Background(), "job", "retry_stock_deductions")
+	for {
+		deducted, err := handler.RetryStockDeductions(ctx)
+		if err != nil {
+			shared.Logger(ctx).Error("failed to retry stock deductions", "error", err)
+		}
+		if deducted > 0 {
+			shared.Logger(ctx).Info("deducted stock of completed orders on retry", "count", deducted)
+		}
+		time.Sleep(interval)
+	}
+}
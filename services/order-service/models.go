@@ -0,0 +1,65 @@
+package main
+
+import (
+	"time"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+)
+
+// Order statuses, in the order an order normally moves through them
+const (
+	StatusPending   = "pending"
+	StatusPreparing = "preparing"
+	StatusReady     = "ready"
+	StatusCompleted = "completed"
+	StatusCancelled = "cancelled"
+)
+
+// nextStatuses lists the statuses an order may move to from each status.
+// Completed and cancelled orders are final.
+var nextStatuses = map[string][]string{
+	StatusPending:   {StatusPreparing, StatusCancelled},
+	StatusPreparing: {StatusReady, StatusCancelled},
+	StatusReady:     {StatusCompleted, StatusCancelled},
+}
+
+// Order represents a customer order at a branch
+type Order struct {
+	ID            int          `json:"id"`
+	QueueNumber   int          `json:"queue_number"` // restarts at 1 every day per branch
+	TelegramID    string       `json:"telegram_id"`
+	CustomerName  string       `json:"customer_name"`
+	BranchID      int          `json:"branch_id"`
+	Status        string       `json:"status"`
+	Items         []OrderItem  `json:"items"`
+	Total         shared.Money `json:"total"`
+	Note          string       `json:"note,omitempty"`
+	StockDeducted bool         `json:"stock_deducted"`
+	CreatedAt     time.Time    `json:"created_at"`
+	UpdatedAt     time.Time    `json:"updated_at"`
+	CompletedAt   *time.Time   `json:"completed_at,omitempty"`
+}
+
+// OrderItem is one menu line of an order, priced when the order was placed
+type OrderItem struct {
+	ID        int          `json:"id"`
+	OrderID   int          `json:"order_id"`
+	MenuID    int          `json:"menu_id"`
+	MenuName  string       `json:"menu_name"`
+	Options   string       `json:"options,omitempty"` // e.g. "Large, Oat Milk"
+	OptionIDs []int        `json:"option_ids,omitempty"`
+	UnitPrice shared.Money `json:"unit_price"`
+	Quantity  int          `json:"quantity"`
+	Subtotal  shared.Money `json:"subtotal"`
+}
+
+// OrderFilter narrows down ListOrders. From and To are inclusive local dates
+// (YYYY-MM-DD) of when the order was placed; Limit 0 returns every match.
+type OrderFilter struct {
+	Statuses   []string
+	BranchID   int
+	TelegramID string
+	From       string
+	To         string
+	Limit      int
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+)
+
+// Repository handles database operations
+type Repository struct {
+	shared.Store
+}
+
+// NewRepository creates a new repository
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{shared.NewStore(db)}
+}
+
+// WithContext returns the repository running its queries with ctx
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{r.Store.WithContext(ctx)}
+}
+
+// WithTx returns the repository running its queries in tx
+func (r *Repository) WithTx(tx *sql.Tx) *Repository {
+	return &Repository{r.Store.WithTx(tx)}
+}
+
+// schemaVersion is recorded in the database by InitSchema and reported by
+// /ready; bump it with every schema change
+const schemaVersion = 2
+
+// InitSchema initializes database schema
+func (r *Repository) InitSchema() error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS orders (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		queue_number INTEGER NOT NULL,
+		telegram_id TEXT NOT NULL,
+		customer_name TEXT,
+		branch_id INTEGER NOT NULL DEFAULT 1,
+		status TEXT NOT NULL DEFAULT 'pending',
+		total INTEGER NOT NULL,
+		note TEXT,
+		stock_deducted BOOLEAN DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		completed_at DATETIME
+	);
+
+	CREATE TABLE IF NOT EXISTS order_items (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		order_id INTEGER NOT NULL,
+		menu_id INTEGER NOT NULL,
+		menu_name TEXT NOT NULL,
+		options TEXT,
+		unit_price INTEGER NOT NULL,
+		quantity INTEGER NOT NULL,
+		subtotal INTEGER NOT NULL,
+		FOREIGN KEY (order_id) REFERENCES orders(id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_orders_status ON orders(status);
+	CREATE INDEX IF NOT EXISTS idx_orders_branch ON orders(branch_id);
+	CREATE INDEX IF NOT EXISTS idx_orders_telegram ON orders(telegram_id);
+	CREATE INDEX IF NOT EXISTS idx_order_items_order ON order_items(order_id);
+	`
+	if err := shared.ExecuteSchema(r.DB, schema); err != nil {
+		return err
+	}
+	// option_ids holds the IDs of the chosen options as a JSON array, so
+	// stock is deducted for them too
+	if err := shared.AddColumnIfNotExists(r.DB, "order_items", "option_ids", "TEXT NOT NULL DEFAULT '[]'"); err != nil {
+		return err
+	}
+	return shared.SetSchemaVersion(r.DB, schemaVersion)
+}
+
+// CreateOrder stores an order with its items and assigns the next queue
+// number of the day for its branch
+func (r *Repository) CreateOrder(order *Order) (*Order, error) {
+	tx, err := shared.Begin(r.Ctx, r.Q)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(r.Ctx, `SELECT COALESCE(MAX(queue_number), 0) + 1 FROM orders 
+			  WHERE branch_id = ? AND date(created_at, 'localtime') = date('now', 'localtime')`,
+		order.BranchID).Scan(&order.QueueNumber)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+
+	query := `INSERT INTO orders (queue_number, telegram_id, customer_name, branch_id, status, total, note) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?)`
+	result, err := tx.ExecContext(r.Ctx, query, order.QueueNumber, order.TelegramID, order.CustomerName, order.BranchID,
+		StatusPending, order.Total, order.Note)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+
+	id, _ := result.LastInsertId()
+	order.ID = int(id)
+
+	for i := range order.Items {
+		item := &order.Items[i]
+		item.OrderID = order.ID
+		optionIDs, err := json.Marshal(item.OptionIDs)
+		if err != nil {
+			return nil, shared.NewInternalError(err)
+		}
+		result, err := tx.ExecContext(r.Ctx, `INSERT INTO order_items (order_id, menu_id, menu_name, options, option_ids, unit_price, quantity, subtotal) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			item.OrderID, item.MenuID, item.MenuName, item.Options, optionIDs, item.UnitPrice, item.Quantity, item.Subtotal)
+		if err != nil {
+			return nil, shared.NewDatabaseError(err)
+		}
+		itemID, _ := result.LastInsertId()
+		item.ID = int(itemID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+
+	order.Status = StatusPending
+	order.CreatedAt = time.Now()
+	order.UpdatedAt = time.Now()
+	return order, nil
+}
+
+const orderColumns = `id, queue_number, telegram_id, COALESCE(customer_name, ''), branch_id, status, total,
+			  COALESCE(note, ''), stock_deducted, created_at, updated_at, completed_at FROM orders`
+
+func scanOrder(row interface{ Scan(...interface{}) error }) (*Order, error) {
+	var (
+		order       Order
+		completedAt sql.NullTime
+	)
+	err := row.Scan(&order.ID, &order.QueueNumber, &order.TelegramID, &order.CustomerName, &order.BranchID,
+		&order.Status, &order.Total, &order.Note, &order.StockDeducted, &order.CreatedAt, &order.UpdatedAt, &completedAt)
+	if err != nil {
+		return nil, err
+	}
+	if completedAt.Valid {
+		order.CompletedAt = &completedAt.Time
+	}
+	order.Items = []OrderItem{}
+	return &order, nil
+}
+
+// GetOrder gets an order by ID with its items
+func (r *Repository) GetOrder(id int) (*Order, error) {
+	order, err := scanOrder(r.Q.QueryRowContext(r.Ctx, `SELECT `+orderColumns+` WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, shared.NewNotFoundError("Pesanan")
+	}
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+
+	if err := r.loadItems([]*Order{order}); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// ListOrders lists orders, newest first, with their items
+func (r *Repository) ListOrders(filter OrderFilter) ([]Order, error) {
+	query := `SELECT ` + orderColumns + ` WHERE 1=1`
+	args := []interface{}{}
+
+	if len(filter.Statuses) > 0 {
+		query += ` AND status IN (?` + strings.Repeat(`, ?`, len(filter.Statuses)-1) + `)`
+		for _, status := range filter.Statuses {
+			args = append(args, status)
+		}
+	}
+	if filter.BranchID > 0 {
+		query += ` AND branch_id = ?`
+		args = append(args, filter.BranchID)
+	}
+	if filter.TelegramID != "" {
+		query += ` AND telegram_id = ?`
+		args = append(args, filter.TelegramID)
+	}
+
+	if filter.From != "" {
+		query += ` AND date(created_at, 'localtime') >= ?`
+		args = append(args, filter.From)
+	}
+	if filter.To != "" {
+		query += ` AND date(created_at, 'localtime') <= ?`
+		args = append(args, filter.To)
+	}
+
+	query += ` ORDER BY id DESC`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := r.Q.QueryContext(r.Ctx, query, args...)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	defer rows.Close()
+
+	var orders []*Order
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, shared.NewDatabaseError(err)
+		}
+		orders = append(orders, order)
+	}
+	rows.Close()
+
+	if err := r.loadItems(orders); err != nil {
+		return nil, err
+	}
+
+	result := make([]Order, 0, len(orders))
+	for _, order := range orders {
+		result = append(result, *order)
+	}
+	return result, nil
+}
+
+// loadItems fills in the items of the given orders
+func (r *Repository) loadItems(orders []*Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	index := map[int]*Order{}
+	args := []interface{}{}
+	for _, order := range orders {
+		index[order.ID] = order
+		args = append(args, order.ID)
+	}
+
+	query := `SELECT id, order_id, menu_id, menu_name, COALESCE(options, ''), option_ids, unit_price, quantity, subtotal 
+			  FROM order_items WHERE order_id IN (?` + strings.Repeat(`, ?`, len(args)-1) + `) ORDER BY id`
+	rows, err := r.Q.QueryContext(r.Ctx, query, args...)
+	if err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			item      OrderItem
+			optionIDs string
+		)
+		if err := rows.Scan(&item.ID, &item.OrderID, &item.MenuID, &item.MenuName, &item.Options, &optionIDs,
+			&item.UnitPrice, &item.Quantity, &item.Subtotal); err != nil {
+			return shared.NewDatabaseError(err)
+		}
+		if err := json.Unmarshal([]byte(optionIDs), &item.OptionIDs); err != nil {
+			return shared.NewDatabaseError(err)
+		}
+		if order, ok := index[item.OrderID]; ok {
+			order.Items = append(order.Items, item)
+		}
+	}
+	return nil
+}
+
+// UpdateStatus moves an order to a new status. The current status is part of
+// the update so two admins advancing the same order cannot both succeed.
+func (r *Repository) UpdateStatus(id int, from, to string) error {
+	query := `UPDATE orders SET status = ?, updated_at = CURRENT_TIMESTAMP, 
+			  completed_at = CASE WHEN ? = 'completed' THEN CURRENT_TIMESTAMP ELSE completed_at END 
+			  WHERE id = ? AND status = ?`
+	result, err := r.Q.ExecContext(r.Ctx, query, to, to, id, from)
+	if err != nil {
+		return shared.NewDatabaseError(err)
+	}
+
+	affected, _ := result.RowsAffected()
+	if affected == 0 {
+		return shared.NewKeyedInvalidInputError("error.order_status_changed")
+	}
+	return nil
+}
+
+// ListUndeductedOrders lists completed orders whose ingredients have not
+// been taken from stock yet, oldest first, with their items
+func (r *Repository) ListUndeductedOrders() ([]*Order, error) {
+	rows, err := r.Q.QueryContext(r.Ctx, `SELECT `+orderColumns+` WHERE status = ? AND stock_deducted = 0 ORDER BY id`, StatusCompleted)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	defer rows.Close()
+
+	var orders []*Order
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, shared.NewDatabaseError(err)
+		}
+		orders = append(orders, order)
+	}
+	rows.Close()
+
+	if err := r.loadItems(orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// MarkStockDeducted records that the order's ingredients were taken from stock
+func (r *Repository) MarkStockDeducted(id int) error {
+	if _, err := r.Q.ExecContext(r.Ctx, `UPDATE orders SET stock_deducted = 1 WHERE id = ?`, id); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	return nil
+}
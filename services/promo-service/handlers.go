@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
 	"net/http"
 	"time"
@@ -10,12 +12,21 @@ import (
 
 // Handler handles HTTP requests
 type Handler struct {
-	repo *Repository
+	repo   *Repository
+	events *shared.Outbox
+}
+
+// Events published after a successful action
+var actionEvents = map[string]string{
+	"create":          "promo.created",
+	"update":          "promo.updated",
+	"delete":          "promo.deleted",
+	"set_translation": "promo.updated",
 }
 
 // NewHandler creates a new handler
-func NewHandler(repo *Repository) *Handler {
-	return &Handler{repo: repo}
+func NewHandler(repo *Repository, events *shared.Outbox) *Handler {
+	return &Handler{repo: repo, events: events}
 }
 
 // HandleRequest handles all incoming requests
@@ -31,6 +42,24 @@ func (h *Handler) HandleRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Queries are traced as part of this request
+	h = &Handler{repo: h.repo.WithContext(r.Context()), events: h.events}
+
+	// An action changing data runs in a transaction that also stores the
+	// event announcing the change, so the event is kept exactly when the
+	// change is
+	eventType, announced := actionEvents[req.Action]
+	var tx *sql.Tx
+	if announced {
+		var err error
+		if tx, err = h.repo.Begin(); err != nil {
+			sendErrorResponse(w, shared.NewDatabaseError(err))
+			return
+		}
+		defer tx.Rollback()
+		h = &Handler{repo: h.repo.WithTx(tx), events: h.events}
+	}
+
 	var response *shared.Response
 
 	switch req.Action {
@@ -44,14 +73,65 @@ func (h *Handler) HandleRequest(w http.ResponseWriter, r *http.Request) {
 		response = h.deletePromo(req.Payload)
 	case "list":
 		response = h.listPromos(req.Payload)
+	case "set_translation":
+		response = h.setTranslation(req.Payload)
 	default:
 		sendErrorResponse(w, shared.NewError(shared.ErrCodeInvalidInput, "Unknown action", nil))
 		return
 	}
 
+	if tx != nil && response.Success {
+		if err := h.commit(tx, eventType, eventData(req.Payload, response)); err != nil {
+			response = errorResponse(err)
+		}
+	}
+
 	sendResponse(w, response)
 }
 
+// HandleEvent deletes the promos of branches deleted in info-service,
+// announcing each as promo.deleted so their media goes too. Promos of a
+// branch are not made global, which would offer them everywhere. Deleting
+// them again finds nothing, so redelivery is harmless.
+func (h *Handler) HandleEvent(ctx context.Context, event shared.Event) error {
+	if event.Service != "info-service" || event.Type != "branch.deleted" {
+		return nil
+	}
+	branchID, ok := event.Data["branch_id"].(float64)
+	if !ok {
+		shared.Logger(ctx).Warn("event without branch id")
+		return nil
+	}
+
+	h = &Handler{repo: h.repo.WithContext(ctx), events: h.events}
+	tx, err := h.repo.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	repo := h.repo.WithTx(tx)
+	ids, err := repo.ListBranchPromoIDs(int(branchID))
+	if err != nil || len(ids) == 0 {
+		return err
+	}
+	for _, id := range ids {
+		if err := repo.DeletePromo(id); err != nil {
+			return err
+		}
+		data := map[string]interface{}{"id": id, "branch_id": int(branchID)}
+		if err := h.events.Publish(ctx, tx, "promo.deleted", data); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	h.events.Wake()
+	shared.Logger(ctx).Info("deleted promos of removed branch", "branch_id", int(branchID), "count", len(ids))
+	return nil
+}
+
 // createPromo creates a new promo
 func (h *Handler) createPromo(payload interface{}) *shared.Response {
 	data, ok := payload.(map[string]interface{})
@@ -69,33 +149,34 @@ func (h *Handler) createPromo(payload interface{}) *shared.Response {
 	if val, ok := data["is_active"].(bool); ok {
 		isActive = val
 	}
+	branchID := branchIDFromPayload(data)
 
 	// Validate inputs
 	if err := shared.ValidateNotEmpty(title, "Judul promo"); err != nil {
 		return errorResponse(err.(*shared.AppError))
 	}
 
-	discount, err := shared.ValidatePrice(discountRaw)
-	if err != nil {
-		return errorResponse(err.(*shared.AppError))
-	}
-
 	if discountType != "percentage" && discountType != "amount" {
 		return errorResponse(shared.NewInvalidInputError("Tipe diskon harus 'percentage' atau 'amount'"))
 	}
 
+	discount, appErr := validateDiscount(discountRaw, discountType)
+	if appErr != nil {
+		return errorResponse(appErr)
+	}
+
 	startDate, err := time.Parse("2006-01-02", startDateStr)
 	if err != nil {
-		return errorResponse(shared.NewInvalidInputError("Format tanggal mulai tidak valid (YYYY-MM-DD)"))
+		return errorResponse(shared.NewKeyedInvalidInputError("error.promo_start_date"))
 	}
 
 	endDate, err := time.Parse("2006-01-02", endDateStr)
 	if err != nil {
-		return errorResponse(shared.NewInvalidInputError("Format tanggal akhir tidak valid (YYYY-MM-DD)"))
+		return errorResponse(shared.NewKeyedInvalidInputError("error.promo_end_date"))
 	}
 
 	if endDate.Before(startDate) {
-		return errorResponse(shared.NewInvalidInputError("Tanggal akhir harus setelah tanggal mulai"))
+		return errorResponse(shared.NewKeyedInvalidInputError("error.promo_date_order"))
 	}
 
 	promo := &Promo{
@@ -106,6 +187,7 @@ func (h *Handler) createPromo(payload interface{}) *shared.Response {
 		StartDate:    startDate,
 		EndDate:      endDate,
 		IsActive:     isActive,
+		BranchID:     branchID,
 	}
 
 	result, err := h.repo.CreatePromo(promo)
@@ -135,8 +217,18 @@ func (h *Handler) getPromo(payload interface{}) *shared.Response {
 		return errorResponse(err.(*shared.AppError))
 	}
 
+	translations, err := h.repo.GetPromoTranslations(promo.ID)
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+	if locale, _ := data["locale"].(string); locale != "" {
+		promo.Title = translations.Text(locale, "title", promo.Title)
+		promo.Description = translations.Text(locale, "description", promo.Description)
+	}
+
 	return successResponse(map[string]interface{}{
-		"promo": promo,
+		"promo":        promo,
+		"translations": translations,
 	})
 }
 
@@ -165,36 +257,41 @@ func (h *Handler) updatePromo(payload interface{}) *shared.Response {
 	if desc, ok := data["description"].(string); ok {
 		promo.Description = shared.SanitizeInput(desc)
 	}
-	if discountRaw, ok := data["discount"]; ok {
-		discount, err := shared.ValidatePrice(discountRaw)
-		if err != nil {
-			return errorResponse(err.(*shared.AppError))
-		}
-		promo.Discount = discount
-	}
 	if discountType, ok := data["discount_type"].(string); ok && discountType != "" {
 		if discountType != "percentage" && discountType != "amount" {
 			return errorResponse(shared.NewInvalidInputError("Tipe diskon harus 'percentage' atau 'amount'"))
 		}
 		promo.DiscountType = discountType
 	}
+	if discountRaw, ok := data["discount"]; ok {
+		discount, err := validateDiscount(discountRaw, promo.DiscountType)
+		if err != nil {
+			return errorResponse(err)
+		}
+		promo.Discount = discount
+	} else if promo.DiscountType == "percentage" && promo.Discount > 100 {
+		return errorResponse(shared.NewKeyedInvalidInputError("error.discount_percent_max"))
+	}
 	if startDateStr, ok := data["start_date"].(string); ok && startDateStr != "" {
 		startDate, err := time.Parse("2006-01-02", startDateStr)
 		if err != nil {
-			return errorResponse(shared.NewInvalidInputError("Format tanggal mulai tidak valid"))
+			return errorResponse(shared.NewKeyedInvalidInputError("error.promo_start_date"))
 		}
 		promo.StartDate = startDate
 	}
 	if endDateStr, ok := data["end_date"].(string); ok && endDateStr != "" {
 		endDate, err := time.Parse("2006-01-02", endDateStr)
 		if err != nil {
-			return errorResponse(shared.NewInvalidInputError("Format tanggal akhir tidak valid"))
+			return errorResponse(shared.NewKeyedInvalidInputError("error.promo_end_date"))
 		}
 		promo.EndDate = endDate
 	}
 	if isActive, ok := data["is_active"].(bool); ok {
 		promo.IsActive = isActive
 	}
+	if _, ok := data["branch_id"]; ok {
+		promo.BranchID = branchIDFromPayload(data)
+	}
 
 	if err := h.repo.UpdatePromo(promo); err != nil {
 		return errorResponse(err.(*shared.AppError))
@@ -226,27 +323,123 @@ func (h *Handler) deletePromo(payload interface{}) *shared.Response {
 	})
 }
 
-// listPromos lists promos
+// listPromos lists promos, a page at a time when a limit is given
 func (h *Handler) listPromos(payload interface{}) *shared.Response {
 	activeOnly := false
+	branchID := 0
+	locale := ""
+	var page shared.Page
 
 	if data, ok := payload.(map[string]interface{}); ok {
 		if active, ok := data["active_only"].(bool); ok {
 			activeOnly = active
 		}
+		branchID = branchIDFromPayload(data)
+		locale, _ = data["locale"].(string)
+
+		var err error
+		if page, err = shared.PageFromPayload(data); err != nil {
+			return errorResponse(err.(*shared.AppError))
+		}
 	}
 
-	promos, err := h.repo.ListPromos(activeOnly)
+	promos, total, err := h.repo.ListPromos(activeOnly, branchID, page)
 	if err != nil {
 		return errorResponse(err.(*shared.AppError))
 	}
 
+	// Promos are shown in locale where they have a translation
+	if locale != "" && locale != shared.DefaultLocale {
+		translations, err := h.repo.ListPromoTranslations(locale)
+		if err != nil {
+			return errorResponse(err.(*shared.AppError))
+		}
+		for i := range promos {
+			promo := &promos[i]
+			promo.Title = translations[promo.ID].Text(locale, "title", promo.Title)
+			promo.Description = translations[promo.ID].Text(locale, "description", promo.Description)
+		}
+	}
+
 	return successResponse(map[string]interface{}{
 		"promos": promos,
+		"total":  total,
+	})
+}
+
+// setTranslation stores the translation of a promo title or description in
+// a locale; an empty value removes it
+func (h *Handler) setTranslation(payload interface{}) *shared.Response {
+	data, ok := payload.(map[string]interface{})
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("Invalid payload"))
+	}
+
+	id, ok := data["id"].(float64)
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("ID diperlukan"))
+	}
+	locale, _ := data["locale"].(string)
+	field, _ := data["field"].(string)
+	value, _ := data["value"].(string)
+
+	if err := shared.ValidateTranslation(locale, field, translatableFields...); err != nil {
+		return errorResponse(err)
+	}
+	if _, err := h.repo.GetPromoByID(int(id)); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	if err := h.repo.SetPromoTranslation(int(id), locale, field, shared.SanitizeInput(value)); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	translations, err := h.repo.GetPromoTranslations(int(id))
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"translations": translations,
 	})
 }
 
+// commit stores the event of a successful action and commits its
+// transaction, then has the outbox deliver the event right away
+func (h *Handler) commit(tx *sql.Tx, eventType string, data map[string]interface{}) *shared.AppError {
+	if err := h.events.Publish(h.repo.Ctx, tx, eventType, data); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	h.events.Wake()
+	return nil
+}
+
 // Helper functions
+
+// validateDiscount reads a discount: a whole percentage up to 100, or a
+// rupiah amount in any format shared.ParseMoney accepts ("15rb")
+func validateDiscount(raw interface{}, discountType string) (int, *shared.AppError) {
+	discount, err := shared.ValidatePrice(raw)
+	if err != nil {
+		return 0, err.(*shared.AppError)
+	}
+	if discountType == "percentage" && discount > 100 {
+		return 0, shared.NewKeyedInvalidInputError("error.discount_percent_max")
+	}
+	return int(discount), nil
+}
+
+// branchIDFromPayload reads the optional branch_id field. 0 means the promo
+// applies to (or the listing covers) all branches.
+func branchIDFromPayload(data map[string]interface{}) int {
+	if id, ok := data["branch_id"].(float64); ok && id > 0 {
+		return int(id)
+	}
+	return 0
+}
 func successResponse(data interface{}) *shared.Response {
 	return &shared.Response{
 		Success: true,
@@ -257,10 +450,7 @@ func successResponse(data interface{}) *shared.Response {
 func errorResponse(err *shared.AppError) *shared.Response {
 	return &shared.Response{
 		Success: false,
-		Error: &shared.ErrorInfo{
-			Code:    err.Code,
-			Message: err.Message,
-		},
+		Error:   err.Info(),
 	}
 }
 
@@ -274,3 +464,18 @@ func sendErrorResponse(w http.ResponseWriter, err *shared.AppError) {
 	response := errorResponse(err)
 	json.NewEncoder(w).Encode(response)
 }
+
+// eventData is the payload of an action, plus the id of the promo it
+// created or updated
+func eventData(payload interface{}, response *shared.Response) map[string]interface{} {
+	data, _ := payload.(map[string]interface{})
+	result := make(map[string]interface{}, len(data)+1)
+	for key, value := range data {
+		result[key] = value
+	}
+	answer, _ := response.Data.(map[string]interface{})
+	if promo, ok := answer["promo"].(*Promo); ok && promo != nil {
+		result["id"] = promo.ID
+	}
+	return result
+}
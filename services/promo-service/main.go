@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/alrescha79-cmd/bot-cafe/shared"
 	"github.com/joho/godotenv"
@@ -13,6 +15,8 @@ import (
 func main() {
 	// Load environment variables
 	godotenv.Load()
+	shared.ConfigureLogger("promo-service")
+	shared.ConfigureTracing("promo-service")
 
 	port := os.Getenv("PROMO_SERVICE_PORT")
 	if port == "" {
@@ -37,20 +41,77 @@ func main() {
 		log.Fatalf("Failed to initialize schema: %v", err)
 	}
 
+	// Changes are announced to other services through the outbox
+	events, err := shared.NewOutbox(db, "promo-service")
+	if err != nil {
+		log.Fatalf("Failed to initialize outbox: %v", err)
+	}
+	go events.Run()
+
+	// Announce promos as they end
+	go publishExpiredPromos(repo, events, time.Minute)
+
+	// Events are handled once, even when delivered again
+	dedup, err := shared.NewDBDeduper(db)
+	if err != nil {
+		log.Fatalf("Failed to initialize event log: %v", err)
+	}
+
 	// Initialize handler
-	handler := NewHandler(repo)
+	handler := NewHandler(repo, events)
 
 	// Setup routes
-	http.HandleFunc("/", handler.HandleRequest)
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("OK"))
-	})
+	http.HandleFunc("/", shared.InstrumentRequests(handler.HandleRequest))
+	http.HandleFunc("/events", shared.HandleEvents(dedup, handler.HandleEvent))
+	shared.NewHealth("promo-service").WithDatabase(db).Register()
+	http.HandleFunc("/metrics", shared.MetricsHandler)
 
 	// Start server
 	addr := fmt.Sprintf(":%s", port)
-	shared.LogInfo("Promo service starting on %s", addr)
+	shared.Logger(context.Background()).Info("service starting", "addr", addr)
 	if err := http.ListenAndServe(addr, nil); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// publishExpiredPromos periodically publishes promo.expired for promos whose
+// end date has passed
+func publishExpiredPromos(repo *Repository, events *shared.Outbox, interval time.Duration) {
+	ctx := shared.WithLogFields(context.Background(), "job", "publish_expired_promos")
+	repo = repo.WithContext(ctx)
+	for {
+		promos, err := repo.ListNewlyExpired()
+		if err != nil {
+			shared.Logger(ctx).Error("failed to list expired promos", "error", err)
+		}
+		for _, promo := range promos {
+			if err := publishExpiry(repo, events, promo); err != nil {
+				shared.Logger(ctx).Error("failed to publish promo expiry", "promo_id", promo.ID, "error", err)
+			}
+		}
+		time.Sleep(interval)
+	}
+}
+
+// publishExpiry stores promo.expired and marks the promo as announced in one
+// transaction, so the expiry is announced exactly once
+func publishExpiry(repo *Repository, events *shared.Outbox, promo Promo) error {
+	tx, err := repo.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	data := map[string]interface{}{"id": promo.ID, "title": promo.Title, "branch_id": promo.BranchID}
+	if err := events.Publish(context.Background(), tx, "promo.expired", data); err != nil {
+		return err
+	}
+	if err := repo.WithTx(tx).MarkExpiryPublished(promo.ID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	events.Wake()
+	return nil
+}
@@ -12,6 +12,10 @@ type Promo struct {
 	StartDate    time.Time `json:"start_date"`
 	EndDate      time.Time `json:"end_date"`
 	IsActive     bool      `json:"is_active"`
+	BranchID     int       `json:"branch_id"` // 0 means valid in all branches
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 }
+
+// translatableFields are the promo fields that set_translation translates
+var translatableFields = []string{"title", "description"}
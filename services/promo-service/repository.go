@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"time"
 
@@ -9,14 +10,28 @@ import (
 
 // Repository handles database operations
 type Repository struct {
-	db *sql.DB
+	shared.Store
 }
 
 // NewRepository creates a new repository
 func NewRepository(db *sql.DB) *Repository {
-	return &Repository{db: db}
+	return &Repository{shared.NewStore(db)}
 }
 
+// WithContext returns the repository running its queries with ctx
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{r.Store.WithContext(ctx)}
+}
+
+// WithTx returns the repository running its queries in tx
+func (r *Repository) WithTx(tx *sql.Tx) *Repository {
+	return &Repository{r.Store.WithTx(tx)}
+}
+
+// schemaVersion is recorded in the database by InitSchema and reported by
+// /ready; bump it with every schema change
+const schemaVersion = 4
+
 // InitSchema initializes database schema
 func (r *Repository) InitSchema() error {
 	schema := `
@@ -34,16 +49,40 @@ func (r *Repository) InitSchema() error {
 	);
 	CREATE INDEX IF NOT EXISTS idx_active ON promos(is_active);
 	CREATE INDEX IF NOT EXISTS idx_dates ON promos(start_date, end_date);
+
+	CREATE TABLE IF NOT EXISTS promo_translations (
+		promo_id INTEGER NOT NULL,
+		locale TEXT NOT NULL,
+		field TEXT NOT NULL,
+		value TEXT NOT NULL,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (promo_id, locale, field),
+		FOREIGN KEY (promo_id) REFERENCES promos(id)
+	);
 	`
-	return shared.ExecuteSchema(r.db, schema)
+	if err := shared.ExecuteSchema(r.DB, schema); err != nil {
+		return err
+	}
+
+	// Columns added after the first release
+	if err := shared.AddColumnIfNotExists(r.DB, "promos", "branch_id", "INTEGER NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	if err := shared.ExecuteSchema(r.DB, `CREATE INDEX IF NOT EXISTS idx_branch ON promos(branch_id);`); err != nil {
+		return err
+	}
+	if err := shared.AddColumnIfNotExists(r.DB, "promos", "expiry_published", "BOOLEAN NOT NULL DEFAULT 0"); err != nil {
+		return err
+	}
+	return shared.SetSchemaVersion(r.DB, schemaVersion)
 }
 
 // CreatePromo creates a new promo
 func (r *Repository) CreatePromo(promo *Promo) (*Promo, error) {
-	query := `INSERT INTO promos (title, description, discount, discount_type, start_date, end_date, is_active) 
-			  VALUES (?, ?, ?, ?, ?, ?, ?)`
-	result, err := r.db.Exec(query, promo.Title, promo.Description, promo.Discount, promo.DiscountType,
-		promo.StartDate, promo.EndDate, promo.IsActive)
+	query := `INSERT INTO promos (title, description, discount, discount_type, start_date, end_date, is_active, branch_id) 
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := r.Q.ExecContext(r.Ctx, query, promo.Title, promo.Description, promo.Discount, promo.DiscountType,
+		promo.StartDate, promo.EndDate, promo.IsActive, promo.BranchID)
 	if err != nil {
 		return nil, shared.NewDatabaseError(err)
 	}
@@ -56,12 +95,12 @@ func (r *Repository) CreatePromo(promo *Promo) (*Promo, error) {
 
 // GetPromoByID gets promo by ID
 func (r *Repository) GetPromoByID(id int) (*Promo, error) {
-	query := `SELECT id, title, description, discount, discount_type, start_date, end_date, is_active, created_at, updated_at 
+	query := `SELECT id, title, description, discount, discount_type, start_date, end_date, is_active, branch_id, created_at, updated_at 
 			  FROM promos WHERE id = ?`
 	var promo Promo
-	err := r.db.QueryRow(query, id).Scan(
+	err := r.Q.QueryRowContext(r.Ctx, query, id).Scan(
 		&promo.ID, &promo.Title, &promo.Description, &promo.Discount, &promo.DiscountType,
-		&promo.StartDate, &promo.EndDate, &promo.IsActive, &promo.CreatedAt, &promo.UpdatedAt,
+		&promo.StartDate, &promo.EndDate, &promo.IsActive, &promo.BranchID, &promo.CreatedAt, &promo.UpdatedAt,
 	)
 	if err == sql.ErrNoRows {
 		return nil, shared.NewNotFoundError("Promo")
@@ -72,18 +111,33 @@ func (r *Repository) GetPromoByID(id int) (*Promo, error) {
 	return &promo, nil
 }
 
-// ListPromos lists all promos with optional filters
-func (r *Repository) ListPromos(activeOnly bool) ([]Promo, error) {
-	query := `SELECT id, title, description, discount, discount_type, start_date, end_date, is_active, created_at, updated_at 
+// ListPromos lists the promos on page with optional filters, and the number
+// of promos matching them. A non-zero branchID returns the promos of that
+// branch together with the ones for all branches.
+func (r *Repository) ListPromos(activeOnly bool, branchID int, page shared.Page) ([]Promo, int, error) {
+	query := `SELECT id, title, description, discount, discount_type, start_date, end_date, is_active, branch_id, created_at, updated_at 
 			  FROM promos WHERE 1=1`
+	args := []interface{}{}
 	if activeOnly {
 		query += ` AND is_active = 1 AND start_date <= datetime('now') AND end_date >= datetime('now')`
 	}
-	query += ` ORDER BY start_date DESC`
+	if branchID > 0 {
+		query += ` AND (branch_id = 0 OR branch_id = ?)`
+		args = append(args, branchID)
+	}
+	query += ` ORDER BY start_date DESC, id DESC`
+
+	total := 0
+	if page.Limit > 0 {
+		if err := r.Q.QueryRowContext(r.Ctx, shared.CountQuery(query), args...).Scan(&total); err != nil {
+			return nil, 0, shared.NewDatabaseError(err)
+		}
+	}
 
-	rows, err := r.db.Query(query)
+	query, args = page.Apply(query, args)
+	rows, err := r.Q.QueryContext(r.Ctx, query, args...)
 	if err != nil {
-		return nil, shared.NewDatabaseError(err)
+		return nil, 0, shared.NewDatabaseError(err)
 	}
 	defer rows.Close()
 
@@ -91,21 +145,26 @@ func (r *Repository) ListPromos(activeOnly bool) ([]Promo, error) {
 	for rows.Next() {
 		var promo Promo
 		if err := rows.Scan(&promo.ID, &promo.Title, &promo.Description, &promo.Discount, &promo.DiscountType,
-			&promo.StartDate, &promo.EndDate, &promo.IsActive, &promo.CreatedAt, &promo.UpdatedAt); err != nil {
-			return nil, shared.NewDatabaseError(err)
+			&promo.StartDate, &promo.EndDate, &promo.IsActive, &promo.BranchID, &promo.CreatedAt, &promo.UpdatedAt); err != nil {
+			return nil, 0, shared.NewDatabaseError(err)
 		}
 		promos = append(promos, promo)
 	}
-	return promos, nil
+	if page.Limit == 0 {
+		total = len(promos)
+	}
+	return promos, total, nil
 }
 
 // UpdatePromo updates a promo
 func (r *Repository) UpdatePromo(promo *Promo) error {
+	// A new end date may expire the promo again later
 	query := `UPDATE promos SET title = ?, description = ?, discount = ?, discount_type = ?, 
-			  start_date = ?, end_date = ?, is_active = ?, updated_at = CURRENT_TIMESTAMP 
+			  start_date = ?, end_date = ?, is_active = ?, branch_id = ?, updated_at = CURRENT_TIMESTAMP,
+			  expiry_published = CASE WHEN end_date = ? THEN expiry_published ELSE 0 END
 			  WHERE id = ?`
-	result, err := r.db.Exec(query, promo.Title, promo.Description, promo.Discount, promo.DiscountType,
-		promo.StartDate, promo.EndDate, promo.IsActive, promo.ID)
+	result, err := r.Q.ExecContext(r.Ctx, query, promo.Title, promo.Description, promo.Discount, promo.DiscountType,
+		promo.StartDate, promo.EndDate, promo.IsActive, promo.BranchID, promo.EndDate, promo.ID)
 	if err != nil {
 		return shared.NewDatabaseError(err)
 	}
@@ -119,7 +178,7 @@ func (r *Repository) UpdatePromo(promo *Promo) error {
 // DeletePromo deletes a promo
 func (r *Repository) DeletePromo(id int) error {
 	query := `DELETE FROM promos WHERE id = ?`
-	result, err := r.db.Exec(query, id)
+	result, err := r.Q.ExecContext(r.Ctx, query, id)
 	if err != nil {
 		return shared.NewDatabaseError(err)
 	}
@@ -127,5 +186,119 @@ func (r *Repository) DeletePromo(id int) error {
 	if affected == 0 {
 		return shared.NewNotFoundError("Promo")
 	}
+	if _, err := r.Q.ExecContext(r.Ctx, `DELETE FROM promo_translations WHERE promo_id = ?`, id); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	return nil
+}
+
+// ListBranchPromoIDs lists the promos of one branch
+func (r *Repository) ListBranchPromoIDs(branchID int) ([]int, error) {
+	rows, err := r.Q.QueryContext(r.Ctx, `SELECT id FROM promos WHERE branch_id = ? ORDER BY id`, branchID)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, shared.NewDatabaseError(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// GetPromoTranslations gets every translation of a promo
+func (r *Repository) GetPromoTranslations(promoID int) (shared.Translations, error) {
+	rows, err := r.Q.QueryContext(r.Ctx, `SELECT locale, field, value FROM promo_translations WHERE promo_id = ?`, promoID)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	defer rows.Close()
+
+	translations := shared.Translations{}
+	for rows.Next() {
+		var locale, field, value string
+		if err := rows.Scan(&locale, &field, &value); err != nil {
+			return nil, shared.NewDatabaseError(err)
+		}
+		translations.Set(locale, field, value)
+	}
+	return translations, nil
+}
+
+// ListPromoTranslations gets the translations of all promos in one locale,
+// by promo ID
+func (r *Repository) ListPromoTranslations(locale string) (map[int]shared.Translations, error) {
+	rows, err := r.Q.QueryContext(r.Ctx, `SELECT promo_id, field, value FROM promo_translations WHERE locale = ?`, locale)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	defer rows.Close()
+
+	translations := make(map[int]shared.Translations)
+	for rows.Next() {
+		var promoID int
+		var field, value string
+		if err := rows.Scan(&promoID, &field, &value); err != nil {
+			return nil, shared.NewDatabaseError(err)
+		}
+		if translations[promoID] == nil {
+			translations[promoID] = shared.Translations{}
+		}
+		translations[promoID].Set(locale, field, value)
+	}
+	return translations, nil
+}
+
+// SetPromoTranslation stores the translation of a promo field; an empty
+// value removes it, so the field falls back to the promo's own text
+func (r *Repository) SetPromoTranslation(promoID int, locale, field, value string) error {
+	var err error
+	if value == "" {
+		_, err = r.Q.ExecContext(r.Ctx, `DELETE FROM promo_translations WHERE promo_id = ? AND locale = ? AND field = ?`,
+			promoID, locale, field)
+	} else {
+		_, err = r.Q.ExecContext(r.Ctx, `INSERT INTO promo_translations (promo_id, locale, field, value) VALUES (?, ?, ?, ?)
+			  ON CONFLICT(promo_id, locale, field) DO UPDATE SET value = excluded.value, updated_at = CURRENT_TIMESTAMP`,
+			promoID, locale, field, value)
+	}
+	if err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	return nil
+}
+
+// ListNewlyExpired lists active promos that ended and whose expiry has not
+// been published yet
+func (r *Repository) ListNewlyExpired() ([]Promo, error) {
+	query := `SELECT id, title, description, discount, discount_type, start_date, end_date, is_active, branch_id, created_at, updated_at 
+			  FROM promos WHERE is_active = 1 AND expiry_published = 0 AND end_date < datetime('now')`
+	rows, err := r.Q.QueryContext(r.Ctx, query)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	defer rows.Close()
+
+	var promos []Promo
+	for rows.Next() {
+		var promo Promo
+		if err := rows.Scan(&promo.ID, &promo.Title, &promo.Description, &promo.Discount, &promo.DiscountType,
+			&promo.StartDate, &promo.EndDate, &promo.IsActive, &promo.BranchID, &promo.CreatedAt, &promo.UpdatedAt); err != nil {
+			return nil, shared.NewDatabaseError(err)
+		}
+		promos = append(promos, promo)
+	}
+	return promos, nil
+}
+
+// MarkExpiryPublished records that the expiry of a promo was published
+func (r *Repository) MarkExpiryPublished(id int) error {
+	if _, err := r.Q.ExecContext(r.Ctx, `UPDATE promos SET expiry_published = 1 WHERE id = ?`, id); err != nil {
+		return shared.NewDatabaseError(err)
+	}
 	return nil
 }
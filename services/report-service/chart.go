@@ -0,0 +1,306 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"strings"
+)
+
+// Chart types rendered by the chart action
+const (
+	ChartRevenue     = "revenue"
+	ChartTopItems    = "top_items"
+	ChartCategoryMix = "category_mix"
+	ChartHeatmap     = "heatmap"
+)
+
+const (
+	chartWidth  = 800
+	chartHeight = 450
+)
+
+var (
+	colorBackground = color.RGBA{255, 255, 255, 255}
+	colorText       = color.RGBA{51, 38, 30, 255}
+	colorMuted      = color.RGBA{140, 130, 122, 255}
+	colorGrid       = color.RGBA{230, 224, 218, 255}
+	colorBar        = color.RGBA{156, 102, 68, 255}
+	colorHeatLow    = color.RGBA{246, 240, 233, 255}
+	colorHeatHigh   = color.RGBA{111, 60, 30, 255}
+
+	// Slice colors of the category chart, reused when there are more categories
+	palette = []color.RGBA{
+		{111, 60, 30, 255},
+		{201, 133, 74, 255},
+		{232, 196, 144, 255},
+		{88, 129, 87, 255},
+		{163, 177, 138, 255},
+		{70, 95, 130, 255},
+		{190, 90, 80, 255},
+		{150, 150, 150, 255},
+	}
+
+	weekdayLabels = []string{"Sen", "Sel", "Rab", "Kam", "Jum", "Sab", "Min"}
+)
+
+// RenderChart draws a chart of the report as a PNG image
+func RenderChart(report *Report, kind string) ([]byte, error) {
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, chartHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{colorBackground}, image.Point{}, draw.Src)
+
+	switch kind {
+	case ChartRevenue:
+		drawRevenueChart(img, report)
+	case ChartTopItems:
+		drawTopItemsChart(img, report)
+	case ChartCategoryMix:
+		drawCategoryChart(img, report)
+	case ChartHeatmap:
+		drawHeatmap(img, report)
+	default:
+		return nil, fmt.Errorf("unknown chart type %q", kind)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func drawTitle(img *image.RGBA, title string, report *Report) {
+	drawText(img, 20, 16, title, 2, colorText)
+	subtitle := report.From + " - " + report.To
+	drawText(img, chartWidth-20-textWidth(subtitle, 1), 20, subtitle, 1, colorMuted)
+}
+
+func drawEmpty(img *image.RGBA) {
+	text := "Tidak ada data"
+	drawText(img, (chartWidth-textWidth(text, 2))/2, chartHeight/2, text, 2, colorMuted)
+}
+
+func drawRevenueChart(img *image.RGBA, report *Report) {
+	drawTitle(img, "Pendapatan", report)
+
+	left, top, right, bottom := 90, 60, chartWidth-20, chartHeight-40
+	maxRevenue := 0
+	for _, point := range report.Series {
+		if point.Revenue.Int() > maxRevenue {
+			maxRevenue = point.Revenue.Int()
+		}
+	}
+	if maxRevenue == 0 {
+		drawEmpty(img)
+		return
+	}
+	scaleMax := niceCeil(maxRevenue)
+
+	const gridLines = 4
+	for i := 0; i <= gridLines; i++ {
+		y := bottom - (bottom-top)*i/gridLines
+		fillRect(img, left, y, right-left, 1, colorGrid)
+		label := formatShort(scaleMax * i / gridLines)
+		drawText(img, left-8-textWidth(label, 1), y-3, label, 1, colorMuted)
+	}
+
+	n := len(report.Series)
+	slot := float64(right-left) / float64(n)
+	barWidth := int(slot * 0.7)
+	if barWidth < 1 {
+		barWidth = 1
+	}
+
+	// Skip labels when they would overlap
+	labelWidth := 0
+	for _, point := range report.Series {
+		if w := textWidth(point.Label, 1); w > labelWidth {
+			labelWidth = w
+		}
+	}
+	every := int(math.Ceil(float64(labelWidth+8) / slot))
+	if every < 1 {
+		every = 1
+	}
+
+	for i, point := range report.Series {
+		x := left + int(slot*float64(i)+(slot-float64(barWidth))/2)
+		h := (bottom - top) * point.Revenue.Int() / scaleMax
+		fillRect(img, x, bottom-h, barWidth, h, colorBar)
+
+		if i%every == 0 {
+			center := x + barWidth/2
+			drawText(img, center-textWidth(point.Label, 1)/2, bottom+8, point.Label, 1, colorText)
+		}
+	}
+}
+
+func drawTopItemsChart(img *image.RGBA, report *Report) {
+	drawTitle(img, "Menu Terlaris", report)
+	if len(report.TopItems) == 0 {
+		drawEmpty(img)
+		return
+	}
+
+	left, top, right := 190, 60, chartWidth-70
+	rowHeight := (chartHeight - top - 20) / topItemLimit
+	maxQuantity := report.TopItems[0].Quantity
+
+	for i, item := range report.TopItems {
+		y := top + i*rowHeight
+		name := truncateText(item.Name, 14)
+		drawText(img, 20, y+rowHeight/2-7, name, 2, colorText)
+
+		w := (right - left) * item.Quantity / maxQuantity
+		if w < 1 {
+			w = 1
+		}
+		fillRect(img, left, y+4, w, rowHeight-8, colorBar)
+
+		label := fmt.Sprintf("%d", item.Quantity)
+		drawText(img, left+w+8, y+rowHeight/2-7, label, 2, colorText)
+	}
+}
+
+func drawCategoryChart(img *image.RGBA, report *Report) {
+	drawTitle(img, "Penjualan per Kategori", report)
+	if len(report.Categories) == 0 {
+		drawEmpty(img)
+		return
+	}
+
+	cx, cy, radius := 230, 255, 160.0
+
+	// Slice boundaries as fractions of the full circle, clockwise from 12 o'clock
+	bounds := make([]float64, len(report.Categories))
+	total := 0.0
+	for i, category := range report.Categories {
+		total += category.Share
+		bounds[i] = total / 100
+	}
+
+	for y := cy - int(radius); y <= cy+int(radius); y++ {
+		for x := cx - int(radius); x <= cx+int(radius); x++ {
+			dx, dy := float64(x-cx), float64(y-cy)
+			if dx*dx+dy*dy > radius*radius {
+				continue
+			}
+			angle := math.Atan2(dx, -dy) / (2 * math.Pi)
+			if angle < 0 {
+				angle++
+			}
+			for i, bound := range bounds {
+				if angle <= bound || i == len(bounds)-1 {
+					img.Set(x, y, palette[i%len(palette)])
+					break
+				}
+			}
+		}
+	}
+
+	legendX, legendY := 440, 90
+	for i, category := range report.Categories {
+		y := legendY + i*32
+		if y > chartHeight-30 {
+			break
+		}
+		fillRect(img, legendX, y, 18, 18, palette[i%len(palette)])
+		label := fmt.Sprintf("%s %.0f%%", truncateText(category.Category, 14), category.Share)
+		drawText(img, legendX+30, y+2, label, 2, colorText)
+	}
+}
+
+func drawHeatmap(img *image.RGBA, report *Report) {
+	drawTitle(img, "Pesanan per Jam", report)
+
+	left, top := 60, 70
+	cellWidth := (chartWidth - left - 20) / 24
+	cellHeight := (chartHeight - top - 50) / 7
+
+	maxOrders := 0
+	for _, row := range report.Heatmap {
+		for _, count := range row {
+			if count > maxOrders {
+				maxOrders = count
+			}
+		}
+	}
+
+	for day, row := range report.Heatmap {
+		y := top + day*cellHeight
+		drawText(img, 12, y+cellHeight/2-7, weekdayLabels[day], 2, colorText)
+
+		for hour, count := range row {
+			x := left + hour*cellWidth
+			c := colorHeatLow
+			if maxOrders > 0 && count > 0 {
+				c = blend(colorHeatLow, colorHeatHigh, 0.15+0.85*float64(count)/float64(maxOrders))
+			}
+			fillRect(img, x+1, y+1, cellWidth-2, cellHeight-2, c)
+
+			if count > 0 {
+				label := fmt.Sprintf("%d", count)
+				textColor := colorText
+				if float64(count)/float64(maxOrders) > 0.5 {
+					textColor = colorBackground
+				}
+				drawText(img, x+(cellWidth-textWidth(label, 1))/2, y+cellHeight/2-3, label, 1, textColor)
+			}
+		}
+	}
+
+	for hour := 0; hour < 24; hour += 2 {
+		label := fmt.Sprintf("%d", hour)
+		x := left + hour*cellWidth + (cellWidth-textWidth(label, 1))/2
+		drawText(img, x, top+7*cellHeight+8, label, 1, colorMuted)
+	}
+}
+
+// fillRect fills a w x h rectangle with its top left corner at (x, y)
+func fillRect(img *image.RGBA, x, y, w, h int, c color.Color) {
+	draw.Draw(img, image.Rect(x, y, x+w, y+h), &image.Uniform{c}, image.Point{}, draw.Src)
+}
+
+// blend mixes two colors, t = 0 gives a and t = 1 gives b
+func blend(a, b color.RGBA, t float64) color.RGBA {
+	mix := func(x, y uint8) uint8 {
+		return uint8(float64(x) + (float64(y)-float64(x))*t)
+	}
+	return color.RGBA{mix(a.R, b.R), mix(a.G, b.G), mix(a.B, b.B), 255}
+}
+
+// niceCeil rounds v up to 1, 2, 2.5 or 5 times a power of ten, so axis
+// labels are round numbers
+func niceCeil(v int) int {
+	if v <= 0 {
+		return 1
+	}
+	magnitude := math.Pow(10, math.Floor(math.Log10(float64(v))))
+	for _, step := range []float64{1, 2, 2.5, 5, 10} {
+		if candidate := step * magnitude; candidate >= float64(v) {
+			return int(candidate)
+		}
+	}
+	return int(10 * magnitude)
+}
+
+// formatShort abbreviates rupiah amounts for axis labels, e.g. 150rb, 1,5jt
+func formatShort(v int) string {
+	switch {
+	case v >= 1000000:
+		return trimDecimal(float64(v)/1000000) + "jt"
+	case v >= 1000:
+		return trimDecimal(float64(v)/1000) + "rb"
+	}
+	return fmt.Sprintf("%d", v)
+}
+
+func trimDecimal(v float64) string {
+	s := fmt.Sprintf("%.1f", v)
+	s = strings.TrimSuffix(s, ".0")
+	return strings.Replace(s, ".", ",", 1)
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+)
+
+// Longest range a single report may cover
+const maxReportDays = 366
+
+// Handler handles HTTP requests
+type Handler struct {
+	source *Source
+}
+
+// NewHandler creates a new handler
+func NewHandler(source *Source) *Handler {
+	return &Handler{source: source}
+}
+
+// HandleRequest handles all incoming requests
+func (h *Handler) HandleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, shared.NewError(shared.ErrCodeInvalidInput, "Method not allowed", nil))
+		return
+	}
+
+	var req shared.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, shared.NewError(shared.ErrCodeInvalidInput, "Invalid request format", err))
+		return
+	}
+
+	// Calls to the other services carry this request's ID
+	h = &Handler{source: h.source.WithContext(r.Context())}
+
+	var response *shared.Response
+
+	switch req.Action {
+	case "summary":
+		response = h.summary(req.Payload)
+	case "revenue":
+		response = h.revenue(req.Payload)
+	case "top_items":
+		response = h.topItems(req.Payload)
+	case "category_mix":
+		response = h.categoryMix(req.Payload)
+	case "heatmap":
+		response = h.heatmap(req.Payload)
+	case "promo_effectiveness":
+		response = h.promoEffectiveness(req.Payload)
+	case "chart":
+		response = h.chart(req.Payload)
+	default:
+		sendErrorResponse(w, shared.NewError(shared.ErrCodeInvalidInput, "Unknown action", nil))
+		return
+	}
+
+	sendResponse(w, response)
+}
+
+// summary returns every section of the report
+func (h *Handler) summary(payload interface{}) *shared.Response {
+	report, err := h.buildReport(payload)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	return successResponse(map[string]interface{}{
+		"report": report,
+	})
+}
+
+// revenue returns the totals and the revenue per day, week or month
+func (h *Handler) revenue(payload interface{}) *shared.Response {
+	report, err := h.buildReport(payload)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	return successResponse(map[string]interface{}{
+		"from":             report.From,
+		"to":               report.To,
+		"period":           report.Period,
+		"revenue":          report.Revenue,
+		"orders":           report.Orders,
+		"average_order":    report.AverageOrder,
+		"items_sold":       report.ItemsSold,
+		"cancelled_orders": report.CancelledOrders,
+		"series":           report.Series,
+	})
+}
+
+// topItems returns the best selling menus by quantity
+func (h *Handler) topItems(payload interface{}) *shared.Response {
+	report, err := h.buildReport(payload)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	return successResponse(map[string]interface{}{
+		"from":      report.From,
+		"to":        report.To,
+		"top_items": report.TopItems,
+	})
+}
+
+// categoryMix returns the sales per menu category
+func (h *Handler) categoryMix(payload interface{}) *shared.Response {
+	report, err := h.buildReport(payload)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	return successResponse(map[string]interface{}{
+		"from":       report.From,
+		"to":         report.To,
+		"categories": report.Categories,
+	})
+}
+
+// heatmap returns completed orders by weekday and hour
+func (h *Handler) heatmap(payload interface{}) *shared.Response {
+	report, err := h.buildReport(payload)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	return successResponse(map[string]interface{}{
+		"from":    report.From,
+		"to":      report.To,
+		"heatmap": report.Heatmap,
+	})
+}
+
+// promoEffectiveness compares sales on promo days with the other days
+func (h *Handler) promoEffectiveness(payload interface{}) *shared.Response {
+	report, err := h.buildReport(payload)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	return successResponse(map[string]interface{}{
+		"from":   report.From,
+		"to":     report.To,
+		"promos": report.Promos,
+	})
+}
+
+// chart renders a section of the report as a base64 encoded PNG
+func (h *Handler) chart(payload interface{}) *shared.Response {
+	data, _ := payload.(map[string]interface{})
+	kind, _ := data["type"].(string)
+	switch kind {
+	case ChartRevenue, ChartTopItems, ChartCategoryMix, ChartHeatmap:
+	default:
+		return errorResponse(shared.NewInvalidInputError("type harus revenue, top_items, category_mix, atau heatmap"))
+	}
+
+	report, err := h.buildReport(payload)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	image, renderErr := RenderChart(report, kind)
+	if renderErr != nil {
+		return errorResponse(shared.NewInternalError(renderErr))
+	}
+
+	return successResponse(map[string]interface{}{
+		"type":         kind,
+		"content_type": "image/png",
+		"image":        base64.StdEncoding.EncodeToString(image),
+	})
+}
+
+// buildReport reads the range (from/to, YYYY-MM-DD, default the last 7
+// days), branch_id and period of a payload and builds the report
+func (h *Handler) buildReport(payload interface{}) (*Report, *shared.AppError) {
+	data, _ := payload.(map[string]interface{})
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	today := dayOf(time.Now())
+	to, err := dateFromPayload(data, "to", today)
+	if err != nil {
+		return nil, err
+	}
+	from, err := dateFromPayload(data, "from", to.AddDate(0, 0, -6))
+	if err != nil {
+		return nil, err
+	}
+	if from.After(to) {
+		return nil, shared.NewKeyedInvalidInputError("error.report_date_order")
+	}
+	if daysBetween(from, to) > maxReportDays {
+		return nil, shared.NewKeyedInvalidInputError("error.report_range_max", strconv.Itoa(maxReportDays))
+	}
+
+	period, _ := data["period"].(string)
+	switch period {
+	case "":
+		period = defaultPeriod(from, to)
+	case PeriodDay, PeriodWeek, PeriodMonth:
+	default:
+		return nil, shared.NewInvalidInputError("period harus day, week, atau month")
+	}
+
+	branchID := branchIDFromPayload(data)
+
+	orders, fetchErr := h.source.Orders(from.Format(dateLayout), to.Format(dateLayout), branchID)
+	if fetchErr != nil {
+		return nil, fetchErr.(*shared.AppError)
+	}
+	categories, fetchErr := h.source.MenuCategories()
+	if fetchErr != nil {
+		return nil, fetchErr.(*shared.AppError)
+	}
+	promos, fetchErr := h.source.Promos(branchID)
+	if fetchErr != nil {
+		return nil, fetchErr.(*shared.AppError)
+	}
+
+	return BuildReport(from, to, branchID, period, orders, categories, promos), nil
+}
+
+// Helper functions
+
+// dateFromPayload reads an optional YYYY-MM-DD field as a local date
+func dateFromPayload(data map[string]interface{}, field string, fallback time.Time) (time.Time, *shared.AppError) {
+	value, _ := data[field].(string)
+	if value == "" {
+		return fallback, nil
+	}
+	t, err := time.ParseInLocation(dateLayout, value, time.Local)
+	if err != nil {
+		return time.Time{}, shared.NewKeyedInvalidInputError("error.field_date_format", field)
+	}
+	return t, nil
+}
+
+// branchIDFromPayload reads the optional branch_id field. 0 means every branch.
+func branchIDFromPayload(data map[string]interface{}) int {
+	if id, ok := data["branch_id"].(float64); ok && id > 0 {
+		return int(id)
+	}
+	return 0
+}
+
+func successResponse(data interface{}) *shared.Response {
+	return &shared.Response{
+		Success: true,
+		Data:    data,
+	}
+}
+
+func errorResponse(err *shared.AppError) *shared.Response {
+	return &shared.Response{
+		Success: false,
+		Error:   err.Info(),
+	}
+}
+
+func sendResponse(w http.ResponseWriter, response *shared.Response) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func sendErrorResponse(w http.ResponseWriter, err *shared.AppError) {
+	w.Header().Set("Content-Type", "application/json")
+	response := errorResponse(err)
+	json.NewEncoder(w).Encode(response)
+}
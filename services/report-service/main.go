@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	// Load environment variables
+	godotenv.Load()
+	shared.ConfigureLogger("report-service")
+	shared.ConfigureTracing("report-service")
+
+	port := os.Getenv("REPORT_SERVICE_PORT")
+	if port == "" {
+		port = "8087"
+	}
+
+	orderServiceURL := shared.ServiceURL("order-service")
+	menuServiceURL := shared.ServiceURL("menu-service")
+	promoServiceURL := shared.ServiceURL("promo-service")
+
+	// Initialize handler
+	handler := NewHandler(NewSource(orderServiceURL, menuServiceURL, promoServiceURL))
+
+	// Setup routes
+	http.HandleFunc("/", shared.InstrumentRequests(handler.HandleRequest))
+	shared.NewHealth("report-service").
+		DependsOn("order-service", orderServiceURL).
+		DependsOn("menu-service", menuServiceURL).
+		DependsOn("promo-service", promoServiceURL).Register()
+	http.HandleFunc("/metrics", shared.MetricsHandler)
+
+	// Start server
+	addr := fmt.Sprintf(":%s", port)
+	shared.Logger(context.Background()).Info("service starting", "addr", addr)
+	if err := http.ListenAndServe(addr, nil); err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+}
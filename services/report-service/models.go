@@ -0,0 +1,106 @@
+package main
+
+import (
+	"time"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+)
+
+// Report periods used to group revenue
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+// Report aggregates the completed orders of a date range. From and To are
+// inclusive local dates (YYYY-MM-DD); BranchID 0 covers every branch.
+type Report struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	BranchID int    `json:"branch_id"`
+	Period   string `json:"period"`
+
+	Revenue         shared.Money `json:"revenue"`
+	Orders          int          `json:"orders"`
+	AverageOrder    shared.Money `json:"average_order"`
+	ItemsSold       int          `json:"items_sold"`
+	CancelledOrders int          `json:"cancelled_orders"`
+
+	Series     []SeriesPoint  `json:"series"`
+	TopItems   []ItemStat     `json:"top_items"`
+	Categories []CategoryStat `json:"categories"`
+	Heatmap    [7][24]int     `json:"heatmap"` // completed orders by weekday (0 = Senin) and hour
+	Promos     []PromoStat    `json:"promos"`
+}
+
+// SeriesPoint is the revenue of one day, week or month
+type SeriesPoint struct {
+	Label   string       `json:"label"`
+	Start   string       `json:"start"`
+	Revenue shared.Money `json:"revenue"`
+	Orders  int          `json:"orders"`
+}
+
+// ItemStat is the sales of a single menu
+type ItemStat struct {
+	MenuID   int          `json:"menu_id"`
+	Name     string       `json:"name"`
+	Quantity int          `json:"quantity"`
+	Revenue  shared.Money `json:"revenue"`
+}
+
+// CategoryStat is the sales of a menu category
+type CategoryStat struct {
+	Category string       `json:"category"`
+	Quantity int          `json:"quantity"`
+	Revenue  shared.Money `json:"revenue"`
+	Share    float64      `json:"share"` // percentage of total revenue
+}
+
+// PromoStat compares sales on the days a promo ran with the other days of
+// the range. LiftPercent is nil when the promo ran on every day of the range.
+type PromoStat struct {
+	PromoID              int          `json:"promo_id"`
+	Title                string       `json:"title"`
+	BranchID             int          `json:"branch_id"`
+	DaysActive           int          `json:"days_active"`
+	AvgDailyRevenue      shared.Money `json:"avg_daily_revenue"`
+	AvgDailyRevenueOther shared.Money `json:"avg_daily_revenue_other"`
+	AvgDailyOrders       float64      `json:"avg_daily_orders"`
+	AvgDailyOrdersOther  float64      `json:"avg_daily_orders_other"`
+	LiftPercent          *float64     `json:"lift_percent"`
+}
+
+// Order is a customer order as returned by order-service
+type Order struct {
+	ID        int          `json:"id"`
+	BranchID  int          `json:"branch_id"`
+	Status    string       `json:"status"`
+	Items     []OrderItem  `json:"items"`
+	Total     shared.Money `json:"total"`
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// OrderItem is one menu line of an order
+type OrderItem struct {
+	MenuID   int          `json:"menu_id"`
+	MenuName string       `json:"menu_name"`
+	Quantity int          `json:"quantity"`
+	Subtotal shared.Money `json:"subtotal"`
+}
+
+// Menu is the part of a menu-service menu needed for category reports
+type Menu struct {
+	ID       int    `json:"id"`
+	Category string `json:"category"`
+}
+
+// Promo is a promo as returned by promo-service
+type Promo struct {
+	ID        int       `json:"id"`
+	Title     string    `json:"title"`
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date"`
+	BranchID  int       `json:"branch_id"`
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+)
+
+const (
+	dateLayout   = "2006-01-02"
+	topItemLimit = 10
+
+	// Orders of menus that no longer exist in menu-service
+	unknownCategory = "Lainnya"
+)
+
+var monthNames = []string{"Jan", "Feb", "Mar", "Apr", "Mei", "Jun", "Jul", "Agu", "Sep", "Okt", "Nov", "Des"}
+
+// defaultPeriod picks a grouping that keeps the revenue series readable
+func defaultPeriod(from, to time.Time) string {
+	days := daysBetween(from, to)
+	switch {
+	case days <= 31:
+		return PeriodDay
+	case days <= 120:
+		return PeriodWeek
+	default:
+		return PeriodMonth
+	}
+}
+
+// daysBetween counts the days of an inclusive date range
+func daysBetween(from, to time.Time) int {
+	return int(to.Sub(from).Hours()/24+0.5) + 1
+}
+
+// dayOf truncates a time to its local date
+func dayOf(t time.Time) time.Time {
+	t = t.Local()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// periodStart returns the first day of the period containing day. Weeks
+// start on Monday.
+func periodStart(day time.Time, period string) time.Time {
+	switch period {
+	case PeriodWeek:
+		return day.AddDate(0, 0, -weekday(day))
+	case PeriodMonth:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.Local)
+	}
+	return day
+}
+
+func nextPeriod(start time.Time, period string) time.Time {
+	switch period {
+	case PeriodWeek:
+		return start.AddDate(0, 0, 7)
+	case PeriodMonth:
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+func periodLabel(start time.Time, period string) string {
+	if period == PeriodMonth {
+		return fmt.Sprintf("%s %d", monthNames[start.Month()-1], start.Year())
+	}
+	return start.Format("02/01")
+}
+
+// weekday numbers days from Monday (0) to Sunday (6)
+func weekday(t time.Time) int {
+	return (int(t.Weekday()) + 6) % 7
+}
+
+// dailySales is the completed revenue and order count of one day
+type dailySales struct {
+	revenue shared.Money
+	orders  int
+}
+
+// BuildReport aggregates orders placed between from and to (local dates,
+// inclusive). Only completed orders count as sales.
+func BuildReport(from, to time.Time, branchID int, period string, orders []Order, categories map[int]string, promos []Promo) *Report {
+	report := &Report{
+		From:       from.Format(dateLayout),
+		To:         to.Format(dateLayout),
+		BranchID:   branchID,
+		Period:     period,
+		Series:     []SeriesPoint{},
+		TopItems:   []ItemStat{},
+		Categories: []CategoryStat{},
+		Promos:     []PromoStat{},
+	}
+
+	// Revenue series, with empty periods included so gaps show
+	seriesIndex := map[string]int{}
+	for start := periodStart(from, period); !start.After(to); start = nextPeriod(start, period) {
+		seriesIndex[start.Format(dateLayout)] = len(report.Series)
+		report.Series = append(report.Series, SeriesPoint{
+			Label: periodLabel(start, period),
+			Start: start.Format(dateLayout),
+		})
+	}
+
+	items := map[int]*ItemStat{}
+	categoryStats := map[string]*CategoryStat{}
+	// Sales per day and branch, used to compare promo days with other days
+	daily := map[string]map[int]*dailySales{}
+
+	for _, order := range orders {
+		if order.Status == "cancelled" {
+			report.CancelledOrders++
+			continue
+		}
+		if order.Status != "completed" {
+			continue
+		}
+
+		day := dayOf(order.CreatedAt)
+		if day.Before(from) || day.After(to) {
+			continue
+		}
+
+		report.Revenue += order.Total
+		report.Orders++
+
+		if i, ok := seriesIndex[periodStart(day, period).Format(dateLayout)]; ok {
+			report.Series[i].Revenue += order.Total
+			report.Series[i].Orders++
+		}
+
+		local := order.CreatedAt.Local()
+		report.Heatmap[weekday(local)][local.Hour()]++
+
+		key := day.Format(dateLayout)
+		if daily[key] == nil {
+			daily[key] = map[int]*dailySales{}
+		}
+		if daily[key][order.BranchID] == nil {
+			daily[key][order.BranchID] = &dailySales{}
+		}
+		daily[key][order.BranchID].revenue += order.Total
+		daily[key][order.BranchID].orders++
+
+		for _, item := range order.Items {
+			report.ItemsSold += item.Quantity
+
+			stat, ok := items[item.MenuID]
+			if !ok {
+				stat = &ItemStat{MenuID: item.MenuID}
+				items[item.MenuID] = stat
+			}
+			stat.Name = item.MenuName
+			stat.Quantity += item.Quantity
+			stat.Revenue += item.Subtotal
+
+			category, ok := categories[item.MenuID]
+			if !ok || category == "" {
+				category = unknownCategory
+			}
+			cs, ok := categoryStats[category]
+			if !ok {
+				cs = &CategoryStat{Category: category}
+				categoryStats[category] = cs
+			}
+			cs.Quantity += item.Quantity
+			cs.Revenue += item.Subtotal
+		}
+	}
+
+	if report.Orders > 0 {
+		report.AverageOrder = report.Revenue.Div(report.Orders)
+	}
+
+	for _, stat := range items {
+		report.TopItems = append(report.TopItems, *stat)
+	}
+	sort.Slice(report.TopItems, func(i, j int) bool {
+		a, b := report.TopItems[i], report.TopItems[j]
+		if a.Quantity != b.Quantity {
+			return a.Quantity > b.Quantity
+		}
+		if a.Revenue != b.Revenue {
+			return a.Revenue > b.Revenue
+		}
+		return a.Name < b.Name
+	})
+	if len(report.TopItems) > topItemLimit {
+		report.TopItems = report.TopItems[:topItemLimit]
+	}
+
+	// Order lines are priced per line, so shares are taken from line totals
+	var lineRevenue shared.Money
+	for _, cs := range categoryStats {
+		lineRevenue += cs.Revenue
+	}
+	for _, cs := range categoryStats {
+		if lineRevenue > 0 {
+			cs.Share = float64(cs.Revenue) * 100 / float64(lineRevenue)
+		}
+		report.Categories = append(report.Categories, *cs)
+	}
+	sort.Slice(report.Categories, func(i, j int) bool {
+		if report.Categories[i].Revenue != report.Categories[j].Revenue {
+			return report.Categories[i].Revenue > report.Categories[j].Revenue
+		}
+		return report.Categories[i].Category < report.Categories[j].Category
+	})
+
+	for _, promo := range promos {
+		if stat, ok := promoEffectiveness(promo, from, to, branchID, daily); ok {
+			report.Promos = append(report.Promos, stat)
+		}
+	}
+
+	return report
+}
+
+// promoEffectiveness compares average daily sales on the days a promo ran
+// with the other days of the range. A branch promo only counts the sales of
+// its own branch. ok is false when the promo did not run in the range.
+func promoEffectiveness(promo Promo, from, to time.Time, branchID int, daily map[string]map[int]*dailySales) (PromoStat, bool) {
+	stat := PromoStat{PromoID: promo.ID, Title: promo.Title, BranchID: promo.BranchID}
+	// Promo dates are calendar dates stored as midnight UTC; keep the date as is
+	start := time.Date(promo.StartDate.Year(), promo.StartDate.Month(), promo.StartDate.Day(), 0, 0, 0, 0, time.Local)
+	end := time.Date(promo.EndDate.Year(), promo.EndDate.Month(), promo.EndDate.Day(), 0, 0, 0, 0, time.Local)
+
+	var during, other dailySales
+	otherDays := 0
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		var sales dailySales
+		for branch, s := range daily[day.Format(dateLayout)] {
+			if promo.BranchID == 0 || promo.BranchID == branch || branchID != 0 {
+				sales.revenue += s.revenue
+				sales.orders += s.orders
+			}
+		}
+
+		if !day.Before(start) && !day.After(end) {
+			stat.DaysActive++
+			during.revenue += sales.revenue
+			during.orders += sales.orders
+		} else {
+			otherDays++
+			other.revenue += sales.revenue
+			other.orders += sales.orders
+		}
+	}
+
+	if stat.DaysActive == 0 {
+		return stat, false
+	}
+
+	stat.AvgDailyRevenue = during.revenue.Div(stat.DaysActive)
+	stat.AvgDailyOrders = float64(during.orders) / float64(stat.DaysActive)
+	if otherDays > 0 {
+		stat.AvgDailyRevenueOther = other.revenue.Div(otherDays)
+		stat.AvgDailyOrdersOther = float64(other.orders) / float64(otherDays)
+		if stat.AvgDailyRevenueOther > 0 {
+			lift := (float64(stat.AvgDailyRevenue) - float64(stat.AvgDailyRevenueOther)) * 100 / float64(stat.AvgDailyRevenueOther)
+			stat.LiftPercent = &lift
+		}
+	}
+	return stat, true
+}
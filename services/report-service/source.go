@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+)
+
+// Source reads the data reports are built from. Reports own no database;
+// orders, menus and promos are fetched from their services on every request.
+type Source struct {
+	client          *shared.HTTPClient
+	orderServiceURL string
+	menuServiceURL  string
+	promoServiceURL string
+}
+
+// NewSource creates a new source
+func NewSource(orderServiceURL, menuServiceURL, promoServiceURL string) *Source {
+	return &Source{
+		client:          shared.NewHTTPClient(),
+		orderServiceURL: orderServiceURL,
+		menuServiceURL:  menuServiceURL,
+		promoServiceURL: promoServiceURL,
+	}
+}
+
+// WithContext returns a copy of the source fetching with ctx
+func (s *Source) WithContext(ctx context.Context) *Source {
+	source := *s
+	source.client = s.client.WithContext(ctx)
+	return &source
+}
+
+// Orders lists every order placed between from and to (inclusive)
+func (s *Source) Orders(from, to string, branchID int) ([]Order, error) {
+	var orders []Order
+	err := s.fetch(s.orderServiceURL, "Order service", "list", map[string]interface{}{
+		"from":      from,
+		"to":        to,
+		"branch_id": branchID,
+	}, "orders", &orders)
+	return orders, err
+}
+
+// MenuCategories maps menu IDs to their category
+func (s *Source) MenuCategories() (map[int]string, error) {
+	var menus []Menu
+	if err := s.fetch(s.menuServiceURL, "Menu service", "list", map[string]interface{}{}, "menus", &menus); err != nil {
+		return nil, err
+	}
+
+	categories := make(map[int]string, len(menus))
+	for _, menu := range menus {
+		categories[menu.ID] = menu.Category
+	}
+	return categories, nil
+}
+
+// Promos lists the promos valid in a branch, or every promo for branch 0
+func (s *Source) Promos(branchID int) ([]Promo, error) {
+	var promos []Promo
+	err := s.fetch(s.promoServiceURL, "Promo service", "list", map[string]interface{}{
+		"branch_id": branchID,
+	}, "promos", &promos)
+	return promos, err
+}
+
+// fetch calls an action of a service and decodes one field of its data
+func (s *Source) fetch(url, service, action string, payload map[string]interface{}, field string, target interface{}) error {
+	resp, err := s.client.Post(url, shared.Request{Action: action, Payload: payload})
+	if err != nil {
+		return shared.NewKeyedError(shared.ErrCodeServiceError, "error.service_unreachable", err, service)
+	}
+	if !resp.Success {
+		if resp.Error != nil {
+			return shared.ErrorFromInfo(resp.Error)
+		}
+		return shared.NewKeyedError(shared.ErrCodeServiceError, "error.service_failed", nil, service)
+	}
+
+	data, _ := resp.Data.(map[string]interface{})
+	raw, err := json.Marshal(data[field])
+	if err != nil {
+		return shared.NewInternalError(err)
+	}
+	if err := json.Unmarshal(raw, target); err != nil {
+		return shared.NewKeyedError(shared.ErrCodeServiceError, "error.service_invalid_data", err, service)
+	}
+	return nil
+}
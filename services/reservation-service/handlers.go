@@ -0,0 +1,519 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+)
+
+// Handler handles HTTP requests
+type Handler struct {
+	repo           *Repository
+	client         *shared.HTTPClient
+	infoServiceURL string
+	settings       Settings
+
+	// bookings checks and books tables for one booking at a time, so
+	// two guests cannot get the same table
+	bookings *sync.Mutex
+}
+
+// NewHandler creates a new handler
+func NewHandler(repo *Repository, infoServiceURL string, settings Settings) *Handler {
+	return &Handler{
+		repo:           repo,
+		client:         shared.NewHTTPClient(),
+		infoServiceURL: infoServiceURL,
+		settings:       settings,
+		bookings:       &sync.Mutex{},
+	}
+}
+
+// withContext returns a copy of the handler making its queries and service
+// calls with ctx. A booking is made even when the caller gives up, so
+// cancellation is not passed on.
+func (h *Handler) withContext(ctx context.Context) *Handler {
+	ctx = context.WithoutCancel(ctx)
+	handler := *h
+	handler.repo = h.repo.WithContext(ctx)
+	handler.client = h.client.WithContext(ctx)
+	return &handler
+}
+
+// HandleRequest handles all incoming requests
+func (h *Handler) HandleRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		sendErrorResponse(w, shared.NewError(shared.ErrCodeInvalidInput, "Method not allowed", nil))
+		return
+	}
+
+	var req shared.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		sendErrorResponse(w, shared.NewError(shared.ErrCodeInvalidInput, "Invalid request format", err))
+		return
+	}
+
+	// Queries and calls to info-service are traced as part of this request
+	h = h.withContext(r.Context())
+
+	data, ok := req.Payload.(map[string]interface{})
+	if !ok {
+		data = map[string]interface{}{}
+	}
+
+	var response *shared.Response
+
+	switch req.Action {
+	case "slots":
+		response = h.listSlots(data)
+	case "create":
+		response = h.createReservation(data)
+	case "read":
+		response = h.getReservation(data)
+	case "list":
+		response = h.listReservations(data)
+	case "update_status":
+		response = h.updateStatus(data)
+	case "due_reminders":
+		response = h.dueReminders()
+	case "mark_reminded":
+		response = h.markReminded(data)
+	case "create_table":
+		response = h.createTable(data)
+	case "list_tables":
+		response = h.listTables(data)
+	case "delete_table":
+		response = h.deleteTable(data)
+	default:
+		sendErrorResponse(w, shared.NewError(shared.ErrCodeInvalidInput, "Unknown action", nil))
+		return
+	}
+
+	sendResponse(w, response)
+}
+
+// TABLES
+
+func (h *Handler) createTable(data map[string]interface{}) *shared.Response {
+	name, _ := data["name"].(string)
+	area, _ := data["area"].(string)
+	capacity, _ := data["capacity"].(float64)
+
+	if err := shared.ValidateNotEmpty(name, "Nama meja"); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+	if capacity < 1 || capacity != float64(int(capacity)) {
+		return errorResponse(shared.NewKeyedInvalidInputError("error.table_capacity"))
+	}
+
+	table, err := h.repo.CreateTable(&Table{
+		BranchID: branchIDFromPayload(data),
+		Name:     shared.SanitizeInput(name),
+		Capacity: int(capacity),
+		Area:     shared.SanitizeInput(area),
+	})
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"table": table,
+	})
+}
+
+func (h *Handler) listTables(data map[string]interface{}) *shared.Response {
+	tables, err := h.repo.ListTables(branchIDFromPayload(data))
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"tables": tables,
+	})
+}
+
+func (h *Handler) deleteTable(data map[string]interface{}) *shared.Response {
+	id, ok := data["id"].(float64)
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("ID diperlukan"))
+	}
+
+	if err := h.repo.DeleteTable(int(id)); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"message": "Meja berhasil dihapus",
+	})
+}
+
+// SLOTS AND BOOKINGS
+
+// openingHours asks info-service when a branch opens and closes
+func (h *Handler) openingHours(branchID int) (opening, closing time.Duration, appErr *shared.AppError) {
+	resp, err := h.client.Post(h.infoServiceURL, shared.Request{
+		Action:  "read",
+		Payload: map[string]interface{}{"branch_id": branchID},
+	})
+	if err != nil {
+		return 0, 0, shared.NewKeyedError(shared.ErrCodeServiceError, "error.service_unreachable", err, "Info service")
+	}
+	if !resp.Success {
+		if resp.Error != nil {
+			return 0, 0, shared.ErrorFromInfo(resp.Error)
+		}
+		return 0, 0, shared.NewKeyedError(shared.ErrCodeServiceError, "error.opening_hours_failed", nil)
+	}
+
+	info, _ := resp.Data.(map[string]interface{})["info"].(map[string]interface{})
+	openingHour, _ := info["opening_hour"].(string)
+	closingHour, _ := info["closing_hour"].(string)
+	opening, okOpening := parseClock(openingHour)
+	closing, okClosing := parseClock(closingHour)
+	if !okOpening || !okClosing {
+		return 0, 0, shared.NewKeyedError(shared.ErrCodeServiceError,
+			"error.opening_hours_invalid", nil, openingHour, closingHour)
+	}
+	return opening, closing, nil
+}
+
+// bookingDay reads the date and party size of a slots or create request
+// and checks the date can be booked
+func (h *Handler) bookingDay(data map[string]interface{}) (day time.Time, partySize int, appErr *shared.AppError) {
+	date, _ := data["date"].(string)
+	day, ok := parseDate(date)
+	if !ok {
+		return day, 0, shared.NewKeyedInvalidInputError("error.date_format")
+	}
+	today, _ := parseDate(time.Now().Format(dateLayout))
+	if day.Before(today) {
+		return day, 0, shared.NewKeyedInvalidInputError("error.reservation_past")
+	}
+	if day.After(today.AddDate(0, 0, h.settings.DaysAhead)) {
+		return day, 0, shared.NewKeyedInvalidInputError(
+			"error.reservation_too_far", strconv.Itoa(h.settings.DaysAhead))
+	}
+
+	size, _ := data["party_size"].(float64)
+	if size < 1 || size != float64(int(size)) {
+		return day, 0, shared.NewKeyedInvalidInputError("error.party_size")
+	}
+	return day, int(size), nil
+}
+
+// daySchedule returns the start times of a day at a branch with the tables
+// and the bookings holding them
+func (h *Handler) daySchedule(branchID int, day time.Time) ([]time.Time, []Table, []Reservation, *shared.AppError) {
+	opening, closing, appErr := h.openingHours(branchID)
+	if appErr != nil {
+		return nil, nil, nil, appErr
+	}
+
+	tables, err := h.repo.ListTables(branchID)
+	if err != nil {
+		return nil, nil, nil, err.(*shared.AppError)
+	}
+	date := day.Format(dateLayout)
+	bookings, _, err := h.repo.ListReservations(ReservationFilter{
+		Statuses: activeStatuses,
+		BranchID: branchID,
+		From:     date,
+		To:       date,
+	})
+	if err != nil {
+		return nil, nil, nil, err.(*shared.AppError)
+	}
+
+	return daySlots(day, opening, closing, h.settings, time.Now()), tables, bookings, nil
+}
+
+// listSlots lists the start times of a day and whether a table fits the
+// party at each
+func (h *Handler) listSlots(data map[string]interface{}) *shared.Response {
+	day, partySize, appErr := h.bookingDay(data)
+	if appErr != nil {
+		return errorResponse(appErr)
+	}
+	branchID := branchIDFromPayload(data)
+
+	starts, tables, bookings, appErr := h.daySchedule(branchID, day)
+	if appErr != nil {
+		return errorResponse(appErr)
+	}
+
+	slots := []Slot{}
+	for _, start := range starts {
+		slots = append(slots, Slot{
+			Time:      start.Format(clockLayout),
+			Available: pickTable(tables, bookings, partySize, start, h.settings.Duration) != nil,
+		})
+	}
+
+	return successResponse(map[string]interface{}{
+		"date":           day.Format(dateLayout),
+		"slots":          slots,
+		"max_party_size": maxCapacity(tables),
+	})
+}
+
+// createReservation books the smallest free table fitting the party. The
+// booking waits for an admin to confirm it.
+func (h *Handler) createReservation(data map[string]interface{}) *shared.Response {
+	telegramID, _ := data["telegram_id"].(string)
+	customerName, _ := data["customer_name"].(string)
+	clock, _ := data["time"].(string)
+	note, _ := data["note"].(string)
+
+	if err := shared.ValidateNotEmpty(telegramID, "Telegram ID"); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+	day, partySize, appErr := h.bookingDay(data)
+	if appErr != nil {
+		return errorResponse(appErr)
+	}
+	branchID := branchIDFromPayload(data)
+
+	h.bookings.Lock()
+	defer h.bookings.Unlock()
+
+	starts, tables, bookings, appErr := h.daySchedule(branchID, day)
+	if appErr != nil {
+		return errorResponse(appErr)
+	}
+	if partySize > maxCapacity(tables) {
+		return errorResponse(shared.NewKeyedInvalidInputError(
+			"error.no_table_for_party", strconv.Itoa(partySize)))
+	}
+
+	var start time.Time
+	for _, slot := range starts {
+		if slot.Format(clockLayout) == clock {
+			start = slot
+		}
+	}
+	if start.IsZero() {
+		return errorResponse(shared.NewKeyedInvalidInputError("error.slot_unavailable"))
+	}
+	table := pickTable(tables, bookings, partySize, start, h.settings.Duration)
+	if table == nil {
+		return errorResponse(shared.NewKeyedInvalidInputError("error.slot_full"))
+	}
+
+	reservation, err := h.repo.CreateReservation(&Reservation{
+		BranchID:     branchID,
+		TableID:      table.ID,
+		TelegramID:   telegramID,
+		CustomerName: shared.SanitizeInput(customerName),
+		PartySize:    partySize,
+		Date:         day.Format(dateLayout),
+		Time:         clock,
+		Note:         shared.SanitizeInput(note),
+	})
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"reservation":   reservation,
+		"next_statuses": allowedNextStatuses(reservation.Status),
+	})
+}
+
+func (h *Handler) getReservation(data map[string]interface{}) *shared.Response {
+	id, ok := data["id"].(float64)
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("ID diperlukan"))
+	}
+
+	reservation, err := h.repo.GetReservation(int(id))
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"reservation":   reservation,
+		"next_statuses": allowedNextStatuses(reservation.Status),
+	})
+}
+
+// listReservations lists reservations. status may be a single status or
+// "active" for bookings still waiting or confirmed; upcoming leaves out the
+// days before today.
+func (h *Handler) listReservations(data map[string]interface{}) *shared.Response {
+	page, err := shared.PageFromPayload(data)
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+	filter := ReservationFilter{Page: page}
+
+	switch status, _ := data["status"].(string); status {
+	case "":
+	case "active":
+		filter.Statuses = activeStatuses
+	default:
+		filter.Statuses = []string{status}
+	}
+	if v, ok := data["branch_id"].(float64); ok {
+		filter.BranchID = int(v)
+	}
+	filter.TelegramID, _ = data["telegram_id"].(string)
+	filter.From, _ = data["from"].(string)
+	filter.To, _ = data["to"].(string)
+	for _, date := range []string{filter.From, filter.To} {
+		if _, ok := parseDate(date); date != "" && !ok {
+			return errorResponse(shared.NewKeyedInvalidInputError("error.date_format"))
+		}
+	}
+	if upcoming, _ := data["upcoming"].(bool); upcoming && filter.From == "" {
+		filter.From = time.Now().Format(dateLayout)
+	}
+
+	reservations, total, err := h.repo.ListReservations(filter)
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"reservations": reservations,
+		"total":        total,
+	})
+}
+
+// updateStatus confirms, rejects, cancels or closes a reservation. A
+// request naming a telegram_id is the customer's own and may only cancel.
+func (h *Handler) updateStatus(data map[string]interface{}) *shared.Response {
+	id, ok := data["id"].(float64)
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("ID diperlukan"))
+	}
+	status, _ := data["status"].(string)
+	reason, _ := data["reason"].(string)
+	telegramID, _ := data["telegram_id"].(string)
+
+	reservation, err := h.repo.GetReservation(int(id))
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+	if telegramID != "" && (telegramID != reservation.TelegramID || status != StatusCancelled) {
+		return errorResponse(shared.NewUnauthorizedError())
+	}
+	if !shared.Contains(nextStatuses[reservation.Status], status) {
+		return errorResponse(shared.NewKeyedInvalidInputError(
+			"error.status_transition", reservation.Status, status))
+	}
+
+	if err := h.repo.UpdateStatus(reservation.ID, reservation.Status, status, shared.SanitizeInput(reason)); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	updated, err := h.repo.GetReservation(reservation.ID)
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"reservation":   updated,
+		"next_statuses": allowedNextStatuses(updated.Status),
+	})
+}
+
+// dueReminders lists confirmed bookings starting within the reminder time
+// whose customer was not reminded yet
+func (h *Handler) dueReminders() *shared.Response {
+	now := time.Now()
+	candidates, _, err := h.repo.ListReservations(ReservationFilter{
+		Statuses:   []string{StatusConfirmed},
+		From:       now.Format(dateLayout),
+		To:         now.Add(h.settings.ReminderBefore).Format(dateLayout),
+		Unreminded: true,
+	})
+	if err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	due := []Reservation{}
+	for _, reservation := range candidates {
+		start := startOf(reservation.Date, reservation.Time)
+		if start.After(now) && start.Sub(now) <= h.settings.ReminderBefore {
+			due = append(due, reservation)
+		}
+	}
+
+	return successResponse(map[string]interface{}{
+		"reservations": due,
+	})
+}
+
+func (h *Handler) markReminded(data map[string]interface{}) *shared.Response {
+	id, ok := data["id"].(float64)
+	if !ok {
+		return errorResponse(shared.NewInvalidInputError("ID diperlukan"))
+	}
+
+	if err := h.repo.MarkReminded(int(id)); err != nil {
+		return errorResponse(err.(*shared.AppError))
+	}
+
+	return successResponse(map[string]interface{}{
+		"message": "Pengingat tercatat",
+	})
+}
+
+// Helper functions
+
+// maxCapacity is the largest party a table of tables seats
+func maxCapacity(tables []Table) int {
+	largest := 0
+	for _, table := range tables {
+		if table.Capacity > largest {
+			largest = table.Capacity
+		}
+	}
+	return largest
+}
+
+// allowedNextStatuses returns the statuses a reservation may move to, never
+// nil so final reservations encode as an empty list
+func allowedNextStatuses(status string) []string {
+	if next, ok := nextStatuses[status]; ok {
+		return next
+	}
+	return []string{}
+}
+
+func branchIDFromPayload(data map[string]interface{}) int {
+	if id, ok := data["branch_id"].(float64); ok && id > 0 {
+		return int(id)
+	}
+	return DefaultBranchID
+}
+
+func successResponse(data interface{}) *shared.Response {
+	return &shared.Response{
+		Success: true,
+		Data:    data,
+	}
+}
+
+func errorResponse(err *shared.AppError) *shared.Response {
+	return &shared.Response{
+		Success: false,
+		Error:   err.Info(),
+	}
+}
+
+func sendResponse(w http.ResponseWriter, response *shared.Response) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func sendErrorResponse(w http.ResponseWriter, err *shared.AppError) {
+	w.Header().Set("Content-Type", "application/json")
+	response := errorResponse(err)
+	json.NewEncoder(w).Encode(response)
+}
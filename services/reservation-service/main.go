@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	// Load environment variables
+	godotenv.Load()
+	shared.ConfigureLogger("reservation-service")
+	shared.ConfigureTracing("reservation-service")
+
+	port := os.Getenv("RESERVATION_SERVICE_PORT")
+	if port == "" {
+		port = "8089"
+	}
+
+	dbPath := os.Getenv("RESERVATION_DB_PATH")
+	if dbPath == "" {
+		dbPath = "./data/reservation.db"
+	}
+
+	settings := Settings{
+		SlotInterval:   durationSetting("RESERVATION_SLOT_INTERVAL", 30*time.Minute),
+		Duration:       durationSetting("RESERVATION_DURATION", 90*time.Minute),
+		DaysAhead:      14,
+		ReminderBefore: durationSetting("RESERVATION_REMINDER_BEFORE", 2*time.Hour),
+	}
+	if value := os.Getenv("RESERVATION_DAYS_AHEAD"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			log.Fatalf("Invalid RESERVATION_DAYS_AHEAD: %q", value)
+		}
+		settings.DaysAhead = n
+	}
+
+	infoServiceURL := shared.ServiceURL("info-service")
+
+	// Initialize database
+	db, err := shared.InitDB(dbPath)
+	if err != nil {
+		log.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer db.Close()
+
+	// Initialize repository
+	repo := NewRepository(db)
+	if err := repo.InitSchema(); err != nil {
+		log.Fatalf("Failed to initialize schema: %v", err)
+	}
+
+	// Initialize handler
+	handler := NewHandler(repo, infoServiceURL, settings)
+
+	// Setup routes
+	http.HandleFunc("/", shared.InstrumentRequests(handler.HandleRequest))
+	shared.NewHealth("reservation-service").WithDatabase(db).DependsOn("info-service", infoServiceURL).Register()
+	http.HandleFunc("/metrics", shared.MetricsHandler)
+
+	// Start server
+	addr := fmt.Sprintf(":%s", port)
+	shared.Logger(context.Background()).Info("service starting", "addr", addr)
+	if err := http.ListenAndServe(addr, nil); err != nil {
+		log.Fatalf("Failed to start server: %v", err)
+	}
+}
+
+// durationSetting reads a duration such as "30m" from env, or fallback when
+// it is not set
+func durationSetting(env string, fallback time.Duration) time.Duration {
+	value := os.Getenv(env)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < time.Minute {
+		log.Fatalf("Invalid %s: %q (e.g. 30m, minimum 1m)", env, value)
+	}
+	return d
+}
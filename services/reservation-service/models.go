@@ -0,0 +1,95 @@
+package main
+
+import (
+	"time"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+)
+
+// Reservation statuses. A booking waits as pending until an admin confirms
+// or rejects it; a confirmed booking ends as completed when the guests came
+// or no_show when they did not.
+const (
+	StatusPending   = "pending"
+	StatusConfirmed = "confirmed"
+	StatusRejected  = "rejected"
+	StatusCancelled = "cancelled"
+	StatusCompleted = "completed"
+	StatusNoShow    = "no_show"
+)
+
+// nextStatuses lists the statuses a reservation may move to from each
+// status. The others are final.
+var nextStatuses = map[string][]string{
+	StatusPending:   {StatusConfirmed, StatusRejected, StatusCancelled},
+	StatusConfirmed: {StatusCompleted, StatusNoShow, StatusCancelled},
+}
+
+// activeStatuses are the statuses of bookings that hold their table
+var activeStatuses = []string{StatusPending, StatusConfirmed}
+
+// DefaultBranchID is the branch of requests that name none
+const DefaultBranchID = 1
+
+// Table is a table of a branch that can be booked
+type Table struct {
+	ID        int       `json:"id"`
+	BranchID  int       `json:"branch_id"`
+	Name      string    `json:"name"`
+	Capacity  int       `json:"capacity"`
+	Area      string    `json:"area,omitempty"` // e.g. "Indoor", "Teras"
+	IsActive  bool      `json:"is_active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Reservation is a booking of a table for a party at a date and time
+type Reservation struct {
+	ID           int       `json:"id"`
+	BranchID     int       `json:"branch_id"`
+	TableID      int       `json:"table_id"`
+	TableName    string    `json:"table_name"`
+	TableArea    string    `json:"table_area,omitempty"`
+	TelegramID   string    `json:"telegram_id"`
+	CustomerName string    `json:"customer_name"`
+	PartySize    int       `json:"party_size"`
+	Date         string    `json:"date"` // YYYY-MM-DD, local time
+	Time         string    `json:"time"` // HH:MM, local time
+	Status       string    `json:"status"`
+	Note         string    `json:"note,omitempty"`
+	Reason       string    `json:"reason,omitempty"` // why it was rejected
+	Reminded     bool      `json:"reminded"`
+	NoShows      int       `json:"no_shows"` // bookings of the customer marked no_show
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Slot is a start time of a day and whether a table for the party is free
+type Slot struct {
+	Time      string `json:"time"`
+	Available bool   `json:"available"`
+}
+
+// ReservationFilter narrows down ListReservations. From and To are
+// inclusive dates (YYYY-MM-DD).
+type ReservationFilter struct {
+	Statuses   []string
+	BranchID   int
+	TelegramID string
+	From       string
+	To         string
+	Unreminded bool
+	Page       shared.Page
+}
+
+// Settings are the booking rules of the service
+type Settings struct {
+	// SlotInterval is the time between bookable start times
+	SlotInterval time.Duration
+	// Duration is how long a booking holds its table
+	Duration time.Duration
+	// DaysAhead is the furthest day that can be booked, counted from today
+	DaysAhead int
+	// ReminderBefore is how long before a confirmed booking its reminder
+	// is due
+	ReminderBefore time.Duration
+}
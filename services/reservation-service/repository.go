@@ -0,0 +1,274 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/alrescha79-cmd/bot-cafe/shared"
+)
+
+// Repository handles database operations
+type Repository struct {
+	shared.Store
+}
+
+// NewRepository creates a new repository
+func NewRepository(db *sql.DB) *Repository {
+	return &Repository{shared.NewStore(db)}
+}
+
+// WithContext returns the repository running its queries with ctx
+func (r *Repository) WithContext(ctx context.Context) *Repository {
+	return &Repository{r.Store.WithContext(ctx)}
+}
+
+// schemaVersion is recorded in the database by InitSchema and reported by
+// /ready; bump it with every schema change
+const schemaVersion = 1
+
+// InitSchema initializes database schema
+func (r *Repository) InitSchema() error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS dining_tables (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		branch_id INTEGER NOT NULL DEFAULT 1,
+		name TEXT NOT NULL,
+		capacity INTEGER NOT NULL,
+		area TEXT,
+		is_active BOOLEAN NOT NULL DEFAULT 1,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS reservations (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		branch_id INTEGER NOT NULL DEFAULT 1,
+		table_id INTEGER NOT NULL,
+		telegram_id TEXT NOT NULL,
+		customer_name TEXT,
+		party_size INTEGER NOT NULL,
+		date TEXT NOT NULL,
+		time TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		note TEXT,
+		reason TEXT,
+		reminded BOOLEAN NOT NULL DEFAULT 0,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (table_id) REFERENCES dining_tables(id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_tables_branch ON dining_tables(branch_id);
+	CREATE INDEX IF NOT EXISTS idx_reservations_day ON reservations(branch_id, date);
+	CREATE INDEX IF NOT EXISTS idx_reservations_status ON reservations(status);
+	CREATE INDEX IF NOT EXISTS idx_reservations_telegram ON reservations(telegram_id);
+	`
+	if err := shared.ExecuteSchema(r.DB, schema); err != nil {
+		return err
+	}
+	return shared.SetSchemaVersion(r.DB, schemaVersion)
+}
+
+// TABLES
+
+// CreateTable adds a table to a branch
+func (r *Repository) CreateTable(table *Table) (*Table, error) {
+	result, err := r.DB.ExecContext(r.Ctx, `INSERT INTO dining_tables (branch_id, name, capacity, area) VALUES (?, ?, ?, ?)`,
+		table.BranchID, table.Name, table.Capacity, table.Area)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+
+	id, _ := result.LastInsertId()
+	table.ID = int(id)
+	table.IsActive = true
+	table.CreatedAt = time.Now()
+	return table, nil
+}
+
+// ListTables lists the tables of a branch in use, smallest first
+func (r *Repository) ListTables(branchID int) ([]Table, error) {
+	rows, err := r.DB.QueryContext(r.Ctx, `SELECT id, branch_id, name, capacity, COALESCE(area, ''), is_active, created_at
+			  FROM dining_tables WHERE branch_id = ? AND is_active = 1 ORDER BY capacity, name, id`, branchID)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	defer rows.Close()
+
+	tables := []Table{}
+	for rows.Next() {
+		var table Table
+		if err := rows.Scan(&table.ID, &table.BranchID, &table.Name, &table.Capacity, &table.Area,
+			&table.IsActive, &table.CreatedAt); err != nil {
+			return nil, shared.NewDatabaseError(err)
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+// DeleteTable takes a table out of use. Past bookings keep referring to it.
+func (r *Repository) DeleteTable(id int) error {
+	var upcoming int
+	err := r.DB.QueryRowContext(r.Ctx, `SELECT COUNT(*) FROM reservations
+			  WHERE table_id = ? AND status IN (?, ?) AND date >= date('now', 'localtime')`,
+		id, StatusPending, StatusConfirmed).Scan(&upcoming)
+	if err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	if upcoming > 0 {
+		return shared.NewKeyedInvalidInputError("error.table_has_reservations")
+	}
+
+	result, err := r.DB.ExecContext(r.Ctx, `UPDATE dining_tables SET is_active = 0 WHERE id = ? AND is_active = 1`, id)
+	if err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return shared.NewNotFoundError("Meja")
+	}
+	return nil
+}
+
+// RESERVATIONS
+
+// CreateReservation stores a pending booking
+func (r *Repository) CreateReservation(reservation *Reservation) (*Reservation, error) {
+	query := `INSERT INTO reservations (branch_id, table_id, telegram_id, customer_name, party_size, date, time, status, note)
+			  VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := r.DB.ExecContext(r.Ctx, query, reservation.BranchID, reservation.TableID, reservation.TelegramID,
+		reservation.CustomerName, reservation.PartySize, reservation.Date, reservation.Time, StatusPending, reservation.Note)
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+
+	id, _ := result.LastInsertId()
+	return r.GetReservation(int(id))
+}
+
+const reservationColumns = `r.id, r.branch_id, r.table_id, t.name, COALESCE(t.area, ''), r.telegram_id,
+			  COALESCE(r.customer_name, ''), r.party_size, r.date, r.time, r.status, COALESCE(r.note, ''),
+			  COALESCE(r.reason, ''), r.reminded,
+			  (SELECT COUNT(*) FROM reservations n WHERE n.telegram_id = r.telegram_id AND n.status = 'no_show'),
+			  r.created_at, r.updated_at
+			  FROM reservations r JOIN dining_tables t ON t.id = r.table_id`
+
+func scanReservation(row interface{ Scan(...interface{}) error }) (*Reservation, error) {
+	var reservation Reservation
+	err := row.Scan(&reservation.ID, &reservation.BranchID, &reservation.TableID, &reservation.TableName,
+		&reservation.TableArea, &reservation.TelegramID, &reservation.CustomerName, &reservation.PartySize,
+		&reservation.Date, &reservation.Time, &reservation.Status, &reservation.Note, &reservation.Reason,
+		&reservation.Reminded, &reservation.NoShows, &reservation.CreatedAt, &reservation.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+// GetReservation gets a reservation by ID
+func (r *Repository) GetReservation(id int) (*Reservation, error) {
+	reservation, err := scanReservation(r.DB.QueryRowContext(r.Ctx, `SELECT `+reservationColumns+` WHERE r.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, shared.NewNotFoundError("Reservasi")
+	}
+	if err != nil {
+		return nil, shared.NewDatabaseError(err)
+	}
+	return reservation, nil
+}
+
+// ListReservations lists reservations by date and time, earliest first,
+// with the total number of matches when paged
+func (r *Repository) ListReservations(filter ReservationFilter) ([]Reservation, int, error) {
+	query := `SELECT ` + reservationColumns + ` WHERE 1=1`
+	args := []interface{}{}
+
+	if len(filter.Statuses) > 0 {
+		query += ` AND r.status IN (?` + strings.Repeat(`, ?`, len(filter.Statuses)-1) + `)`
+		for _, status := range filter.Statuses {
+			args = append(args, status)
+		}
+	}
+	if filter.BranchID > 0 {
+		query += ` AND r.branch_id = ?`
+		args = append(args, filter.BranchID)
+	}
+	if filter.TelegramID != "" {
+		query += ` AND r.telegram_id = ?`
+		args = append(args, filter.TelegramID)
+	}
+	if filter.From != "" {
+		query += ` AND r.date >= ?`
+		args = append(args, filter.From)
+	}
+	if filter.To != "" {
+		query += ` AND r.date <= ?`
+		args = append(args, filter.To)
+	}
+	if filter.Unreminded {
+		query += ` AND r.reminded = 0`
+	}
+	query += ` ORDER BY r.date, r.time, r.id`
+
+	total, err := r.countRows(query, args, filter.Page)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query, args = filter.Page.Apply(query, args)
+	rows, err := r.DB.QueryContext(r.Ctx, query, args...)
+	if err != nil {
+		return nil, 0, shared.NewDatabaseError(err)
+	}
+	defer rows.Close()
+
+	reservations := []Reservation{}
+	for rows.Next() {
+		reservation, err := scanReservation(rows)
+		if err != nil {
+			return nil, 0, shared.NewDatabaseError(err)
+		}
+		reservations = append(reservations, *reservation)
+	}
+	if filter.Page.Limit == 0 {
+		total = len(reservations)
+	}
+	return reservations, total, nil
+}
+
+// countRows counts the rows a paged query selects; whole lists are counted
+// by the caller
+func (r *Repository) countRows(query string, args []interface{}, page shared.Page) (int, error) {
+	if page.Limit == 0 {
+		return 0, nil
+	}
+	var total int
+	if err := r.DB.QueryRowContext(r.Ctx, shared.CountQuery(query), args...).Scan(&total); err != nil {
+		return 0, shared.NewDatabaseError(err)
+	}
+	return total, nil
+}
+
+// UpdateStatus moves a reservation to a new status. The current status is
+// part of the update so two admins handling the same booking cannot both
+// succeed.
+func (r *Repository) UpdateStatus(id int, from, to, reason string) error {
+	result, err := r.DB.ExecContext(r.Ctx, `UPDATE reservations SET status = ?, reason = ?, updated_at = CURRENT_TIMESTAMP
+			  WHERE id = ? AND status = ?`, to, reason, id, from)
+	if err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return shared.NewKeyedInvalidInputError("error.reservation_status_changed")
+	}
+	return nil
+}
+
+// MarkReminded records that the customer was reminded of a booking
+func (r *Repository) MarkReminded(id int) error {
+	if _, err := r.DB.ExecContext(r.Ctx, `UPDATE reservations SET reminded = 1 WHERE id = ?`, id); err != nil {
+		return shared.NewDatabaseError(err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	dateLayout  = "2006-01-02"
+	clockLayout = "15:04"
+)
+
+// parseClock reads a time of day such as "08:00" as the time since midnight
+func parseClock(value string) (time.Duration, bool) {
+	clock, err := time.Parse(clockLayout, value)
+	if err != nil {
+		return 0, false
+	}
+	return time.Duration(clock.Hour())*time.Hour + time.Duration(clock.Minute())*time.Minute, true
+}
+
+// parseDate reads a date as midnight of that day in local time
+func parseDate(value string) (time.Time, bool) {
+	date, err := time.ParseInLocation(dateLayout, value, time.Local)
+	return date, err == nil
+}
+
+// startOf returns when a booking at date and clock starts
+func startOf(date, clock string) time.Time {
+	day, _ := parseDate(date)
+	offset, _ := parseClock(clock)
+	return day.Add(offset)
+}
+
+// daySlots lists the start times of bookings on day between opening and
+// closing, each leaving a full booking before closing. A closing time not
+// after the opening time is on the next day; start times past midnight
+// belong to that day and are left out. Times before now are left out too.
+func daySlots(day time.Time, opening, closing time.Duration, settings Settings, now time.Time) []time.Time {
+	if closing <= opening {
+		closing += 24 * time.Hour
+	}
+	last := closing - settings.Duration
+	if last >= 24*time.Hour {
+		last = 24*time.Hour - settings.SlotInterval
+	}
+
+	var slots []time.Time
+	for offset := opening; offset <= last; offset += settings.SlotInterval {
+		start := day.Add(offset)
+		if start.After(now) {
+			slots = append(slots, start)
+		}
+	}
+	return slots
+}
+
+// overlaps reports whether bookings starting at a and b, each lasting
+// duration, hold a table at the same time
+func overlaps(a, b time.Time, duration time.Duration) bool {
+	return a.Before(b.Add(duration)) && b.Before(a.Add(duration))
+}
+
+// pickTable returns the smallest table fitting the party that none of
+// bookings holds at start, or nil when every fitting table is taken. tables
+// are sorted by capacity.
+func pickTable(tables []Table, bookings []Reservation, partySize int, start time.Time, duration time.Duration) *Table {
+	taken := map[int]bool{}
+	for _, booking := range bookings {
+		if overlaps(start, startOf(booking.Date, booking.Time), duration) {
+			taken[booking.TableID] = true
+		}
+	}
+	for i := range tables {
+		if tables[i].Capacity >= partySize && !taken[tables[i].ID] {
+			return &tables[i]
+		}
+	}
+	return nil
+}
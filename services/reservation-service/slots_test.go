@@ -0,0 +1,114 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func at(clock string) time.Time {
+	return startOf("2025-03-10", clock)
+}
+
+func TestOverlaps(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want bool
+	}{
+		{"same start", "18:00", "18:00", true},
+		{"starts during", "18:00", "19:30", true},
+		{"ends during", "19:30", "18:00", true},
+		{"back to back", "18:00", "20:00", false},
+		{"back to back reversed", "20:00", "18:00", false},
+		{"apart", "12:00", "18:00", false},
+		{"one minute short", "18:00", "19:59", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := overlaps(at(tt.a), at(tt.b), 2*time.Hour); got != tt.want {
+				t.Errorf("overlaps(%s, %s) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPickTable(t *testing.T) {
+	// Sorted by capacity, as pickTable expects
+	tables := []Table{
+		{ID: 1, Name: "A1", Capacity: 2},
+		{ID: 2, Name: "A2", Capacity: 2},
+		{ID: 3, Name: "B1", Capacity: 4},
+		{ID: 4, Name: "C1", Capacity: 6},
+	}
+	booking := func(tableID int, clock string) Reservation {
+		return Reservation{TableID: tableID, Date: "2025-03-10", Time: clock}
+	}
+
+	tests := []struct {
+		name      string
+		bookings  []Reservation
+		partySize int
+		start     string
+		want      int // table ID, 0 for none
+	}{
+		{"smallest fitting table", nil, 2, "18:00", 1},
+		{"exact capacity", nil, 4, "18:00", 3},
+		{"larger table when needed", nil, 3, "18:00", 3},
+		{"next table when taken", []Reservation{booking(1, "18:00")}, 2, "18:00", 2},
+		{"larger table when small ones taken", []Reservation{booking(1, "17:00"), booking(2, "19:00")}, 2, "18:00", 3},
+		{"free after booking ends", []Reservation{booking(1, "16:00")}, 2, "18:00", 1},
+		{"party too large", nil, 7, "18:00", 0},
+		{"every fitting table taken", []Reservation{booking(3, "18:00"), booking(4, "18:30")}, 4, "18:00", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := pickTable(tables, tt.bookings, tt.partySize, at(tt.start), 2*time.Hour)
+			got := 0
+			if table != nil {
+				got = table.ID
+			}
+			if got != tt.want {
+				t.Errorf("pickTable() = table %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDaySlots(t *testing.T) {
+	settings := Settings{SlotInterval: time.Hour, Duration: 2 * time.Hour}
+	day, _ := parseDate("2025-03-10")
+	before := day.Add(-time.Hour)
+
+	tests := []struct {
+		name             string
+		opening, closing string
+		now              time.Time
+		want             []string
+	}{
+		{"full booking before closing", "08:00", "12:00", before, []string{"08:00", "09:00", "10:00"}},
+		{"past times left out", "08:00", "12:00", day.Add(9 * time.Hour), []string{"10:00"}},
+		{"too short to book", "08:00", "09:00", before, nil},
+		{"closing after midnight", "20:00", "02:00", before, []string{"20:00", "21:00", "22:00", "23:00"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opening, _ := parseClock(tt.opening)
+			closing, _ := parseClock(tt.closing)
+			slots := daySlots(day, opening, closing, settings, tt.now)
+
+			var got []string
+			for _, slot := range slots {
+				got = append(got, slot.Format(clockLayout))
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("daySlots() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("daySlots() = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// Consecutive failed calls that open a service's circuit
+	breakerThreshold = 5
+	// How long an open circuit refuses calls before letting one through
+	breakerCooldown = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned, wrapped, for calls refused because the service
+// failed repeatedly and is given time to recover
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+var circuitOpen = NewGauge("cafe_client_circuit_open",
+	"Whether calls to a service are refused by its circuit breaker (1) or not (0).", "target")
+
+// breaker is the circuit breaker of one service. After breakerThreshold
+// failures in a row it opens and refuses calls; after breakerCooldown it lets
+// a single trial call through, which closes it again on success.
+type breaker struct {
+	mu        sync.Mutex
+	target    string
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+// allow reports whether a call may go out now
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.failures < breakerThreshold {
+		return true
+	}
+	if time.Now().Before(b.openUntil) || b.trial {
+		return false
+	}
+	b.trial = true
+	return true
+}
+
+// record reports the outcome of a call that was allowed; ctx is that of
+// the call, whose request ID is logged when the circuit changes
+func (b *breaker) record(ctx context.Context, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	if ok {
+		if b.failures >= breakerThreshold {
+			Logger(ctx).Info("circuit closed, the service answers again", "target", b.target)
+			circuitOpen.Set(0, b.target)
+		}
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures >= breakerThreshold {
+		if b.failures == breakerThreshold {
+			Logger(ctx).Warn("circuit opened", "target", b.target, "failures", b.failures)
+			circuitOpen.Set(1, b.target)
+		}
+		b.openUntil = time.Now().Add(breakerCooldown)
+	}
+}
+
+// release gives up an allowed call without an outcome, e.g. when the caller
+// cancelled it
+func (b *breaker) release() {
+	b.mu.Lock()
+	b.trial = false
+	b.mu.Unlock()
+}
+
+// breakers holds one breaker per service, shared by copies of a client
+type breakers struct {
+	mu       sync.Mutex
+	byTarget map[string]*breaker
+}
+
+func (s *breakers) get(target string) *breaker {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.byTarget[target]
+	if !ok {
+		b = &breaker{target: target}
+		s.byTarget[target] = b
+	}
+	return b
+}
@@ -1,22 +1,33 @@
 package shared
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
 	"os"
-
-	_ "github.com/mattn/go-sqlite3"
+	"strings"
 )
 
-// InitDB initializes the SQLite database
+// InitDB initializes the SQLite database. Statements run on it are timed
+// for the cafe_db_* metrics and traced when their context carries a span.
+// Transactions take the write lock when they begin, so two of them reading
+// before they write wait for each other instead of failing with "database
+// is locked".
 func InitDB(dbPath string) (*sql.DB, error) {
 	// Create data directory if it doesn't exist
 	if err := os.MkdirAll("./data", 0755); err != nil {
 		return nil, fmt.Errorf("failed to create data directory: %w", err)
 	}
 
-	db, err := sql.Open("sqlite3", dbPath)
+	if !strings.Contains(dbPath, "_txlock=") {
+		separator := "?"
+		if strings.Contains(dbPath, "?") {
+			separator = "&"
+		}
+		dbPath += separator + "_txlock=immediate"
+	}
+
+	db, err := sql.Open(instrumentedDriver, dbPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -26,7 +37,7 @@ func InitDB(dbPath string) (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	log.Printf("Database initialized: %s", dbPath)
+	Logger(context.Background()).Info("database initialized", "path", dbPath)
 	return db, nil
 }
 
@@ -38,3 +49,137 @@ func ExecuteSchema(db *sql.DB, schema string) error {
 	}
 	return nil
 }
+
+// AddColumnIfNotExists adds a column to an existing table. SQLite has no
+// "ADD COLUMN IF NOT EXISTS", so the table info is checked first.
+func AddColumnIfNotExists(db *sql.DB, table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to read table info: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return fmt.Errorf("failed to scan table info: %w", err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	rows.Close()
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	if err != nil {
+		return fmt.Errorf("failed to add column %s.%s: %w", table, column, err)
+	}
+	return nil
+}
+
+// SetSchemaVersion records the schema version of a database once its
+// migrations have run; /ready reports it
+func SetSchemaVersion(db *sql.DB, version int) error {
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+		return fmt.Errorf("failed to set schema version: %w", err)
+	}
+	return nil
+}
+
+// SchemaVersion returns the schema version recorded in a database
+func SchemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	var version int
+	if err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// Querier runs statements on a database or inside a transaction, so a
+// repository works the same either way
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// Store is the database access a service repository embeds. Its queries run
+// on Q with Ctx: a repository starts on the whole database, WithContext
+// traces its queries as part of a request and WithTx runs them in a
+// transaction, so they are committed together with whatever else it holds,
+// such as the event announcing them. Both return a copy, leaving the
+// repository the service shares as it is. Cancellation of the context is
+// not passed on, so a caller giving up never interrupts a write halfway.
+type Store struct {
+	DB  *sql.DB
+	Q   Querier // DB, or the transaction of WithTx
+	Ctx context.Context
+}
+
+// NewStore returns a store running queries on db
+func NewStore(db *sql.DB) Store {
+	return Store{DB: db, Q: db, Ctx: context.Background()}
+}
+
+// WithContext returns the store running its queries with ctx
+func (s Store) WithContext(ctx context.Context) Store {
+	s.Ctx = context.WithoutCancel(ctx)
+	return s
+}
+
+// WithTx returns the store running its queries in tx
+func (s Store) WithTx(tx *sql.Tx) Store {
+	s.Q = tx
+	return s
+}
+
+// Begin starts a transaction for WithTx
+func (s Store) Begin() (*sql.Tx, error) {
+	return s.DB.BeginTx(s.Ctx, nil)
+}
+
+// Tx is a transaction started by Begin
+type Tx struct {
+	*sql.Tx
+	joined bool
+}
+
+// Begin starts a transaction on q. When q is a transaction already, the
+// statements join it: Commit and Rollback are left to whoever started it.
+func Begin(ctx context.Context, q Querier) (*Tx, error) {
+	switch q := q.(type) {
+	case *sql.Tx:
+		return &Tx{Tx: q, joined: true}, nil
+	case *sql.DB:
+		tx, err := q.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, err
+		}
+		return &Tx{Tx: tx}, nil
+	}
+	return nil, fmt.Errorf("cannot begin a transaction on %T", q)
+}
+
+// Commit commits the transaction unless it was joined
+func (t *Tx) Commit() error {
+	if t.joined {
+		return nil
+	}
+	return t.Tx.Commit()
+}
+
+// Rollback rolls the transaction back unless it was joined; whoever started
+// it rolls back when the joined work reports an error
+func (t *Tx) Rollback() error {
+	if t.joined {
+		return nil
+	}
+	return t.Tx.Rollback()
+}
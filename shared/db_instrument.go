@@ -0,0 +1,115 @@
+package shared
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// instrumentedDriver is the SQLite driver InitDB opens databases with. It
+// times every statement run through database/sql, and traces statements run
+// with a context that carries a span.
+const instrumentedDriver = "sqlite3_instrumented"
+
+var (
+	queryDuration = NewHistogram("cafe_db_query_duration_seconds",
+		"Time to run an SQLite statement and read its rows, by operation.", QueryBuckets, "operation")
+	queryErrors = NewCounter("cafe_db_query_errors_total",
+		"SQLite statements that failed, by operation.", "operation")
+)
+
+func init() {
+	sql.Register(instrumentedDriver, &timedDriver{&sqlite3.SQLiteDriver{}})
+}
+
+type timedDriver struct {
+	driver.Driver
+}
+
+func (d *timedDriver) Open(name string) (driver.Conn, error) {
+	conn, err := d.Driver.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &timedConn{conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+// timedConn records statements run directly on the connection, which is how
+// database/sql runs Exec and Query on databases and transactions
+type timedConn struct {
+	*sqlite3.SQLiteConn
+}
+
+func (c *timedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	start, span := startQuery(ctx, query)
+	result, err := c.SQLiteConn.ExecContext(ctx, query, args)
+	observeQuery(query, start, span, err)
+	return result, err
+}
+
+func (c *timedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	start, span := startQuery(ctx, query)
+	rows, err := c.SQLiteConn.QueryContext(ctx, query, args)
+	if err != nil {
+		observeQuery(query, start, span, err)
+		return nil, err
+	}
+	return &timedRows{Rows: rows, query: query, start: start, span: span}, nil
+}
+
+// timedRows records the query when its rows are closed, so reading them
+// counts toward the query time
+type timedRows struct {
+	driver.Rows
+	query string
+	start time.Time
+	span  *Span
+}
+
+func (r *timedRows) Close() error {
+	err := r.Rows.Close()
+	observeQuery(r.query, r.start, r.span, err)
+	return err
+}
+
+// startQuery starts timing a statement, with a span when ctx is traced
+func startQuery(ctx context.Context, query string) (time.Time, *Span) {
+	var span *Span
+	if SpanFromContext(ctx) != nil {
+		_, span = StartSpan(ctx, "db "+queryOperation(query), SpanKindClient)
+		span.SetAttributes("db.system", "sqlite", "db.statement", strings.Join(strings.Fields(query), " "))
+	}
+	return time.Now(), span
+}
+
+func observeQuery(query string, start time.Time, span *Span, err error) {
+	operation := queryOperation(query)
+	queryDuration.Observe(time.Since(start).Seconds(), operation)
+	if err != nil {
+		queryErrors.Inc(operation)
+	}
+	if span != nil {
+		if err != nil {
+			span.SetError(err.Error())
+		}
+		span.Finish()
+	}
+}
+
+// queryOperation labels a statement by its first keyword, e.g. "select"
+func queryOperation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "other"
+	}
+	switch operation := strings.ToLower(fields[0]); operation {
+	case "select", "insert", "update", "delete", "create", "alter", "pragma", "with":
+		return operation
+	default:
+		return "other"
+	}
+}
@@ -13,10 +13,14 @@ const (
 	ErrCodeServiceError   = "ERR_SERVICE"
 )
 
-// AppError represents application error
+// AppError represents application error. Errors made by the constructors
+// below also carry a catalogue Key and its Params, so clients can show them
+// in the user's language; Message is the text in DefaultLocale.
 type AppError struct {
 	Code    string
 	Message string
+	Key     string
+	Params  []string
 	Err     error
 }
 
@@ -27,6 +31,16 @@ func (e *AppError) Error() string {
 	return fmt.Sprintf("%s: %s", e.Code, e.Message)
 }
 
+// Info returns the error details sent in a Response
+func (e *AppError) Info() *ErrorInfo {
+	return &ErrorInfo{
+		Code:    e.Code,
+		Message: e.Message,
+		Key:     e.Key,
+		Params:  e.Params,
+	}
+}
+
 // NewError creates a new application error
 func NewError(code, message string, err error) *AppError {
 	return &AppError{
@@ -36,7 +50,25 @@ func NewError(code, message string, err error) *AppError {
 	}
 }
 
-// NewInvalidInputError creates invalid input error
+// NewKeyedError creates an error whose message is the catalogue text of
+// key, so clients can show it in the user's language
+func NewKeyedError(code, key string, err error, params ...string) *AppError {
+	args := make([]interface{}, len(params))
+	for i, param := range params {
+		args[i] = param
+	}
+	return &AppError{
+		Code:    code,
+		Message: T(DefaultLocale, key, args...),
+		Key:     key,
+		Params:  params,
+		Err:     err,
+	}
+}
+
+// NewInvalidInputError creates invalid input error. It has no catalogue key,
+// so it is meant for malformed requests from other services; use
+// NewKeyedInvalidInputError for input a user can get wrong.
 func NewInvalidInputError(message string) *AppError {
 	return &AppError{
 		Code:    ErrCodeInvalidInput,
@@ -44,11 +76,30 @@ func NewInvalidInputError(message string) *AppError {
 	}
 }
 
+// NewKeyedInvalidInputError creates invalid input error with the catalogue
+// text of key
+func NewKeyedInvalidInputError(key string, params ...string) *AppError {
+	return NewKeyedError(ErrCodeInvalidInput, key, nil, params...)
+}
+
+// ErrorFromInfo rebuilds an error another service answered with, keeping
+// its catalogue key
+func ErrorFromInfo(info *ErrorInfo) *AppError {
+	return &AppError{
+		Code:    info.Code,
+		Message: info.Message,
+		Key:     info.Key,
+		Params:  info.Params,
+	}
+}
+
 // NewNotFoundError creates not found error
 func NewNotFoundError(resource string) *AppError {
 	return &AppError{
 		Code:    ErrCodeNotFound,
-		Message: fmt.Sprintf("%s tidak ditemukan", resource),
+		Message: T(DefaultLocale, "error.not_found", resource),
+		Key:     "error.not_found",
+		Params:  []string{resource},
 	}
 }
 
@@ -56,7 +107,8 @@ func NewNotFoundError(resource string) *AppError {
 func NewUnauthorizedError() *AppError {
 	return &AppError{
 		Code:    ErrCodeUnauthorized,
-		Message: "Akses tidak diizinkan",
+		Message: T(DefaultLocale, "error.unauthorized"),
+		Key:     "error.unauthorized",
 	}
 }
 
@@ -64,7 +116,8 @@ func NewUnauthorizedError() *AppError {
 func NewDatabaseError(err error) *AppError {
 	return &AppError{
 		Code:    ErrCodeDatabaseError,
-		Message: "Terjadi kesalahan database",
+		Message: T(DefaultLocale, "error.database"),
+		Key:     "error.database",
 		Err:     err,
 	}
 }
@@ -73,7 +126,8 @@ func NewDatabaseError(err error) *AppError {
 func NewInternalError(err error) *AppError {
 	return &AppError{
 		Code:    ErrCodeInternalError,
-		Message: "Terjadi kesalahan internal",
+		Message: T(DefaultLocale, "error.internal"),
+		Key:     "error.internal",
 		Err:     err,
 	}
 }
@@ -0,0 +1,383 @@
+package shared
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Services announce their changes as events, e.g. menu.deleted. An event is
+// written to the outbox table of its service, one row per subscriber, in the
+// transaction making the change, so it is kept exactly when the change is.
+// Run posts it to each subscriber until it answers 2xx, so every subscriber
+// gets it at least once. Subscribers must therefore handle an event more
+// than once without harm; HandleEvents skips events it has seen.
+
+const (
+	// Subscribers get this long to take an event
+	eventDeliveryTimeout = 2 * time.Second
+	// First retry of an undelivered event; it doubles up to eventMaxRetryDelay
+	eventRetryDelay    = 2 * time.Second
+	eventMaxRetryDelay = 10 * time.Minute
+	// How often the outbox looks for events when nobody wakes it
+	outboxInterval = time.Second
+	// Delivered events are kept this long for troubleshooting
+	outboxRetention = 7 * 24 * time.Hour
+)
+
+// Event is a change a service announces to the others
+type Event struct {
+	ID        string                 `json:"id"`
+	Type      string                 `json:"type"`
+	Service   string                 `json:"service"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+}
+
+var (
+	eventsPublished = NewCounter("cafe_events_published_total",
+		"Events written to the outbox, by type.", "type")
+	eventDeliveries = NewCounter("cafe_event_deliveries_total",
+		"Attempts to deliver an event, by subscriber and result (ok or failed).", "subscriber", "result")
+	outboxPending = NewGauge("cafe_outbox_pending",
+		"Event deliveries waiting in the outbox.")
+)
+
+// Outbox stores the events of a service and delivers them to the URLs in
+// EVENT_SUBSCRIBERS (comma separated), by default the /events of the agent,
+// auth-service, menu-service, promo-service, media-service and
+// display-service.
+// Setting EVENT_SUBSCRIBERS to an empty value disables it.
+type Outbox struct {
+	db          *sql.DB
+	service     string
+	subscribers []string
+	client      *http.Client
+	wake        chan struct{}
+}
+
+// NewOutbox creates the outbox table of a service
+func NewOutbox(db *sql.DB, service string) (*Outbox, error) {
+	schema := `
+	CREATE TABLE IF NOT EXISTS outbox (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id TEXT NOT NULL,
+		subscriber TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at INTEGER NOT NULL,
+		last_error TEXT,
+		delivered_at INTEGER
+	);
+	CREATE INDEX IF NOT EXISTS idx_outbox_pending ON outbox(delivered_at, next_attempt_at);
+	`
+	if err := ExecuteSchema(db, schema); err != nil {
+		return nil, err
+	}
+
+	value, ok := os.LookupEnv("EVENT_SUBSCRIBERS")
+	if !ok {
+		value = "http://localhost:8080/events,http://localhost:8081/events,http://localhost:8082/events,http://localhost:8083/events,http://localhost:8085/events,http://localhost:8090/events"
+	}
+	var subscribers []string
+	for _, url := range strings.Split(value, ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			subscribers = append(subscribers, url)
+		}
+	}
+
+	return &Outbox{
+		db:          db,
+		service:     service,
+		subscribers: subscribers,
+		client:      &http.Client{Timeout: eventDeliveryTimeout},
+		wake:        make(chan struct{}, 1),
+	}, nil
+}
+
+// Publish stores an event in tx, the transaction making the change it
+// announces. Run delivers it once tx is committed; call Wake after the
+// commit to have it delivered right away.
+func (o *Outbox) Publish(ctx context.Context, tx *sql.Tx, eventType string, data map[string]interface{}) error {
+	if len(o.subscribers) == 0 {
+		return nil
+	}
+
+	event := Event{
+		ID:        randomHex(16),
+		Type:      eventType,
+		Service:   o.service,
+		Data:      data,
+		CreatedAt: time.Now().UTC(),
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	now := time.Now().UnixMilli()
+	for _, subscriber := range o.subscribers {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO outbox (event_id, subscriber, payload, next_attempt_at) VALUES (?, ?, ?, ?)`,
+			event.ID, subscriber, string(payload), now); err != nil {
+			return fmt.Errorf("failed to store event: %w", err)
+		}
+	}
+	eventsPublished.Inc(eventType)
+	return nil
+}
+
+// Wake makes Run deliver the events committed so far without waiting for
+// its next round
+func (o *Outbox) Wake() {
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers stored events, and retries undelivered ones, until the
+// process exits
+func (o *Outbox) Run() {
+	ctx := context.Background()
+	for {
+		o.deliverDue(ctx)
+		select {
+		case <-o.wake:
+		case <-time.After(outboxInterval):
+		}
+	}
+}
+
+type outboxRow struct {
+	id         int64
+	subscriber string
+	payload    []byte
+	attempts   int
+}
+
+// deliverDue delivers the events due, each subscriber's in the order they
+// were stored. A subscriber that fails is left alone for the rest of the
+// round, so it cannot hold the others up.
+func (o *Outbox) deliverDue(ctx context.Context) {
+	now := time.Now()
+	rows, err := o.db.QueryContext(ctx,
+		`SELECT id, subscriber, payload, attempts FROM outbox
+		 WHERE delivered_at IS NULL AND next_attempt_at <= ? ORDER BY id LIMIT 100`, now.UnixMilli())
+	if err != nil {
+		Logger(ctx).Error("failed to read outbox", "error", err)
+		return
+	}
+	var due []outboxRow
+	for rows.Next() {
+		var row outboxRow
+		var payload string
+		if err := rows.Scan(&row.id, &row.subscriber, &payload, &row.attempts); err != nil {
+			rows.Close()
+			Logger(ctx).Error("failed to read outbox", "error", err)
+			return
+		}
+		row.payload = []byte(payload)
+		due = append(due, row)
+	}
+	rows.Close()
+
+	bySubscriber := map[string][]outboxRow{}
+	for _, row := range due {
+		bySubscriber[row.subscriber] = append(bySubscriber[row.subscriber], row)
+	}
+	var wg sync.WaitGroup
+	for _, rows := range bySubscriber {
+		wg.Add(1)
+		go func(rows []outboxRow) {
+			defer wg.Done()
+			for _, row := range rows {
+				if !o.deliver(ctx, row) {
+					return
+				}
+			}
+		}(rows)
+	}
+	wg.Wait()
+
+	var pending int
+	if err := o.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM outbox WHERE delivered_at IS NULL`).Scan(&pending); err == nil {
+		outboxPending.Set(float64(pending))
+	}
+	o.db.ExecContext(ctx, `DELETE FROM outbox WHERE delivered_at < ?`, now.Add(-outboxRetention).UnixMilli())
+}
+
+// deliver posts one event to one subscriber, records the outcome and
+// reports whether it was delivered
+func (o *Outbox) deliver(ctx context.Context, row outboxRow) bool {
+	err := o.post(ctx, row.subscriber, row.payload)
+	if err == nil {
+		eventDeliveries.Inc(row.subscriber, "ok")
+		if _, err := o.db.ExecContext(ctx, `UPDATE outbox SET delivered_at = ?, attempts = attempts + 1 WHERE id = ?`,
+			time.Now().UnixMilli(), row.id); err != nil {
+			Logger(ctx).Error("failed to mark event delivered", "subscriber", row.subscriber, "error", err)
+		}
+		return true
+	}
+
+	eventDeliveries.Inc(row.subscriber, "failed")
+	delay := eventRetryDelay << min(row.attempts, 16)
+	if delay > eventMaxRetryDelay {
+		delay = eventMaxRetryDelay
+	}
+	Logger(ctx).Warn("event delivery failed", "subscriber", row.subscriber, "attempt", row.attempts+1, "error", err)
+	if _, err := o.db.ExecContext(ctx,
+		`UPDATE outbox SET attempts = attempts + 1, next_attempt_at = ?, last_error = ? WHERE id = ?`,
+		time.Now().Add(delay).UnixMilli(), err.Error(), row.id); err != nil {
+		Logger(ctx).Error("failed to reschedule event", "subscriber", row.subscriber, "error", err)
+	}
+	return false
+}
+
+func (o *Outbox) post(ctx context.Context, url string, payload []byte) error {
+	req, err := newRequest(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("subscriber answered %s", resp.Status)
+	}
+	return nil
+}
+
+// EventHandler handles one event; an error makes the publisher retry it
+type EventHandler func(ctx context.Context, event Event) error
+
+// Deduper remembers which events were handled
+type Deduper interface {
+	Seen(ctx context.Context, id string) (bool, error)
+	Mark(ctx context.Context, id string) error
+}
+
+// HandleEvents serves the /events endpoint of a subscriber. Events seen
+// before are acknowledged without handling them again.
+func HandleEvents(dedup Deduper, handle EventHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		if r.Method != http.MethodPost || json.NewDecoder(r.Body).Decode(&event) != nil || event.ID == "" || event.Type == "" {
+			http.Error(w, "invalid event", http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+		if id := r.Header.Get(RequestIDHeader); validRequestID.MatchString(id) {
+			ctx = WithRequestID(ctx, id)
+		}
+		ctx = WithLogFields(ctx, "event_id", event.ID, "event_type", event.Type)
+		ctx, span := StartSpan(ExtractTrace(ctx, r.Header.Get(TraceparentHeader)), "event "+event.Type, SpanKindServer)
+		span.SetAttributes("event.id", event.ID, "event.type", event.Type, "event.service", event.Service)
+		defer span.Finish()
+
+		seen, err := dedup.Seen(ctx, event.ID)
+		if err != nil {
+			span.SetError(err.Error())
+			Logger(ctx).Error("failed to check event", "error", err)
+			http.Error(w, "failed to check event", http.StatusInternalServerError)
+			return
+		}
+		if seen {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if err := handle(ctx, event); err != nil {
+			span.SetError(err.Error())
+			Logger(ctx).Error("failed to handle event", "error", err)
+			http.Error(w, "failed to handle event", http.StatusInternalServerError)
+			return
+		}
+		if err := dedup.Mark(ctx, event.ID); err != nil {
+			Logger(ctx).Warn("failed to remember event", "error", err)
+		}
+		Logger(ctx).Debug("event handled")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// MemoryDeduper remembers the last handled events in memory, for
+// subscribers whose handling is cheap to repeat after a restart
+type MemoryDeduper struct {
+	mu    sync.Mutex
+	seen  map[string]bool
+	order []string
+	next  int
+}
+
+// NewMemoryDeduper remembers up to size events
+func NewMemoryDeduper(size int) *MemoryDeduper {
+	return &MemoryDeduper{seen: map[string]bool{}, order: make([]string, size)}
+}
+
+// Seen reports whether an event was handled
+func (d *MemoryDeduper) Seen(ctx context.Context, id string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.seen[id], nil
+}
+
+// Mark remembers an event, forgetting the oldest one when full
+func (d *MemoryDeduper) Mark(ctx context.Context, id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.seen[id] {
+		return nil
+	}
+	if old := d.order[d.next]; old != "" {
+		delete(d.seen, old)
+	}
+	d.order[d.next] = id
+	d.next = (d.next + 1) % len(d.order)
+	d.seen[id] = true
+	return nil
+}
+
+// DBDeduper remembers handled events in the database of the subscriber
+type DBDeduper struct {
+	db *sql.DB
+}
+
+// NewDBDeduper creates the processed_events table
+func NewDBDeduper(db *sql.DB) (*DBDeduper, error) {
+	schema := `
+	CREATE TABLE IF NOT EXISTS processed_events (
+		event_id TEXT PRIMARY KEY,
+		processed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+	`
+	if err := ExecuteSchema(db, schema); err != nil {
+		return nil, err
+	}
+	return &DBDeduper{db: db}, nil
+}
+
+// Seen reports whether an event was handled
+func (d *DBDeduper) Seen(ctx context.Context, id string) (bool, error) {
+	var count int
+	err := d.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM processed_events WHERE event_id = ?`, id).Scan(&count)
+	return count > 0, err
+}
+
+// Mark remembers an event
+func (d *DBDeduper) Mark(ctx context.Context, id string) error {
+	_, err := d.db.ExecContext(ctx, `INSERT OR IGNORE INTO processed_events (event_id) VALUES (?)`, id)
+	return err
+}
@@ -0,0 +1,347 @@
+package shared
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testDB is an empty in-memory database. It keeps a single connection,
+// since each connection to :memory: opens a database of its own.
+func testDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open(instrumentedDriver, ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// testOutbox is an outbox delivering to urls
+func testOutbox(t *testing.T, urls ...string) *Outbox {
+	t.Helper()
+	t.Setenv("EVENT_SUBSCRIBERS", strings.Join(urls, ","))
+	outbox, err := NewOutbox(testDB(t), "test-service")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return outbox
+}
+
+func publish(t *testing.T, outbox *Outbox, eventType string) {
+	t.Helper()
+	ctx := context.Background()
+	tx, err := outbox.db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := outbox.Publish(ctx, tx, eventType, map[string]interface{}{"id": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// makeDue makes every undelivered event due now, as if its retry delay
+// had passed
+func makeDue(t *testing.T, outbox *Outbox) {
+	t.Helper()
+	if _, err := outbox.db.Exec(`UPDATE outbox SET next_attempt_at = 0 WHERE delivered_at IS NULL`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// recorder is a subscriber remembering the types of the events it took.
+// While failing is set it answers 503 instead.
+type recorder struct {
+	mu      sync.Mutex
+	types   []string
+	failing atomic.Bool
+	hits    atomic.Int32
+}
+
+func (r *recorder) serve(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		r.hits.Add(1)
+		if r.failing.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var event Event
+		json.NewDecoder(req.Body).Decode(&event)
+		r.mu.Lock()
+		r.types = append(r.types, event.Type)
+		r.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func (r *recorder) received() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return strings.Join(r.types, " ")
+}
+
+func pending(t *testing.T, outbox *Outbox) int {
+	t.Helper()
+	var count int
+	if err := outbox.db.QueryRow(`SELECT COUNT(*) FROM outbox WHERE delivered_at IS NULL`).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+func TestOutboxDelivery(t *testing.T) {
+	var kitchen, display recorder
+	outbox := testOutbox(t, kitchen.serve(t), display.serve(t))
+	ctx := context.Background()
+
+	publish(t, outbox, "menu.updated")
+	publish(t, outbox, "menu.deleted")
+	outbox.deliverDue(ctx)
+
+	for name, subscriber := range map[string]*recorder{"kitchen": &kitchen, "display": &display} {
+		if got := subscriber.received(); got != "menu.updated menu.deleted" {
+			t.Errorf("%s received %q", name, got)
+		}
+	}
+	if n := pending(t, outbox); n != 0 {
+		t.Errorf("%d deliveries pending after a successful round", n)
+	}
+
+	// Delivered events are not sent again
+	outbox.deliverDue(ctx)
+	if hits := kitchen.hits.Load(); hits != 2 {
+		t.Errorf("kitchen got %d requests, want 2", hits)
+	}
+}
+
+func TestOutboxRollback(t *testing.T) {
+	var subscriber recorder
+	outbox := testOutbox(t, subscriber.serve(t))
+	ctx := context.Background()
+
+	tx, err := outbox.db.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := outbox.Publish(ctx, tx, "menu.deleted", nil); err != nil {
+		t.Fatal(err)
+	}
+	tx.Rollback()
+
+	outbox.deliverDue(ctx)
+	if hits := subscriber.hits.Load(); hits != 0 {
+		t.Errorf("event of a rolled back change delivered %d times", hits)
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	var subscriber recorder
+	subscriber.failing.Store(true)
+	outbox := testOutbox(t, subscriber.serve(t))
+	ctx := context.Background()
+	publish(t, outbox, "menu.updated")
+
+	retryDelay := func() (int, time.Duration, string) {
+		t.Helper()
+		var attempts int
+		var next int64
+		var lastError string
+		if err := outbox.db.QueryRow(`SELECT attempts, next_attempt_at, last_error FROM outbox`).Scan(&attempts, &next, &lastError); err != nil {
+			t.Fatal(err)
+		}
+		return attempts, time.Until(time.UnixMilli(next)), lastError
+	}
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, eventRetryDelay},
+		{2, 2 * eventRetryDelay},
+		{3, 4 * eventRetryDelay},
+		{4, 8 * eventRetryDelay},
+		{20, eventMaxRetryDelay},
+	}
+	for _, tt := range tests {
+		// Jump ahead to the attempt before the one checked
+		if _, err := outbox.db.Exec(`UPDATE outbox SET attempts = ?, next_attempt_at = 0`, tt.attempts-1); err != nil {
+			t.Fatal(err)
+		}
+		outbox.deliverDue(ctx)
+
+		attempts, delay, lastError := retryDelay()
+		if attempts != tt.attempts {
+			t.Errorf("attempts = %d, want %d", attempts, tt.attempts)
+		}
+		if delay > tt.want || delay < tt.want-time.Second {
+			t.Errorf("attempt %d: retried in %v, want %v", tt.attempts, delay, tt.want)
+		}
+		if !strings.Contains(lastError, "503") {
+			t.Errorf("last_error = %q, want the status of the answer", lastError)
+		}
+	}
+
+	// An event is not retried before its delay passed
+	hits := subscriber.hits.Load()
+	outbox.deliverDue(ctx)
+	if subscriber.hits.Load() != hits {
+		t.Error("event retried before its delay passed")
+	}
+}
+
+func TestOutboxOrdering(t *testing.T) {
+	var flaky, healthy recorder
+	flaky.failing.Store(true)
+	outbox := testOutbox(t, flaky.serve(t), healthy.serve(t))
+	ctx := context.Background()
+
+	types := []string{"order.created", "order.updated", "order.ready", "order.completed"}
+	for _, eventType := range types {
+		publish(t, outbox, eventType)
+	}
+	want := strings.Join(types, " ")
+
+	// A failing subscriber is left alone after its first failure, so it
+	// cannot take a later event before an earlier one, nor hold up the others
+	outbox.deliverDue(ctx)
+	if hits := flaky.hits.Load(); hits != 1 {
+		t.Errorf("failing subscriber got %d requests in a round, want 1", hits)
+	}
+	if got := healthy.received(); got != want {
+		t.Errorf("healthy subscriber received %q, want %q", got, want)
+	}
+
+	// Once it recovers it gets every event, in order
+	flaky.failing.Store(false)
+	makeDue(t, outbox)
+	outbox.deliverDue(ctx)
+	if got := flaky.received(); got != want {
+		t.Errorf("recovered subscriber received %q, want %q", got, want)
+	}
+	if n := pending(t, outbox); n != 0 {
+		t.Errorf("%d deliveries pending after the subscriber recovered", n)
+	}
+}
+
+func TestOutboxRedelivery(t *testing.T) {
+	dedup, err := NewDBDeduper(testDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var handled atomic.Int32
+	server := httptest.NewServer(HandleEvents(dedup, func(ctx context.Context, event Event) error {
+		handled.Add(1)
+		return nil
+	}))
+	t.Cleanup(server.Close)
+
+	outbox := testOutbox(t, server.URL)
+	ctx := context.Background()
+	publish(t, outbox, "branch.deleted")
+	outbox.deliverDue(ctx)
+
+	// As if the acknowledgement got lost: the event is delivered again
+	if _, err := outbox.db.Exec(`UPDATE outbox SET delivered_at = NULL`); err != nil {
+		t.Fatal(err)
+	}
+	outbox.deliverDue(ctx)
+
+	if n := handled.Load(); n != 1 {
+		t.Errorf("event handled %d times, want 1", n)
+	}
+	if n := pending(t, outbox); n != 0 {
+		t.Errorf("re-delivered event not acknowledged: %d pending", n)
+	}
+}
+
+// post sends an event to handler and returns the status it answered
+func post(handler http.Handler, event Event) int {
+	body, _ := json.Marshal(event)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/events", bytes.NewReader(body)))
+	return w.Code
+}
+
+func TestHandleEvents(t *testing.T) {
+	dbDedup, err := NewDBDeduper(testDB(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dedupers := map[string]Deduper{
+		"memory":   NewMemoryDeduper(10),
+		"database": dbDedup,
+	}
+
+	for name, dedup := range dedupers {
+		t.Run(name, func(t *testing.T) {
+			var handled atomic.Int32
+			var fail atomic.Bool
+			handler := HandleEvents(dedup, func(ctx context.Context, event Event) error {
+				handled.Add(1)
+				if fail.Load() {
+					return errors.New("busy")
+				}
+				return nil
+			})
+
+			event := Event{ID: "event-" + name, Type: "menu.deleted", Service: "menu-service"}
+			if code := post(handler, event); code != http.StatusNoContent {
+				t.Errorf("first delivery answered %d", code)
+			}
+			if code := post(handler, event); code != http.StatusNoContent {
+				t.Errorf("repeated delivery answered %d", code)
+			}
+			if n := handled.Load(); n != 1 {
+				t.Errorf("event handled %d times, want 1", n)
+			}
+
+			// A failed event is not remembered, so its retry is handled
+			fail.Store(true)
+			failed := Event{ID: "failed-" + name, Type: "menu.deleted"}
+			if code := post(handler, failed); code != http.StatusInternalServerError {
+				t.Errorf("failing handler answered %d", code)
+			}
+			fail.Store(false)
+			if code := post(handler, failed); code != http.StatusNoContent {
+				t.Errorf("retry answered %d", code)
+			}
+			if n := handled.Load(); n != 3 {
+				t.Errorf("handler ran %d times, want 3", n)
+			}
+
+			if code := post(handler, Event{Type: "menu.deleted"}); code != http.StatusBadRequest {
+				t.Errorf("event without an ID answered %d", code)
+			}
+		})
+	}
+}
+
+func TestMemoryDeduperForgetsOldest(t *testing.T) {
+	ctx := context.Background()
+	dedup := NewMemoryDeduper(2)
+	for _, id := range []string{"a", "b", "b", "c"} {
+		dedup.Mark(ctx, id)
+	}
+	for id, want := range map[string]bool{"a": false, "b": true, "c": true} {
+		if seen, _ := dedup.Seen(ctx, id); seen != want {
+			t.Errorf("Seen(%q) = %v, want %v", id, seen, want)
+		}
+	}
+}
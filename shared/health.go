@@ -0,0 +1,178 @@
+package shared
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Health states, from best to worst
+const (
+	HealthOK       = "ok"
+	HealthDegraded = "degraded"
+	HealthDown     = "down"
+)
+
+// Dependencies get this long to answer a health check
+const healthCheckTimeout = 2 * time.Second
+
+// HealthReport is the answer of /health and /ready
+type HealthReport struct {
+	Service       string        `json:"service"`
+	Status        string        `json:"status"`
+	UptimeSeconds int64         `json:"uptime_seconds"`
+	SchemaVersion int           `json:"schema_version,omitempty"`
+	Checks        []HealthCheck `json:"checks,omitempty"`
+}
+
+// HealthCheck is the result of one readiness check
+type HealthCheck struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latency_ms"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Health serves the health endpoints of a service:
+//
+//	/health  liveness; answers as long as the process runs
+//	/ready   readiness; pings the database, reads its schema version and
+//	         checks the services this one calls
+//
+// A failing database makes the service down (503); a failing dependency
+// only degrades it, since its other actions still work.
+type Health struct {
+	service string
+	started time.Time
+	db      *sql.DB
+	checks  []namedCheck
+}
+
+type namedCheck struct {
+	name     string
+	critical bool
+	check    func(ctx context.Context) error
+}
+
+// NewHealth creates the health endpoints of a service
+func NewHealth(service string) *Health {
+	return &Health{service: service, started: time.Now()}
+}
+
+// WithDatabase makes readiness depend on db
+func (h *Health) WithDatabase(db *sql.DB) *Health {
+	h.db = db
+	return h
+}
+
+// DependsOn adds a service this one calls, checked through its /health
+func (h *Health) DependsOn(name, url string) *Health {
+	return h.WithCheck(name, false, func(ctx context.Context) error {
+		return checkService(ctx, url)
+	})
+}
+
+// WithCheck adds a readiness check; a failing critical check makes the
+// service down, any other failing check degrades it
+func (h *Health) WithCheck(name string, critical bool, check func(ctx context.Context) error) *Health {
+	h.checks = append(h.checks, namedCheck{name: name, critical: critical, check: check})
+	return h
+}
+
+// Register adds /health and /ready to the default mux
+func (h *Health) Register() {
+	http.HandleFunc("/health", h.HandleHealth)
+	http.HandleFunc("/ready", h.HandleReady)
+}
+
+// HandleHealth answers liveness checks
+func (h *Health) HandleHealth(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, HealthReport{Service: h.service, Status: HealthOK, UptimeSeconds: h.uptime()})
+}
+
+// HandleReady runs every check and answers with the report
+func (h *Health) HandleReady(w http.ResponseWriter, r *http.Request) {
+	writeHealth(w, h.Report(r.Context()))
+}
+
+// Report runs every check concurrently
+func (h *Health) Report(ctx context.Context) HealthReport {
+	report := HealthReport{Service: h.service, Status: HealthOK, UptimeSeconds: h.uptime()}
+
+	checks := h.checks
+	if h.db != nil {
+		checks = append([]namedCheck{{name: "database", critical: true, check: func(ctx context.Context) error {
+			if err := h.db.PingContext(ctx); err != nil {
+				return err
+			}
+			version, err := SchemaVersion(ctx, h.db)
+			report.SchemaVersion = version
+			return err
+		}}}, checks...)
+	}
+
+	report.Checks = make([]HealthCheck, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c namedCheck) {
+			defer wg.Done()
+			start := time.Now()
+			result := HealthCheck{Name: c.name, Status: HealthOK}
+			if err := c.check(ctx); err != nil {
+				result.Status = HealthDown
+				result.Error = Redact(err.Error())
+			}
+			result.LatencyMs = time.Since(start).Milliseconds()
+			report.Checks[i] = result
+		}(i, c)
+	}
+	wg.Wait()
+
+	for i, result := range report.Checks {
+		switch {
+		case result.Status == HealthOK:
+		case checks[i].critical:
+			report.Status = HealthDown
+		case report.Status == HealthOK:
+			report.Status = HealthDegraded
+		}
+	}
+	return report
+}
+
+func (h *Health) uptime() int64 {
+	return int64(time.Since(h.started).Seconds())
+}
+
+func writeHealth(w http.ResponseWriter, report HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status == HealthDown {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// checkService checks that the service at url answers its /health
+func checkService(ctx context.Context, url string) error {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	req, err := newRequest(ctx, http.MethodGet, url+"/health", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("health check answered %s", resp.Status)
+	}
+	return nil
+}
@@ -2,27 +2,80 @@ package shared
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
+	"net"
 	"net/http"
+	neturl "net/url"
+	"sync/atomic"
 	"time"
 )
 
-// HTTPClient wraps HTTP client with default settings
+const (
+	// Timeout of a single attempt
+	requestTimeout = 10 * time.Second
+	// Attempts for calls that are safe to repeat
+	maxAttempts = 3
+	// Delay before the first retry; it doubles for every later one
+	retryBaseDelay = 200 * time.Millisecond
+)
+
+// Actions that only read, so a call may be repeated when its outcome is
+// unknown. Other actions are retried only when the request never reached
+// the service.
+var readOnlyActions = map[string]bool{
+	"read": true, "list": true, "verify": true, "nearest": true, "quote": true,
+	"export": true, "download": true, "active": true, "get_preference": true, "get_recipe": true,
+	"list_categories": true, "list_options": true, "list_ingredients": true, "list_stock_movements": true,
+	"summary": true, "revenue": true, "top_items": true, "category_mix": true, "heatmap": true,
+	"promo_effectiveness": true, "chart": true,
+}
+
+// IsReadOnlyAction reports whether a service action only reads data
+func IsReadOnlyAction(action string) bool {
+	return readOnlyActions[action]
+}
+
+// HTTPClient calls the services. Calls that fail before reaching a service,
+// and read-only calls that fail on the way, are retried with backoff. Every
+// service has a circuit breaker: after repeated failures its calls fail
+// fast with ErrCircuitOpen until it has had time to recover.
 type HTTPClient struct {
-	client *http.Client
+	client   *http.Client
+	ctx      context.Context
+	breakers *breakers
+	refused  *atomic.Bool
 }
 
 // NewHTTPClient creates a new HTTP client
 func NewHTTPClient() *HTTPClient {
 	return &HTTPClient{
 		client: &http.Client{
-			Timeout: 10 * time.Second,
+			Timeout: requestTimeout,
 		},
+		breakers: &breakers{byTarget: map[string]*breaker{}},
+		refused:  &atomic.Bool{},
 	}
 }
 
+// WithContext returns a client whose requests are made with ctx, so they
+// carry its request ID and trace and are cancelled with it. The copy shares
+// the connection pool and circuit breakers of c.
+func (c *HTTPClient) WithContext(ctx context.Context) *HTTPClient {
+	return &HTTPClient{client: c.client, ctx: ctx, breakers: c.breakers, refused: &atomic.Bool{}}
+}
+
+// RefusedByBreaker reports whether a call made with this client was refused
+// because the circuit of its service was open. Copies made with WithContext
+// start over.
+func (c *HTTPClient) RefusedByBreaker() bool {
+	return c.refused.Load()
+}
+
 // Request represents a standard request
 type Request struct {
 	Action  string      `json:"action"`
@@ -36,55 +89,179 @@ type Response struct {
 	Error   *ErrorInfo  `json:"error,omitempty"`
 }
 
-// ErrorInfo represents error details
+// ErrorInfo represents error details. Key and Params, when set, name the
+// message in the catalogue (see T) so it can be shown in another locale.
 type ErrorInfo struct {
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Code    string   `json:"code"`
+	Message string   `json:"message"`
+	Key     string   `json:"key,omitempty"`
+	Params  []string `json:"params,omitempty"`
 }
 
 // Post sends a POST request with JSON body
 func (c *HTTPClient) Post(url string, req Request) (*Response, error) {
+	return c.PostContext(c.context(), url, req)
+}
+
+// PostContext sends a POST request with JSON body, made with ctx
+func (c *HTTPClient) PostContext(ctx context.Context, url string, req Request) (*Response, error) {
+	start := time.Now()
+	target := metricsTarget(url)
+	ctx, span := StartSpan(ctx, "call "+req.Action, SpanKindClient)
+	span.SetAttributes("rpc.target", target, "rpc.action", req.Action)
+	defer span.Finish()
+
 	jsonData, err := json.Marshal(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	resp, err := c.client.Post(url, "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
+	response, attempts, err := c.do(ctx, http.MethodPost, url, target, jsonData, IsReadOnlyAction(req.Action))
+	span.SetAttributes("rpc.attempts", attempts)
 
-	body, err := io.ReadAll(resp.Body)
+	clientRequestsTotal.Inc(target, req.Action)
+	clientRequestDuration.Observe(time.Since(start).Seconds(), target, req.Action)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		clientRequestErrors.Inc(target, req.Action, ErrCodeServiceError)
+		span.SetError(err.Error())
+	} else if !response.Success && response.Error != nil {
+		clientRequestErrors.Inc(target, req.Action, response.Error.Code)
+		span.SetAttributes("error.code", response.Error.Code)
 	}
 
-	var response Response
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
-	}
-
-	return &response, nil
+	return response, err
 }
 
 // Get sends a GET request
 func (c *HTTPClient) Get(url string) (*Response, error) {
-	resp, err := c.client.Get(url)
+	response, _, err := c.do(c.context(), http.MethodGet, url, metricsTarget(url), nil, true)
+	return response, err
+}
+
+// outcome classifies a single attempt
+type outcome int
+
+const (
+	answered  outcome = iota // the service answered, possibly with an error
+	rejected                 // the service answered with a client error or garbage
+	notSent                  // the request never reached the service
+	failed                   // the request may have reached the service
+	cancelled                // the caller gave up
+)
+
+// do sends a request through the breaker of target, retrying attempts that
+// are safe to repeat. It returns the number of attempts made.
+func (c *HTTPClient) do(ctx context.Context, method, url, target string, body []byte, repeatable bool) (*Response, int, error) {
+	b := c.breakers.get(target)
+
+	for attempt := 1; ; attempt++ {
+		if !b.allow() {
+			c.refused.Store(true)
+			return nil, attempt - 1, fmt.Errorf("%s: %w", target, ErrCircuitOpen)
+		}
+
+		response, result, err := c.send(ctx, method, url, body)
+		switch result {
+		case answered, rejected:
+			b.record(ctx, true)
+		case cancelled:
+			b.release()
+		default:
+			b.record(ctx, false)
+		}
+
+		retry := result == notSent || result == failed && repeatable
+		if err == nil || !retry || attempt == maxAttempts {
+			return response, attempt, err
+		}
+
+		// Exponential backoff with jitter, so callers do not retry in step
+		delay := retryBaseDelay << (attempt - 1)
+		delay = delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+		select {
+		case <-ctx.Done():
+			return nil, attempt, err
+		case <-time.After(delay):
+		}
+	}
+}
+
+// send makes a single attempt
+func (c *HTTPClient) send(ctx context.Context, method, url string, body []byte) (*Response, outcome, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpReq, err := newRequest(ctx, method, url, reader)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, rejected, err
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.client.Do(httpReq)
+	if err != nil {
+		err = fmt.Errorf("failed to send request: %w", err)
+		var opErr *net.OpError
+		switch {
+		case ctx.Err() != nil:
+			return nil, cancelled, err
+		case errors.As(err, &opErr) && opErr.Op == "dial":
+			return nil, notSent, err
+		default:
+			return nil, failed, err
+		}
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	respBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, failed, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode >= 500 {
+		return nil, failed, fmt.Errorf("service answered %s", resp.Status)
+	}
+	if resp.StatusCode >= 300 {
+		return nil, rejected, fmt.Errorf("service answered %s", resp.Status)
 	}
 
 	var response Response
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	if err := json.Unmarshal(respBody, &response); err != nil {
+		return nil, rejected, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
-	return &response, nil
+	return &response, answered, nil
+}
+
+// metricsTarget labels calls by the host they went to, e.g. menu-service:8082
+func metricsTarget(rawURL string) string {
+	if u, err := neturl.Parse(rawURL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return "unknown"
+}
+
+// context returns the context requests are made with
+func (c *HTTPClient) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// newRequest builds a request with the request ID and trace context of ctx
+func newRequest(ctx context.Context, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if id := RequestIDFromContext(ctx); id != "" {
+		req.Header.Set(RequestIDHeader, id)
+	}
+	if span := SpanFromContext(ctx); span != nil {
+		req.Header.Set(TraceparentHeader, span.Traceparent())
+	}
+	return req, nil
 }
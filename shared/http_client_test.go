@@ -0,0 +1,206 @@
+package shared
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingServer answers with handler and counts the requests it got
+func countingServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	hits := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server, hits
+}
+
+func answer(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+}
+
+// testClient is a client with a short timeout, so tests need not wait long
+func testClient() *HTTPClient {
+	c := NewHTTPClient()
+	c.client.Timeout = 100 * time.Millisecond
+	return c
+}
+
+// closedURL is the address of a server that no longer listens
+func closedURL() string {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	return server.URL
+}
+
+func TestSendOutcome(t *testing.T) {
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+	}
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		url     string
+		want    outcome
+	}{
+		{"success", answer(200, `{"success":true}`), "", answered},
+		{"error answer", answer(200, `{"success":false,"error":{"code":"NOT_FOUND"}}`), "", answered},
+		{"client error", answer(400, ""), "", rejected},
+		{"not json", answer(200, "<html>"), "", rejected},
+		{"server error", answer(502, ""), "", failed},
+		{"timeout", slow, "", failed},
+		{"refused", nil, closedURL(), notSent},
+		{"bad url", nil, "http://[::1", rejected},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			url := tt.url
+			if tt.handler != nil {
+				server, _ := countingServer(t, tt.handler)
+				url = server.URL
+			}
+			_, got, _ := testClient().send(context.Background(), http.MethodPost, url, []byte(`{}`))
+			if got != tt.want {
+				t.Errorf("send() outcome = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("cancelled", func(t *testing.T) {
+		server, _ := countingServer(t, slow)
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if _, got, _ := testClient().send(ctx, http.MethodPost, server.URL, []byte(`{}`)); got != cancelled {
+			t.Errorf("send() outcome = %d, want %d", got, cancelled)
+		}
+	})
+}
+
+func TestRetries(t *testing.T) {
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(300 * time.Millisecond)
+	}
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		action   string
+		wantHits int32
+	}{
+		{"write sent once after a timeout", slow, "create", 1},
+		{"write sent once after a server error", answer(500, ""), "create", 1},
+		{"read repeated after a timeout", slow, "list", maxAttempts},
+		{"read repeated after a server error", answer(500, ""), "list", maxAttempts},
+		{"client error not repeated", answer(400, ""), "list", 1},
+		{"error answer not repeated", answer(200, `{"success":false}`), "create", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, hits := countingServer(t, tt.handler)
+			testClient().Post(server.URL, Request{Action: tt.action})
+			if got := hits.Load(); got != tt.wantHits {
+				t.Errorf("service got %d requests, want %d", got, tt.wantHits)
+			}
+		})
+	}
+
+	t.Run("write repeated when never sent", func(t *testing.T) {
+		c := testClient()
+		_, attempts, err := c.do(context.Background(), http.MethodPost, closedURL(), "closed", []byte(`{}`), false)
+		if err == nil || attempts != maxAttempts {
+			t.Errorf("do() = %d attempts, %v; want %d attempts and an error", attempts, err, maxAttempts)
+		}
+	})
+}
+
+func TestBreaker(t *testing.T) {
+	b := &breaker{target: "test-breaker"}
+
+	for i := 1; i < breakerThreshold; i++ {
+		if !b.allow() {
+			t.Fatalf("closed breaker refused call %d", i)
+		}
+		b.record(context.Background(), false)
+	}
+	if !b.allow() {
+		t.Fatal("breaker opened before the threshold")
+	}
+	b.record(context.Background(), false)
+	if b.allow() {
+		t.Fatalf("breaker allowed a call after %d failures", breakerThreshold)
+	}
+
+	// Half-open: once the cooldown is over a single trial goes through
+	b.openUntil = time.Now().Add(-time.Second)
+	if !b.allow() {
+		t.Fatal("breaker refused the trial after the cooldown")
+	}
+	if b.allow() {
+		t.Fatal("breaker allowed a second call during the trial")
+	}
+
+	// A failed trial opens it for another cooldown
+	b.record(context.Background(), false)
+	if b.allow() {
+		t.Fatal("breaker allowed a call after a failed trial")
+	}
+
+	// A cancelled trial lets the next call try again
+	b.openUntil = time.Now().Add(-time.Second)
+	if !b.allow() {
+		t.Fatal("breaker refused the trial after the cooldown")
+	}
+	b.release()
+	if !b.allow() {
+		t.Fatal("breaker refused a trial after the last one was cancelled")
+	}
+
+	// A successful trial closes it
+	b.record(context.Background(), true)
+	for i := 0; i < breakerThreshold; i++ {
+		if !b.allow() {
+			t.Fatalf("closed breaker refused call %d", i)
+		}
+	}
+}
+
+func TestClientOpensBreaker(t *testing.T) {
+	server, hits := countingServer(t, answer(500, ""))
+	c := testClient()
+
+	for i := 0; i < breakerThreshold; i++ {
+		c.Post(server.URL, Request{Action: "create"})
+	}
+	if c.RefusedByBreaker() {
+		t.Fatal("client refused a call before the circuit opened")
+	}
+
+	_, err := c.Post(server.URL, Request{Action: "create"})
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Post() error = %v, want %v", err, ErrCircuitOpen)
+	}
+	if !c.RefusedByBreaker() {
+		t.Error("RefusedByBreaker() = false after a refused call")
+	}
+	if got := hits.Load(); got != breakerThreshold {
+		t.Errorf("service got %d requests, want %d", got, breakerThreshold)
+	}
+
+	// Copies share the breaker but not the refused flag
+	copied := c.WithContext(context.Background())
+	if copied.RefusedByBreaker() {
+		t.Error("copy starts out refused")
+	}
+	if _, err := copied.Post(server.URL, Request{Action: "create"}); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("copy Post() error = %v, want %v", err, ErrCircuitOpen)
+	}
+}
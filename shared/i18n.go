@@ -0,0 +1,183 @@
+package shared
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DefaultLocale is used for users without a language preference and for
+// texts that have no translation in their locale
+const DefaultLocale = "id"
+
+// Locales lists the supported locales, in the order they are offered to users
+var Locales = []string{"id", "en"}
+
+// The message catalogue: one JSON file per locale mapping keys to texts.
+// A text is a fmt format string, or an object with "one" and "other" forms
+// for texts that depend on a count (see TN).
+//
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+type message struct {
+	Text  string
+	One   string
+	Other string
+}
+
+func (m *message) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '"' {
+		return json.Unmarshal(data, &m.Text)
+	}
+	var forms struct {
+		One   string `json:"one"`
+		Other string `json:"other"`
+	}
+	if err := json.Unmarshal(data, &forms); err != nil {
+		return err
+	}
+	m.One, m.Other = forms.One, forms.Other
+	return nil
+}
+
+var catalogue = loadCatalogue()
+
+// loadCatalogue reads the embedded locale files. They are part of the
+// binary, so a broken file is a programming error.
+func loadCatalogue() map[string]map[string]message {
+	messages := make(map[string]map[string]message)
+	for _, locale := range Locales {
+		data, err := localeFiles.ReadFile("locales/" + locale + ".json")
+		if err != nil {
+			panic(fmt.Sprintf("locale %s: %v", locale, err))
+		}
+		var entries map[string]message
+		if err := json.Unmarshal(data, &entries); err != nil {
+			panic(fmt.Sprintf("locale %s: %v", locale, err))
+		}
+		messages[locale] = entries
+	}
+	return messages
+}
+
+// IsSupportedLocale reports whether locale has a catalogue
+func IsSupportedLocale(locale string) bool {
+	_, ok := catalogue[locale]
+	return ok
+}
+
+// NormalizeLocale maps a language code such as Telegram's language_code
+// ("en", "en-US", "id") to a supported locale, falling back to DefaultLocale
+func NormalizeLocale(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if i := strings.IndexAny(code, "-_"); i >= 0 {
+		code = code[:i]
+	}
+	if IsSupportedLocale(code) {
+		return code
+	}
+	return DefaultLocale
+}
+
+func lookupMessage(locale, key string) (message, bool) {
+	if msg, ok := catalogue[locale][key]; ok {
+		return msg, true
+	}
+	msg, ok := catalogue[DefaultLocale][key]
+	return msg, ok
+}
+
+// Lookup returns the text of key in locale itself, without falling back to
+// DefaultLocale
+func Lookup(locale, key string) (string, bool) {
+	msg, ok := catalogue[locale][key]
+	if !ok {
+		return "", false
+	}
+	if msg.Text != "" {
+		return msg.Text, true
+	}
+	return msg.Other, true
+}
+
+// T returns the text of key in locale formatted with args. Keys missing in
+// locale fall back to DefaultLocale; unknown keys are returned as they are.
+func T(locale, key string, args ...interface{}) string {
+	msg, ok := lookupMessage(locale, key)
+	if !ok {
+		return key
+	}
+	text := msg.Text
+	if text == "" {
+		text = msg.Other
+	}
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// TN returns the plural form of key for count n in locale, formatted with n
+// followed by args
+func TN(locale, key string, n int, args ...interface{}) string {
+	msg, ok := lookupMessage(locale, key)
+	if !ok {
+		return key
+	}
+	text := msg.Other
+	if msg.One != "" && pluralOne(locale, n) {
+		text = msg.One
+	}
+	if text == "" {
+		text = msg.Text
+	}
+	return fmt.Sprintf(text, append([]interface{}{n}, args...)...)
+}
+
+// pluralOne reports whether n takes the "one" form in locale. Indonesian
+// nouns do not change with the count, so it only uses "other".
+func pluralOne(locale string, n int) bool {
+	switch locale {
+	case "en":
+		return n == 1
+	default:
+		return false
+	}
+}
+
+// Translations holds the translated fields of one record by locale, then
+// field. The record's own fields hold its DefaultLocale text.
+type Translations map[string]map[string]string
+
+// Set stores the translation of field in locale
+func (t Translations) Set(locale, field, value string) {
+	if t[locale] == nil {
+		t[locale] = make(map[string]string)
+	}
+	t[locale][field] = value
+}
+
+// Text returns field in locale, or fallback when it has no translation
+func (t Translations) Text(locale, field, fallback string) string {
+	if value := t[locale][field]; value != "" {
+		return value
+	}
+	return fallback
+}
+
+// ValidateTranslation checks the locale and field of a translation. fields
+// lists the translatable fields of the record; its DefaultLocale text is
+// edited on the record itself.
+func ValidateTranslation(locale, field string, fields ...string) *AppError {
+	if locale == DefaultLocale || !IsSupportedLocale(locale) {
+		return NewKeyedInvalidInputError("error.translation_locale")
+	}
+	for _, f := range fields {
+		if f == field {
+			return nil
+		}
+	}
+	return NewKeyedInvalidInputError("error.translation_field")
+}
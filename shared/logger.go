@@ -1,29 +1,150 @@
 package shared
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
 )
 
-var (
-	// InfoLogger logs info messages
-	InfoLogger *log.Logger
-	// ErrorLogger logs error messages
-	ErrorLogger *log.Logger
-)
+// Logs are written to stdout as logfmt, or as JSON with LOG_FORMAT=json.
+// LOG_LEVEL selects the lowest level written: debug, info (default), warn
+// or error. Bot tokens and personal data are redacted from every record.
+var logger = slog.Default()
+
+// Keys whose values are never logged
+var sensitiveKeys = []string{"token", "password", "secret", "authorization", "phone", "email", "address"}
+
+// Values redacted wherever they appear, including inside messages and errors
+var sensitivePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`\d{6,}:[A-Za-z0-9_-]{30,}`),                      // Telegram bot tokens, e.g. in API URLs
+	regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), // email addresses
+	regexp.MustCompile(`(?:\+62|\b62|\b0)8[1-9]\d{6,10}\b`),              // Indonesian phone numbers
+}
+
+const redacted = "[REDACTED]"
+
+type logFieldsKey struct{}
 
 func init() {
-	InfoLogger = log.New(os.Stdout, "INFO: ", log.Ldate|log.Ltime|log.Lshortfile)
-	ErrorLogger = log.New(os.Stderr, "ERROR: ", log.Ldate|log.Ltime|log.Lshortfile)
+	ConfigureLogger("")
+}
+
+// ConfigureLogger sets up logging from LOG_LEVEL and LOG_FORMAT and adds the
+// service name to every record. Call it after loading the .env file. It also
+// routes the standard log package, so log.Fatalf goes through the same output.
+func ConfigureLogger(service string) {
+	opts := &slog.HandlerOptions{
+		AddSource:   true,
+		Level:       parseLogLevel(os.Getenv("LOG_LEVEL")),
+		ReplaceAttr: replaceLogAttr,
+	}
+
+	var handler slog.Handler
+	if strings.EqualFold(os.Getenv("LOG_FORMAT"), "json") {
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	} else {
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	}
+
+	logger = slog.New(handler)
+	if service != "" {
+		logger = logger.With("service", service)
+	}
+	slog.SetDefault(logger)
+	log.SetFlags(log.Lshortfile)
+	log.SetOutput(stdLogWriter{})
 }
 
-// LogInfo logs an info message
-func LogInfo(format string, v ...interface{}) {
-	InfoLogger.Printf(format, v...)
+// Logger returns the logger for ctx, carrying the request ID and fields
+// added with WithRequestID and WithLogFields
+func Logger(ctx context.Context) *slog.Logger {
+	if ctx == nil {
+		return logger
+	}
+	if fields, ok := ctx.Value(logFieldsKey{}).([]any); ok {
+		return logger.With(fields...)
+	}
+	return logger
 }
 
-// LogError logs an error message
-func LogError(format string, v ...interface{}) {
-	ErrorLogger.Printf(format, v...)
+// WithLogFields returns a context whose logger adds the given key-value pairs
+func WithLogFields(ctx context.Context, args ...any) context.Context {
+	fields, _ := ctx.Value(logFieldsKey{}).([]any)
+	fields = append(append([]any{}, fields...), args...)
+	return context.WithValue(ctx, logFieldsKey{}, fields)
+}
+
+// Redact replaces bot tokens, email addresses and phone numbers in s
+func Redact(s string) string {
+	for _, pattern := range sensitivePatterns {
+		s = pattern.ReplaceAllString(s, redacted)
+	}
+	return s
+}
+
+// stdLogWriter logs lines from the log package as errors. The services only
+// use it for log.Fatal, and the bot library for failed API calls.
+type stdLogWriter struct{}
+
+func (stdLogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	record := slog.NewRecord(time.Now(), slog.LevelError, msg, 0)
+	// Lines start with the "file.go:12: " prefix of log.Lshortfile
+	if source, rest, ok := strings.Cut(msg, ": "); ok && strings.Contains(source, ".go:") {
+		record.Message = rest
+		record.AddAttrs(slog.String(slog.SourceKey, source))
+	}
+	if err := logger.Handler().Handle(context.Background(), record); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func parseLogLevel(value string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// replaceLogAttr shortens sources to file:line and redacts sensitive values
+func replaceLogAttr(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.SourceKey {
+		if source, ok := a.Value.Any().(*slog.Source); ok {
+			if source.File == "" {
+				return slog.Attr{}
+			}
+			return slog.String(slog.SourceKey, fmt.Sprintf("%s:%d", filepath.Base(source.File), source.Line))
+		}
+		return a
+	}
+
+	key := strings.ToLower(a.Key)
+	for _, sensitive := range sensitiveKeys {
+		if strings.Contains(key, sensitive) {
+			return slog.String(a.Key, redacted)
+		}
+	}
 
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Redact(a.Value.String()))
+	case slog.KindAny:
+		if err, ok := a.Value.Any().(error); ok {
+			return slog.String(a.Key, Redact(err.Error()))
+		}
+	}
+	return a
 }
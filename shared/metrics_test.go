@@ -0,0 +1,91 @@
+package shared
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func render(m metric) string {
+	var b strings.Builder
+	m.write(&b)
+	return b.String()
+}
+
+func TestHistogramOutput(t *testing.T) {
+	h := NewHistogram("test_histogram_seconds", "A test histogram.", []float64{0.1, 0.5, 1}, "action")
+	for _, v := range []float64{0.05, 0.1, 0.3, 0.7, 2} {
+		h.Observe(v, "list")
+	}
+
+	want := `# HELP test_histogram_seconds A test histogram.
+# TYPE test_histogram_seconds histogram
+test_histogram_seconds_bucket{action="list",le="0.1"} 2
+test_histogram_seconds_bucket{action="list",le="0.5"} 3
+test_histogram_seconds_bucket{action="list",le="1"} 4
+test_histogram_seconds_bucket{action="list",le="+Inf"} 5
+test_histogram_seconds_sum{action="list"} 3.15
+test_histogram_seconds_count{action="list"} 5
+`
+	if got := render(h); got != want {
+		t.Errorf("histogram output =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	c := NewCounter("test_escaped_total", "A test counter.", "query")
+	c.Inc(`say "hi"`)
+	c.Inc(`C:\path`)
+	c.Inc("two\nlines")
+
+	want := `# HELP test_escaped_total A test counter.
+# TYPE test_escaped_total counter
+test_escaped_total{query="C:\\path"} 1
+test_escaped_total{query="say \"hi\""} 1
+test_escaped_total{query="two\nlines"} 1
+`
+	if got := render(c); got != want {
+		t.Errorf("counter output =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestSeriesOrder(t *testing.T) {
+	g := NewGauge("test_order", "A test gauge.", "service", "state")
+	g.Set(3, "order-service", "open")
+	g.Set(1, "menu-service", "open")
+	g.Set(2, "menu-service", "closed")
+	g.Set(4, "auth-service", "closed")
+
+	want := `# HELP test_order A test gauge.
+# TYPE test_order gauge
+test_order{service="auth-service",state="closed"} 4
+test_order{service="menu-service",state="closed"} 2
+test_order{service="menu-service",state="open"} 1
+test_order{service="order-service",state="open"} 3
+`
+	// Rendered twice to show the order does not depend on map iteration
+	for i := 0; i < 2; i++ {
+		if got := render(g); got != want {
+			t.Fatalf("gauge output =\n%s\nwant\n%s", got, want)
+		}
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	c := NewCounter("test_handler_total", "Counted by the handler test.")
+	c.Add(2.5)
+
+	rec := httptest.NewRecorder()
+	MetricsHandler(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if got := rec.Header().Get("Content-Type"); !strings.HasPrefix(got, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", got)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "# TYPE test_handler_total counter\ntest_handler_total 2.5\n") {
+		t.Errorf("body is missing the test counter:\n%s", body)
+	}
+	if !strings.Contains(body, "# TYPE cafe_requests_total counter\n") {
+		t.Errorf("body is missing the request counter:\n%s", body)
+	}
+}
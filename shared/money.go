@@ -0,0 +1,286 @@
+package shared
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Money is an amount of rupiah. Rupiah are not split into smaller units in
+// practice, so amounts are whole rupiah: parsing rejects sen instead of
+// dropping them, and percentages round to the nearest rupiah.
+type Money int64
+
+// String formats m as "Rp 25.000"
+func (m Money) String() string {
+	return m.Format(DefaultLocale)
+}
+
+// Format formats m the way locale writes it: "Rp 25.000" in Indonesian,
+// "IDR 25,000" in English
+func (m Money) Format(locale string) string {
+	sign := ""
+	amount := uint64(m)
+	if m < 0 {
+		sign, amount = "-", uint64(-(m+1))+1
+	}
+	switch locale {
+	case "en":
+		return sign + "IDR " + groupDigits(amount, ",")
+	default:
+		return sign + "Rp " + groupDigits(amount, ".")
+	}
+}
+
+// Int returns m as an int, for quantities such as chart values
+func (m Money) Int() int {
+	return int(m)
+}
+
+// Add returns m + other
+func (m Money) Add(other Money) Money {
+	return m + other
+}
+
+// Sub returns m - other
+func (m Money) Sub(other Money) Money {
+	return m - other
+}
+
+// Mul returns m times a quantity
+func (m Money) Mul(quantity int) Money {
+	return m * Money(quantity)
+}
+
+// Div returns m split into n parts, rounded to the nearest rupiah. Dividing
+// by zero gives zero, so an average over no orders is Rp 0.
+func (m Money) Div(n int) Money {
+	if n == 0 {
+		return 0
+	}
+	return Money(divRound(int64(m), int64(n)))
+}
+
+// Percent returns percent of m rounded to the nearest rupiah, e.g. a 10%
+// discount, 11% tax or 5% service charge. Percentages are taken to two
+// decimals, so 2.5% is exact.
+func (m Money) Percent(percent float64) Money {
+	basisPoints := int64(math.Round(percent * 100))
+	// Split m so the product cannot overflow for any realistic amount
+	whole, rest := int64(m)/10000, int64(m)%10000
+	return Money(whole*basisPoints + divRound(rest*basisPoints, 10000))
+}
+
+// Discounted returns m less a discount, never below zero
+func (m Money) Discounted(discount Money) Money {
+	if discount >= m {
+		return 0
+	}
+	return m - discount
+}
+
+// divRound divides a by b rounding half away from zero
+func divRound(a, b int64) int64 {
+	if b < 0 {
+		a, b = -a, -b
+	}
+	if a < 0 {
+		return -((-a + b/2) / b)
+	}
+	return (a + b/2) / b
+}
+
+// groupDigits writes n with sep between groups of three digits
+func groupDigits(n uint64, sep string) string {
+	digits := strconv.FormatUint(n, 10)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(sep)
+		}
+		b.WriteRune(d)
+	}
+	return b.String()
+}
+
+// fractionalAmount is returned by the parse helpers for amounts with sen
+const fractionalAmount = -1
+
+// Suffixes users write for thousands and millions
+var moneyMultipliers = []struct {
+	suffix string
+	factor int64
+}{
+	{"ribu", 1000},
+	{"rb", 1000},
+	{"k", 1000},
+	{"juta", 1000000},
+	{"jt", 1000000},
+}
+
+// ParseMoney reads an amount as users type it: "25000", "25.000",
+// "Rp 25.000,-", "Rp25k", "25rb" or "1,5jt". Both "." and "," are accepted
+// as thousands separators; amounts with sen are rejected.
+func ParseMoney(input string) (Money, error) {
+	text := strings.ToLower(strings.Join(strings.Fields(input), ""))
+
+	negative := false
+	if strings.HasPrefix(text, "-") || strings.HasPrefix(text, "+") {
+		negative = text[0] == '-'
+		text = text[1:]
+	}
+	for _, prefix := range []string{"idr", "rp.", "rp"} {
+		if rest, ok := strings.CutPrefix(text, prefix); ok {
+			text = rest
+			break
+		}
+	}
+	text = strings.TrimSuffix(strings.TrimSuffix(text, "-"), ",")
+
+	factor := int64(1)
+	for _, m := range moneyMultipliers {
+		if rest, ok := strings.CutSuffix(text, m.suffix); ok {
+			text, factor = rest, m.factor
+			break
+		}
+	}
+
+	var amount int64
+	var ok bool
+	if factor > 1 {
+		amount, ok = parseScaled(text, factor)
+	} else {
+		amount, ok = parseGrouped(text)
+	}
+	if !ok {
+		return 0, NewKeyedInvalidInputError("error.price_format")
+	}
+	if amount == fractionalAmount {
+		return 0, NewKeyedInvalidInputError("error.price_fraction")
+	}
+	if negative {
+		amount = -amount
+	}
+	return Money(amount), nil
+}
+
+// parseScaled reads a number written with a multiplier, where one "." or ","
+// is a decimal point ("1,5jt")
+func parseScaled(text string, factor int64) (int64, bool) {
+	whole, frac := text, ""
+	if i := strings.IndexAny(text, ".,"); i >= 0 {
+		whole, frac = text[:i], text[i+1:]
+		if frac == "" {
+			return 0, false
+		}
+		if isDigits(frac) {
+			frac = strings.TrimRight(frac, "0")
+		}
+	}
+	if !isDigits(whole) || (frac != "" && !isDigits(frac)) {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || n > math.MaxInt64/factor {
+		return 0, false
+	}
+	amount := n * factor
+
+	scale := int64(1)
+	for range frac {
+		scale *= 10
+		if scale > factor {
+			return fractionalAmount, true
+		}
+	}
+	if frac != "" {
+		f, _ := strconv.ParseInt(frac, 10, 64)
+		if f*factor%scale != 0 {
+			return fractionalAmount, true
+		}
+		amount += f * factor / scale
+	}
+	return amount, true
+}
+
+// parseGrouped reads a number with optional thousands separators
+// ("25.000", "25,000") and an optional decimal part ("25.000,00")
+func parseGrouped(text string) (int64, bool) {
+	if text == "" {
+		return 0, false
+	}
+
+	// A last separator followed by one or two digits starts sen
+	if i := strings.LastIndexAny(text, ".,"); i >= 0 && len(text)-i-1 <= 2 {
+		whole, frac, sep := text[:i], text[i+1:], text[i]
+		if frac == "" || !isDigits(frac) || strings.IndexByte(whole, sep) >= 0 {
+			return 0, false
+		}
+		amount, ok := parseGrouped(whole)
+		if !ok {
+			return 0, false
+		}
+		if strings.Trim(frac, "0") != "" {
+			return fractionalAmount, true
+		}
+		return amount, true
+	}
+
+	digits := text
+	if i := strings.IndexAny(text, ".,"); i >= 0 {
+		sep := text[i : i+1]
+		groups := strings.Split(text, sep)
+		if len(groups[0]) == 0 || len(groups[0]) > 3 {
+			return 0, false
+		}
+		for _, group := range groups[1:] {
+			if len(group) != 3 {
+				return 0, false
+			}
+		}
+		digits = strings.Join(groups, "")
+	}
+	if !isDigits(digits) {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(digits, 10, 64)
+	return n, err == nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// MoneyFromPayload reads an amount from a decoded JSON payload: a whole
+// number, or a string in any format ParseMoney accepts
+func MoneyFromPayload(value interface{}) (Money, error) {
+	switch v := value.(type) {
+	case Money:
+		return v, nil
+	case int:
+		return Money(v), nil
+	case int64:
+		return Money(v), nil
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, NewKeyedInvalidInputError("error.price_fraction")
+		}
+		if math.Abs(v) > 1e15 {
+			return 0, NewKeyedInvalidInputError("error.price_too_large")
+		}
+		return Money(v), nil
+	case string:
+		return ParseMoney(v)
+	default:
+		return 0, NewKeyedInvalidInputError("error.price_format")
+	}
+}
@@ -0,0 +1,129 @@
+package shared
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Money
+	}{
+		{"25000", 25000},
+		{"25.000", 25000},
+		{"25,000", 25000},
+		{"Rp 12.500", 12500},
+		{"Rp12.500", 12500},
+		{"rp. 12.500", 12500},
+		{"IDR 1,250,000", 1250000},
+		{"Rp 25.000,-", 25000},
+		{"25.000,00", 25000},
+		{"25rb", 25000},
+		{"25 ribu", 25000},
+		{"Rp25k", 25000},
+		{"1,5jt", 1500000},
+		{"1.25jt", 1250000},
+		{"2 juta", 2000000},
+		{"2,5rb", 2500},
+		{"-5.000", -5000},
+		{"+5000", 5000},
+		{"0", 0},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.input)
+		if err != nil {
+			t.Errorf("ParseMoney(%q) error = %v", tt.input, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseMoney(%q) = %d, want %d", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestParseMoneyRejects(t *testing.T) {
+	tests := []struct {
+		input   string
+		wantKey string
+	}{
+		{"12.5", "error.price_fraction"},
+		{"12,50", "error.price_fraction"},
+		{"1,2345rb", "error.price_fraction"},
+		{"", "error.price_format"},
+		{"Rp", "error.price_format"},
+		{"abc", "error.price_format"},
+		{"12.34.5", "error.price_format"},
+		{"1.2345", "error.price_format"},
+		{"25.000.00", "error.price_format"},
+		{"1,5", "error.price_fraction"},
+		{"jt", "error.price_format"},
+		{"99999999999999999999", "error.price_format"},
+	}
+	for _, tt := range tests {
+		_, err := ParseMoney(tt.input)
+		appErr, ok := err.(*AppError)
+		if !ok {
+			t.Errorf("ParseMoney(%q) error = %v, want %s", tt.input, err, tt.wantKey)
+			continue
+		}
+		if appErr.Code != ErrCodeInvalidInput || appErr.Key != tt.wantKey {
+			t.Errorf("ParseMoney(%q) error = %s %s, want %s %s", tt.input, appErr.Code, appErr.Key, ErrCodeInvalidInput, tt.wantKey)
+		}
+		if want := T(DefaultLocale, tt.wantKey); appErr.Message != want {
+			t.Errorf("ParseMoney(%q) message = %q, want %q", tt.input, appErr.Message, want)
+		}
+	}
+}
+
+func TestMoneyFormat(t *testing.T) {
+	tests := []struct {
+		amount Money
+		locale string
+		want   string
+	}{
+		{0, "id", "Rp 0"},
+		{500, "id", "Rp 500"},
+		{25000, "id", "Rp 25.000"},
+		{1250000, "id", "Rp 1.250.000"},
+		{-12500, "id", "-Rp 12.500"},
+		{25000, "en", "IDR 25,000"},
+		{1250000, "en", "IDR 1,250,000"},
+		{Money(math.MinInt64), "id", "-Rp 9.223.372.036.854.775.808"},
+	}
+	for _, tt := range tests {
+		if got := tt.amount.Format(tt.locale); got != tt.want {
+			t.Errorf("Money(%d).Format(%q) = %q, want %q", tt.amount, tt.locale, got, tt.want)
+		}
+	}
+}
+
+func TestFormatThenParse(t *testing.T) {
+	for _, amount := range []Money{0, 7, 999, 1000, 12500, 1250000, 987654321} {
+		for _, locale := range []string{"id", "en"} {
+			got, err := ParseMoney(amount.Format(locale))
+			if err != nil || got != amount {
+				t.Errorf("ParseMoney(%q) = %d, %v, want %d", amount.Format(locale), got, err, amount)
+			}
+		}
+	}
+}
+
+func TestMoneyPercent(t *testing.T) {
+	tests := []struct {
+		amount  Money
+		percent float64
+		want    Money
+	}{
+		{25000, 10, 2500},
+		{25000, 2.5, 625},
+		{12345, 11, 1358},
+		{-12345, 11, -1358},
+		{99, 50, 50},
+	}
+	for _, tt := range tests {
+		if got := tt.amount.Percent(tt.percent); got != tt.want {
+			t.Errorf("Money(%d).Percent(%v) = %d, want %d", tt.amount, tt.percent, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package shared
+
+import "strconv"
+
+// Largest page a list action returns
+const MaxPageSize = 100
+
+// Page selects part of a list: Limit rows after skipping Offset. A zero
+// Limit selects the whole list, which is what callers that do not page get.
+type Page struct {
+	Limit  int
+	Offset int
+}
+
+// PageFromPayload reads the optional "limit" and "offset" of a list request
+func PageFromPayload(data map[string]interface{}) (Page, error) {
+	var page Page
+	if v, ok := data["limit"].(float64); ok {
+		if v < 0 || v > MaxPageSize || v != float64(int(v)) {
+			return page, NewKeyedInvalidInputError("error.page_limit", strconv.Itoa(MaxPageSize))
+		}
+		page.Limit = int(v)
+	}
+	if v, ok := data["offset"].(float64); ok {
+		if v < 0 || v != float64(int(v)) {
+			return page, NewKeyedInvalidInputError("error.page_offset")
+		}
+		page.Offset = int(v)
+	}
+	return page, nil
+}
+
+// Apply adds the LIMIT and OFFSET of p to a query
+func (p Page) Apply(query string, args []interface{}) (string, []interface{}) {
+	if p.Limit == 0 {
+		return query, args
+	}
+	return query + ` LIMIT ? OFFSET ?`, append(args, p.Limit, p.Offset)
+}
+
+// CountQuery returns a query counting the rows query selects, for the total
+// of a paged list
+func CountQuery(query string) string {
+	return `SELECT COUNT(*) FROM (` + query + `)`
+}
@@ -0,0 +1,149 @@
+package shared
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// RequestIDHeader carries the correlation ID of a bot update from the agent
+// through every service call it causes
+const RequestIDHeader = "X-Request-ID"
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+type requestIDKey struct{}
+
+// NewRequestID returns a random request ID
+func NewRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WithRequestID returns a context carrying the request ID, which is also
+// added to its logger and sent by HTTPClient requests made with it
+func WithRequestID(ctx context.Context, id string) context.Context {
+	ctx = context.WithValue(ctx, requestIDKey{}, id)
+	return WithLogFields(ctx, "request_id", id)
+}
+
+// RequestIDFromContext returns the request ID of ctx, if any
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// InstrumentRequests wraps a service handler. It takes the request ID from
+// the caller or creates one, adds it and the requested action to the request
+// context, echoes the ID in the response, and logs, counts and traces each
+// request with its duration and error code.
+func InstrumentRequests(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = NewRequestID()
+		}
+		ctx := WithRequestID(r.Context(), id)
+
+		// Peek at the action; the handler still reads the whole body
+		action := ""
+		if r.Body != nil {
+			body, err := io.ReadAll(r.Body)
+			r.Body.Close()
+			if err == nil {
+				var req struct {
+					Action string `json:"action"`
+				}
+				if json.Unmarshal(body, &req) == nil {
+					action = req.Action
+				}
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		if action != "" {
+			ctx = WithLogFields(ctx, "action", action)
+		}
+
+		// Continue the caller's trace
+		ctx, span := StartSpan(ExtractTrace(ctx, r.Header.Get(TraceparentHeader)), "handle", SpanKindServer)
+		defer span.Finish()
+
+		w.Header().Set(RequestIDHeader, id)
+		recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(recorder, r.WithContext(ctx))
+		duration := time.Since(start)
+
+		// Unknown actions share one label so callers cannot grow the metrics
+		label := action
+		errInfo := recorder.errorInfo()
+		if label == "" || errInfo != nil && errInfo.Message == "Unknown action" {
+			label = "unknown"
+		}
+		requestsTotal.Inc(label)
+		requestDuration.Observe(duration.Seconds(), label)
+
+		span.Name = "handle " + label
+		span.SetAttributes("rpc.action", label, "request_id", id, "http.status_code", recorder.status)
+
+		logger := Logger(ctx)
+		if errInfo != nil {
+			requestErrors.Inc(label, errInfo.Code)
+			span.SetAttributes("error.code", errInfo.Code)
+			if errInfo.Code == ErrCodeDatabaseError || errInfo.Code == ErrCodeInternalError || errInfo.Code == ErrCodeServiceError {
+				span.SetError(errInfo.Message)
+			}
+			logger = logger.With("error_code", errInfo.Code)
+		}
+		logger.Info("request handled",
+			"method", r.Method,
+			"status", recorder.status,
+			"duration_ms", duration.Milliseconds())
+	}
+}
+
+// Error responses are small; larger bodies are not kept
+const maxRecordedBody = 4 << 10
+
+// responseRecorder remembers the status code and the start of the body
+// written by a handler
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   []byte
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	if len(r.body) <= maxRecordedBody {
+		r.body = append(r.body, p[:min(len(p), maxRecordedBody+1-len(r.body))]...)
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+// errorInfo returns the error of a recorded error response
+func (r *responseRecorder) errorInfo() *ErrorInfo {
+	if len(r.body) > maxRecordedBody {
+		return nil
+	}
+	var response Response
+	if json.Unmarshal(r.body, &response) != nil || response.Success {
+		return nil
+	}
+	return response.Error
+}
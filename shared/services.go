@@ -0,0 +1,42 @@
+package shared
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Service is a backend service the agent and other services call
+type Service struct {
+	Name string
+	Port string
+}
+
+// Services lists every backend service with its default port
+var Services = []Service{
+	{"auth-service", "8081"},
+	{"menu-service", "8082"},
+	{"promo-service", "8083"},
+	{"info-service", "8084"},
+	{"media-service", "8085"},
+	{"order-service", "8086"},
+	{"report-service", "8087"},
+	{"backup-service", "8088"},
+	{"reservation-service", "8089"},
+	{"display-service", "8090"},
+}
+
+// ServiceURL returns the URL of a service, e.g. "menu-service", from
+// MENU_SERVICE_URL or else http://localhost with its default port
+func ServiceURL(name string) string {
+	env := strings.ToUpper(strings.ReplaceAll(name, "-", "_")) + "_URL"
+	if url := os.Getenv(env); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	for _, service := range Services {
+		if service.Name == name {
+			return fmt.Sprintf("http://localhost:%s", service.Port)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,353 @@
+package shared
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Traces follow one Telegram update through the agent and every service it
+// calls. Trace context travels in the W3C traceparent header. Finished spans
+// are exported in the OTLP/JSON format, selected with TRACE_EXPORTER:
+//
+//	none  spans are not exported (default); trace IDs are still propagated
+//	file  appended to TRACE_FILE, one OTLP/JSON request per line
+//	otlp  posted to TRACE_OTLP_ENDPOINT, an OTLP/HTTP collector such as Jaeger
+
+// TraceparentHeader carries the trace context of a call to another service
+const TraceparentHeader = "traceparent"
+
+// SpanKind tells what a span covers
+type SpanKind int
+
+// Span kinds, numbered as in OTLP
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+const (
+	exportBatchSize     = 256
+	exportInterval      = 2 * time.Second
+	exportQueueCapacity = 4096
+)
+
+// Span is a timed operation within a trace
+type Span struct {
+	TraceID    string
+	SpanID     string
+	ParentID   string
+	Name       string
+	Kind       SpanKind
+	Start      time.Time
+	End        time.Time
+	Attributes map[string]interface{}
+	Failed     bool
+	Message    string
+
+	mu    sync.Mutex
+	ended bool
+}
+
+type spanKey struct{}
+
+// remoteParent is a span of another process, read from a traceparent header
+type remoteParent struct {
+	traceID string
+	spanID  string
+}
+
+var (
+	tracingService = "bot-cafe"
+	exporter       *spanExporter
+)
+
+// ConfigureTracing sets the service name spans are exported under and starts
+// the exporter chosen with TRACE_EXPORTER. Call it after loading the .env file.
+func ConfigureTracing(service string) {
+	tracingService = service
+
+	var write func(payload []byte) error
+	switch kind := strings.ToLower(strings.TrimSpace(os.Getenv("TRACE_EXPORTER"))); kind {
+	case "", "none":
+		return
+	case "file":
+		path := os.Getenv("TRACE_FILE")
+		if path == "" {
+			path = "./traces.jsonl"
+		}
+		write = fileWriter(path)
+		Logger(context.Background()).Info("exporting traces", "file", path)
+	case "otlp":
+		endpoint := os.Getenv("TRACE_OTLP_ENDPOINT")
+		if endpoint == "" {
+			endpoint = "http://localhost:4318/v1/traces"
+		}
+		write = otlpWriter(endpoint)
+		Logger(context.Background()).Info("exporting traces", "endpoint", endpoint)
+	default:
+		Logger(context.Background()).Warn("unknown TRACE_EXPORTER, traces are not exported", "exporter", kind)
+		return
+	}
+
+	exporter = &spanExporter{queue: make(chan *Span, exportQueueCapacity), write: write}
+	go exporter.run()
+}
+
+// StartSpan starts a span as a child of the span in ctx, or as the root of a
+// new trace, and returns a context carrying it. Finish the span when done.
+func StartSpan(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	span := &Span{
+		SpanID:     randomHex(8),
+		Name:       name,
+		Kind:       kind,
+		Start:      time.Now(),
+		Attributes: map[string]interface{}{},
+	}
+
+	switch parent := ctx.Value(spanKey{}).(type) {
+	case *Span:
+		span.TraceID, span.ParentID = parent.TraceID, parent.SpanID
+	case remoteParent:
+		span.TraceID, span.ParentID = parent.traceID, parent.spanID
+	default:
+		span.TraceID = randomHex(16)
+	}
+
+	ctx = context.WithValue(ctx, spanKey{}, span)
+	if span.ParentID == "" || kind == SpanKindServer {
+		ctx = WithLogFields(ctx, "trace_id", span.TraceID)
+	}
+	return ctx, span
+}
+
+// SpanFromContext returns the span in ctx, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ExtractTrace returns a context continuing the trace of a traceparent
+// header; an invalid or missing header leaves ctx unchanged
+func ExtractTrace(ctx context.Context, traceparent string) context.Context {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 || !isHex(parts[1]) || !isHex(parts[2]) ||
+		parts[1] == strings.Repeat("0", 32) || parts[2] == strings.Repeat("0", 16) {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, remoteParent{traceID: parts[1], spanID: parts[2]})
+}
+
+// Traceparent returns the traceparent header for calls made within span
+func (s *Span) Traceparent() string {
+	return fmt.Sprintf("00-%s-%s-01", s.TraceID, s.SpanID)
+}
+
+// SetAttributes sets key-value pairs on the span
+func (s *Span) SetAttributes(args ...interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i+1 < len(args); i += 2 {
+		if key, ok := args[i].(string); ok {
+			s.Attributes[key] = args[i+1]
+		}
+	}
+}
+
+// SetError marks the span as failed
+func (s *Span) SetError(message string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Failed = true
+	s.Message = message
+}
+
+// Finish ends the span and queues it for export. Later calls do nothing.
+func (s *Span) Finish() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.End = time.Now()
+	s.mu.Unlock()
+
+	if exporter != nil {
+		exporter.enqueue(s)
+	}
+}
+
+// spanExporter batches finished spans and writes them in the background
+type spanExporter struct {
+	queue   chan *Span
+	write   func(payload []byte) error
+	dropped int
+	mu      sync.Mutex
+}
+
+func (e *spanExporter) enqueue(span *Span) {
+	select {
+	case e.queue <- span:
+	default:
+		// Never slow requests down for tracing; count what is lost instead
+		e.mu.Lock()
+		e.dropped++
+		e.mu.Unlock()
+	}
+}
+
+func (e *spanExporter) run() {
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+
+	var batch []*Span
+	flush := func() {
+		e.mu.Lock()
+		dropped := e.dropped
+		e.dropped = 0
+		e.mu.Unlock()
+		if dropped > 0 {
+			Logger(context.Background()).Warn("dropped spans, the export queue was full", "count", dropped)
+		}
+		if len(batch) == 0 {
+			return
+		}
+		payload, err := json.Marshal(otlpRequest(batch))
+		if err == nil {
+			err = e.write(payload)
+		}
+		if err != nil {
+			Logger(context.Background()).Error("failed to export spans", "count", len(batch), "error", err)
+		}
+		batch = nil
+	}
+
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) >= exportBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// fileWriter appends each batch as one line to the file at path
+func fileWriter(path string) func(payload []byte) error {
+	return func(payload []byte) error {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = f.Write(append(payload, '\n'))
+		return err
+	}
+}
+
+// otlpWriter posts each batch to an OTLP/HTTP collector
+func otlpWriter(endpoint string) func(payload []byte) error {
+	client := &http.Client{Timeout: 10 * time.Second}
+	return func(payload []byte) error {
+		resp, err := client.Post(endpoint, "application/json", bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			return fmt.Errorf("collector answered %s", resp.Status)
+		}
+		return nil
+	}
+}
+
+// otlpRequest builds an OTLP/JSON ExportTraceServiceRequest
+func otlpRequest(spans []*Span) map[string]interface{} {
+	otlpSpans := make([]map[string]interface{}, 0, len(spans))
+	for _, span := range spans {
+		span.mu.Lock()
+		otlpSpan := map[string]interface{}{
+			"traceId":           span.TraceID,
+			"spanId":            span.SpanID,
+			"name":              span.Name,
+			"kind":              int(span.Kind),
+			"startTimeUnixNano": strconv.FormatInt(span.Start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(span.End.UnixNano(), 10),
+			"attributes":        otlpAttributes(span.Attributes),
+			"status":            map[string]interface{}{"code": 1},
+		}
+		if span.ParentID != "" {
+			otlpSpan["parentSpanId"] = span.ParentID
+		}
+		if span.Failed {
+			otlpSpan["status"] = map[string]interface{}{"code": 2, "message": Redact(span.Message)}
+		}
+		span.mu.Unlock()
+		otlpSpans = append(otlpSpans, otlpSpan)
+	}
+
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{
+			map[string]interface{}{
+				"resource": map[string]interface{}{
+					"attributes": otlpAttributes(map[string]interface{}{"service.name": tracingService}),
+				},
+				"scopeSpans": []interface{}{
+					map[string]interface{}{
+						"scope": map[string]interface{}{"name": "github.com/alrescha79-cmd/bot-cafe/shared"},
+						"spans": otlpSpans,
+					},
+				},
+			},
+		},
+	}
+}
+
+func otlpAttributes(attributes map[string]interface{}) []interface{} {
+	result := make([]interface{}, 0, len(attributes))
+	for _, key := range sortedKeys(attributes) {
+		var value map[string]interface{}
+		switch v := attributes[key].(type) {
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": Redact(fmt.Sprint(v))}
+		}
+		result = append(result, map[string]interface{}{"key": key, "value": value})
+	}
+	return result
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
@@ -2,7 +2,6 @@ package shared
 
 import (
 	"regexp"
-	"strconv"
 	"strings"
 )
 
@@ -17,37 +16,23 @@ func SanitizeInput(input string) string {
 	return strings.TrimSpace(result)
 }
 
-// ValidatePrice validates price is a positive number
-func ValidatePrice(price interface{}) (int, error) {
-	switch v := price.(type) {
-	case int:
-		if v < 0 {
-			return 0, NewInvalidInputError("Harga harus positif")
-		}
-		return v, nil
-	case float64:
-		if v < 0 {
-			return 0, NewInvalidInputError("Harga harus positif")
-		}
-		return int(v), nil
-	case string:
-		p, err := strconv.Atoi(v)
-		if err != nil {
-			return 0, NewInvalidInputError("Harga harus berupa angka")
-		}
-		if p < 0 {
-			return 0, NewInvalidInputError("Harga harus positif")
-		}
-		return p, nil
-	default:
-		return 0, NewInvalidInputError("Format harga tidak valid")
+// ValidatePrice reads a price from a payload (see MoneyFromPayload) and
+// checks it is not negative
+func ValidatePrice(price interface{}) (Money, error) {
+	amount, err := MoneyFromPayload(price)
+	if err != nil {
+		return 0, err
 	}
+	if amount < 0 {
+		return 0, NewKeyedInvalidInputError("error.price_negative")
+	}
+	return amount, nil
 }
 
 // ValidateNotEmpty validates string is not empty
 func ValidateNotEmpty(value, fieldName string) error {
 	if strings.TrimSpace(value) == "" {
-		return NewInvalidInputError(fieldName + " tidak boleh kosong")
+		return NewKeyedInvalidInputError("error.required", fieldName)
 	}
 	return nil
 }
@@ -61,16 +46,11 @@ func ValidatePhotoURL(url string) error {
 	pattern := `^https?://.*\.(jpg|jpeg|png|gif|webp)$`
 	matched, err := regexp.MatchString(pattern, strings.ToLower(url))
 	if err != nil || !matched {
-		return NewInvalidInputError("URL foto tidak valid")
+		return NewKeyedInvalidInputError("error.photo_url_invalid")
 	}
 	return nil
 }
 
-// FormatPrice formats price with Rp currency
-func FormatPrice(price int) string {
-	return "Rp " + strconv.Itoa(price)
-}
-
 // Contains checks if slice contains value
 func Contains(slice []string, value string) bool {
 	for _, v := range slice {